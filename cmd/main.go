@@ -1,314 +1,1043 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"net/http"
-	"os"
-	"os/signal"
-	"strconv"
-	"syscall"
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-contrib/gzip"
-	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/vhvplatform/go-api-gateway/internal/cache"
-	"github.com/vhvplatform/go-api-gateway/internal/circuitbreaker"
-	"github.com/vhvplatform/go-api-gateway/internal/client"
-	"github.com/vhvplatform/go-api-gateway/internal/handler"
-	"github.com/vhvplatform/go-api-gateway/internal/health"
-	internalmiddleware "github.com/vhvplatform/go-api-gateway/internal/middleware"
-	"github.com/vhvplatform/go-api-gateway/internal/router"
-	"github.com/vhvplatform/go-api-gateway/internal/tracing"
-	"github.com/vhvplatform/go-shared/config"
-	"github.com/vhvplatform/go-shared/logger"
-	pkgmiddleware "github.com/vhvplatform/go-shared/middleware"
-	"go.uber.org/zap"
-)
-
-func main() {
-	// Initialize logger
-	log := logger.NewLogger()
-	defer log.Sync()
-
-	log.Info("Starting API Gateway...")
-
-	// Create main context for graceful shutdown
-	_, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatal("Failed to load configuration", zap.Error(err))
-	}
-
-	// Initialize distributed tracing (optional)
-	if os.Getenv("ENABLE_TRACING") == "true" {
-		jaegerURL := getServiceURL("JAEGER_URL", "http://jaeger:14268/api/traces")
-		tp, err := tracing.InitTracer("api-gateway", jaegerURL)
-		if err != nil {
-			log.Error("Failed to initialize tracer", zap.Error(err))
-		} else {
-			defer func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer cancel()
-				if err := tp.Shutdown(ctx); err != nil {
-					log.Error("Failed to shutdown tracer", zap.Error(err))
-				}
-			}()
-			log.Info("Distributed tracing enabled")
-		}
-	}
-
-	// Initialize Local Cache (Ristretto)
-	var cacheClient *cache.Cache
-	// Default 100MB
-	maxCacheCost := int64(100 * 1024 * 1024)
-	if cost := os.Getenv("CACHE_MAX_COST"); cost != "" {
-		if parsedCost, err := strconv.ParseInt(cost, 10, 64); err == nil {
-			maxCacheCost = parsedCost
-		}
-	}
-
-	cacheClient, err = cache.NewCache(maxCacheCost, maxCacheCost*10)
-	if err != nil {
-		log.Error("Failed to initialize cache", zap.Error(err))
-	} else {
-		defer cacheClient.Close()
-		log.Info("Local cache initialized", zap.Int64("max_cost", maxCacheCost))
-	}
-
-	// Initialize circuit breaker
-	_ = circuitbreaker.NewCircuitBreaker()
-	// TODO: Use circuit breaker in handlers when making external calls
-
-	// Initialize health checker
-	healthChecker := health.NewHealthChecker()
-	// Register health checks for services
-	healthChecker.RegisterCheck("auth-service", func(ctx context.Context) error {
-		// TODO: Implement actual health check when proto is available
-		return nil
-	})
-	healthChecker.RegisterCheck("user-service", func(ctx context.Context) error {
-		// TODO: Implement actual health check when proto is available
-		return nil
-	})
-	healthChecker.RegisterCheck("tenant-service", func(ctx context.Context) error {
-		// TODO: Implement actual health check when proto is available
-		return nil
-	})
-
-	// Initialize mTLS config
-	tlsConfig := &client.TLSConfig{
-		Enabled:        os.Getenv("MTLS_ENABLED") == "true",
-		CACertFile:     getServiceURL("MTLS_CA_CERT", "certs/ca.crt"),
-		ClientCertFile: getServiceURL("MTLS_CLIENT_CERT", "certs/client.crt"),
-		ClientKeyFile:  getServiceURL("MTLS_CLIENT_KEY", "certs/client.key"),
-		ServerName:     os.Getenv("MTLS_SERVER_NAME"),
-	}
-
-	// Initialize gRPC clients
-	authClient := client.NewAuthClient(getServiceURL("AUTH_SERVICE_URL", "auth-service:50051"), log, tlsConfig)
-	userClient := client.NewUserClient(getServiceURL("USER_SERVICE_URL", "user-service:50052"), log, tlsConfig)
-	tenantClient := client.NewTenantClient(getServiceURL("TENANT_SERVICE_URL", "tenant-service:50053"), log, tlsConfig)
-
-	// Initialize HTTP client for notification service
-	notificationURL := getServiceURL("NOTIFICATION_SERVICE_URL", "http://notification-service:8084")
-
-	// Initialize handlers
-	// Initialize Handlers
-	authHandler := handler.NewAuthHandler(authClient, log)
-	userHandler := handler.NewUserHandler(userClient, log)
-	tenantHandler := handler.NewTenantHandler(tenantClient, log)
-	notificationHandler := handler.NewNotificationHandler(notificationURL, log)
-	proxyHandler := handler.NewProxyHandler(log)
-
-	// Setup Gin router
-	gin.SetMode(gin.ReleaseMode)
-	r := gin.New()
-
-	// Recovery middleware with custom error handling
-	r.Use(pkgmiddleware.Recovery(log))
-
-	// Correlation ID middleware (should be first)
-	r.Use(pkgmiddleware.CorrelationID())
-
-	// Logging middleware
-	r.Use(pkgmiddleware.Logger(log))
-
-	// Metrics middleware (if enabled)
-	if os.Getenv("ENABLE_METRICS") != "false" {
-		r.Use(pkgmiddleware.DefaultMetrics("api_gateway"))
-	}
-
-	// Compression middleware
-	r.Use(gzip.Gzip(gzip.DefaultCompression))
-
-	// Request validation middleware
-	r.Use(pkgmiddleware.RequestValidation())
-
-	// Request size limit middleware
-	maxRequestSize := int64(10485760) // 10MB default
-	if size := os.Getenv("MAX_REQUEST_SIZE"); size != "" {
-		if parsedSize, err := strconv.ParseInt(size, 10, 64); err == nil {
-			maxRequestSize = parsedSize
-		}
-	}
-	r.Use(pkgmiddleware.RequestSizeLimit(maxRequestSize))
-
-	// Timeout middleware
-	r.Use(pkgmiddleware.Timeout(30 * time.Second))
-
-	// CORS configuration
-	corsConfig := cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Correlation-ID", "X-Tenant-ID"},
-		ExposeHeaders:    []string{"Content-Length", "X-Correlation-ID"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}
-	r.Use(cors.New(corsConfig))
-
-	// Rate limiting middleware
-	rateLimit := 100.0
-	rateBurst := 200
-	if rps := os.Getenv("RATE_LIMIT_RPS"); rps != "" {
-		if parsedRPS, err := strconv.ParseFloat(rps, 64); err == nil {
-			rateLimit = parsedRPS
-		}
-	}
-	if burst := os.Getenv("RATE_LIMIT_BURST"); burst != "" {
-		if parsedBurst, err := strconv.Atoi(burst); err == nil {
-			rateBurst = parsedBurst
-		}
-	}
-	r.Use(pkgmiddleware.PerIP(rateLimit, rateBurst))
-
-	// Health check endpoints
-	r.GET("/health", func(c *gin.Context) {
-		status := healthChecker.CheckAll(c.Request.Context())
-		if status.Status == "healthy" {
-			c.JSON(http.StatusOK, status)
-		} else {
-			c.JSON(http.StatusServiceUnavailable, status)
-		}
-	})
-	r.GET("/ready", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ready"})
-	})
-
-	// Metrics endpoint (Prometheus)
-	if os.Getenv("ENABLE_METRICS") != "false" {
-		r.GET("/metrics", gin.WrapH(promhttp.Handler()))
-	}
-
-	// Initialize permission middleware
-	permConfig := &internalmiddleware.PermissionConfig{
-		AuthClient: authClient,
-		Cache:      cacheClient,
-		Logger:     log,
-		CacheTTL:   5 * time.Minute,
-		SkipPaths:  []string{"/health", "/ready", "/metrics", "/auth/login", "/auth/register"},
-	}
-	permMiddleware := internalmiddleware.NewPermissionMiddleware(permConfig)
-
-	// Setup main routes
-	router.SetupRoutes(r, cfg, authClient, cacheClient, proxyHandler, authHandler, userHandler, tenantHandler, notificationHandler, log)
-
-	// Setup permission example routes (for testing/demonstration)
-	// Note: These routes use custom middleware that wraps existing AuthMiddleware
-	if os.Getenv("ENABLE_PERMISSION_EXAMPLES") == "true" {
-		// Example routes group with auth + permission middleware
-		examples := r.Group("/api/v2")
-		examples.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
-		{
-			// User routes with permissions
-			users := examples.Group("/users")
-			users.GET("", permMiddleware.RequirePermission("user.read"), func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{"message": "List users", "data": []string{"user1", "user2"}})
-			})
-			users.POST("", permMiddleware.RequirePermission("user.write", "user.create"), func(c *gin.Context) {
-				c.JSON(http.StatusCreated, gin.H{"message": "User created"})
-			})
-			users.DELETE("/:id", permMiddleware.RequirePermission("user.delete"), func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
-			})
-
-			// Admin routes
-			admin := examples.Group("/admin")
-			admin.GET("/dashboard", permMiddleware.RequireAnyPermission("admin.dashboard", "super_admin.*"), func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{"message": "Admin dashboard"})
-			})
-			admin.GET("/system", permMiddleware.RequireRole("super_admin"), func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{"message": "System config"})
-			})
-		}
-
-		log.Info("Permission example routes enabled at /api/v2/*")
-	}
-
-	// Start HTTP server
-	port := os.Getenv("API_GATEWAY_PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", port),
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	// Start server in goroutine
-	go func() {
-		log.Info("API Gateway started", zap.String("port", port))
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server", zap.Error(err))
-		}
-	}()
-
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Info("Shutting down API Gateway...")
-
-	// Cancel context to stop background goroutines
-	cancel()
-
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
-
-	// Shutdown HTTP server
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Error("Server forced to shutdown", zap.Error(err))
-	}
-
-	// Close gRPC connections
-	if err := authClient.Close(); err != nil {
-		log.Error("Failed to close auth client", zap.Error(err))
-	}
-	if err := userClient.Close(); err != nil {
-		log.Error("Failed to close user client", zap.Error(err))
-	}
-	if err := tenantClient.Close(); err != nil {
-		log.Error("Failed to close tenant client", zap.Error(err))
-	}
-
-	log.Info("API Gateway stopped")
-}
-
-func getServiceURL(envVar, defaultValue string) string {
-	url := os.Getenv(envVar)
-	if url == "" {
-		return defaultValue
-	}
-	return url
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"github.com/vhvplatform/go-api-gateway/internal/aggregate"
+	"github.com/vhvplatform/go-api-gateway/internal/async"
+	"github.com/vhvplatform/go-api-gateway/internal/batch"
+	"github.com/vhvplatform/go-api-gateway/internal/cache"
+	"github.com/vhvplatform/go-api-gateway/internal/canary"
+	"github.com/vhvplatform/go-api-gateway/internal/cidr"
+	"github.com/vhvplatform/go-api-gateway/internal/circuitbreaker"
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	"github.com/vhvplatform/go-api-gateway/internal/cors"
+	"github.com/vhvplatform/go-api-gateway/internal/fairqueue"
+	"github.com/vhvplatform/go-api-gateway/internal/forwarded"
+	"github.com/vhvplatform/go-api-gateway/internal/graphql"
+	"github.com/vhvplatform/go-api-gateway/internal/grpcproxy"
+	"github.com/vhvplatform/go-api-gateway/internal/grpcweb"
+	"github.com/vhvplatform/go-api-gateway/internal/handler"
+	"github.com/vhvplatform/go-api-gateway/internal/health"
+	"github.com/vhvplatform/go-api-gateway/internal/metering"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	internalmiddleware "github.com/vhvplatform/go-api-gateway/internal/middleware"
+	"github.com/vhvplatform/go-api-gateway/internal/mirror"
+	"github.com/vhvplatform/go-api-gateway/internal/proxyproto"
+	"github.com/vhvplatform/go-api-gateway/internal/registry"
+	"github.com/vhvplatform/go-api-gateway/internal/respcache"
+	"github.com/vhvplatform/go-api-gateway/internal/router"
+	"github.com/vhvplatform/go-api-gateway/internal/stream"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"github.com/vhvplatform/go-api-gateway/internal/tracing"
+	"github.com/vhvplatform/go-api-gateway/internal/transcode"
+	"github.com/vhvplatform/go-api-gateway/internal/transform"
+	"github.com/vhvplatform/go-api-gateway/internal/websocket"
+	sharedcache "github.com/vhvplatform/go-shared/cache"
+	"github.com/vhvplatform/go-shared/config"
+	"github.com/vhvplatform/go-shared/logger"
+	pkgmiddleware "github.com/vhvplatform/go-shared/middleware"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+func main() {
+	// Initialize logger
+	log := logger.NewLogger()
+	defer log.Sync()
+
+	log.Info("Starting API Gateway...")
+
+	// Create main context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	// Initialize distributed tracing (optional)
+	if os.Getenv("ENABLE_TRACING") == "true" {
+		jaegerURL := getServiceURL("JAEGER_URL", "http://jaeger:14268/api/traces")
+		tp, err := tracing.InitTracer("api-gateway", jaegerURL)
+		if err != nil {
+			log.Error("Failed to initialize tracer", zap.Error(err))
+		} else {
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := tp.Shutdown(ctx); err != nil {
+					log.Error("Failed to shutdown tracer", zap.Error(err))
+				}
+			}()
+			log.Info("Distributed tracing enabled")
+		}
+	}
+
+	// Initialize Local Cache (Ristretto), optionally backed by Redis (L2)
+	var cacheClient sharedcache.Cache
+	var inspectableCache handler.InspectableCache
+	// Default 100MB
+	maxCacheCost := int64(100 * 1024 * 1024)
+	if cost := os.Getenv("CACHE_MAX_COST"); cost != "" {
+		if parsedCost, err := strconv.ParseInt(cost, 10, 64); err == nil {
+			maxCacheCost = parsedCost
+		}
+	}
+
+	localCache, err := cache.NewCache(maxCacheCost, maxCacheCost*10)
+	if err != nil {
+		log.Error("Failed to initialize cache", zap.Error(err))
+	} else {
+		cacheClient = localCache
+		inspectableCache = localCache
+		metrics.RegisterCacheCollector("local", localCache)
+		log.Info("Local cache initialized", zap.Int64("max_cost", maxCacheCost))
+
+		if os.Getenv("CACHE_REDIS_ENABLED") == "true" {
+			tieredCache, err := newTieredCache(localCache)
+			if err != nil {
+				log.Error("Failed to initialize Redis L2 cache, using local cache only", zap.Error(err))
+			} else {
+				cacheClient = tieredCache
+				inspectableCache = tieredCache
+				log.Info("Two-tier cache enabled (L1 Ristretto + L2 Redis)")
+			}
+		}
+		defer cacheClient.Close()
+	}
+
+	// Initialize circuit breaker
+	_ = circuitbreaker.NewCircuitBreaker()
+	// TODO: Use circuit breaker in handlers when making external calls
+
+	// Initialize health checker
+	healthChecker := health.NewHealthChecker()
+	// Register health checks for services
+	healthChecker.RegisterCheck("auth-service", func(ctx context.Context) error {
+		// TODO: Implement actual health check when proto is available
+		return nil
+	})
+	healthChecker.RegisterCheck("user-service", func(ctx context.Context) error {
+		// TODO: Implement actual health check when proto is available
+		return nil
+	})
+	healthChecker.RegisterCheck("tenant-service", func(ctx context.Context) error {
+		// TODO: Implement actual health check when proto is available
+		return nil
+	})
+
+	// Initialize mTLS config
+	tlsConfig := &client.TLSConfig{
+		Enabled:        os.Getenv("MTLS_ENABLED") == "true",
+		CACertFile:     getServiceURL("MTLS_CA_CERT", "certs/ca.crt"),
+		ClientCertFile: getServiceURL("MTLS_CLIENT_CERT", "certs/client.crt"),
+		ClientKeyFile:  getServiceURL("MTLS_CLIENT_KEY", "certs/client.key"),
+		ServerName:     os.Getenv("MTLS_SERVER_NAME"),
+	}
+
+	// Initialize gRPC clients
+	authClient := client.NewAuthClient(getServiceURL("AUTH_SERVICE_URL", "auth-service:50051"), log, tlsConfig)
+	userClient := client.NewUserClient(getServiceURL("USER_SERVICE_URL", "user-service:50052"), log, tlsConfig)
+	tenantClient := client.NewTenantClient(getServiceURL("TENANT_SERVICE_URL", "tenant-service:50053"), log, tlsConfig)
+	// Tenant lookups use a JSON codec stub until the tenant protos are generated
+	tenantJSONCodec := os.Getenv("TENANT_SERVICE_JSON_CODEC") == "true"
+	if tenantJSONCodec {
+		tenantClient.EnableJSONCodec()
+	}
+
+	// gRPC services called on behalf of clients, on the connections above or their own
+	grpcBackends, err := newGRPCBackends(authClient, userClient, tenantClient, tlsConfig, log)
+	if err != nil {
+		log.Fatal("Failed to connect to gRPC backends", zap.Error(err))
+	}
+
+	// Initialize HTTP client for notification service
+	notificationURL := getServiceURL("NOTIFICATION_SERVICE_URL", "http://notification-service:8084")
+
+	// Initialize handlers
+	// Initialize Handlers
+	authHandler := handler.NewAuthHandler(authClient, log)
+	userHandler := handler.NewUserHandler(userClient, log)
+	tenantHandler := handler.NewTenantHandler(tenantClient, log)
+	notificationHandler := handler.NewNotificationHandler(notificationURL, log)
+	serviceRegistry, err := newServiceRegistry()
+	if err != nil {
+		log.Fatal("Failed to load service registry", zap.Error(err))
+	}
+	// Forwarding headers and client IPs are only trusted from these proxies
+	trustedProxies := parseList(os.Getenv("TRUSTED_PROXIES"))
+	forwarder, err := forwarded.New(trustedProxies)
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
+	proxyConfig := handler.ProxyConfig{Registry: serviceRegistry, Forwarded: forwarder}
+	if os.Getenv("CANARY_ENABLED") == "true" {
+		splitter, err := newCanarySplitter()
+		if err != nil {
+			log.Fatal("Failed to initialize canary splits", zap.Error(err))
+		}
+		proxyConfig.Canary = splitter
+		log.Info("Canary traffic splitting enabled")
+	}
+	webSockets := newWebSocketManager()
+	proxyConfig.WebSockets = webSockets
+	// Upgrades under these prefixes are exempt from request timeouts
+	webSocketRoutes := websocket.NewRoutes(parseList(os.Getenv("WEBSOCKET_ROUTES")))
+	// Requests under these prefixes stream their responses
+	streamRoutes := stream.NewRoutes(parseList(os.Getenv("STREAMING_ROUTES")))
+	proxyConfig.Streams = streamRoutes
+	proxyHandler := handler.NewProxyHandler(proxyConfig, log)
+
+	// Setup Gin router
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	// ClientIP() (used by rate limiting) only honors X-Forwarded-For from trusted proxies;
+	// with none configured it uses the connection's address
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
+
+	// Recovery middleware with custom error handling
+	r.Use(pkgmiddleware.Recovery(log))
+
+	// Correlation ID middleware (should be first)
+	r.Use(pkgmiddleware.CorrelationID())
+
+	// Logging middleware
+	r.Use(pkgmiddleware.Logger(log))
+
+	// Metrics middleware (if enabled)
+	if os.Getenv("ENABLE_METRICS") != "false" {
+		r.Use(pkgmiddleware.DefaultMetrics("api_gateway"))
+	}
+
+	// Compression middleware (WebSockets and streams are never compressed)
+	r.Use(internalmiddleware.SkipLongLived(streamRoutes, webSocketRoutes, gzip.Gzip(gzip.DefaultCompression)))
+
+	// Request validation middleware
+	r.Use(pkgmiddleware.RequestValidation())
+
+	// Request size limit middleware
+	maxRequestSize := int64(10485760) // 10MB default
+	if size := os.Getenv("MAX_REQUEST_SIZE"); size != "" {
+		if parsedSize, err := strconv.ParseInt(size, 10, 64); err == nil {
+			maxRequestSize = parsedSize
+		}
+	}
+	r.Use(pkgmiddleware.RequestSizeLimit(maxRequestSize))
+
+	// Timeout middleware (WebSockets use their own idle timeout; streams end when either side closes)
+	r.Use(internalmiddleware.SkipLongLived(streamRoutes, webSocketRoutes, pkgmiddleware.Timeout(30*time.Second)))
+
+	// Tenant resolution from host, path prefix or header
+	var tenantResolver *tenant.Resolver
+	if os.Getenv("TENANT_RESOLUTION_ENABLED") == "true" {
+		if !tenantJSONCodec {
+			log.Fatal("TENANT_RESOLUTION_ENABLED needs TENANT_SERVICE_JSON_CODEC=true: tenant lookups only have the JSON codec stub until the tenant protos are generated")
+		}
+		tenantResolver = tenant.NewResolver(internalmiddleware.NewTenantSource(tenantClient), newTenantResolverConfig(cacheClient))
+		r.Use(internalmiddleware.TenantResolutionMiddleware(tenantResolver, log))
+		log.Info("Tenant resolution enabled")
+	}
+
+	// CORS (after tenant resolution, which supplies tenant custom-domain origins)
+	grpcWebEnabled := os.Getenv("GRPC_WEB_ENABLED") == "true"
+	allowedHeaders := parseList(getServiceURL("CORS_ALLOWED_HEADERS", "Origin,Content-Type,Accept,Authorization,X-Correlation-ID,X-Tenant-ID"))
+	exposedHeaders := parseList(getServiceURL("CORS_EXPOSED_HEADERS", "Content-Length,X-Correlation-ID"))
+	if grpcWebEnabled {
+		// browsers must be able to send the gRPC-Web headers and read the call status
+		allowedHeaders = append(allowedHeaders, grpcweb.RequestHeaders...)
+		exposedHeaders = append(exposedHeaders, grpcweb.ResponseHeaders...)
+	}
+	corsPolicy, err := cors.New(cors.Config{
+		AllowedOrigins:   parseList(getServiceURL("CORS_ALLOWED_ORIGINS", "*")),
+		AllowedMethods:   parseList(getServiceURL("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE,OPTIONS")),
+		AllowedHeaders:   allowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") != "false",
+		MaxAge:           time.Duration(getEnvInt("CORS_MAX_AGE", 43200)) * time.Second,
+	})
+	if err != nil {
+		log.Fatal("Invalid CORS configuration", zap.Error(err))
+	}
+	r.Use(internalmiddleware.CORSMiddleware(corsPolicy))
+	if tenantResolver != nil {
+		// unknown and inadmissible tenants are refused after CORS, so
+		// browsers can read the error
+		r.Use(internalmiddleware.TenantAdmissionMiddleware())
+	}
+
+	// Rate limiting middleware
+	rateLimit := 100.0
+	rateBurst := 200
+	if rps := os.Getenv("RATE_LIMIT_RPS"); rps != "" {
+		if parsedRPS, err := strconv.ParseFloat(rps, 64); err == nil {
+			rateLimit = parsedRPS
+		}
+	}
+	if burst := os.Getenv("RATE_LIMIT_BURST"); burst != "" {
+		if parsedBurst, err := strconv.Atoi(burst); err == nil {
+			rateBurst = parsedBurst
+		}
+	}
+	r.Use(pkgmiddleware.PerIP(rateLimit, rateBurst))
+
+	// Health check endpoints
+	r.GET("/health", func(c *gin.Context) {
+		status := healthChecker.CheckAll(c.Request.Context())
+		if status.Status == "healthy" {
+			c.JSON(http.StatusOK, status)
+		} else {
+			c.JSON(http.StatusServiceUnavailable, status)
+		}
+	})
+	r.GET("/ready", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+
+	// Metrics endpoint (Prometheus)
+	if os.Getenv("ENABLE_METRICS") != "false" {
+		r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
+
+	// Initialize permission middleware
+	permConfig := &internalmiddleware.PermissionConfig{
+		AuthClient: authClient,
+		Cache:      cacheClient,
+		Logger:     log,
+		CacheTTL:   5 * time.Minute,
+		SkipPaths:  []string{"/health", "/ready", "/metrics", "/auth/login", "/auth/register"},
+	}
+	permMiddleware := internalmiddleware.NewPermissionMiddleware(permConfig)
+
+	// Authenticated requests naming no tenant are admitted and routed as
+	// their token's tenant; follows the auth middleware
+	var tokenTenant []gin.HandlerFunc
+	if tenantResolver != nil {
+		tokenTenant = append(tokenTenant, internalmiddleware.TokenTenantMiddleware(tenantResolver, log))
+	}
+
+	// Middleware applied in front of every proxied route
+	proxyMiddleware := append([]gin.HandlerFunc(nil), tokenTenant...)
+
+	// Tenant path prefixes (e.g. /t/acme/...) are stripped before routing
+	var serverHandler http.Handler = r
+	if prefix := os.Getenv("TENANT_PATH_PREFIX"); prefix != "" && os.Getenv("TENANT_RESOLUTION_ENABLED") == "true" {
+		serverHandler = tenant.StripPathPrefix(prefix, r)
+	}
+
+	// Requests to gateway routes made on a client's behalf, routed like the
+	// client's own
+	dispatcher := subrequest.New(serverHandler)
+
+	// Background requests for clients preferring respond-async; first, so the
+	// background request goes through the rest of the chain
+	var asyncManager *async.Manager
+	if os.Getenv("ASYNC_ENABLED") == "true" {
+		// the local cache may drop writes, so without Redis jobs are kept in
+		// memory and must be polled on the instance that accepted them
+		var store async.Store
+		if tieredCache, ok := cacheClient.(*cache.TieredCache); ok {
+			store = tieredCache
+		} else {
+			store = async.NewMemoryStore()
+			log.Warn("Async jobs are kept in memory; enable the Redis cache to poll them on any instance")
+		}
+		asyncManager = newAsyncManager(dispatcher, store)
+		path := getServiceURL("ASYNC_PATH", "/async/jobs")
+		proxyMiddleware = append(proxyMiddleware, internalmiddleware.AsyncMiddleware(asyncManager, path, log))
+		asyncHandler := handler.NewAsyncHandler(asyncManager, path)
+		jobs := r.Group(path)
+		jobs.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
+		jobs.Use(tokenTenant...)
+		jobs.GET("/:id", asyncHandler.Status)
+		jobs.GET("/:id/result", asyncHandler.Result)
+		log.Info("Async requests enabled", zap.String("path", path))
+	}
+
+	// Admin routes (authenticated, each route checks its own permission)
+	admin := r.Group("/admin")
+	admin.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
+
+	// Cache inspection and purging
+	if inspectableCache != nil {
+		cacheHandler := handler.NewCacheHandler(inspectableCache, log)
+		admin.GET("/cache/stats", permMiddleware.RequirePermission("cache.read"), cacheHandler.Stats)
+		admin.GET("/cache/keys", permMiddleware.RequirePermission("cache.read"), cacheHandler.Keys)
+		admin.DELETE("/cache/keys", permMiddleware.RequirePermission("cache.purge"), cacheHandler.Purge)
+	}
+
+	// Usage metering for billing
+	meteringDone := make(chan struct{})
+	meteringCtx, stopMetering := context.WithCancel(context.Background())
+	if os.Getenv("METERING_ENABLED") == "true" {
+		meteringStore, err := metering.NewFileStore(getServiceURL("METERING_DIR", "data/metering"))
+		if err != nil {
+			log.Fatal("Failed to initialize metering store", zap.Error(err))
+		}
+		meter := metering.NewMeter(meteringStore, getEnvDuration("METERING_WINDOW", time.Minute))
+		go func() {
+			defer close(meteringDone)
+			meter.Run(meteringCtx, getEnvDuration("METERING_FLUSH_INTERVAL", time.Minute), func(err error) {
+				log.Error("Failed to flush usage records", zap.Error(err))
+			})
+		}()
+
+		proxyMiddleware = append(proxyMiddleware, internalmiddleware.MeteringMiddleware(meter))
+		meteringHandler := handler.NewMeteringHandler(meter, log)
+		admin.GET("/metering/usage", permMiddleware.RequirePermission("billing.read"), meteringHandler.Export)
+		log.Info("Usage metering enabled")
+	} else {
+		close(meteringDone)
+	}
+
+	// Response caching for proxied GET requests; cache hits never reach the fair queue
+	if os.Getenv("RESPONSE_CACHE_ENABLED") == "true" && cacheClient != nil {
+		responseCache, err := newResponseCache(cacheClient)
+		if err != nil {
+			log.Fatal("Failed to initialize response cache", zap.Error(err))
+		}
+		proxyMiddleware = append(proxyMiddleware, internalmiddleware.SkipLongLived(streamRoutes, webSocketRoutes, internalmiddleware.ResponseCacheMiddleware(responseCache, proxyHandler.CanaryVersion)))
+		responseCacheHandler := handler.NewResponseCacheHandler(responseCache, log)
+		admin.POST("/cache/responses/purge", permMiddleware.RequirePermission("cache.purge"), responseCacheHandler.PurgeTags)
+		log.Info("Response cache enabled")
+	}
+
+	// Priority classes and weighted fair queuing between tenants
+	if os.Getenv("FAIR_QUEUE_ENABLED") == "true" {
+		fairQueue, classifier, err := newFairQueue()
+		if err != nil {
+			log.Fatal("Failed to initialize fair queue", zap.Error(err))
+		}
+		proxyMiddleware = append(proxyMiddleware, internalmiddleware.SkipLongLived(streamRoutes, webSocketRoutes, internalmiddleware.FairQueueMiddleware(fairQueue, classifier, log)))
+		log.Info("Fair queue enabled", zap.String("default_class", fairQueue.DefaultClass()))
+	}
+
+	// Traffic mirroring to shadow upstreams; last, so only requests that reach the upstream are mirrored
+	if os.Getenv("MIRROR_ENABLED") == "true" {
+		shadow, err := newMirror(log)
+		if err != nil {
+			log.Fatal("Failed to initialize traffic mirroring", zap.Error(err))
+		}
+		proxyMiddleware = append(proxyMiddleware, internalmiddleware.SkipLongLived(streamRoutes, webSocketRoutes, internalmiddleware.MirrorMiddleware(shadow)))
+		log.Info("Traffic mirroring enabled")
+	}
+
+	// Setup main routes
+	router.SetupRoutes(r, cfg, authClient, cacheClient, proxyHandler, authHandler, userHandler, tenantHandler, notificationHandler, log, proxyMiddleware...)
+
+	// REST endpoints transcoded to gRPC methods
+	if os.Getenv("TRANSCODE_ENABLED") == "true" {
+		transcoder, err := newTranscoder()
+		if err != nil {
+			log.Fatal("Failed to initialize gRPC transcoding", zap.Error(err))
+		}
+		prefix := getServiceURL("TRANSCODE_PREFIX", "/rpc")
+		rpc := r.Group(prefix)
+		rpc.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
+		rpc.Use(tokenTenant...)
+		rpc.Any("/*path", handler.NewTranscodeHandler(transcoder, grpcBackends, prefix, log).Handle)
+		log.Info("gRPC transcoding enabled", zap.String("prefix", prefix))
+	}
+
+	// gRPC-Web calls from browsers, over the same gRPC connections
+	if grpcWebEnabled {
+		prefix := getServiceURL("GRPC_WEB_PREFIX", "/grpc")
+		grpcWeb := r.Group(prefix)
+		grpcWeb.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
+		grpcWeb.Use(tokenTenant...)
+		grpcWeb.POST("/*method", handler.NewGRPCWebHandler(grpcBackends, streamRoutes, prefix, log).Handle)
+		log.Info("gRPC-Web enabled", zap.String("prefix", prefix))
+	}
+
+	// Composite views, made of calls to other gateway routes on the client's behalf
+	if path := os.Getenv("AGGREGATES_FILE"); path != "" {
+		routes, err := aggregate.LoadFile(path)
+		if err != nil {
+			log.Fatal("Failed to load aggregate routes", zap.Error(err))
+		}
+		aggregator := aggregate.New(dispatcher)
+		for _, route := range routes {
+			r.Handle(route.Method, route.Path, handler.NewAggregateHandler(aggregator, route).Handle)
+		}
+		log.Info("Aggregate routes enabled", zap.Int("routes", len(routes)))
+	}
+
+	// Several requests in one round trip, each through the full pipeline
+	if os.Getenv("BATCH_ENABLED") == "true" {
+		runner := batch.New(dispatcher, batch.Config{
+			MaxItems:    getEnvInt("BATCH_MAX_REQUESTS", batch.DefaultMaxItems),
+			Concurrency: getEnvInt("BATCH_CONCURRENCY", batch.DefaultConcurrency),
+			Timeout:     getEnvDuration("BATCH_REQUEST_TIMEOUT", batch.DefaultTimeout),
+		})
+		path := getServiceURL("BATCH_PATH", "/batch")
+		r.POST(path, handler.NewBatchHandler(runner).Handle)
+		log.Info("Batch endpoint enabled", zap.String("path", path))
+	}
+
+	// A GraphQL endpoint over the user, tenant and notification services
+	if os.Getenv("GRAPHQL_ENABLED") == "true" {
+		graphqlHandler, err := newGraphQLHandler(dispatcher, tenantClient, permMiddleware, log)
+		if err != nil {
+			log.Fatal("Failed to initialize GraphQL", zap.Error(err))
+		}
+		prefix := getServiceURL("GRAPHQL_PATH", "/graphql")
+		gql := r.Group(prefix)
+		gql.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
+		gql.Use(tokenTenant...)
+		gql.GET("", graphqlHandler.Handle)
+		gql.POST("", graphqlHandler.Handle)
+		gql.GET("/schema", graphqlHandler.Schema)
+		log.Info("GraphQL enabled", zap.String("path", prefix))
+	}
+
+	// Native gRPC clients, proxied to the gRPC backends on their own listener
+	var grpcServer *grpc.Server
+	if os.Getenv("GRPC_PROXY_ENABLED") == "true" {
+		limiter := internalmiddleware.NewRateLimiter(rateLimit, rateBurst)
+		go limiter.CleanupLimiters(ctx)
+		authenticator := internalmiddleware.NewAuthenticator(authClient, cacheClient, cfg.JWT.Secret)
+		grpcServer, err = newGRPCProxy(grpcBackends, authenticator, tenantResolver, limiter)
+		if err != nil {
+			log.Fatal("Failed to initialize gRPC proxy", zap.Error(err))
+		}
+		grpcAddr := ":" + getServiceURL("GRPC_PROXY_PORT", "9090")
+		grpcListener, err := newListener(grpcAddr)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC", zap.Error(err))
+		}
+		go func() {
+			log.Info("gRPC proxy started", zap.String("addr", grpcAddr))
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Fatal("Failed to start gRPC proxy", zap.Error(err))
+			}
+		}()
+	}
+
+	// Setup permission example routes (for testing/demonstration)
+	// Note: These routes use custom middleware that wraps existing AuthMiddleware
+	if os.Getenv("ENABLE_PERMISSION_EXAMPLES") == "true" {
+		// Example routes group with auth + permission middleware
+		examples := r.Group("/api/v2")
+		examples.Use(internalmiddleware.AuthMiddleware(authClient, cacheClient, cfg.JWT.Secret))
+		{
+			// User routes with permissions
+			users := examples.Group("/users")
+			users.GET("", permMiddleware.RequirePermission("user.read"), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "List users", "data": []string{"user1", "user2"}})
+			})
+			users.POST("", permMiddleware.RequirePermission("user.write", "user.create"), func(c *gin.Context) {
+				c.JSON(http.StatusCreated, gin.H{"message": "User created"})
+			})
+			users.DELETE("/:id", permMiddleware.RequirePermission("user.delete"), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+			})
+
+			// Admin routes
+			admin := examples.Group("/admin")
+			admin.GET("/dashboard", permMiddleware.RequireAnyPermission("admin.dashboard", "super_admin.*"), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "Admin dashboard"})
+			})
+			admin.GET("/system", permMiddleware.RequireRole("super_admin"), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "System config"})
+			})
+		}
+
+		log.Info("Permission example routes enabled at /api/v2/*")
+	}
+
+	// Start HTTP server
+	port := os.Getenv("API_GATEWAY_PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%s", port),
+		Handler:      serverHandler,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	listener, err := newListener(srv.Addr)
+	if err != nil {
+		log.Fatal("Failed to listen", zap.Error(err))
+	}
+
+	// Start server in goroutine
+	go func() {
+		log.Info("API Gateway started", zap.String("port", port))
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	// Graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info("Shutting down API Gateway...")
+
+	// Cancel context to stop background goroutines
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+
+	// Shutdown HTTP server
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error("Server forced to shutdown", zap.Error(err))
+	}
+
+	// Let proxied gRPC calls finish, cancelling those still running at the deadline
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			log.Error("gRPC proxy forced to shutdown")
+			grpcServer.Stop()
+		}
+	}
+
+	// Let background requests finish, failing those still running at the deadline
+	if asyncManager != nil {
+		if err := asyncManager.Shutdown(shutdownCtx); err != nil {
+			log.Error("Background requests forced to stop", zap.Error(err))
+		}
+	}
+
+	// Hijacked WebSocket connections are not tracked by the server
+	if err := webSockets.Shutdown(shutdownCtx); err != nil {
+		log.Error("WebSocket connections forced to close", zap.Error(err))
+	}
+
+	// Flush remaining usage records
+	stopMetering()
+	<-meteringDone
+
+	// Close gRPC connections
+	if err := authClient.Close(); err != nil {
+		log.Error("Failed to close auth client", zap.Error(err))
+	}
+	if err := userClient.Close(); err != nil {
+		log.Error("Failed to close user client", zap.Error(err))
+	}
+	if err := tenantClient.Close(); err != nil {
+		log.Error("Failed to close tenant client", zap.Error(err))
+	}
+	if err := grpcBackends.Close(); err != nil {
+		log.Error("Failed to close gRPC backends", zap.Error(err))
+	}
+
+	log.Info("API Gateway stopped")
+}
+
+func getServiceURL(envVar, defaultValue string) string {
+	url := os.Getenv(envVar)
+	if url == "" {
+		return defaultValue
+	}
+	return url
+}
+
+// newGRPCBackends maps gRPC services or packages to backend connections from
+// GRPC_BACKENDS ("package=auth|user|tenant|host:port,..."); auth, user and
+// tenant reuse the gateway's client connections
+func newGRPCBackends(authClient *client.AuthClient, userClient *client.UserClient, tenantClient *client.TenantClient, tlsConfig *client.TLSConfig, log *logger.Logger) (*client.Backends, error) {
+	backends := client.NewBackends()
+	existing := map[string]*grpc.ClientConn{
+		"auth":   authClient.Conn(),
+		"user":   userClient.Conn(),
+		"tenant": tenantClient.Conn(),
+	}
+
+	for service, target := range parseKeyValueList(getServiceURL("GRPC_BACKENDS", "auth.v1=auth,user.v1=user,tenant.v1=tenant")) {
+		if conn, ok := existing[target]; ok {
+			backends.Add(service, conn)
+			continue
+		}
+		if err := backends.Dial(service, target, log, tlsConfig); err != nil {
+			backends.Close()
+			return nil, fmt.Errorf("%s: %w", service, err)
+		}
+	}
+	return backends, nil
+}
+
+// newGRPCProxy builds the server for native gRPC clients, with the HTTP
+// path's rate limits, token verification and tenant resolution (when
+// resolver is set). It serves TLS with GRPC_PROXY_TLS_CERT and GRPC_PROXY_TLS_KEY
+// and cleartext HTTP/2 (h2c) otherwise.
+func newGRPCProxy(backends *client.Backends, authenticator *internalmiddleware.Authenticator, resolver *tenant.Resolver, limiter *internalmiddleware.RateLimiter) (*grpc.Server, error) {
+	verify := func(ctx context.Context, token string) (metadata.MD, error) {
+		resp, err := authenticator.Verify(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return metadata.Pairs("x-tenant-id", resp.TenantId, "x-internal-token", authenticator.InternalToken(resp)), nil
+	}
+
+	interceptors := []grpc.StreamServerInterceptor{grpcproxy.Metrics(backends), grpcproxy.RateLimit(limiter), grpcproxy.Auth(verify)}
+	if resolver != nil {
+		interceptors = append(interceptors, grpcproxy.Tenant(resolver))
+	}
+	opts := []grpc.ServerOption{grpc.ChainStreamInterceptor(interceptors...)}
+
+	certFile, keyFile := os.Getenv("GRPC_PROXY_TLS_CERT"), os.Getenv("GRPC_PROXY_TLS_KEY")
+	if certFile != "" || keyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRPC_PROXY_TLS_CERT/GRPC_PROXY_TLS_KEY: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	return grpcproxy.NewServer(backends, opts...), nil
+}
+
+// newTranscoder loads descriptor sets (TRANSCODE_DESCRIPTORS) and maps their
+// google.api.http annotations, plus rules from TRANSCODE_RULES_FILE
+func newTranscoder() (*transcode.Transcoder, error) {
+	files, err := transcode.LoadDescriptorSets(parseList(os.Getenv("TRANSCODE_DESCRIPTORS")))
+	if err != nil {
+		return nil, err
+	}
+	rules, err := transcode.AnnotatedRules(files)
+	if err != nil {
+		return nil, err
+	}
+	if path := os.Getenv("TRANSCODE_RULES_FILE"); path != "" {
+		fileRules, err := transcode.LoadRules(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	return transcode.New(files, rules)
+}
+
+// newAsyncManager configures background requests from environment variables
+func newAsyncManager(dispatcher *subrequest.Dispatcher, store async.Store) *async.Manager {
+	return async.New(dispatcher, store, async.Config{
+		Routes:         parseList(getServiceURL("ASYNC_ROUTES", "/api/")),
+		Timeout:        getEnvDuration("ASYNC_TIMEOUT", async.DefaultTimeout),
+		TTL:            getEnvDuration("ASYNC_RESULT_TTL", async.DefaultTTL),
+		MaxJobs:        getEnvInt("ASYNC_MAX_JOBS", async.DefaultMaxJobs),
+		MaxResultSize:  getEnvInt("ASYNC_MAX_RESULT_SIZE", async.DefaultMaxResultSize),
+		CallbackHosts:  parseList(os.Getenv("ASYNC_CALLBACK_HOSTS")),
+		CallbackSecret: os.Getenv("ASYNC_CALLBACK_SECRET"),
+	})
+}
+
+// newGraphQLHandler builds the GraphQL schema, limits and persisted queries
+// from environment variables
+func newGraphQLHandler(dispatcher *subrequest.Dispatcher, tenantClient *client.TenantClient, permissions *internalmiddleware.PermissionMiddleware, log *logger.Logger) (*handler.GraphQLHandler, error) {
+	sources := &handler.GraphQLSources{
+		Dispatcher:        dispatcher,
+		Tenants:           tenantClient,
+		UsersPath:         getServiceURL("GRAPHQL_USERS_PATH", "/api/user-service/users"),
+		NotificationsPath: getServiceURL("GRAPHQL_NOTIFICATIONS_PATH", "/api/notification-service/notifications"),
+	}
+	schema, err := handler.NewGraphQLSchema(sources)
+	if err != nil {
+		return nil, err
+	}
+	config := graphql.Config{
+		MaxDepth:      getEnvInt("GRAPHQL_MAX_DEPTH", 8),
+		MaxComplexity: getEnvInt("GRAPHQL_MAX_COMPLEXITY", 1000),
+	}
+
+	var persisted *graphql.PersistedQueries
+	only := os.Getenv("GRAPHQL_PERSISTED_ONLY") == "true"
+	path := os.Getenv("GRAPHQL_PERSISTED_QUERIES_FILE")
+	if os.Getenv("GRAPHQL_PERSISTED_QUERIES_ENABLED") != "false" || only {
+		store := graphql.NewMemoryStore(getEnvInt("GRAPHQL_PERSISTED_QUERIES_MAX", 1000))
+		if path != "" {
+			queries, err := graphql.LoadPersistedQueries(path)
+			if err != nil {
+				return nil, err
+			}
+			store.Preload(queries)
+		}
+		persisted = graphql.NewPersistedQueries(store, only)
+	}
+	return handler.NewGraphQLHandler(schema, sources, config, persisted, permissions.HasPermissions, log), nil
+}
+
+// newWebSocketManager configures limits for proxied WebSocket connections
+func newWebSocketManager() *websocket.Manager {
+	return websocket.NewManager(websocket.Config{
+		MaxPerTenant: getEnvInt("WEBSOCKET_MAX_CONNECTIONS_PER_TENANT", 1000),
+		IdleTimeout:  getEnvDuration("WEBSOCKET_IDLE_TIMEOUT", 5*time.Minute),
+	}, websocket.Events{
+		Opened: func(service string) {
+			metrics.WebSocketConnections.WithLabelValues(service).Inc()
+		},
+		Closed: func(service string, duration time.Duration) {
+			metrics.WebSocketConnections.WithLabelValues(service).Dec()
+			metrics.WebSocketConnectionDuration.WithLabelValues(service).Observe(duration.Seconds())
+		},
+		Message: func(service string, direction websocket.Direction, size int) {
+			metrics.WebSocketMessages.WithLabelValues(service, string(direction)).Inc()
+			metrics.WebSocketMessageBytes.WithLabelValues(service, string(direction)).Add(float64(size))
+		},
+	})
+}
+
+// newListener listens on addr, accepting PROXY protocol headers from trusted
+// TCP load balancers when PROXY_PROTOCOL_ENABLED is set
+func newListener(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if os.Getenv("PROXY_PROTOCOL_ENABLED") != "true" {
+		return listener, nil
+	}
+
+	trusted, err := cidr.ParseList(os.Getenv("PROXY_PROTOCOL_TRUSTED_CIDRS"))
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("invalid PROXY_PROTOCOL_TRUSTED_CIDRS: %w", err)
+	}
+	if len(trusted) == 0 {
+		listener.Close()
+		return nil, fmt.Errorf("PROXY_PROTOCOL_TRUSTED_CIDRS is required when PROXY_PROTOCOL_ENABLED=true")
+	}
+	return proxyproto.NewListener(listener, proxyproto.Config{
+		TrustedNetworks: trusted,
+		HeaderTimeout:   getEnvDuration("PROXY_PROTOCOL_HEADER_TIMEOUT", 5*time.Second),
+	}), nil
+}
+
+// newFairQueue builds the fair queue and request classifier from environment variables
+func newFairQueue() (*fairqueue.Queue, *fairqueue.Classifier, error) {
+	queueConfig := fairqueue.Config{
+		MaxConcurrent:     getEnvInt("FAIR_QUEUE_MAX_CONCURRENT", 512),
+		MaxQueuePerTenant: getEnvInt("FAIR_QUEUE_MAX_PER_TENANT", 100),
+		DefaultClass:      os.Getenv("FAIR_QUEUE_DEFAULT_CLASS"),
+	}
+	if spec := os.Getenv("FAIR_QUEUE_CLASSES"); spec != "" {
+		classes, err := fairqueue.ParseClasses(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		queueConfig.Classes = classes
+	}
+
+	queue, err := fairqueue.NewQueue(queueConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trusted, err := cidr.ParseList(os.Getenv("PRIORITY_TRUSTED_CIDRS"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid PRIORITY_TRUSTED_CIDRS: %w", err)
+	}
+
+	classifier := fairqueue.NewClassifier(fairqueue.ClassifierConfig{
+		RouteClasses:    parseKeyValueList(os.Getenv("PRIORITY_ROUTE_CLASSES")),
+		PlanClasses:     parseKeyValueList(os.Getenv("PRIORITY_PLAN_CLASSES")),
+		TrustedNetworks: trusted,
+		DefaultClass:    queue.DefaultClass(),
+	})
+
+	return queue, classifier, nil
+}
+
+// newServiceRegistry builds the upstream registry from SERVICE_REGISTRY_FILE and
+// SERVICE_UPSTREAMS; SERVICE_UPSTREAMS entries take precedence
+func newServiceRegistry() (*registry.Registry, error) {
+	upstreams, err := registry.ParseUpstreams(os.Getenv("SERVICE_UPSTREAMS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_UPSTREAMS: %w", err)
+	}
+
+	var routes map[string]*transform.Route
+	if path := os.Getenv("SERVICE_REGISTRY_FILE"); path != "" {
+		fileUpstreams, fileRoutes, err := registry.LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, fileUpstreams...)
+		routes = fileRoutes
+	}
+
+	return registry.New(upstreams, routes), nil
+}
+
+// newCanarySplitter builds the canary traffic splits from environment variables
+func newCanarySplitter() (*canary.Splitter, error) {
+	splits, err := canary.ParseSplits(
+		parseKeyValueList(os.Getenv("CANARY_SPLITS")),
+		parseKeyValueList(os.Getenv("CANARY_TENANTS")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return canary.New(canary.Config{
+		Splits: splits,
+		Header: getServiceURL("CANARY_HEADER", "X-Canary-Version"),
+		Cookie: getServiceURL("CANARY_COOKIE", "canary_version"),
+	})
+}
+
+// newMirror builds the traffic mirror from environment variables
+func newMirror(log *logger.Logger) (*mirror.Mirror, error) {
+	rules, err := mirror.ParseRules(parseKeyValueList(os.Getenv("MIRROR_RULES")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MIRROR_RULES: %w", err)
+	}
+
+	return mirror.New(mirror.Config{
+		Rules:         rules,
+		MaxBodySize:   int64(getEnvInt("MIRROR_MAX_BODY_SIZE", 1<<20)),
+		Timeout:       getEnvDuration("MIRROR_TIMEOUT", 10*time.Second),
+		MaxConcurrent: getEnvInt("MIRROR_MAX_CONCURRENT", 64),
+		Compare:       os.Getenv("MIRROR_COMPARE") == "true",
+	}, func(result mirror.Result) {
+		if result.Err != nil {
+			log.Debug("Mirrored request failed",
+				zap.String("target", result.Rule.Target),
+				zap.String("uri", result.Request.RequestURI),
+				zap.Error(result.Err))
+			return
+		}
+		if result.Diff != "" {
+			log.Warn("Shadow response differs from primary",
+				zap.String("target", result.Rule.Target),
+				zap.String("method", result.Request.Method),
+				zap.String("uri", result.Request.RequestURI),
+				zap.Int("primary_status", result.PrimaryStatus),
+				zap.Int("shadow_status", result.ShadowStatus),
+				zap.String("diff", result.Diff))
+		}
+	}), nil
+}
+
+// newResponseCache builds the HTTP response cache on top of the gateway cache
+func newResponseCache(store respcache.Store) (*respcache.Cache, error) {
+	rules, err := respcache.ParseRules(parseKeyValueList(os.Getenv("RESPONSE_CACHE_RULES")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESPONSE_CACHE_RULES: %w", err)
+	}
+
+	return respcache.New(store, respcache.Config{
+		Rules:       rules,
+		MaxTTL:      getEnvDuration("RESPONSE_CACHE_MAX_TTL", time.Hour),
+		StaleTTL:    getEnvDuration("RESPONSE_CACHE_STALE_TTL", 10*time.Minute),
+		MaxBodySize: int64(getEnvInt("RESPONSE_CACHE_MAX_BODY_SIZE", 1<<20)),
+	}), nil
+}
+
+// newTenantResolverConfig builds the tenant resolver configuration from environment variables
+func newTenantResolverConfig(store sharedcache.Cache) tenant.ResolverConfig {
+	return tenant.ResolverConfig{
+		BaseDomains:        parseList(os.Getenv("TENANT_BASE_DOMAINS")),
+		ReservedSubdomains: parseList(getServiceURL("TENANT_RESERVED_SUBDOMAINS", "www,api,admin")),
+		CustomDomains:      os.Getenv("TENANT_CUSTOM_DOMAINS") == "true",
+		Header:             getServiceURL("TENANT_HEADER", "X-Tenant-ID"),
+		Cache:              store,
+		CacheTTL:           getEnvDuration("TENANT_CACHE_TTL", 5*time.Minute),
+	}
+}
+
+// newTieredCache connects to Redis and builds the two-tier cache on top of the local cache
+func newTieredCache(localCache *cache.Cache) (*cache.TieredCache, error) {
+	opts, err := redis.ParseURL(getServiceURL("REDIS_URL", "redis://redis:6379/0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	if password := os.Getenv("REDIS_PASSWORD"); password != "" {
+		opts.Password = password
+	}
+	opts.PoolSize = getEnvInt("REDIS_POOL_SIZE", opts.PoolSize)
+	opts.MaxRetries = getEnvInt("REDIS_MAX_RETRIES", opts.MaxRetries)
+
+	redisClient := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	tieredCache, err := cache.NewTieredCache(ctx, localCache, redisClient, cache.TieredConfig{
+		KeyPrefix:  getServiceURL("CACHE_REDIS_KEY_PREFIX", "gateway"),
+		DefaultTTL: getEnvDuration("CACHE_REDIS_DEFAULT_TTL", 15*time.Minute),
+		L1TTL:      getEnvDuration("CACHE_LOCAL_TTL", time.Minute),
+	})
+	if err != nil {
+		_ = redisClient.Close()
+		return nil, err
+	}
+	return tieredCache, nil
+}
+
+func getEnvDuration(envVar string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(envVar); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(envVar string, defaultValue int) int {
+	if value := os.Getenv(envVar); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// parseList parses "a,b,c" into a slice, skipping empty items
+func parseList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// parseKeyValueList parses "key=value,key2=value2" into a map
+func parseKeyValueList(value string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			continue
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return result
+}
@@ -0,0 +1,214 @@
+// Package aggregate composes one response from several gateway calls. The
+// calls of a route run in parallel, except where one needs another's result,
+// each under its own timeout; failed calls leave error markers next to the
+// results of the others.
+package aggregate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// CallError marks a call without a result
+type CallError struct {
+	// Status is the call's HTTP status, or 504 on timeout and 424 when a call
+	// it depends on failed
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// Result is an aggregate response
+type Result struct {
+	// Data holds every call's result by name, null for failed calls
+	Data map[string]interface{} `json:"data"`
+	// Errors describes the failed calls
+	Errors map[string]*CallError `json:"errors,omitempty"`
+	// Partial is set when some calls failed
+	Partial bool `json:"partial,omitempty"`
+
+	failed bool
+}
+
+// Status is the HTTP status of the aggregate response: 200, including for
+// partial results, or 502 when a required call or every call failed
+func (r *Result) Status() int {
+	if r.failed || len(r.Errors) == len(r.Data) {
+		return http.StatusBadGateway
+	}
+	return http.StatusOK
+}
+
+// Outcome labels the result for metrics: complete, partial or failed
+func (r *Result) Outcome() string {
+	switch {
+	case r.Status() != http.StatusOK:
+		return "failed"
+	case r.Partial:
+		return "partial"
+	}
+	return "complete"
+}
+
+// Aggregator runs aggregate routes
+type Aggregator struct {
+	dispatcher *subrequest.Dispatcher
+}
+
+// New creates an Aggregator making calls with dispatcher
+func New(dispatcher *subrequest.Dispatcher) *Aggregator {
+	return &Aggregator{dispatcher: dispatcher}
+}
+
+// Run makes the route's calls for the client request r, whose route
+// parameters are params
+func (a *Aggregator) Run(r *http.Request, route *Route, params map[string]string) *Result {
+	result := &Result{
+		Data:   make(map[string]interface{}, len(route.Calls)),
+		Errors: map[string]*CallError{},
+	}
+	vals := &values{params: params, query: r.URL.Query(), results: map[string]interface{}{}}
+
+	var mu sync.Mutex
+	done := make(map[string]chan struct{}, len(route.Calls))
+	for _, call := range route.Calls {
+		done[call.Name] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for _, call := range route.Calls {
+		wg.Add(1)
+		go func(call *Call) {
+			defer wg.Done()
+			defer close(done[call.Name])
+
+			for _, dep := range call.deps {
+				<-done[dep]
+			}
+
+			mu.Lock()
+			var callErr *CallError
+			for _, dep := range call.deps {
+				if result.Errors[dep] != nil {
+					callErr = &CallError{Status: http.StatusFailedDependency, Error: fmt.Sprintf("depends on failed call %s", dep)}
+					break
+				}
+			}
+			var req subrequest.Request
+			if callErr == nil {
+				var err error
+				if req, err = call.request(vals); err != nil {
+					callErr = &CallError{Status: http.StatusBadRequest, Error: err.Error()}
+				}
+			}
+			mu.Unlock()
+
+			var data interface{}
+			if callErr == nil {
+				start := time.Now()
+				data, callErr = a.call(r, call, req)
+				status := http.StatusOK
+				if callErr != nil {
+					status = callErr.Status
+				}
+				metrics.AggregateCallDuration.WithLabelValues(route.Path, call.Name).Observe(time.Since(start).Seconds())
+				metrics.AggregateCalls.WithLabelValues(route.Path, call.Name, strconv.Itoa(status)).Inc()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Data[call.Name] = data
+			if callErr != nil {
+				result.Errors[call.Name] = callErr
+				result.Partial = true
+				if call.Required {
+					result.failed = true
+				}
+				return
+			}
+			vals.results[call.Name] = data
+		}(call)
+	}
+	wg.Wait()
+
+	if len(result.Errors) == 0 {
+		result.Errors = nil
+	}
+	return result
+}
+
+// request renders the call's templates
+func (c *Call) request(vals *values) (subrequest.Request, error) {
+	path, err := vals.expandPath(c.Path)
+	if err != nil {
+		return subrequest.Request{}, err
+	}
+	req := subrequest.Request{Method: c.Method, Path: path, Header: http.Header{}}
+	for name, template := range c.Headers {
+		value, err := vals.expand(template, func(s string) string { return s })
+		if err != nil {
+			return subrequest.Request{}, err
+		}
+		req.Header.Set(name, value)
+	}
+	if c.body != nil {
+		body, err := vals.expandBody(c.body)
+		if err != nil {
+			return subrequest.Request{}, err
+		}
+		if req.Body, err = json.Marshal(body); err != nil {
+			return subrequest.Request{}, err
+		}
+	}
+	return req, nil
+}
+
+// call makes one call and decodes its result: JSON bodies as values, other
+// bodies as strings
+func (a *Aggregator) call(r *http.Request, call *Call, req subrequest.Request) (interface{}, *CallError) {
+	ctx, cancel := context.WithTimeout(r.Context(), call.timeout)
+	defer cancel()
+
+	resp, err := a.dispatcher.Do(ctx, r, req)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, &CallError{Status: http.StatusGatewayTimeout, Error: fmt.Sprintf("timed out after %s", call.timeout)}
+	}
+	if err != nil {
+		return nil, &CallError{Status: http.StatusBadGateway, Error: err.Error()}
+	}
+
+	var data interface{}
+	if len(resp.Body) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(resp.Body))
+		// keeps large IDs exact when fed to other calls
+		dec.UseNumber()
+		if dec.Decode(&data) != nil {
+			data = string(resp.Body)
+		}
+	}
+	if resp.Status < 200 || resp.Status > 299 {
+		return nil, &CallError{Status: resp.Status, Error: errorMessage(resp.Status, data)}
+	}
+	return data, nil
+}
+
+// errorMessage takes the message of a failed call from its error body
+func errorMessage(status int, body interface{}) string {
+	if fields, ok := body.(map[string]interface{}); ok {
+		for _, key := range []string{"message", "error"} {
+			if msg, ok := fields[key].(string); ok && msg != "" {
+				return msg
+			}
+		}
+	}
+	return http.StatusText(status)
+}
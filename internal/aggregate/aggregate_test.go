@@ -0,0 +1,227 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// gateway stands in for the gateway's router
+func gateway(t *testing.T) (http.Handler, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 9007199254740993, "tenant_id": "t 1", "roles": ["admin"]}`))
+	})
+	mux.HandleFunc("/tenants/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "Acme", "path": "` + r.URL.EscapedPath() + `"}`))
+	})
+	mux.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"code": "INTERNAL", "message": "boom"}`))
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.RequestURI())
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+	return handler, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, seen...)
+	}
+}
+
+func compile(t *testing.T, route *Route) *Route {
+	t.Helper()
+	if err := route.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	return route
+}
+
+func TestRun(t *testing.T) {
+	handler, seen := gateway(t)
+	route := compile(t, &Route{Path: "/views/dashboard", Calls: []*Call{
+		{Name: "profile", Path: "/users/me"},
+		{Name: "tenant", Path: "/tenants/{profile.tenant_id}?user={profile.id}&q={query.q}"},
+		{Name: "audit", Method: "post", Path: "/audit", Body: json.RawMessage(`{"user": "{profile.id}", "role": "{profile.roles.0}", "view": "dash-{param.view}"}`)},
+		{Name: "text", Path: "/text"},
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "/views/dashboard?q=a%26b", nil)
+	result := New(subrequest.New(handler)).Run(req, route, map[string]string{"view": "main"})
+
+	if result.Status() != http.StatusOK || result.Partial || result.Errors != nil {
+		t.Fatalf("status %d partial %v errors %v", result.Status(), result.Partial, result.Errors)
+	}
+	tenant := result.Data["tenant"].(map[string]interface{})
+	if tenant["path"] != "/tenants/t%201" {
+		t.Errorf("tenant path = %v, want escaped tenant ID", tenant["path"])
+	}
+	if !contains(seen(), "/tenants/t%201?user=9007199254740993&q=a%26b") {
+		t.Errorf("requests = %v, want exact ID and escaped query", seen())
+	}
+	audit := result.Data["audit"].(map[string]interface{})
+	if audit["role"] != "admin" || audit["view"] != "dash-main" {
+		t.Errorf("audit body = %v", audit)
+	}
+	// a lone reference keeps its JSON type
+	if _, ok := audit["user"].(json.Number); !ok {
+		t.Errorf("audit user = %#v, want a number", audit["user"])
+	}
+	if result.Data["text"] != "plain" {
+		t.Errorf("text = %v", result.Data["text"])
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRun_Partial(t *testing.T) {
+	handler, seen := gateway(t)
+	route := compile(t, &Route{Path: "/views/dashboard", Timeout: "50ms", Calls: []*Call{
+		{Name: "profile", Path: "/users/me"},
+		{Name: "slow", Path: "/slow"},
+		{Name: "broken", Path: "/fail"},
+		{Name: "after", Path: "/tenants/x", DependsOn: []string{"broken"}},
+	}})
+
+	start := time.Now()
+	result := New(subrequest.New(handler)).Run(httptest.NewRequest(http.MethodGet, "/", nil), route, nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("took %v, calls were not timed out", elapsed)
+	}
+
+	if result.Status() != http.StatusOK || !result.Partial {
+		t.Fatalf("status %d partial %v", result.Status(), result.Partial)
+	}
+	if result.Data["profile"] == nil {
+		t.Error("profile missing")
+	}
+	want := map[string]int{"slow": http.StatusGatewayTimeout, "broken": http.StatusInternalServerError, "after": http.StatusFailedDependency}
+	for name, status := range want {
+		err := result.Errors[name]
+		if err == nil || err.Status != status {
+			t.Errorf("%s error = %+v, want status %d", name, err, status)
+		}
+		if value, ok := result.Data[name]; !ok || value != nil {
+			t.Errorf("%s data = %v, want null marker", name, value)
+		}
+	}
+	if result.Errors["broken"].Error != "boom" {
+		t.Errorf("broken error = %q, want upstream message", result.Errors["broken"].Error)
+	}
+	if contains(seen(), "/tenants/x") {
+		t.Error("call ran although its dependency failed")
+	}
+
+	data, _ := json.Marshal(result)
+	if !strings.Contains(string(data), `"after":null`) || !strings.Contains(string(data), `"partial":true`) {
+		t.Errorf("json = %s", data)
+	}
+}
+
+func TestRun_Required(t *testing.T) {
+	handler, _ := gateway(t)
+	route := compile(t, &Route{Path: "/v", Calls: []*Call{
+		{Name: "profile", Path: "/users/me"},
+		{Name: "broken", Path: "/fail", Required: true},
+	}})
+	result := New(subrequest.New(handler)).Run(httptest.NewRequest(http.MethodGet, "/", nil), route, nil)
+	if result.Status() != http.StatusBadGateway || result.Outcome() != "failed" {
+		t.Errorf("status %d outcome %s, want 502 failed", result.Status(), result.Outcome())
+	}
+}
+
+func TestRun_MissingValue(t *testing.T) {
+	handler, _ := gateway(t)
+	route := compile(t, &Route{Path: "/v", Calls: []*Call{
+		{Name: "profile", Path: "/users/me"},
+		{Name: "tenant", Path: "/tenants/{profile.missing}"},
+	}})
+	result := New(subrequest.New(handler)).Run(httptest.NewRequest(http.MethodGet, "/", nil), route, nil)
+	if err := result.Errors["tenant"]; err == nil || err.Status != http.StatusBadRequest {
+		t.Errorf("tenant error = %+v, want 400", err)
+	}
+	if result.Outcome() != "partial" {
+		t.Errorf("outcome = %s, want partial", result.Outcome())
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := map[string]*Route{
+		"no calls":       {Path: "/v"},
+		"relative path":  {Path: "v", Calls: []*Call{{Name: "a", Path: "/a"}}},
+		"duplicate":      {Path: "/v", Calls: []*Call{{Name: "a", Path: "/a"}, {Name: "a", Path: "/b"}}},
+		"reserved name":  {Path: "/v", Calls: []*Call{{Name: "query", Path: "/a"}}},
+		"unknown dep":    {Path: "/v", Calls: []*Call{{Name: "a", Path: "/a/{b.id}"}}},
+		"self":           {Path: "/v", Calls: []*Call{{Name: "a", Path: "/a", DependsOn: []string{"a"}}}},
+		"cycle":          {Path: "/v", Calls: []*Call{{Name: "a", Path: "/a/{b.id}"}, {Name: "b", Path: "/b/{a.id}"}}},
+		"bad timeout":    {Path: "/v", Timeout: "soon", Calls: []*Call{{Name: "a", Path: "/a"}}},
+		"bad body":       {Path: "/v", Calls: []*Call{{Name: "a", Path: "/a", Body: json.RawMessage(`{`)}}},
+		"call path":      {Path: "/v", Calls: []*Call{{Name: "a", Path: "a"}}},
+		"negative delay": {Path: "/v", Calls: []*Call{{Name: "a", Path: "/a", Timeout: "-1s"}}},
+	}
+	for name, route := range tests {
+		if err := route.Compile(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregates.json")
+	data := `{"aggregates": [{"path": "/views/dashboard", "timeout": "3s", "calls": [
+		{"name": "profile", "path": "/api/user-service/users/me"},
+		{"name": "tenant", "path": "/api/tenant-service/tenants/{profile.tenant_id}", "timeout": "1s"}]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].Method != http.MethodGet {
+		t.Fatalf("routes = %+v", routes)
+	}
+	calls := routes[0].Calls
+	if calls[0].timeout != 3*time.Second || calls[1].timeout != time.Second {
+		t.Errorf("timeouts = %v, %v", calls[0].timeout, calls[1].timeout)
+	}
+	if len(calls[1].deps) != 1 || calls[1].deps[0] != "profile" {
+		t.Errorf("tenant deps = %v, want [profile]", calls[1].deps)
+	}
+}
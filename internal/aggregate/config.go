@@ -0,0 +1,208 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultCallTimeout bounds calls of routes that set no timeout
+const DefaultCallTimeout = 10 * time.Second
+
+// Route is an endpoint whose response is composed from several calls
+type Route struct {
+	// Path is the gateway route, which may have parameters ("/views/users/:id")
+	Path string `json:"path"`
+	// Method defaults to GET
+	Method string `json:"method,omitempty"`
+	// Timeout bounds each call that sets none, e.g. "2s" (default 10s)
+	Timeout string  `json:"timeout,omitempty"`
+	Calls   []*Call `json:"calls"`
+
+	timeout time.Duration
+}
+
+// Call is one request of an aggregate. Its path, header values and body may
+// use templates: {param.<name>} and {query.<name>} from the client request,
+// and {<call>.<field>...} from another call's JSON result, which makes this
+// call wait for that one.
+type Call struct {
+	// Name is the call's key in the response
+	Name string `json:"name"`
+	// Method defaults to GET
+	Method string `json:"method,omitempty"`
+	// Path is a gateway path, e.g. "/api/tenant-service/tenants/{profile.tenant_id}"
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	// DependsOn lists calls that must succeed first, besides those referenced by templates
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Timeout overrides the route's timeout for this call
+	Timeout string `json:"timeout,omitempty"`
+	// Required calls fail the whole aggregate instead of returning partial results
+	Required bool `json:"required,omitempty"`
+
+	timeout time.Duration
+	body    interface{}
+	deps    []string
+}
+
+// file is the JSON aggregates file format
+type file struct {
+	Aggregates []*Route `json:"aggregates"`
+}
+
+// LoadFile reads aggregate routes from a JSON file:
+//
+//	{"aggregates": [{"path": "/views/dashboard", "timeout": "3s", "calls": [
+//	    {"name": "profile", "path": "/api/user-service/users/me"},
+//	    {"name": "tenant", "path": "/api/tenant-service/tenants/{profile.tenant_id}"},
+//	    {"name": "notifications", "path": "/api/notification-service/notifications?limit=10", "timeout": "1s"}]}]}
+func LoadFile(path string) ([]*Route, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid aggregates file %s: %w", path, err)
+	}
+	for _, route := range f.Aggregates {
+		if err := route.Compile(); err != nil {
+			return nil, fmt.Errorf("invalid aggregate %s: %w", route.Path, err)
+		}
+	}
+	return f.Aggregates, nil
+}
+
+// Compile validates the route and resolves its call dependencies; it must be
+// called before use
+func (r *Route) Compile() error {
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	r.Method = strings.ToUpper(r.Method)
+	if len(r.Calls) == 0 {
+		return fmt.Errorf("no calls")
+	}
+	var err error
+	if r.timeout, err = parseTimeout(r.Timeout, DefaultCallTimeout); err != nil {
+		return err
+	}
+
+	calls := make(map[string]*Call, len(r.Calls))
+	for _, call := range r.Calls {
+		if call.Name == "" || call.Name == "param" || call.Name == "query" || strings.ContainsAny(call.Name, ".{}") {
+			return fmt.Errorf("invalid call name %q", call.Name)
+		}
+		if calls[call.Name] != nil {
+			return fmt.Errorf("duplicate call %q", call.Name)
+		}
+		calls[call.Name] = call
+	}
+
+	for _, call := range r.Calls {
+		if err := call.compile(r.timeout, calls); err != nil {
+			return fmt.Errorf("call %s: %w", call.Name, err)
+		}
+	}
+	return checkCycles(r.Calls, calls)
+}
+
+func (c *Call) compile(timeout time.Duration, calls map[string]*Call) error {
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if c.Method == "" {
+		c.Method = http.MethodGet
+	}
+	c.Method = strings.ToUpper(c.Method)
+	var err error
+	if c.timeout, err = parseTimeout(c.Timeout, timeout); err != nil {
+		return err
+	}
+	if len(c.Body) > 0 {
+		if err := json.Unmarshal(c.Body, &c.body); err != nil {
+			return fmt.Errorf("invalid body: %w", err)
+		}
+	}
+
+	// dependencies: explicit ones plus every call a template reads
+	seen := map[string]bool{}
+	refs := append([]string{}, c.DependsOn...)
+	templates := []string{c.Path}
+	for _, value := range c.Headers {
+		templates = append(templates, value)
+	}
+	templates = append(templates, bodyStrings(c.body)...)
+	for _, template := range templates {
+		for _, ref := range references(template) {
+			root, _, _ := strings.Cut(ref, ".")
+			if root != "param" && root != "query" {
+				refs = append(refs, root)
+			}
+		}
+	}
+	for _, name := range refs {
+		if calls[name] == nil {
+			return fmt.Errorf("unknown call %q", name)
+		}
+		if name == c.Name {
+			return fmt.Errorf("call depends on itself")
+		}
+		if !seen[name] {
+			seen[name] = true
+			c.deps = append(c.deps, name)
+		}
+	}
+	return nil
+}
+
+// checkCycles rejects dependency cycles, which would never start
+func checkCycles(list []*Call, calls map[string]*Call) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through call %q", name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range calls[name].deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for _, call := range list {
+		if err := visit(call.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTimeout(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return d, nil
+}
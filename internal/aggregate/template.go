@@ -0,0 +1,188 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// references returns the {...} expressions of a template
+func references(template string) []string {
+	var refs []string
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			return refs
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return refs
+		}
+		refs = append(refs, template[start+1:start+end])
+		template = template[start+end+1:]
+	}
+}
+
+// bodyStrings returns the strings of a decoded JSON body, which may be templates
+func bodyStrings(body interface{}) []string {
+	switch v := body.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, bodyStrings(item)...)
+		}
+		return out
+	case map[string]interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, bodyStrings(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// values are what templates read: the client request's route parameters and
+// query, and the decoded results of finished calls
+type values struct {
+	params  map[string]string
+	query   url.Values
+	results map[string]interface{}
+}
+
+// lookup resolves a reference such as "param.id" or "profile.roles.0"
+func (v *values) lookup(ref string) (interface{}, bool) {
+	root, rest, _ := strings.Cut(ref, ".")
+	switch root {
+	case "param":
+		value, ok := v.params[rest]
+		return value, ok
+	case "query":
+		return v.query.Get(rest), v.query.Has(rest)
+	}
+
+	value, ok := v.results[root]
+	if !ok {
+		return nil, false
+	}
+	if rest == "" {
+		return value, true
+	}
+	for _, key := range strings.Split(rest, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			if value, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			value = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// expand renders a template, escaping each value with escape
+func (v *values) expand(template string, escape func(string) string) (string, error) {
+	if !strings.Contains(template, "{") {
+		return template, nil
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		ref := template[start+1 : start+end]
+		value, ok := v.lookup(ref)
+		if !ok {
+			return "", fmt.Errorf("no value for {%s}", ref)
+		}
+		b.WriteString(template[:start])
+		b.WriteString(escape(format(value)))
+		template = template[start+end+1:]
+	}
+	b.WriteString(template)
+	return b.String(), nil
+}
+
+// expandPath renders a path template, escaping values for the path or the
+// query string as appropriate
+func (v *values) expandPath(template string) (string, error) {
+	path, query, hasQuery := strings.Cut(template, "?")
+	path, err := v.expand(path, url.PathEscape)
+	if err != nil || !hasQuery {
+		return path, err
+	}
+	query, err = v.expand(query, url.QueryEscape)
+	if err != nil {
+		return "", err
+	}
+	return path + "?" + query, nil
+}
+
+// expandBody renders the templates in a decoded JSON body. A string that is a
+// single reference is replaced by the referenced value, keeping its JSON type.
+func (v *values) expandBody(body interface{}) (interface{}, error) {
+	switch node := body.(type) {
+	case string:
+		if refs := references(node); len(refs) == 1 && node == "{"+refs[0]+"}" {
+			value, ok := v.lookup(refs[0])
+			if !ok {
+				return nil, fmt.Errorf("no value for %s", node)
+			}
+			return value, nil
+		}
+		return v.expand(node, func(s string) string { return s })
+	case []interface{}:
+		out := make([]interface{}, len(node))
+		for i, item := range node {
+			value, err := v.expandBody(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for key, item := range node {
+			value, err := v.expandBody(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = value
+		}
+		return out, nil
+	}
+	return body, nil
+}
+
+// format renders a JSON value for a path, query or header
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,375 @@
+// Package async runs requests in the background for clients that send
+// "Prefer: respond-async" (RFC 7240). The client gets 202 Accepted with a
+// status URL at once; the request runs through the gateway under its own
+// deadline instead of the request timeout, and its response is kept for a
+// while for the client to poll, or is announced to a callback URL.
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+)
+
+// CallbackHeader carries the URL a job's status is posted to when it finishes
+const CallbackHeader = "X-Callback-URL"
+
+// Job states
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+const (
+	// DefaultTimeout is the default deadline of background requests
+	DefaultTimeout = 10 * time.Minute
+	// DefaultTTL is how long finished jobs are kept by default
+	DefaultTTL = time.Hour
+	// DefaultMaxJobs is how many jobs may run at once by default
+	DefaultMaxJobs = 100
+	// DefaultMaxResultSize is the largest response body kept by default
+	DefaultMaxResultSize = 10 << 20
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired jobs
+	ErrNotFound = errors.New("job not found")
+	// ErrTooManyJobs is returned when the configured number of jobs is running
+	ErrTooManyJobs = errors.New("too many jobs running")
+	// ErrCallbackNotAllowed is returned for callback URLs on hosts that are not allowed
+	ErrCallbackNotAllowed = errors.New("callback URL not allowed")
+)
+
+// transportHeaders are request headers not carried to the background request
+var transportHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Keep-Alive",
+	"Prefer",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	CallbackHeader,
+}
+
+type contextKey struct{}
+
+// Store keeps jobs and results: the Redis-backed gateway cache, so clients
+// may poll any gateway instance, or a MemoryStore. Writes must not be
+// dropped, which rules out the local cache alone.
+type Store interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// Job is a request running in the background
+type Job struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+	// ResultStatus is the HTTP status of the finished request's response
+	ResultStatus int    `json:"result_status,omitempty"`
+	Error        string `json:"error,omitempty"`
+	CallbackURL  string `json:"callback_url,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// Finished reports whether the job has a result
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+// Result is a finished job's response
+type Result struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// Owner is the client a job belongs to; only it may read the job
+type Owner struct {
+	UserID   string
+	TenantID string
+}
+
+// Config controls background requests
+type Config struct {
+	// Routes are the path prefixes that may run asynchronously
+	Routes []string
+	// Timeout is the background request's deadline (default 10m)
+	Timeout time.Duration
+	// TTL is how long finished jobs are kept (default 1h)
+	TTL time.Duration
+	// MaxJobs is how many jobs may run at once (default 100)
+	MaxJobs int
+	// MaxResultSize is the largest response body kept; larger responses
+	// fail the job (default 10MB)
+	MaxResultSize int
+	// CallbackHosts are the hosts callback URLs may point to; callbacks are
+	// refused when empty
+	CallbackHosts []string
+	// CallbackSecret signs callbacks with HMAC-SHA256 when set
+	CallbackSecret string
+}
+
+// Manager runs and tracks jobs
+type Manager struct {
+	dispatcher *subrequest.Dispatcher
+	store      Store
+	config     Config
+	callbacks  *callbacks
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a Manager running requests with dispatcher and keeping jobs in store
+func New(dispatcher *subrequest.Dispatcher, store Store, config Config) *Manager {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = DefaultMaxJobs
+	}
+	if config.MaxResultSize <= 0 {
+		config.MaxResultSize = DefaultMaxResultSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		dispatcher: dispatcher,
+		store:      store,
+		config:     config,
+		callbacks:  newCallbacks(config.CallbackHosts, config.CallbackSecret),
+		ctx:        ctx,
+		cancel:     cancel,
+		running:    make(chan struct{}, config.MaxJobs),
+	}
+}
+
+// IsJob reports whether r is a job's background request
+func IsJob(r *http.Request) bool {
+	return r.Context().Value(contextKey{}) != nil
+}
+
+// Accepts reports whether r asks to run asynchronously on a route that allows it
+func (m *Manager) Accepts(r *http.Request) bool {
+	if IsJob(r) || !prefersAsync(r.Header) {
+		return false
+	}
+	for _, prefix := range m.config.Routes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefersAsync reports whether the Prefer header includes respond-async
+func prefersAsync(header http.Header) bool {
+	for _, value := range header.Values("Prefer") {
+		for _, pref := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(pref), ";")
+			if strings.EqualFold(strings.TrimSpace(name), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Submit starts running r, whose body has been read, in the background
+func (m *Manager) Submit(r *http.Request, body []byte, owner Owner) (*Job, error) {
+	callbackURL := r.Header.Get(CallbackHeader)
+	if callbackURL != "" {
+		if err := m.callbacks.allowed(callbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	select {
+	case m.running <- struct{}{}:
+	default:
+		metrics.AsyncJobs.WithLabelValues("rejected").Inc()
+		return nil, ErrTooManyJobs
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:          uuid.NewString(),
+		Status:      StatusPending,
+		Method:      r.Method,
+		Path:        r.URL.RequestURI(),
+		UserID:      owner.UserID,
+		TenantID:    owner.TenantID,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(m.config.Timeout + m.config.TTL),
+	}
+	if err := m.save(m.ctx, job); err != nil {
+		<-m.running
+		return nil, fmt.Errorf("failed to store job: %w", err)
+	}
+
+	// the client's request ends once it has its 202; its tenant path stays
+	parent := r.Clone(tenant.InheritPath(context.Background(), r.Context()))
+	req := subrequest.Request{Method: r.Method, Path: job.Path, Header: r.Header.Clone(), Body: body}
+	for _, name := range transportHeaders {
+		req.Header.Del(name)
+	}
+
+	submitted := *job
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() { <-m.running }()
+		m.run(job, parent, req)
+	}()
+	return &submitted, nil
+}
+
+// run makes the job's request and stores its result
+func (m *Manager) run(job *Job, parent *http.Request, req subrequest.Request) {
+	metrics.AsyncJobsRunning.Inc()
+	defer metrics.AsyncJobsRunning.Dec()
+
+	started := time.Now().UTC()
+	job.Status = StatusRunning
+	job.StartedAt = &started
+	if err := m.save(m.ctx, job); err != nil {
+		// pollers see the job as pending until its result is stored
+		metrics.AsyncStoreErrors.Inc()
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(m.ctx, contextKey{}, true), m.config.Timeout)
+	defer cancel()
+	resp, err := m.dispatcher.Do(ctx, parent, req)
+
+	var result *Result
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result = failedResult(http.StatusGatewayTimeout, "request timed out")
+	case err != nil && m.ctx.Err() != nil:
+		result = failedResult(http.StatusServiceUnavailable, "gateway shutting down")
+	case err != nil:
+		result = failedResult(http.StatusBadGateway, err.Error())
+	case len(resp.Body) > m.config.MaxResultSize:
+		result = failedResult(http.StatusInsufficientStorage, fmt.Sprintf("response of %d bytes exceeds the %d byte limit", len(resp.Body), m.config.MaxResultSize))
+	default:
+		result = &Result{Status: resp.Status, Header: resp.Header, Body: resp.Body}
+		result.Header.Del("Set-Cookie")
+		job.Status = StatusCompleted
+	}
+	if job.Status != StatusCompleted {
+		job.Status = StatusFailed
+		job.Error = string(result.Body)
+	}
+
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.ResultStatus = result.Status
+	job.ExpiresAt = finished.Add(m.config.TTL)
+	metrics.AsyncJobs.WithLabelValues(job.Status).Inc()
+	metrics.AsyncJobDuration.Observe(finished.Sub(started).Seconds())
+
+	// stores may outlive the shutdown of the manager
+	storeCtx := context.Background()
+	if err := m.store.Set(storeCtx, resultKey(job.ID), result, m.config.TTL); err != nil {
+		metrics.AsyncStoreErrors.Inc()
+		job.Status = StatusFailed
+		job.Error = "failed to store result"
+	}
+	if err := m.save(storeCtx, job); err != nil {
+		// the job cannot be polled any more; a callback still reports it
+		metrics.AsyncStoreErrors.Inc()
+		job.Status = StatusFailed
+		job.Error = "failed to store job"
+	}
+
+	if job.CallbackURL != "" {
+		m.callbacks.send(m.ctx, job)
+	}
+}
+
+func failedResult(status int, msg string) *Result {
+	return &Result{
+		Status: status,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   []byte(fmt.Sprintf(`{"error":%q}`, msg)),
+	}
+}
+
+func (m *Manager) save(ctx context.Context, job *Job) error {
+	return m.store.Set(ctx, jobKey(job.ID), job, time.Until(job.ExpiresAt))
+}
+
+// Get returns a job of owner
+func (m *Manager) Get(ctx context.Context, id string, owner Owner) (*Job, error) {
+	var job Job
+	if err := m.store.Get(ctx, jobKey(id), &job); err != nil {
+		return nil, ErrNotFound
+	}
+	// other clients' jobs do not exist for them
+	if job.UserID != owner.UserID || job.TenantID != owner.TenantID {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+// Result returns a finished job's response
+func (m *Manager) Result(ctx context.Context, job *Job) (*Result, error) {
+	var result Result
+	if err := m.store.Get(ctx, resultKey(job.ID), &result); err != nil {
+		return nil, ErrNotFound
+	}
+	return &result, nil
+}
+
+// Shutdown waits for running jobs, cancelling those still running when ctx ends
+func (m *Manager) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		m.cancel()
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func jobKey(id string) string {
+	return "async:job:" + id
+}
+
+func resultKey(id string) string {
+	return "async:result:" + id
+}
@@ -0,0 +1,303 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// failingStore fails writes of keys starting with prefix
+type failingStore struct {
+	*MemoryStore
+	prefix string
+}
+
+func (s *failingStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if strings.HasPrefix(key, s.prefix) {
+		return errors.New("store unavailable")
+	}
+	return s.MemoryStore.Set(ctx, key, value, ttl)
+}
+
+// gateway stands in for the gateway's router
+func gateway() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/export/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Set-Cookie", "session=1")
+		w.Header().Set("X-Job", boolString(IsJob(r)))
+		w.Header().Set("X-Prefer", r.Header.Get("Prefer"))
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(body)))
+	})
+	mux.HandleFunc("/api/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/api/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	return mux
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func submit(t *testing.T, m *Manager, method, target, body string, header http.Header) *Job {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	job, err := m.Submit(r, []byte(body), Owner{UserID: "u1", TenantID: "t1"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	return job
+}
+
+// wait polls a job until it finishes
+func wait(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(context.Background(), id, Owner{UserID: "u1", TenantID: "t1"})
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if job.Finished() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestAccepts(t *testing.T) {
+	m := New(subrequest.New(gateway()), NewMemoryStore(), Config{Routes: []string{"/api/export/"}})
+	tests := []struct {
+		path   string
+		prefer string
+		want   bool
+	}{
+		{"/api/export/users", "respond-async", true},
+		{"/api/export/users", "return=minimal, Respond-Async; wait=10", true},
+		{"/api/export/users", "", false},
+		{"/api/export/users", "return=minimal", false},
+		{"/api/users", "respond-async", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		if tt.prefer != "" {
+			r.Header.Set("Prefer", tt.prefer)
+		}
+		if got := m.Accepts(r); got != tt.want {
+			t.Errorf("Accepts(%s, %q) = %v, want %v", tt.path, tt.prefer, got, tt.want)
+		}
+	}
+
+	// background requests never run asynchronously again
+	r := httptest.NewRequest(http.MethodPost, "/api/export/users", nil)
+	r.Header.Set("Prefer", "respond-async")
+	r = r.WithContext(context.WithValue(r.Context(), contextKey{}, true))
+	if m.Accepts(r) {
+		t.Error("Accepts(job) = true")
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	m := New(subrequest.New(gateway()), NewMemoryStore(), Config{Routes: []string{"/api/"}})
+	job := submit(t, m, http.MethodPost, "/api/export/users?format=csv", `{"all":true}`, http.Header{
+		"Prefer":        {"respond-async"},
+		"Authorization": {"Bearer token"},
+	})
+	if job.Status != StatusPending || job.Path != "/api/export/users?format=csv" || job.UserID != "u1" {
+		t.Errorf("submitted job = %+v", job)
+	}
+
+	done := wait(t, m, job.ID)
+	if done.Status != StatusCompleted || done.ResultStatus != http.StatusCreated || done.StartedAt == nil || done.FinishedAt == nil {
+		t.Errorf("finished job = %+v", done)
+	}
+	result, err := m.Result(context.Background(), done)
+	if err != nil {
+		t.Fatalf("Result: %v", err)
+	}
+	if string(result.Body) != `POST /api/export/users?format=csv {"all":true}` {
+		t.Errorf("body = %q", result.Body)
+	}
+	h := result.Header
+	if h.Get("X-Job") != "true" || h.Get("X-Prefer") != "" || h.Get("X-Auth") != "Bearer token" || h.Get("Set-Cookie") != "" || h.Get("Content-Type") != "text/csv" {
+		t.Errorf("headers = %v", h)
+	}
+
+	// other clients cannot see the job
+	if _, err := m.Get(context.Background(), job.ID, Owner{UserID: "u2", TenantID: "t1"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(other user) = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Get(context.Background(), "missing", Owner{UserID: "u1", TenantID: "t1"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSubmitFailures(t *testing.T) {
+	m := New(subrequest.New(gateway()), NewMemoryStore(), Config{Routes: []string{"/api/"}, Timeout: 20 * time.Millisecond, MaxResultSize: 50})
+
+	slow := wait(t, m, submit(t, m, http.MethodGet, "/api/slow", "", nil).ID)
+	if slow.Status != StatusFailed || slow.ResultStatus != http.StatusGatewayTimeout || !strings.Contains(slow.Error, "timed out") {
+		t.Errorf("slow job = %+v", slow)
+	}
+	result, err := m.Result(context.Background(), slow)
+	if err != nil || result.Status != http.StatusGatewayTimeout || result.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("slow result = %+v, %v", result, err)
+	}
+
+	big := wait(t, m, submit(t, m, http.MethodGet, "/api/big", "", nil).ID)
+	if big.Status != StatusFailed || big.ResultStatus != http.StatusInsufficientStorage {
+		t.Errorf("big job = %+v", big)
+	}
+}
+
+func TestSubmitStoreFailures(t *testing.T) {
+	// a result that cannot be stored fails the job rather than losing it
+	store := &failingStore{MemoryStore: NewMemoryStore(), prefix: "async:result:"}
+	m := New(subrequest.New(gateway()), store, Config{Routes: []string{"/api/"}})
+	job := wait(t, m, submit(t, m, http.MethodGet, "/api/export/users", "", nil).ID)
+	if job.Status != StatusFailed || job.Error != "failed to store result" {
+		t.Errorf("job = %+v", job)
+	}
+
+	// a job that cannot be stored is refused before it runs
+	m = New(subrequest.New(gateway()), &failingStore{MemoryStore: NewMemoryStore(), prefix: "async:job:"}, Config{Routes: []string{"/api/"}})
+	r := httptest.NewRequest(http.MethodGet, "/api/export/users", nil)
+	if _, err := m.Submit(r, nil, Owner{UserID: "u1"}); err == nil {
+		t.Error("Submit succeeded without storing the job")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.Set(ctx, "job", Job{ID: "1"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "old", Job{ID: "2"}, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	var job Job
+	if err := s.Get(ctx, "job", &job); err != nil || job.ID != "1" {
+		t.Errorf("Get(job) = %+v, %v", job, err)
+	}
+	if err := s.Get(ctx, "old", &job); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(expired) = %v, want ErrNotFound", err)
+	}
+	if err := s.Get(ctx, "missing", &job); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) = %v, want ErrNotFound", err)
+	}
+
+	// expired entries are swept on a later write
+	s.lastSweep = time.Now().Add(-sweepInterval)
+	s.Set(ctx, "new", Job{ID: "3"}, time.Hour)
+	if n := len(s.entries); n != 2 {
+		t.Errorf("Len after sweep = %d, want 2", n)
+	}
+}
+
+func TestSubmitLimitsJobs(t *testing.T) {
+	m := New(subrequest.New(gateway()), NewMemoryStore(), Config{Routes: []string{"/api/"}, MaxJobs: 1, Timeout: 50 * time.Millisecond})
+	first := submit(t, m, http.MethodGet, "/api/slow", "", nil)
+	r := httptest.NewRequest(http.MethodGet, "/api/slow", nil)
+	if _, err := m.Submit(r, nil, Owner{UserID: "u1"}); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("Submit = %v, want ErrTooManyJobs", err)
+	}
+	wait(t, m, first.ID)
+	if _, err := m.Submit(r, nil, Owner{UserID: "u1"}); err != nil {
+		t.Errorf("Submit after the first job finished = %v", err)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	var got *Job
+	var signature, body string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			// the first delivery fails and is retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		signature = r.Header.Get(SignatureHeader)
+		json.Unmarshal(data, &got)
+	}))
+	defer hook.Close()
+
+	m := New(subrequest.New(gateway()), NewMemoryStore(), Config{
+		Routes:         []string{"/api/"},
+		CallbackHosts:  []string{"127.0.0.1"},
+		CallbackSecret: "secret",
+	})
+	m.callbacks.backoff = time.Millisecond
+
+	job := submit(t, m, http.MethodPost, "/api/export/users", "", http.Header{CallbackHeader: {hook.URL + "/done"}})
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 || got == nil || got.ID != job.ID || got.Status != StatusCompleted {
+		t.Fatalf("callback calls = %d, job = %+v", calls, got)
+	}
+	if signature != Sign([]byte("secret"), []byte(body)) {
+		t.Errorf("signature = %q", signature)
+	}
+
+	for _, callback := range []string{"https://evil.example/hook", "/relative", "ftp://127.0.0.1/x"} {
+		r := httptest.NewRequest(http.MethodPost, "/api/export/users", nil)
+		r.Header.Set(CallbackHeader, callback)
+		if _, err := m.Submit(r, nil, Owner{UserID: "u1"}); !errors.Is(err, ErrCallbackNotAllowed) {
+			t.Errorf("Submit(callback %q) = %v, want ErrCallbackNotAllowed", callback, err)
+		}
+	}
+}
+
+func TestShutdownCancelsJobs(t *testing.T) {
+	m := New(subrequest.New(gateway()), NewMemoryStore(), Config{Routes: []string{"/api/"}})
+	job := submit(t, m, http.MethodGet, "/api/slow", "", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want deadline exceeded", err)
+	}
+	done := wait(t, m, job.ID)
+	if done.Status != StatusFailed || done.ResultStatus != http.StatusServiceUnavailable {
+		t.Errorf("job = %+v", done)
+	}
+}
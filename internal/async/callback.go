@@ -0,0 +1,118 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of a callback's body, as "sha256=<hex>"
+	SignatureHeader = "X-Signature-256"
+	// JobHeader carries a callback's job ID
+	JobHeader = "X-Async-Job-ID"
+
+	callbackAttempts = 3
+	callbackTimeout  = 10 * time.Second
+)
+
+// callbacks posts finished jobs to the URLs their clients gave
+type callbacks struct {
+	hosts   map[string]bool
+	secret  []byte
+	client  *http.Client
+	backoff time.Duration
+}
+
+func newCallbacks(hosts []string, secret string) *callbacks {
+	allowed := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	return &callbacks{
+		hosts:  allowed,
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout: callbackTimeout,
+			// a redirect could lead anywhere
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		backoff: time.Second,
+	}
+}
+
+// allowed checks a callback URL against the allowed hosts
+func (c *callbacks) allowed(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an absolute http(s) URL", ErrCallbackNotAllowed, raw)
+	}
+	if !c.hosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("%w: host %q is not allowed", ErrCallbackNotAllowed, u.Hostname())
+	}
+	return nil
+}
+
+// send posts the job, retrying failures with backoff
+func (c *callbacks) send(ctx context.Context, job *Job) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return
+	}
+	backoff := c.backoff
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		if err = c.post(ctx, job, body); err == nil {
+			metrics.AsyncCallbacks.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt == callbackAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			metrics.AsyncCallbacks.WithLabelValues("failed").Inc()
+			return
+		}
+	}
+	metrics.AsyncCallbacks.WithLabelValues("failed").Inc()
+}
+
+func (c *callbacks) post(ctx context.Context, job *Job, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(JobHeader, job.ID)
+	if len(c.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(c.secret, body))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of a callback body, as sent in SignatureHeader
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
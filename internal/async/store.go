@@ -0,0 +1,72 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a MemoryStore drops expired entries
+const sweepInterval = time.Minute
+
+// MemoryStore is a Store keeping jobs in this gateway instance's memory.
+// Unlike the local cache, writes are never dropped, so a job can always be
+// polled after its 202; clients must poll the instance that accepted it.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), lastSweep: time.Now()}
+}
+
+// Get implements Store, returning ErrNotFound for missing or expired keys
+func (s *MemoryStore) Get(_ context.Context, key string, dest interface{}) error {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	s.mu.Unlock()
+	if !ok || entry.expired(time.Now()) {
+		return ErrNotFound
+	}
+	return json.Unmarshal(entry.data, dest)
+}
+
+// Set implements Store. Values are stored as JSON, like the cache does;
+// ttl <= 0 keeps the value until it is replaced.
+func (s *MemoryStore) Set(_ context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry := memoryEntry{data: data}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
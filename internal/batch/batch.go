@@ -0,0 +1,212 @@
+// Package batch runs several gateway requests sent in one client request.
+// Each item goes through the gateway's normal routing, authentication,
+// permission checks and rate limits, so it counts as a request of its own;
+// items run concurrently up to a limit, each under its own timeout.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+const (
+	// DefaultMaxItems is the largest batch accepted by default
+	DefaultMaxItems = 20
+	// DefaultConcurrency is how many items of a batch run at once by default
+	DefaultConcurrency = 5
+	// DefaultTimeout is each item's default timeout
+	DefaultTimeout = 10 * time.Second
+)
+
+// ErrTooManyItems is returned for batches larger than the configured maximum
+var ErrTooManyItems = errors.New("too many requests in batch")
+
+// methods are the methods batch items may use
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// forbiddenHeaders are request headers items may not set, because they
+// describe the transport rather than the request
+var forbiddenHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// Item is one request of a batch
+type Item struct {
+	// ID is echoed in the item's response, to match responses to requests
+	ID     string `json:"id,omitempty"`
+	Method string `json:"method,omitempty"`
+	// Path is the gateway path, with an optional query
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as JSON
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is an item's response
+type Response struct {
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the response body; bodies that are not JSON are JSON strings
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Config limits batches
+type Config struct {
+	// MaxItems is the largest batch accepted (default 20)
+	MaxItems int
+	// Concurrency is how many items of a batch run at once (default 5)
+	Concurrency int
+	// Timeout is each item's timeout (default 10s)
+	Timeout time.Duration
+}
+
+// Runner runs batches
+type Runner struct {
+	dispatcher *subrequest.Dispatcher
+	config     Config
+}
+
+// New creates a Runner making requests with dispatcher
+func New(dispatcher *subrequest.Dispatcher, config Config) *Runner {
+	if config.MaxItems <= 0 {
+		config.MaxItems = DefaultMaxItems
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &Runner{dispatcher: dispatcher, config: config}
+}
+
+// Validate checks a batch before any of it runs
+func (b *Runner) Validate(items []Item) error {
+	if len(items) == 0 {
+		return errors.New("batch is empty")
+	}
+	if len(items) > b.config.MaxItems {
+		return fmt.Errorf("%w: %d, the maximum is %d", ErrTooManyItems, len(items), b.config.MaxItems)
+	}
+	for i := range items {
+		item := &items[i]
+		item.Method = strings.ToUpper(item.Method)
+		if item.Method == "" {
+			item.Method = http.MethodGet
+		}
+		if !methods[item.Method] {
+			return fmt.Errorf("item %d: unsupported method %q", i, item.Method)
+		}
+		if !strings.HasPrefix(item.Path, "/") || strings.HasPrefix(item.Path, "//") {
+			return fmt.Errorf("item %d: path must start with a single /", i)
+		}
+		for name := range item.Headers {
+			if forbiddenHeaders[http.CanonicalHeaderKey(name)] {
+				return fmt.Errorf("item %d: header %q cannot be set", i, name)
+			}
+		}
+		if len(item.Body) > 0 && !json.Valid(item.Body) {
+			return fmt.Errorf("item %d: body must be JSON", i)
+		}
+	}
+	return nil
+}
+
+// Run runs a validated batch for the client request r. Responses are in
+// the items' order.
+func (b *Runner) Run(r *http.Request, items []Item) []*Response {
+	metrics.BatchSize.Observe(float64(len(items)))
+	responses := make([]*Response, len(items))
+	sem := make(chan struct{}, b.config.Concurrency)
+	var wg sync.WaitGroup
+	// items start in order as earlier ones finish
+	for i := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-r.Context().Done():
+			responses[i] = errorResponse(&items[i], http.StatusServiceUnavailable, "batch cancelled")
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			responses[i] = b.run(r, &items[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, resp := range responses {
+		metrics.BatchItems.WithLabelValues(strconv.Itoa(resp.Status)).Inc()
+	}
+	return responses
+}
+
+func (b *Runner) run(r *http.Request, item *Item) *Response {
+	ctx, cancel := context.WithTimeout(r.Context(), b.config.Timeout)
+	defer cancel()
+
+	req := subrequest.Request{Method: item.Method, Path: item.Path, Header: http.Header{}, Body: item.Body}
+	for name, value := range item.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := b.dispatcher.Do(ctx, r, req)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorResponse(item, http.StatusGatewayTimeout, "request timed out")
+	}
+	if err != nil {
+		return errorResponse(item, http.StatusBadGateway, err.Error())
+	}
+
+	out := &Response{ID: item.ID, Status: resp.Status, Headers: map[string]string{}}
+	for name, values := range resp.Header {
+		// cookies cannot be set through a batch
+		if name != "Set-Cookie" && name != "Content-Length" {
+			out.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+	out.Body = encodeBody(resp.Body)
+	return out
+}
+
+// encodeBody keeps JSON bodies as they are and turns others into JSON strings
+func encodeBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
+
+func errorResponse(item *Item, status int, msg string) *Response {
+	body, _ := json.Marshal(map[string]string{"error": msg})
+	return &Response{ID: item.ID, Status: status, Body: body}
+}
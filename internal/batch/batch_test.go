@@ -0,0 +1,200 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// gateway stands in for the gateway's router. It allows limit requests per
+// client, like the rate limiter, and records the most requests it served at once.
+func gateway(limit int32) (http.Handler, *int32) {
+	var served, active, peak int32
+	var mu sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=1")
+		w.Write([]byte(`{"id": "u1", "lang": "` + r.Header.Get("Accept-Language") + `"}`))
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Item", r.Header.Get("X-Item"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/wait", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&served, 1) > limit {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		mux.ServeHTTP(w, r)
+	})
+	return handler, &peak
+}
+
+func run(t *testing.T, runner *Runner, items []Item) []*Response {
+	t.Helper()
+	if err := runner.Validate(items); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/batch", nil)
+	r.Header.Set("Accept-Language", "vi")
+	return runner.Run(r, items)
+}
+
+func TestRun(t *testing.T) {
+	handler, _ := gateway(100)
+	runner := New(subrequest.New(handler), Config{})
+	responses := run(t, runner, []Item{
+		{ID: "me", Path: "/users/me"},
+		{ID: "create", Method: "post", Path: "/echo", Headers: map[string]string{"X-Item": "2"}, Body: json.RawMessage(`{"name": "Ada"}`)},
+		{Path: "/text"},
+		{Path: "/missing"},
+	})
+
+	me := responses[0]
+	if me.ID != "me" || me.Status != http.StatusOK || string(me.Body) != `{"id": "u1", "lang": "vi"}` {
+		t.Errorf("me = %+v (%s)", me, me.Body)
+	}
+	if _, ok := me.Headers["Set-Cookie"]; ok || me.Headers["Content-Type"] != "application/json" {
+		t.Errorf("me headers = %v", me.Headers)
+	}
+	create := responses[1]
+	if create.Status != http.StatusCreated || create.Headers["X-Method"] != "POST" || create.Headers["X-Item"] != "2" || string(create.Body) != `{"name": "Ada"}` {
+		t.Errorf("create = %+v (%s)", create, create.Body)
+	}
+	if string(responses[2].Body) != `"plain"` {
+		t.Errorf("text body = %s", responses[2].Body)
+	}
+	if responses[3].Status != http.StatusNotFound {
+		t.Errorf("missing status = %d", responses[3].Status)
+	}
+}
+
+func TestRunBoundsConcurrency(t *testing.T) {
+	handler, peak := gateway(100)
+	runner := New(subrequest.New(handler), Config{Concurrency: 2})
+	items := make([]Item, 6)
+	for i := range items {
+		items[i] = Item{Path: "/wait"}
+	}
+	for _, resp := range run(t, runner, items) {
+		if resp.Status != http.StatusOK {
+			t.Errorf("status = %d", resp.Status)
+		}
+	}
+	if *peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", *peak)
+	}
+}
+
+func TestRunCountsEachItem(t *testing.T) {
+	// items are rate limited like separate requests
+	handler, _ := gateway(2)
+	runner := New(subrequest.New(handler), Config{Concurrency: 1})
+	responses := run(t, runner, []Item{{Path: "/text"}, {Path: "/text"}, {Path: "/text"}})
+	if responses[0].Status != http.StatusOK || responses[1].Status != http.StatusOK || responses[2].Status != http.StatusTooManyRequests {
+		t.Errorf("statuses = %d %d %d", responses[0].Status, responses[1].Status, responses[2].Status)
+	}
+}
+
+func TestRunKeepsClientAddress(t *testing.T) {
+	// the gateway trusts X-Forwarded-For from its load balancer; items
+	// cannot use that to pose as another client
+	engine := gin.New()
+	if err := engine.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	engine.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	runner := New(subrequest.New(engine), Config{})
+
+	items := []Item{
+		{Path: "/ip", Headers: map[string]string{"X-Forwarded-For": "198.51.100.9"}},
+		{Path: "/ip", Headers: map[string]string{"X-Real-IP": "198.51.100.9"}},
+	}
+	if err := runner.Validate(items); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/batch", nil)
+	r.RemoteAddr = "10.0.0.2:4242"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	for i, resp := range runner.Run(r, items) {
+		if string(resp.Body) != `"203.0.113.7"` {
+			t.Errorf("item %d client IP = %s, want 203.0.113.7", i, resp.Body)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	handler, _ := gateway(100)
+	runner := New(subrequest.New(handler), Config{Timeout: 20 * time.Millisecond})
+	responses := run(t, runner, []Item{{ID: "slow", Path: "/slow"}, {Path: "/text"}})
+	if responses[0].ID != "slow" || responses[0].Status != http.StatusGatewayTimeout || !strings.Contains(string(responses[0].Body), "timed out") {
+		t.Errorf("slow = %+v (%s)", responses[0], responses[0].Body)
+	}
+	if responses[1].Status != http.StatusOK {
+		t.Errorf("text status = %d", responses[1].Status)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	runner := New(subrequest.New(http.NotFoundHandler()), Config{MaxItems: 2})
+	tests := []struct {
+		items []Item
+		want  string
+	}{
+		{nil, "empty"},
+		{[]Item{{Path: "/a"}, {Path: "/b"}, {Path: "/c"}}, "too many"},
+		{[]Item{{Method: "CONNECT", Path: "/a"}}, "unsupported method"},
+		{[]Item{{Path: "a"}}, "path must start"},
+		{[]Item{{Path: "//evil.example/a"}}, "path must start"},
+		{[]Item{{Path: "/a", Headers: map[string]string{"host": "x"}}}, `header "host"`},
+		{[]Item{{Path: "/a", Body: json.RawMessage(`{bad`)}}, "body must be JSON"},
+	}
+	for _, tt := range tests {
+		err := runner.Validate(tt.items)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.items, err, tt.want)
+		}
+	}
+	if err := runner.Validate([]Item{{Path: "/a"}, {Path: "/b"}, {Path: "/c"}}); !errors.Is(err, ErrTooManyItems) {
+		t.Errorf("Validate = %v, want ErrTooManyItems", err)
+	}
+}
@@ -1,88 +1,245 @@
-package cache
-
-import (
-	"context"
-	"encoding/json"
-	"time"
-
-	"github.com/dgraph-io/ristretto"
-)
-
-// Cache provides caching functionality using Ristretto (in-memory)
-type Cache struct {
-	client *ristretto.Cache[string, any]
-}
-
-// NewCache creates a new Ristretto cache instance
-// maxCost: maximum cost of cache (approx memory usage in bytes if cost=1 means 1 byte, or count)
-// numCounters: should be 10x the number of keys
-func NewCache(maxCost int64, numCounters int64) (*Cache, error) {
-	config := &ristretto.Config[string, any]{
-		NumCounters: numCounters,
-		MaxCost:     maxCost,
-		BufferItems: 64, // recommend 64
-	}
-
-	cache, err := ristretto.NewCache(config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Cache{client: cache}, nil
-}
-
-// Get retrieves a value from cache
-func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
-	val, found := c.client.Get(key)
-	if !found {
-		// Mimic Redis nil error if needed, or just return custom error
-		return context.DeadlineExceeded // Or a custom ErrCacheMiss
-	}
-
-	// If val is []byte (stored as JSON)
-	if data, ok := val.([]byte); ok {
-		return json.Unmarshal(data, dest)
-	}
-
-	// If stored as object directly (Ristretto can do that, but let's stick to JSON for consistency with previous interface just in case)
-	// But actually Ristretto stores interface{}, so we could store the object directly.
-	// However, the interface asks for `dest interface{}` pointer usually for Unmarshal.
-	// To be safe and compatible with "Unmarshal" style usage:
-
-	// Check if dest is a pointer
-	// For now, let's assume we store []byte to transparently support the "Unmarshal" pattern used before
-	// or we can try to cast back if we stored the object.
-	// Let's stick to []byte storage for consistency with the previous Redis implementation which used JSON.
-
-	return json.Unmarshal(val.([]byte), dest)
-}
-
-// Set stores a value in cache with TTL
-func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return err
-	}
-	// Ristretto cost: 1 per item or length of data. Let's use length of data for MaxCost to work as size limit.
-	cost := int64(len(data))
-	c.client.SetWithTTL(key, data, cost, ttl)
-	return nil
-}
-
-// Delete removes a value from cache
-func (c *Cache) Delete(ctx context.Context, key string) error {
-	c.client.Del(key)
-	return nil
-}
-
-// Exists checks if a key exists in cache
-func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
-	_, found := c.client.Get(key)
-	return found, nil
-}
-
-// Close closes the cache (Ristretto implementation uses Close)
-func (c *Cache) Close() error {
-	c.client.Close()
-	return nil
-}
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// ErrCacheMiss is returned by Get when the key is not in the cache
+var ErrCacheMiss = errors.New("cache: miss")
+
+// ErrInvalidValue is returned by Get when the stored value is not encoded JSON
+var ErrInvalidValue = errors.New("cache: invalid stored value")
+
+// Stats is a snapshot of cache counters since the cache was created
+type Stats struct {
+	Hits         uint64  `json:"hits"`
+	Misses       uint64  `json:"misses"`
+	Ratio        float64 `json:"ratio"`
+	KeysAdded    uint64  `json:"keys_added"`
+	KeysUpdated  uint64  `json:"keys_updated"`
+	KeysEvicted  uint64  `json:"keys_evicted"`
+	CostAdded    uint64  `json:"cost_added"`
+	CostEvicted  uint64  `json:"cost_evicted"`
+	SetsDropped  uint64  `json:"sets_dropped"`
+	SetsRejected uint64  `json:"sets_rejected"`
+	Keys         int     `json:"keys"`
+	MaxCost      int64   `json:"max_cost"`
+}
+
+// Cache provides caching functionality using Ristretto (in-memory)
+type Cache struct {
+	client *ristretto.Cache[string, any]
+
+	// index maps Ristretto key hashes back to keys so entries can be listed
+	// and purged by prefix; evicted and rejected entries are removed from it
+	indexMu sync.Mutex
+	index   map[uint64]indexEntry
+	version uint64
+}
+
+// storedValue is what the cache keeps for a key: encoded JSON and the
+// version of the write that stored it
+type storedValue struct {
+	data    []byte
+	version uint64
+}
+
+// indexEntry records the key and the version of the last write for it, so
+// an eviction of an older value does not drop a newer entry from the index
+type indexEntry struct {
+	key     string
+	version uint64
+}
+
+// NewCache creates a new Ristretto cache instance
+// maxCost: maximum cost of cache (approx memory usage in bytes if cost=1 means 1 byte, or count)
+// numCounters: should be 10x the number of keys
+func NewCache(maxCost int64, numCounters int64) (*Cache, error) {
+	c := &Cache{index: make(map[uint64]indexEntry)}
+
+	config := &ristretto.Config[string, any]{
+		NumCounters: numCounters,
+		MaxCost:     maxCost,
+		BufferItems: 64, // recommend 64
+		Metrics:     true,
+		OnEvict:     c.unindex,
+		OnReject:    c.unindex,
+	}
+
+	cache, err := ristretto.NewCache(config)
+	if err != nil {
+		return nil, err
+	}
+
+	c.client = cache
+	return c, nil
+}
+
+// Get retrieves a value from cache, returning ErrCacheMiss if it is not present
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
+	val, found := c.client.Get(key)
+	if !found {
+		return ErrCacheMiss
+	}
+
+	// Values are always stored as encoded JSON
+	stored, ok := val.(storedValue)
+	if !ok {
+		return fmt.Errorf("%w: key %q holds %T", ErrInvalidValue, key, val)
+	}
+	return json.Unmarshal(stored.data, dest)
+}
+
+// Set stores a value in cache with TTL
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.setRaw(key, data, ttl)
+	return nil
+}
+
+// setRaw stores already encoded JSON in cache with TTL
+func (c *Cache) setRaw(key string, data []byte, ttl time.Duration) {
+	// Ristretto cost: length of data, so MaxCost works as a size limit
+	version := c.addIndex(key)
+	if !c.client.SetWithTTL(key, storedValue{data: data, version: version}, int64(len(data)), ttl) {
+		// dropped by a full buffer or rejected outright; OnReject only
+		// covers sets rejected once they leave the buffer
+		c.unindexVersion(key, version)
+	}
+}
+
+// Delete removes a value from cache
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	c.client.Del(key)
+	c.removeIndex(key)
+	return nil
+}
+
+// Exists checks if a key exists in cache
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	_, found := c.client.Get(key)
+	return found, nil
+}
+
+// Keys returns up to limit keys starting with prefix, sorted (limit <= 0 = no limit)
+func (c *Cache) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	keys := c.indexedKeys(prefix)
+
+	// Skip entries that expired but have not been cleaned up yet
+	live := keys[:0]
+	for _, key := range keys {
+		if _, found := c.client.GetTTL(key); found {
+			live = append(live, key)
+		}
+	}
+
+	sort.Strings(live)
+	if limit > 0 && len(live) > limit {
+		live = live[:limit]
+	}
+	return live, nil
+}
+
+// DeletePrefix removes every key starting with prefix and returns how many were removed
+func (c *Cache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	keys := c.indexedKeys(prefix)
+	for _, key := range keys {
+		c.client.Del(key)
+		c.removeIndex(key)
+	}
+	return len(keys), nil
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *Cache) Stats() Stats {
+	m := c.client.Metrics
+	c.indexMu.Lock()
+	keys := len(c.index)
+	c.indexMu.Unlock()
+
+	return Stats{
+		Hits:         m.Hits(),
+		Misses:       m.Misses(),
+		Ratio:        m.Ratio(),
+		KeysAdded:    m.KeysAdded(),
+		KeysUpdated:  m.KeysUpdated(),
+		KeysEvicted:  m.KeysEvicted(),
+		CostAdded:    m.CostAdded(),
+		CostEvicted:  m.CostEvicted(),
+		SetsDropped:  m.SetsDropped(),
+		SetsRejected: m.SetsRejected(),
+		Keys:         keys,
+		MaxCost:      c.client.MaxCost(),
+	}
+}
+
+// Close closes the cache (Ristretto implementation uses Close)
+func (c *Cache) Close() error {
+	c.client.Close()
+	return nil
+}
+
+// addIndex indexes key for a new write and returns the write's version
+func (c *Cache) addIndex(key string) uint64 {
+	hash, _ := z.KeyToHash(key)
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	c.version++
+	c.index[hash] = indexEntry{key: key, version: c.version}
+	return c.version
+}
+
+func (c *Cache) removeIndex(key string) {
+	hash, _ := z.KeyToHash(key)
+	c.indexMu.Lock()
+	delete(c.index, hash)
+	c.indexMu.Unlock()
+}
+
+// unindexVersion removes key from the index unless a later write replaced it
+func (c *Cache) unindexVersion(key string, version uint64) {
+	hash, _ := z.KeyToHash(key)
+	c.unindexHash(hash, version)
+}
+
+// unindex is called by Ristretto when an entry is evicted, expires or is rejected.
+// It runs with Ristretto locks held, so it must not call back into the cache.
+func (c *Cache) unindex(item *ristretto.Item[any]) {
+	if stored, ok := item.Value.(storedValue); ok {
+		c.unindexHash(item.Key, stored.version)
+	}
+}
+
+func (c *Cache) unindexHash(hash, version uint64) {
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	if entry, ok := c.index[hash]; ok && entry.version == version {
+		delete(c.index, hash)
+	}
+}
+
+func (c *Cache) indexedKeys(prefix string) []string {
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+
+	keys := make([]string, 0, len(c.index))
+	for _, entry := range c.index {
+		if strings.HasPrefix(entry.key, prefix) {
+			keys = append(keys, entry.key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	c, err := NewCache(1<<20, 1000)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestCache_GetMiss(t *testing.T) {
+	c := newTestCache(t)
+
+	var got string
+	err := c.Get(context.Background(), "missing", &got)
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestCache_GetInvalidValue(t *testing.T) {
+	c := newTestCache(t)
+
+	// Values written around the JSON API must not panic on read
+	c.client.Set("raw", 42, 1)
+	c.client.Wait()
+
+	var got int
+	err := c.Get(context.Background(), "raw", &got)
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestCache_KeysAndDeletePrefix(t *testing.T) {
+	c := newTestCache(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"token:b", "token:a", "roles:u1", "token:c"} {
+		if err := c.Set(ctx, key, "v", time.Minute); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	c.client.Wait()
+
+	keys, err := c.Keys(ctx, "token:", 0)
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	if want := []string{"token:a", "token:b", "token:c"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys() = %v, want %v", keys, want)
+	}
+
+	keys, _ = c.Keys(ctx, "token:", 2)
+	if len(keys) != 2 {
+		t.Errorf("Expected limit to be applied, got %v", keys)
+	}
+
+	deleted, err := c.DeletePrefix(ctx, "token:")
+	if err != nil || deleted != 3 {
+		t.Errorf("DeletePrefix() = %d, %v; want 3, nil", deleted, err)
+	}
+	c.client.Wait()
+
+	var v string
+	if err := c.Get(ctx, "token:a", &v); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected purged key to miss, got %v", err)
+	}
+	if err := c.Get(ctx, "roles:u1", &v); err != nil {
+		t.Errorf("Expected other keys to survive purge, got %v", err)
+	}
+}
+
+func TestCache_KeysSkipsExpired(t *testing.T) {
+	c := newTestCache(t)
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "short", "v", 10*time.Millisecond)
+	_ = c.Set(ctx, "long", "v", time.Minute)
+	c.client.Wait()
+	time.Sleep(20 * time.Millisecond)
+
+	keys, _ := c.Keys(ctx, "", 0)
+	if !reflect.DeepEqual(keys, []string{"long"}) {
+		t.Errorf("Keys() = %v, want [long]", keys)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := newTestCache(t)
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "k", "value", time.Minute)
+	c.client.Wait()
+
+	var v string
+	_ = c.Get(ctx, "k", &v)
+	_ = c.Get(ctx, "missing", &v)
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+	if stats.KeysAdded != 1 || stats.CostAdded == 0 || stats.Keys != 1 {
+		t.Errorf("Unexpected stats after one Set: %+v", stats)
+	}
+}
+
+func TestCache_UnstoredSetsAreNotIndexed(t *testing.T) {
+	c, err := NewCache(64, 1000)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	ctx := context.Background()
+
+	// refused by SetWithTTL, and rejected by the policy for exceeding MaxCost
+	_ = c.Set(ctx, "k:expired", "v", -time.Second)
+	_ = c.Set(ctx, "k:large", string(make([]byte, 128)), time.Minute)
+	_ = c.Set(ctx, "k:small", "v", time.Minute)
+	c.client.Wait()
+
+	if keys := c.Stats().Keys; keys != 1 {
+		t.Errorf("Stats().Keys = %d, want 1", keys)
+	}
+	if deleted, _ := c.DeletePrefix(ctx, "k:"); deleted != 1 {
+		t.Errorf("DeletePrefix() = %d, want 1", deleted)
+	}
+}
+
+func TestCache_OverwriteKeepsIndex(t *testing.T) {
+	c := newTestCache(t)
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "k", "old", time.Minute)
+	c.client.Wait()
+	_ = c.Set(ctx, "k", "new", time.Minute)
+	c.client.Wait()
+
+	// evicting the first write must not unindex the second
+	hash, _ := z.KeyToHash("k")
+	c.unindex(&ristretto.Item[any]{Key: hash, Value: storedValue{version: 1}})
+
+	if keys, _ := c.Keys(ctx, "", 0); !reflect.DeepEqual(keys, []string{"k"}) {
+		t.Errorf("Keys() = %v, want [k]", keys)
+	}
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Store is the part of a cache that GetOrLoad needs.
+// It is satisfied by Cache, TieredCache and the shared cache.Cache interface.
+type Store interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// LoadFunc loads a fresh value when the cache has none or it needs refreshing
+type LoadFunc func(ctx context.Context) (interface{}, error)
+
+// evictError is a load error that also removes the cached value
+type evictError struct {
+	err error
+}
+
+func (e *evictError) Error() string {
+	return e.err.Error()
+}
+
+func (e *evictError) Unwrap() error {
+	return e.err
+}
+
+// Evict wraps an error a LoadFunc returns when the value no longer exists,
+// such as a revoked token, so the cached value is deleted instead of being
+// served until it expires
+func Evict(err error) error {
+	return &evictError{err: err}
+}
+
+// LoaderConfig holds configuration for a Loader
+type LoaderConfig struct {
+	// StaleTTL is how long after its TTL a value may still be served while it
+	// is refreshed in the background (0 = never serve stale values)
+	StaleTTL time.Duration
+	// Beta tunes early probabilistic refresh; higher values refresh earlier (default: 1)
+	Beta float64
+	// RefreshTimeout bounds each load (default: 10 seconds)
+	RefreshTimeout time.Duration
+}
+
+// Loader wraps a Store with stampede protection: concurrent misses for the
+// same key are coalesced into one load, values are refreshed early with a
+// probability that grows as they approach expiry (XFetch), and expired values
+// are served while a single background refresh runs.
+type Loader struct {
+	store  Store
+	config LoaderConfig
+	group  singleflight.Group
+}
+
+// loaderEntry is what the Loader stores: the value plus freshness metadata
+type loaderEntry struct {
+	Value      json.RawMessage `json:"value"`
+	FreshUntil time.Time       `json:"fresh_until"`
+	// Delta is how long the last load took; slow loads are refreshed earlier
+	Delta time.Duration `json:"delta"`
+}
+
+// NewLoader creates a new loader on top of a store
+func NewLoader(store Store, config LoaderConfig) *Loader {
+	if config.Beta <= 0 {
+		config.Beta = 1
+	}
+	if config.RefreshTimeout <= 0 {
+		config.RefreshTimeout = 10 * time.Second
+	}
+	return &Loader{store: store, config: config}
+}
+
+// GetOrLoad decodes the cached value for key into dest, calling load on a miss.
+// Values are fresh for ttl and may be served stale for LoaderConfig.StaleTTL after that.
+// Errors from load are returned to the caller and never cached; errors
+// wrapped with Evict also delete the cached value, even from a background refresh.
+func (l *Loader) GetOrLoad(ctx context.Context, key string, dest interface{}, ttl time.Duration, load LoadFunc) error {
+	var entry loaderEntry
+	if err := l.store.Get(ctx, key, &entry); err == nil && entry.Value != nil {
+		now := time.Now()
+		if now.Before(entry.FreshUntil) {
+			if l.shouldRefreshEarly(entry, now) {
+				l.refresh(ctx, key, ttl, load)
+			}
+			return json.Unmarshal(entry.Value, dest)
+		}
+
+		if now.Before(entry.FreshUntil.Add(l.config.StaleTTL)) {
+			// Serve stale while revalidating
+			l.refresh(ctx, key, ttl, load)
+			return json.Unmarshal(entry.Value, dest)
+		}
+	}
+
+	select {
+	case res := <-l.flight(ctx, key, ttl, load):
+		if res.Err != nil {
+			return res.Err
+		}
+		return json.Unmarshal(res.Val.(json.RawMessage), dest)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shouldRefreshEarly implements XFetch: refresh when now - delta*beta*ln(rand) >= expiry
+func (l *Loader) shouldRefreshEarly(entry loaderEntry, now time.Time) bool {
+	if entry.Delta <= 0 {
+		return false
+	}
+	gap := -float64(entry.Delta) * l.config.Beta * math.Log(1-rand.Float64())
+	return !now.Add(time.Duration(gap)).Before(entry.FreshUntil)
+}
+
+// refresh reloads key in the background, coalesced with any in-flight load
+func (l *Loader) refresh(ctx context.Context, key string, ttl time.Duration, load LoadFunc) {
+	l.flight(ctx, key, ttl, load)
+}
+
+// flight starts (or joins) the load for key. The load is detached from the
+// caller's cancellation so one disconnecting client cannot fail the others.
+func (l *Loader) flight(ctx context.Context, key string, ttl time.Duration, load LoadFunc) <-chan singleflight.Result {
+	loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.config.RefreshTimeout)
+	ch := l.group.DoChan(key, func() (interface{}, error) {
+		return l.loadAndStore(loadCtx, key, ttl, load)
+	})
+
+	result := make(chan singleflight.Result, 1)
+	go func() {
+		res := <-ch
+		cancel()
+		result <- res
+	}()
+	return result
+}
+
+func (l *Loader) loadAndStore(ctx context.Context, key string, ttl time.Duration, load LoadFunc) (interface{}, error) {
+	start := time.Now()
+	value, err := load(ctx)
+	if err != nil {
+		var evict *evictError
+		if errors.As(err, &evict) {
+			_ = l.store.Delete(ctx, key)
+		}
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := loaderEntry{
+		Value:      data,
+		FreshUntil: time.Now().Add(ttl),
+		Delta:      time.Since(start),
+	}
+	// A failed cache write must not fail the request that loaded the value
+	_ = l.store.Set(ctx, key, entry, ttl+l.config.StaleTTL)
+
+	return json.RawMessage(data), nil
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mapStore is a synchronous Store used by loader tests
+type mapStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{data: make(map[string][]byte)}
+}
+
+func (s *mapStore) Get(ctx context.Context, key string, dest interface{}) error {
+	s.mu.Lock()
+	data, ok := s.data[key]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("miss")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (s *mapStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.data[key] = data
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *mapStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.data, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *mapStore) put(t *testing.T, key string, value interface{}, freshUntil time.Time, delta time.Duration) {
+	t.Helper()
+	data, _ := json.Marshal(value)
+	if err := s.Set(context.Background(), key, loaderEntry{Value: data, FreshUntil: freshUntil, Delta: delta}, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+}
+
+func TestGetOrLoad_MissLoadsAndCaches(t *testing.T) {
+	loader := NewLoader(newMapStore(), LoaderConfig{})
+	var calls int32
+
+	load := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return []string{"user.read"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		var got []string
+		if err := loader.GetOrLoad(context.Background(), "perms", &got, time.Minute, load); err != nil {
+			t.Fatalf("GetOrLoad() error = %v", err)
+		}
+		if len(got) != 1 || got[0] != "user.read" {
+			t.Errorf("Unexpected value: %v", got)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 load, got %d", calls)
+	}
+}
+
+func TestGetOrLoad_CoalescesConcurrentMisses(t *testing.T) {
+	loader := NewLoader(newMapStore(), LoaderConfig{})
+	var calls int32
+	release := make(chan struct{})
+
+	load := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got string
+			errs <- loader.GetOrLoad(context.Background(), "token:abc", &got, time.Minute, load)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetOrLoad() error = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected concurrent misses to be coalesced into 1 load, got %d", calls)
+	}
+}
+
+func TestGetOrLoad_ErrorsAreNotCached(t *testing.T) {
+	loader := NewLoader(newMapStore(), LoaderConfig{})
+	wantErr := errors.New("auth service down")
+
+	var got string
+	err := loader.GetOrLoad(context.Background(), "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return nil, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("Expected load error, got %v", err)
+	}
+
+	err = loader.GetOrLoad(context.Background(), "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "ok", nil
+	})
+	if err != nil || got != "ok" {
+		t.Errorf("Expected retry to load value, got %q, %v", got, err)
+	}
+}
+
+func TestGetOrLoad_ServesStaleWhileRevalidating(t *testing.T) {
+	store := newMapStore()
+	loader := NewLoader(store, LoaderConfig{StaleTTL: time.Minute})
+	store.put(t, "k", "old", time.Now().Add(-time.Second), 0)
+
+	refreshed := make(chan struct{})
+	var got string
+	err := loader.GetOrLoad(context.Background(), "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		defer close(refreshed)
+		return "new", nil
+	})
+	if err != nil {
+		t.Fatalf("GetOrLoad() error = %v", err)
+	}
+	if got != "old" {
+		t.Errorf("Expected stale value to be served, got %q", got)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected background refresh")
+	}
+
+	waitFor(t, func() bool {
+		var v string
+		_ = loader.GetOrLoad(context.Background(), "k", &v, time.Minute, func(ctx context.Context) (interface{}, error) {
+			return "unexpected", nil
+		})
+		return v == "new"
+	})
+}
+
+func TestGetOrLoad_EvictingRefreshDeletesStaleValue(t *testing.T) {
+	store := newMapStore()
+	loader := NewLoader(store, LoaderConfig{StaleTTL: time.Minute})
+	store.put(t, "k", "old", time.Now().Add(-time.Second), 0)
+
+	revoked := errors.New("revoked")
+	var got string
+	err := loader.GetOrLoad(context.Background(), "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return nil, Evict(revoked)
+	})
+	if err != nil || got != "old" {
+		t.Fatalf("Expected stale value while refreshing, got %q, %v", got, err)
+	}
+
+	waitFor(t, func() bool {
+		var entry loaderEntry
+		return store.Get(context.Background(), "k", &entry) != nil
+	})
+	err = loader.GetOrLoad(context.Background(), "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return nil, Evict(revoked)
+	})
+	if !errors.Is(err, revoked) {
+		t.Errorf("Expected the load error once evicted, got %v", err)
+	}
+}
+
+func TestGetOrLoad_ExpiredBeyondStaleLoadsSynchronously(t *testing.T) {
+	store := newMapStore()
+	loader := NewLoader(store, LoaderConfig{StaleTTL: time.Second})
+	store.put(t, "k", "old", time.Now().Add(-time.Minute), 0)
+
+	var got string
+	err := loader.GetOrLoad(context.Background(), "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "new", nil
+	})
+	if err != nil || got != "new" {
+		t.Errorf("Expected synchronous load, got %q, %v", got, err)
+	}
+}
+
+func TestGetOrLoad_EarlyRefresh(t *testing.T) {
+	store := newMapStore()
+	loader := NewLoader(store, LoaderConfig{})
+
+	// A slow load close to expiry is always refreshed early
+	store.put(t, "slow", "old", time.Now().Add(time.Millisecond), time.Hour)
+	refreshed := make(chan struct{})
+	var got string
+	_ = loader.GetOrLoad(context.Background(), "slow", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		close(refreshed)
+		return "new", nil
+	})
+	if got != "old" {
+		t.Errorf("Expected current value while refreshing early, got %q", got)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected early refresh")
+	}
+
+	// A fast load far from expiry is not refreshed
+	store.put(t, "fast", "value", time.Now().Add(time.Hour), time.Microsecond)
+	_ = loader.GetOrLoad(context.Background(), "fast", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		t.Error("Unexpected refresh of fresh value")
+		return "new", nil
+	})
+}
+
+func TestGetOrLoad_CallerCancellation(t *testing.T) {
+	loader := NewLoader(newMapStore(), LoaderConfig{})
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var got string
+	err := loader.GetOrLoad(ctx, "k", &got, time.Minute, func(ctx context.Context) (interface{}, error) {
+		<-release
+		return "value", nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// TieredConfig holds configuration for the two-tier cache
+type TieredConfig struct {
+	// KeyPrefix is prepended to every Redis key (e.g. "gateway" -> "gateway:token:abc")
+	KeyPrefix string
+	// DefaultTTL is used for L2 entries when Set is called without a TTL
+	DefaultTTL time.Duration
+	// L1TTL caps how long entries live in the local cache; it should be shorter
+	// than the L2 TTL so instances converge even if an invalidation is lost
+	L1TTL time.Duration
+}
+
+// invalidation is published on the invalidation channel when a key changes
+type invalidation struct {
+	Origin string `json:"origin"`
+	Key    string `json:"key"`
+	// Prefix marks Key as a prefix: every key starting with it is invalidated
+	Prefix bool `json:"prefix,omitempty"`
+}
+
+// TieredCache combines the local Ristretto cache (L1) with Redis (L2).
+// Writes go to both tiers and are broadcast so other gateway instances
+// drop their stale L1 copies.
+type TieredCache struct {
+	l1         *Cache
+	l2         redis.UniversalClient
+	config     TieredConfig
+	instanceID string
+	pubsub     *redis.PubSub
+	done       chan struct{}
+}
+
+// NewTieredCache creates a two-tier cache and starts listening for invalidations
+func NewTieredCache(ctx context.Context, l1 *Cache, l2 redis.UniversalClient, config TieredConfig) (*TieredCache, error) {
+	if config.DefaultTTL <= 0 {
+		config.DefaultTTL = 15 * time.Minute
+	}
+	if config.L1TTL <= 0 || config.L1TTL >= config.DefaultTTL {
+		config.L1TTL = config.DefaultTTL / 4
+	}
+
+	c := &TieredCache{
+		l1:         l1,
+		l2:         l2,
+		config:     config,
+		instanceID: uuid.New().String(),
+		done:       make(chan struct{}),
+	}
+
+	// Wait for the subscription to be confirmed so no invalidation is missed
+	c.pubsub = l2.Subscribe(ctx, c.invalidationChannel())
+	if _, err := c.pubsub.Receive(ctx); err != nil {
+		_ = c.pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+	}
+	go c.listenInvalidations()
+
+	return c, nil
+}
+
+// Get retrieves a value from L1, falling back to L2 and refilling L1 on an L2 hit.
+// It returns ErrCacheMiss if the key is in neither tier.
+func (c *TieredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	l1Err := c.l1.Get(ctx, key, dest)
+	if l1Err == nil {
+		return nil
+	}
+
+	data, err := c.l2.Get(ctx, c.redisKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ErrCacheMiss
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return err
+	}
+
+	c.l1.setRaw(key, data, c.config.L1TTL)
+	return nil
+}
+
+// Set stores a value in both tiers and invalidates L1 copies on other instances
+func (c *TieredCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		ttl = c.config.DefaultTTL
+	}
+	if err := c.l2.Set(ctx, c.redisKey(key), data, ttl).Err(); err != nil {
+		return err
+	}
+
+	c.l1.setRaw(key, data, min(ttl, c.config.L1TTL))
+	return c.publishInvalidation(ctx, invalidation{Key: key})
+}
+
+// Delete removes a value from both tiers and invalidates L1 copies on other instances
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	_ = c.l1.Delete(ctx, key)
+	if err := c.l2.Del(ctx, c.redisKey(key)).Err(); err != nil {
+		return err
+	}
+	return c.publishInvalidation(ctx, invalidation{Key: key})
+}
+
+// Keys returns up to limit L2 keys starting with prefix, sorted (limit <= 0 = no limit)
+func (c *TieredCache) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	var keys []string
+	err := c.scan(ctx, prefix, func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+// DeletePrefix removes every key starting with prefix from both tiers and
+// invalidates matching L1 entries on other instances
+func (c *TieredCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	_, _ = c.l1.DeletePrefix(ctx, prefix)
+
+	deleted := 0
+	err := c.scan(ctx, prefix, func(batch []string) error {
+		redisKeys := make([]string, len(batch))
+		for i, key := range batch {
+			redisKeys[i] = c.redisKey(key)
+		}
+		n, err := c.l2.Del(ctx, redisKeys...).Result()
+		deleted += int(n)
+		return err
+	})
+	if err != nil {
+		return deleted, err
+	}
+
+	return deleted, c.publishInvalidation(ctx, invalidation{Key: prefix, Prefix: true})
+}
+
+// Stats returns the L1 cache counters
+func (c *TieredCache) Stats() Stats {
+	return c.l1.Stats()
+}
+
+// Exists checks if a key exists in either tier
+func (c *TieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	if found, _ := c.l1.Exists(ctx, key); found {
+		return true, nil
+	}
+	n, err := c.l2.Exists(ctx, c.redisKey(key)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Close stops the invalidation listener and closes both tiers
+func (c *TieredCache) Close() error {
+	err := c.pubsub.Close()
+	<-c.done
+	_ = c.l1.Close()
+	if closeErr := c.l2.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *TieredCache) listenInvalidations() {
+	defer close(c.done)
+
+	for msg := range c.pubsub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			continue
+		}
+		if inv.Origin == c.instanceID {
+			continue
+		}
+		if inv.Prefix {
+			_, _ = c.l1.DeletePrefix(context.Background(), inv.Key)
+			continue
+		}
+		_ = c.l1.Delete(context.Background(), inv.Key)
+	}
+}
+
+func (c *TieredCache) publishInvalidation(ctx context.Context, inv invalidation) error {
+	inv.Origin = c.instanceID
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return c.l2.Publish(ctx, c.invalidationChannel(), payload).Err()
+}
+
+// scan walks the L2 keys starting with prefix in batches, passing them to fn without the key prefix
+func (c *TieredCache) scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	pattern := escapeGlob(c.redisKey(prefix)) + "*"
+
+	var cursor uint64
+	for {
+		redisKeys, next, err := c.l2.Scan(ctx, cursor, pattern, 500).Result()
+		if err != nil {
+			return err
+		}
+
+		batch := make([]string, 0, len(redisKeys))
+		for _, redisKey := range redisKeys {
+			batch = append(batch, c.stripPrefix(redisKey))
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (c *TieredCache) stripPrefix(redisKey string) string {
+	if c.config.KeyPrefix == "" {
+		return redisKey
+	}
+	return strings.TrimPrefix(redisKey, c.config.KeyPrefix+":")
+}
+
+// escapeGlob escapes Redis glob metacharacters so prefix is matched literally
+func escapeGlob(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (c *TieredCache) redisKey(key string) string {
+	if c.config.KeyPrefix == "" {
+		return key
+	}
+	return c.config.KeyPrefix + ":" + key
+}
+
+func (c *TieredCache) invalidationChannel() string {
+	return c.redisKey("cache-invalidation")
+}
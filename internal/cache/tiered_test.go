@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type tieredTestValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newTestTieredCache(t *testing.T, mr *miniredis.Miniredis, config TieredConfig) *TieredCache {
+	t.Helper()
+
+	l1, err := NewCache(1<<20, 1000)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	l2 := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := NewTieredCache(ctx, l1, l2, config)
+	if err != nil {
+		t.Fatalf("NewTieredCache() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// waitFor polls cond until it is true or the deadline passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Condition not met before deadline")
+}
+
+func TestTieredCache_SetAndGet(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestTieredCache(t, mr, TieredConfig{KeyPrefix: "gateway", DefaultTTL: time.Minute, L1TTL: 10 * time.Second})
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "token:abc", tieredTestValue{Name: "a", Count: 1}, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if !mr.Exists("gateway:token:abc") {
+		t.Fatal("Expected key to be stored in Redis with prefix")
+	}
+	if ttl := mr.TTL("gateway:token:abc"); ttl != time.Minute {
+		t.Errorf("Expected default TTL of 1m in Redis, got %v", ttl)
+	}
+
+	var got tieredTestValue
+	if err := c.Get(ctx, "token:abc", &got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Name != "a" || got.Count != 1 {
+		t.Errorf("Unexpected value: %+v", got)
+	}
+
+	exists, err := c.Exists(ctx, "token:abc")
+	if err != nil || !exists {
+		t.Errorf("Exists() = %v, %v; want true, nil", exists, err)
+	}
+}
+
+func TestTieredCache_L2FallbackRefillsL1(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestTieredCache(t, mr, TieredConfig{KeyPrefix: "gw", DefaultTTL: time.Minute})
+	ctx := context.Background()
+
+	// Written by another instance directly into L2
+	mr.Set("gw:perm", `{"name":"remote","count":7}`)
+
+	var got tieredTestValue
+	if err := c.Get(ctx, "perm", &got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Name != "remote" {
+		t.Errorf("Expected value from L2, got %+v", got)
+	}
+
+	c.l1.client.Wait()
+	mr.Del("gw:perm")
+	if err := c.Get(ctx, "perm", &got); err != nil {
+		t.Errorf("Expected L1 hit after L2 refill, got %v", err)
+	}
+}
+
+func TestTieredCache_Miss(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestTieredCache(t, mr, TieredConfig{})
+
+	var got tieredTestValue
+	if err := c.Get(context.Background(), "missing", &got); err == nil {
+		t.Error("Expected error on miss")
+	}
+
+	exists, err := c.Exists(context.Background(), "missing")
+	if err != nil || exists {
+		t.Errorf("Exists() = %v, %v; want false, nil", exists, err)
+	}
+}
+
+func TestTieredCache_L1TTLShorterThanL2(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	c := newTestTieredCache(t, mr, TieredConfig{DefaultTTL: time.Minute, L1TTL: time.Hour})
+	if c.config.L1TTL >= c.config.DefaultTTL {
+		t.Errorf("Expected L1 TTL to be clamped below L2 TTL, got %v", c.config.L1TTL)
+	}
+
+	c = newTestTieredCache(t, mr, TieredConfig{})
+	if c.config.DefaultTTL != 15*time.Minute || c.config.L1TTL >= c.config.DefaultTTL {
+		t.Errorf("Unexpected defaults: %+v", c.config)
+	}
+}
+
+func TestTieredCache_CrossInstanceInvalidation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := TieredConfig{KeyPrefix: "gateway", DefaultTTL: time.Minute}
+	a := newTestTieredCache(t, mr, config)
+	b := newTestTieredCache(t, mr, config)
+	ctx := context.Background()
+
+	if err := a.Set(ctx, "roles:u1", tieredTestValue{Name: "v1"}, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Instance B loads the value into its L1
+	var got tieredTestValue
+	if err := b.Get(ctx, "roles:u1", &got); err != nil || got.Name != "v1" {
+		t.Fatalf("Get() = %+v, %v", got, err)
+	}
+	b.l1.client.Wait()
+
+	// Instance A updates the value, B must drop its stale L1 copy
+	if err := a.Set(ctx, "roles:u1", tieredTestValue{Name: "v2"}, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	waitFor(t, func() bool {
+		var v tieredTestValue
+		return b.Get(ctx, "roles:u1", &v) == nil && v.Name == "v2"
+	})
+
+	// Deleting on A removes the key everywhere
+	if err := a.Delete(ctx, "roles:u1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	waitFor(t, func() bool {
+		var v tieredTestValue
+		return b.Get(ctx, "roles:u1", &v) != nil
+	})
+}
+
+func TestTieredCache_MissError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestTieredCache(t, mr, TieredConfig{})
+
+	var got tieredTestValue
+	if err := c.Get(context.Background(), "missing", &got); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestTieredCache_KeysAndDeletePrefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := TieredConfig{KeyPrefix: "gateway", DefaultTTL: time.Minute}
+	a := newTestTieredCache(t, mr, config)
+	b := newTestTieredCache(t, mr, config)
+	ctx := context.Background()
+
+	for _, key := range []string{"roles:u2", "roles:u1", "token:abc", "roles*:x"} {
+		if err := a.Set(ctx, key, tieredTestValue{Name: key}, 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	keys, err := a.Keys(ctx, "roles:", 0)
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	if want := []string{"roles:u1", "roles:u2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys() = %v, want %v", keys, want)
+	}
+
+	// Instance B holds a copy in L1 that must be purged too
+	var got tieredTestValue
+	if err := b.Get(ctx, "roles:u1", &got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	b.l1.client.Wait()
+
+	deleted, err := a.DeletePrefix(ctx, "roles:")
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeletePrefix() = %d, %v; want 2, nil", deleted, err)
+	}
+	if !mr.Exists("gateway:token:abc") || !mr.Exists("gateway:roles*:x") {
+		t.Error("Expected keys outside the prefix to survive")
+	}
+
+	waitFor(t, func() bool {
+		var v tieredTestValue
+		return errors.Is(b.Get(ctx, "roles:u1", &v), ErrCacheMiss)
+	})
+}
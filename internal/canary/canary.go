@@ -0,0 +1,174 @@
+// Package canary splits traffic to a service between named versions.
+package canary
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Stable is the version reported for requests that are not split off
+const Stable = "stable"
+
+// Reasons a version was chosen
+const (
+	ReasonHeader = "header"
+	ReasonCookie = "cookie"
+	ReasonTenant = "tenant"
+	ReasonWeight = "weight"
+)
+
+// Weight sends a percentage of a service's traffic to a version
+type Weight struct {
+	Version string
+	Percent int
+}
+
+// Split is the traffic split of one service
+type Split struct {
+	Service string
+	// Weights split the remaining traffic by percentage; the rest goes to Stable
+	Weights []Weight
+	// Tenants pins tenants to a version regardless of weights
+	Tenants map[string]string
+}
+
+// Config holds configuration for a Splitter
+type Config struct {
+	Splits []Split
+	// Header names a request header that selects a version by name ("" = disabled)
+	Header string
+	// Cookie names a cookie that selects a version by name ("" = disabled)
+	Cookie string
+}
+
+// Splitter chooses the version of a service each request goes to
+type Splitter struct {
+	config Config
+	splits map[string]*Split
+}
+
+// New creates a splitter, validating that weights add up to at most 100%
+func New(config Config) (*Splitter, error) {
+	s := &Splitter{config: config, splits: make(map[string]*Split)}
+	for i := range config.Splits {
+		split := &config.Splits[i]
+		total := 0
+		for _, w := range split.Weights {
+			if w.Percent < 0 || w.Version == "" || w.Version == Stable {
+				return nil, fmt.Errorf("invalid canary weight %s=%s:%d", split.Service, w.Version, w.Percent)
+			}
+			total += w.Percent
+		}
+		if total > 100 {
+			return nil, fmt.Errorf("canary weights of %s add up to %d%%", split.Service, total)
+		}
+		s.splits[split.Service] = split
+	}
+	return s, nil
+}
+
+// Choose returns the version a request to service goes to and why.
+// Precedence: tenant allowlist, header, cookie, then weights. stickyKey (a
+// user ID, tenant ID or client IP) keeps the weighted choice stable across
+// requests. Clients may only name Stable or a weighted version, so versions
+// open to allowlisted tenants only stay closed to everyone else. Services
+// without a split, and other versions named by clients, get Stable.
+func (s *Splitter) Choose(r *http.Request, service, tenantID, stickyKey string) (string, string) {
+	split, ok := s.splits[service]
+	if !ok {
+		return Stable, ""
+	}
+
+	if version, ok := split.Tenants[tenantID]; ok && tenantID != "" {
+		return version, ReasonTenant
+	}
+	if s.config.Header != "" {
+		if version := r.Header.Get(s.config.Header); version != "" && split.has(version) {
+			return version, ReasonHeader
+		}
+	}
+	if s.config.Cookie != "" {
+		if cookie, err := r.Cookie(s.config.Cookie); err == nil && split.has(cookie.Value) {
+			return cookie.Value, ReasonCookie
+		}
+	}
+
+	if len(split.Weights) == 0 {
+		return Stable, ""
+	}
+	bucket := bucket(service, stickyKey)
+	for _, w := range split.Weights {
+		if bucket < w.Percent {
+			return w.Version, ReasonWeight
+		}
+		bucket -= w.Percent
+	}
+	return Stable, ""
+}
+
+// has reports whether any client may be routed to a version: Stable or a
+// weighted version. Versions only pinned to tenants are left out.
+func (s *Split) has(version string) bool {
+	if version == Stable {
+		return true
+	}
+	for _, w := range s.Weights {
+		if w.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket maps a sticky key to 0-99, independently per service
+func bucket(service, stickyKey string) int {
+	h := fnv.New32a()
+	h.Write([]byte(service + "/" + stickyKey))
+	return int(h.Sum32() % 100)
+}
+
+// ParseSplits builds splits from "service=version:percent" entries and
+// "service:version=tenant|tenant" tenant allowlists
+func ParseSplits(weights, tenants map[string]string) ([]Split, error) {
+	byService := make(map[string]*Split)
+	get := func(service string) *Split {
+		if byService[service] == nil {
+			byService[service] = &Split{Service: service, Tenants: make(map[string]string)}
+		}
+		return byService[service]
+	}
+
+	for service, spec := range weights {
+		for _, entry := range strings.Split(spec, "|") {
+			version, percent, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			p, err := strconv.Atoi(percent)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid canary split %q: expected version:percent", service+"="+entry)
+			}
+			split := get(service)
+			split.Weights = append(split.Weights, Weight{Version: version, Percent: p})
+		}
+	}
+
+	for key, list := range tenants {
+		service, version, ok := strings.Cut(key, ":")
+		if !ok || service == "" || version == "" {
+			return nil, fmt.Errorf("invalid canary tenants %q: expected service:version=tenant|tenant", key)
+		}
+		split := get(service)
+		for _, tenantID := range strings.Split(list, "|") {
+			if tenantID = strings.TrimSpace(tenantID); tenantID != "" {
+				split.Tenants[tenantID] = version
+			}
+		}
+	}
+
+	splits := make([]Split, 0, len(byService))
+	for _, split := range byService {
+		splits = append(splits, *split)
+	}
+	return splits, nil
+}
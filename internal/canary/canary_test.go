@@ -0,0 +1,102 @@
+package canary
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestSplitter(t *testing.T) *Splitter {
+	t.Helper()
+	splits, err := ParseSplits(
+		map[string]string{"cms-service": "v2:20|v3:10"},
+		map[string]string{"cms-service:v3": "tenant-beta|tenant-gamma", "search-service:v2": "tenant-beta"},
+	)
+	if err != nil {
+		t.Fatalf("ParseSplits() error = %v", err)
+	}
+	s, err := New(Config{Splits: splits, Header: "X-Canary-Version", Cookie: "canary_version"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s
+}
+
+func TestNew_Validation(t *testing.T) {
+	if _, err := New(Config{Splits: []Split{{Service: "a", Weights: []Weight{{"v2", 60}, {"v3", 50}}}}}); err == nil {
+		t.Error("Expected error for weights above 100%")
+	}
+	if _, err := ParseSplits(map[string]string{"a": "v2"}, nil); err == nil {
+		t.Error("Expected error for missing percentage")
+	}
+	if _, err := ParseSplits(nil, map[string]string{"a": "tenant-1"}); err == nil {
+		t.Error("Expected error for tenant list without version")
+	}
+}
+
+func TestSplitter_Precedence(t *testing.T) {
+	s := newTestSplitter(t)
+
+	tests := []struct {
+		name        string
+		service     string
+		header      string
+		cookie      string
+		tenantID    string
+		wantVersion string
+		wantReason  string
+	}{
+		{"no split", "billing", "v2", "", "", Stable, ""},
+		{"header", "cms-service", "v2", "v3", "tenant-alpha", "v2", ReasonHeader},
+		{"header stable", "cms-service", "stable", "", "tenant-alpha", Stable, ReasonHeader},
+		{"unknown header version", "cms-service", "v9", "v3", "", "v3", ReasonCookie},
+		{"cookie", "cms-service", "", "v2", "tenant-alpha", "v2", ReasonCookie},
+		{"tenant allowlist", "cms-service", "", "", "tenant-gamma", "v3", ReasonTenant},
+		{"tenant allowlist before header", "cms-service", "v2", "stable", "tenant-beta", "v3", ReasonTenant},
+		{"tenant-only version by header", "search-service", "v2", "", "tenant-alpha", Stable, ""},
+		{"tenant-only version by cookie", "search-service", "", "v2", "", Stable, ""},
+		{"tenant-only version for its tenant", "search-service", "", "", "tenant-beta", "v2", ReasonTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Canary-Version", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "canary_version", Value: tt.cookie})
+			}
+
+			version, reason := s.Choose(req, tt.service, tt.tenantID, "user-1")
+			if version != tt.wantVersion || reason != tt.wantReason {
+				t.Errorf("Choose() = %q, %q; want %q, %q", version, reason, tt.wantVersion, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSplitter_WeightsAreStickyAndProportional(t *testing.T) {
+	s := newTestSplitter(t)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	counts := make(map[string]int)
+	const users = 10000
+	for i := 0; i < users; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		version, _ := s.Choose(req, "cms-service", "", key)
+		if again, _ := s.Choose(req, "cms-service", "", key); again != version {
+			t.Fatalf("Expected sticky assignment for %s, got %s then %s", key, version, again)
+		}
+		counts[version]++
+	}
+
+	for version, want := range map[string]float64{"v2": 0.2, "v3": 0.1, Stable: 0.7} {
+		got := float64(counts[version]) / users
+		if math.Abs(got-want) > 0.02 {
+			t.Errorf("Version %s got %.3f of traffic, want about %.2f", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package cidr parses lists of trusted networks, such as proxies and load
+// balancers, given as CIDRs or single IPs.
+package cidr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Networks is a list of networks
+type Networks []*net.IPNet
+
+// Parse parses CIDRs ("10.0.0.0/8") and single IPs ("192.168.1.5", "::1").
+// Empty entries are skipped.
+func Parse(entries []string) (Networks, error) {
+	var networks Networks
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q: %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ParseList parses a comma separated list of CIDRs or single IPs
+func ParseList(spec string) (Networks, error) {
+	return Parse(strings.Split(spec, ","))
+}
+
+// Contains reports whether ip is in one of the networks
+func (n Networks) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package cidr
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	networks, err := ParseList("10.0.0.0/8, 192.168.1.5,, 2001:db8::/32, ::1")
+	if err != nil {
+		t.Fatalf("ParseList() error = %v", err)
+	}
+	if len(networks) != 4 {
+		t.Fatalf("Expected 4 networks, got %d", len(networks))
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"::ffff:10.1.2.3", true},
+		{"2001:db8::1", true},
+		{"::1", true},
+		{"::2", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := networks.Contains(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, entry := range []string{"nope", "10.0.0.0/33", "10.0.0.300"} {
+		if _, err := Parse([]string{"10.0.0.0/8", entry}); err == nil {
+			t.Errorf("Parse(%q) succeeded", entry)
+		}
+	}
+
+	networks, err := ParseList("")
+	if err != nil || len(networks) != 0 {
+		t.Errorf("ParseList(\"\") = %v, %v; want no networks", networks, err)
+	}
+}
@@ -1,225 +1,230 @@
-package client
-
-import (
-	"context"
-
-	"github.com/vhvplatform/go-shared/logger"
-	"go.uber.org/zap"
-	"google.golang.org/grpc"
-)
-
-// AuthClient handles communication with auth service
-type AuthClient struct {
-	conn *grpc.ClientConn
-	log  *logger.Logger
-	// client proto.AuthServiceClient // Uncomment when proto is generated
-}
-
-// NewAuthClient creates a new auth client with retry logic and mTLS support
-func NewAuthClient(serviceURL string, log *logger.Logger, tlsCfg *TLSConfig) *AuthClient {
-	conn, err := NewGRPCConnection(serviceURL, log, tlsCfg)
-	if err != nil {
-		log.Error("Failed to connect to auth service", zap.Error(err), zap.String("url", serviceURL))
-		return &AuthClient{
-			conn: nil,
-			log:  log,
-		}
-	}
-
-	log.Info("Successfully connected to auth service", zap.String("url", serviceURL))
-	return &AuthClient{
-		conn: conn,
-		log:  log,
-		// client: proto.NewAuthServiceClient(conn), // Uncomment when proto is generated
-	}
-}
-
-// Close closes the gRPC connection
-func (c *AuthClient) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
-	return nil
-}
-
-// VerifyTokenRequest mimics the proto request
-type VerifyTokenRequest struct {
-	Token string `json:"token"`
-}
-
-// VerifyTokenResponse mimics the proto response
-type VerifyTokenResponse struct {
-	Valid       bool              `json:"valid"`
-	UserId      string            `json:"user_id"`
-	TenantId    string            `json:"tenant_id"`
-	Email       string            `json:"email"`
-	Roles       []string          `json:"roles"`
-	Permissions []string          `json:"permissions"`
-	Metadata    map[string]string `json:"metadata"`
-}
-
-// VerifyToken validates an opaque token with the auth service
-func (c *AuthClient) VerifyToken(ctx context.Context, token string) (*VerifyTokenResponse, error) {
-	// In a real scenario with generated proto, this would be:
-	// return c.client.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token})
-
-	// Since we don't have the generated code yet, we use generic Invoke if the server supports it,
-	// OR we assume/hope the server uses JSON encoding or similar.
-	// BUT gRPC uses Protobuf encoding by default.
-	// Without the proto definition compiled into Go, we can't easily marshal/unmarshal unless we manually construct the bytes (hard)
-	// or use a dynamic dispatch library.
-
-	// Implementation Strategy:
-	// Return a mock response for now to allow progress, OR assume the methods will be generated later.
-	// If I must "Implement" it now, I'll add the method but it will fail at runtime if I try to Invoke without proto.
-	// However, I can write the code assuming the `proto` package will be imported.
-	// But I don't have the package.
-
-	// Let's implement a dummy that returns valid for dev purposes, or fail.
-	// User said: "Upgrade...". I should probably try to make it as real as possible.
-	// I will just return nil error and mock data if I can't connect.
-
-	// TODO: Replace with actual proto-generated client when proto is compiled
-	// For now, return stub response for development
-	c.log.Warn("VerifyToken called (stub implementation)", zap.String("token_prefix", token[:min(10, len(token))]))
-
-	// In production, this would be:
-	// resp, err := c.client.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token})
-	// if err != nil {
-	//     return nil, err
-	// }
-	// return &VerifyTokenResponse{
-	//     Valid: resp.Valid,
-	//     UserId: resp.UserId,
-	//     ...
-	// }, nil
-
-	// Mock response for development
-	return &VerifyTokenResponse{
-		Valid:       true,
-		UserId:      "user-123",
-		TenantId:    "tenant-456",
-		Email:       "user@example.com",
-		Roles:       []string{"user"},
-		Permissions: []string{"read", "write"},
-		Metadata:    map[string]string{"source": "stub"},
-	}, nil
-}
-
-// CheckPermissionRequest for permission checking
-type CheckPermissionRequest struct {
-	UserId     string `json:"user_id"`
-	TenantId   string `json:"tenant_id"`
-	Permission string `json:"permission"`
-}
-
-// CheckPermissionResponse contains permission check result
-type CheckPermissionResponse struct {
-	HasPermission bool `json:"has_permission"`
-}
-
-// CheckPermission checks if a user has a specific permission
-func (c *AuthClient) CheckPermission(ctx context.Context, userID, tenantID, permission string) (bool, error) {
-	// TODO: Replace with actual proto-generated client
-	// resp, err := c.client.CheckPermission(ctx, &proto.CheckPermissionRequest{
-	//     UserId: userID,
-	//     TenantId: tenantID,
-	//     Permission: permission,
-	// })
-	// if err != nil {
-	//     c.log.Error("CheckPermission failed", zap.Error(err))
-	//     return false, err
-	// }
-	// return resp.HasPermission, nil
-
-	c.log.Debug("CheckPermission called (stub)",
-		zap.String("user_id", userID),
-		zap.String("tenant_id", tenantID),
-		zap.String("permission", permission))
-
-	// For development: grant all permissions
-	return true, nil
-}
-
-// GetUserRolesRequest for getting user roles
-type GetUserRolesRequest struct {
-	UserId   string `json:"user_id"`
-	TenantId string `json:"tenant_id"`
-}
-
-// GetUserRolesResponse contains user roles
-type GetUserRolesResponse struct {
-	Roles []string `json:"roles"`
-}
-
-// GetUserRoles gets all roles for a user in a tenant
-func (c *AuthClient) GetUserRoles(ctx context.Context, userID, tenantID string) ([]string, error) {
-	// TODO: Replace with actual proto-generated client
-	// resp, err := c.client.GetUserRoles(ctx, &proto.GetUserRolesRequest{
-	//     UserId: userID,
-	//     TenantId: tenantID,
-	// })
-	// if err != nil {
-	//     c.log.Error("GetUserRoles failed", zap.Error(err))
-	//     return nil, err
-	// }
-	// return resp.Roles, nil
-
-	c.log.Debug("GetUserRoles called (stub)",
-		zap.String("user_id", userID),
-		zap.String("tenant_id", tenantID))
-
-	// For development: return mock roles
-	return []string{"user", "admin"}, nil
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// LoginRequest mimics proto
-type LoginRequest struct {
-	Identifier string `json:"identifier"` // username, email, phone, etc.
-	Password   string `json:"password"`
-	TenantId   string `json:"tenant_id,omitempty"`
-}
-
-// LoginResponse mimics proto
-type LoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int64  `json:"expires_in"`
-}
-
-// Login calls the auth service login endpoint via gRPC
-func (c *AuthClient) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
-	// Stub: return c.client.Login(ctx, protoReq)
-	c.log.Info("Login called (gRPC stub)", zap.String("identifier", req.Identifier))
-	return &LoginResponse{
-		AccessToken:  "opaque-access-token-123",
-		RefreshToken: "refresh-token-456",
-		ExpiresIn:    3600,
-	}, nil
-}
-
-// RegisterRequest mimics proto
-type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
-	TenantId string `json:"tenant_id,omitempty"`
-}
-
-// RegisterResponse mimics proto
-type RegisterResponse struct {
-	UserId string `json:"user_id"`
-}
-
-// Register calls the auth service register endpoint via gRPC
-func (c *AuthClient) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
-	c.log.Info("Register called (gRPC stub)", zap.String("username", req.Username))
-	return &RegisterResponse{UserId: "new-user-789"}, nil
-}
+package client
+
+import (
+	"context"
+
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// AuthClient handles communication with auth service
+type AuthClient struct {
+	conn *grpc.ClientConn
+	log  *logger.Logger
+	// client proto.AuthServiceClient // Uncomment when proto is generated
+}
+
+// NewAuthClient creates a new auth client with retry logic and mTLS support
+func NewAuthClient(serviceURL string, log *logger.Logger, tlsCfg *TLSConfig) *AuthClient {
+	conn, err := NewGRPCConnection(serviceURL, log, tlsCfg)
+	if err != nil {
+		log.Error("Failed to connect to auth service", zap.Error(err), zap.String("url", serviceURL))
+		return &AuthClient{
+			conn: nil,
+			log:  log,
+		}
+	}
+
+	log.Info("Successfully connected to auth service", zap.String("url", serviceURL))
+	return &AuthClient{
+		conn: conn,
+		log:  log,
+		// client: proto.NewAuthServiceClient(conn), // Uncomment when proto is generated
+	}
+}
+
+// Conn returns the underlying connection, or nil if the auth service is not connected
+func (c *AuthClient) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// Close closes the gRPC connection
+func (c *AuthClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// VerifyTokenRequest mimics the proto request
+type VerifyTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyTokenResponse mimics the proto response
+type VerifyTokenResponse struct {
+	Valid       bool              `json:"valid"`
+	UserId      string            `json:"user_id"`
+	TenantId    string            `json:"tenant_id"`
+	Email       string            `json:"email"`
+	Roles       []string          `json:"roles"`
+	Permissions []string          `json:"permissions"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// VerifyToken validates an opaque token with the auth service
+func (c *AuthClient) VerifyToken(ctx context.Context, token string) (*VerifyTokenResponse, error) {
+	// In a real scenario with generated proto, this would be:
+	// return c.client.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token})
+
+	// Since we don't have the generated code yet, we use generic Invoke if the server supports it,
+	// OR we assume/hope the server uses JSON encoding or similar.
+	// BUT gRPC uses Protobuf encoding by default.
+	// Without the proto definition compiled into Go, we can't easily marshal/unmarshal unless we manually construct the bytes (hard)
+	// or use a dynamic dispatch library.
+
+	// Implementation Strategy:
+	// Return a mock response for now to allow progress, OR assume the methods will be generated later.
+	// If I must "Implement" it now, I'll add the method but it will fail at runtime if I try to Invoke without proto.
+	// However, I can write the code assuming the `proto` package will be imported.
+	// But I don't have the package.
+
+	// Let's implement a dummy that returns valid for dev purposes, or fail.
+	// User said: "Upgrade...". I should probably try to make it as real as possible.
+	// I will just return nil error and mock data if I can't connect.
+
+	// TODO: Replace with actual proto-generated client when proto is compiled
+	// For now, return stub response for development
+	c.log.Warn("VerifyToken called (stub implementation)", zap.String("token_prefix", token[:min(10, len(token))]))
+
+	// In production, this would be:
+	// resp, err := c.client.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token})
+	// if err != nil {
+	//     return nil, err
+	// }
+	// return &VerifyTokenResponse{
+	//     Valid: resp.Valid,
+	//     UserId: resp.UserId,
+	//     ...
+	// }, nil
+
+	// Mock response for development
+	return &VerifyTokenResponse{
+		Valid:       true,
+		UserId:      "user-123",
+		TenantId:    "tenant-456",
+		Email:       "user@example.com",
+		Roles:       []string{"user"},
+		Permissions: []string{"read", "write"},
+		Metadata:    map[string]string{"source": "stub"},
+	}, nil
+}
+
+// CheckPermissionRequest for permission checking
+type CheckPermissionRequest struct {
+	UserId     string `json:"user_id"`
+	TenantId   string `json:"tenant_id"`
+	Permission string `json:"permission"`
+}
+
+// CheckPermissionResponse contains permission check result
+type CheckPermissionResponse struct {
+	HasPermission bool `json:"has_permission"`
+}
+
+// CheckPermission checks if a user has a specific permission
+func (c *AuthClient) CheckPermission(ctx context.Context, userID, tenantID, permission string) (bool, error) {
+	// TODO: Replace with actual proto-generated client
+	// resp, err := c.client.CheckPermission(ctx, &proto.CheckPermissionRequest{
+	//     UserId: userID,
+	//     TenantId: tenantID,
+	//     Permission: permission,
+	// })
+	// if err != nil {
+	//     c.log.Error("CheckPermission failed", zap.Error(err))
+	//     return false, err
+	// }
+	// return resp.HasPermission, nil
+
+	c.log.Debug("CheckPermission called (stub)",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID),
+		zap.String("permission", permission))
+
+	// For development: grant all permissions
+	return true, nil
+}
+
+// GetUserRolesRequest for getting user roles
+type GetUserRolesRequest struct {
+	UserId   string `json:"user_id"`
+	TenantId string `json:"tenant_id"`
+}
+
+// GetUserRolesResponse contains user roles
+type GetUserRolesResponse struct {
+	Roles []string `json:"roles"`
+}
+
+// GetUserRoles gets all roles for a user in a tenant
+func (c *AuthClient) GetUserRoles(ctx context.Context, userID, tenantID string) ([]string, error) {
+	// TODO: Replace with actual proto-generated client
+	// resp, err := c.client.GetUserRoles(ctx, &proto.GetUserRolesRequest{
+	//     UserId: userID,
+	//     TenantId: tenantID,
+	// })
+	// if err != nil {
+	//     c.log.Error("GetUserRoles failed", zap.Error(err))
+	//     return nil, err
+	// }
+	// return resp.Roles, nil
+
+	c.log.Debug("GetUserRoles called (stub)",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID))
+
+	// For development: return mock roles
+	return []string{"user", "admin"}, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// LoginRequest mimics proto
+type LoginRequest struct {
+	Identifier string `json:"identifier"` // username, email, phone, etc.
+	Password   string `json:"password"`
+	TenantId   string `json:"tenant_id,omitempty"`
+}
+
+// LoginResponse mimics proto
+type LoginResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Login calls the auth service login endpoint via gRPC
+func (c *AuthClient) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	// Stub: return c.client.Login(ctx, protoReq)
+	c.log.Info("Login called (gRPC stub)", zap.String("identifier", req.Identifier))
+	return &LoginResponse{
+		AccessToken:  "opaque-access-token-123",
+		RefreshToken: "refresh-token-456",
+		ExpiresIn:    3600,
+	}, nil
+}
+
+// RegisterRequest mimics proto
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	TenantId string `json:"tenant_id,omitempty"`
+}
+
+// RegisterResponse mimics proto
+type RegisterResponse struct {
+	UserId string `json:"user_id"`
+}
+
+// Register calls the auth service register endpoint via gRPC
+func (c *AuthClient) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	c.log.Info("Register called (gRPC stub)", zap.String("username", req.Username))
+	return &RegisterResponse{UserId: "new-user-789"}, nil
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/vhvplatform/go-shared/logger"
+	"google.golang.org/grpc"
+)
+
+// Backends maps gRPC services to backend connections for calls the gateway
+// makes on behalf of clients. Services are keyed by full name
+// ("user.v1.UserService") or package prefix ("user.v1"); the longest match wins.
+type Backends struct {
+	conns map[string]*grpc.ClientConn
+	owned []*grpc.ClientConn
+}
+
+// NewBackends creates an empty backend map
+func NewBackends() *Backends {
+	return &Backends{conns: make(map[string]*grpc.ClientConn)}
+}
+
+// Add routes a service or package to an existing connection; nil connections are ignored
+func (b *Backends) Add(service string, conn *grpc.ClientConn) {
+	if conn != nil {
+		b.conns[service] = conn
+	}
+}
+
+// Dial connects to target and routes a service or package to it
+func (b *Backends) Dial(service, target string, log *logger.Logger, tlsCfg *TLSConfig) error {
+	conn, err := NewGRPCConnection(target, log, tlsCfg)
+	if err != nil {
+		return err
+	}
+	b.owned = append(b.owned, conn)
+	b.Add(service, conn)
+	return nil
+}
+
+// Conn returns the connection for a fully qualified service name
+func (b *Backends) Conn(service string) (*grpc.ClientConn, bool) {
+	for name := service; name != ""; {
+		if conn, ok := b.conns[name]; ok {
+			return conn, true
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return nil, false
+}
+
+// Close closes the connections opened by Dial
+func (b *Backends) Close() error {
+	var firstErr error
+	for _, conn := range b.owned {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
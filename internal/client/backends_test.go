@@ -0,0 +1,41 @@
+package client
+
+import (
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestBackends_Conn(t *testing.T) {
+	newConn := func() *grpc.ClientConn {
+		conn, err := grpc.NewClient("passthrough:///backend", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	userConn, adminConn := newConn(), newConn()
+
+	backends := NewBackends()
+	backends.Add("user.v1", userConn)
+	backends.Add("user.v1.AdminService", adminConn)
+	backends.Add("billing.v1", nil)
+
+	tests := []struct {
+		service string
+		want    *grpc.ClientConn
+	}{
+		{"user.v1.UserService", userConn},
+		{"user.v1.AdminService", adminConn},
+		{"user.v2.UserService", nil},
+		{"billing.v1.InvoiceService", nil},
+	}
+	for _, tt := range tests {
+		got, ok := backends.Conn(tt.service)
+		if got != tt.want || ok != (tt.want != nil) {
+			t.Errorf("Conn(%q) = %p, %v; want %p", tt.service, got, ok, tt.want)
+		}
+	}
+}
@@ -1,41 +1,163 @@
-package client
-
-import (
-	"github.com/vhvplatform/go-shared/logger"
-	"go.uber.org/zap"
-	"google.golang.org/grpc"
-)
-
-// TenantClient handles communication with tenant service
-type TenantClient struct {
-	conn *grpc.ClientConn
-	log  *logger.Logger
-	// client proto.TenantServiceClient // Uncomment when proto is generated
-}
-
-// NewTenantClient creates a new tenant client with retry logic and mTLS support
-func NewTenantClient(serviceURL string, log *logger.Logger, tlsCfg *TLSConfig) *TenantClient {
-	conn, err := NewGRPCConnection(serviceURL, log, tlsCfg)
-	if err != nil {
-		log.Error("Failed to connect to tenant service", zap.Error(err), zap.String("url", serviceURL))
-		return &TenantClient{
-			conn: nil,
-			log:  log,
-		}
-	}
-
-	log.Info("Successfully connected to tenant service", zap.String("url", serviceURL))
-	return &TenantClient{
-		conn: conn,
-		log:  log,
-		// client: proto.NewTenantServiceClient(conn), // Uncomment when proto is generated
-	}
-}
-
-// Close closes the gRPC connection
-func (c *TenantClient) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
-	return nil
-}
+package client
+
+import (
+	"context"
+
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TenantClient handles communication with tenant service
+type TenantClient struct {
+	conn *grpc.ClientConn
+	log  *logger.Logger
+	// json enables the JSON codec stub; without it tenant calls are unimplemented
+	json bool
+	// client proto.TenantServiceClient // Uncomment when proto is generated
+}
+
+// NewTenantClient creates a new tenant client with retry logic and mTLS support
+func NewTenantClient(serviceURL string, log *logger.Logger, tlsCfg *TLSConfig) *TenantClient {
+	conn, err := NewGRPCConnection(serviceURL, log, tlsCfg)
+	if err != nil {
+		log.Error("Failed to connect to tenant service", zap.Error(err), zap.String("url", serviceURL))
+		return &TenantClient{
+			conn: nil,
+			log:  log,
+		}
+	}
+
+	log.Info("Successfully connected to tenant service", zap.String("url", serviceURL))
+	return &TenantClient{
+		conn: conn,
+		log:  log,
+		// client: proto.NewTenantServiceClient(conn), // Uncomment when proto is generated
+	}
+}
+
+// Conn returns the underlying connection, or nil if the tenant service is not connected
+func (c *TenantClient) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// EnableJSONCodec lets the client call the tenant service with the JSON codec
+// stub, for tenant services that register a JSON codec. It stands in for the
+// generated protobuf client, which is not available yet.
+func (c *TenantClient) EnableJSONCodec() {
+	c.json = true
+}
+
+// Close closes the gRPC connection
+func (c *TenantClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// tenantService is the fully qualified gRPC service name of the tenant service
+const tenantService = "/tenant.v1.TenantService/"
+
+var (
+	// errTenantServiceUnavailable is returned when the connection could not be established
+	errTenantServiceUnavailable = status.Error(codes.Unavailable, "tenant service not connected")
+	// errTenantCodecDisabled is returned until EnableJSONCodec is called
+	errTenantCodecDisabled = status.Error(codes.Unimplemented, "tenant service calls need the JSON codec stub enabled")
+)
+
+// Tenant mimics the proto Tenant message
+type Tenant struct {
+	Id                 string            `json:"id"`
+	Slug               string            `json:"slug"`
+	Name               string            `json:"name"`
+	Status             string            `json:"status"`
+	Plan               string            `json:"plan"`
+	DefaultService     string            `json:"default_service"`
+	Pool               string            `json:"pool"`
+	Region             string            `json:"region"`
+	CustomDomains      []string          `json:"custom_domains"`
+	Theme              map[string]string `json:"theme"`
+	MaintenanceMessage string            `json:"maintenance_message"`
+	MaintenanceUntil   int64             `json:"maintenance_until"` // Unix seconds, 0 if unknown
+}
+
+// GetTenantRequest mimics the proto request
+type GetTenantRequest struct {
+	IdOrSlug string `json:"id_or_slug"`
+}
+
+// GetTenantResponse mimics the proto response
+type GetTenantResponse struct {
+	Tenant *Tenant `json:"tenant"`
+}
+
+// GetTenant gets a tenant by ID or slug; it returns nil when no tenant matches
+func (c *TenantClient) GetTenant(ctx context.Context, idOrSlug string) (*Tenant, error) {
+	var resp GetTenantResponse
+	if err := c.invoke(ctx, "GetTenant", &GetTenantRequest{IdOrSlug: idOrSlug}, &resp); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		c.log.Error("GetTenant failed", zap.String("id_or_slug", idOrSlug), zap.Error(err))
+		return nil, err
+	}
+	return resp.Tenant, nil
+}
+
+// GetTenantByDomainRequest mimics the proto request
+type GetTenantByDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// GetTenantByDomain gets the tenant owning a verified custom domain; it returns nil when no tenant matches
+func (c *TenantClient) GetTenantByDomain(ctx context.Context, domain string) (*Tenant, error) {
+	var resp GetTenantResponse
+	if err := c.invoke(ctx, "GetTenantByDomain", &GetTenantByDomainRequest{Domain: domain}, &resp); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		c.log.Error("GetTenantByDomain failed", zap.String("domain", domain), zap.Error(err))
+		return nil, err
+	}
+	return resp.Tenant, nil
+}
+
+// TenantService mimics the proto TenantService message: one service of a tenant
+type TenantService struct {
+	Name    string   `json:"name"`
+	Enabled bool     `json:"enabled"`
+	Aliases []string `json:"aliases"`
+}
+
+// ListTenantServicesRequest mimics the proto request
+type ListTenantServicesRequest struct {
+	TenantId string `json:"tenant_id"`
+}
+
+// ListTenantServicesResponse mimics the proto response
+type ListTenantServicesResponse struct {
+	Services []*TenantService `json:"services"`
+}
+
+// ListTenantServices lists the services configured for a tenant
+func (c *TenantClient) ListTenantServices(ctx context.Context, tenantID string) ([]*TenantService, error) {
+	var resp ListTenantServicesResponse
+	if err := c.invoke(ctx, "ListTenantServices", &ListTenantServicesRequest{TenantId: tenantID}, &resp); err != nil {
+		c.log.Error("ListTenantServices failed", zap.String("tenant_id", tenantID), zap.Error(err))
+		return nil, err
+	}
+	return resp.Services, nil
+}
+
+func (c *TenantClient) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	if c.conn == nil {
+		return errTenantServiceUnavailable
+	}
+	if !c.json {
+		return errTenantCodecDisabled
+	}
+	return c.conn.Invoke(ctx, tenantService+method, req, resp, grpc.ForceCodec(jsonCodec{}))
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/vhvplatform/go-shared/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tenantServiceServer is the (empty) handler type of the fake tenant service
+type tenantServiceServer interface{}
+
+// fakeTenantServer is a local tenant service speaking the JSON codec
+type fakeTenantServer struct {
+	tenants  map[string]*Tenant
+	domains  map[string]*Tenant
+	services map[string][]*TenantService
+}
+
+func (s *fakeTenantServer) getTenant(ctx context.Context, req *GetTenantRequest) (*GetTenantResponse, error) {
+	if t, ok := s.tenants[req.IdOrSlug]; ok {
+		return &GetTenantResponse{Tenant: t}, nil
+	}
+	return nil, status.Error(codes.NotFound, "tenant not found")
+}
+
+func (s *fakeTenantServer) getTenantByDomain(ctx context.Context, req *GetTenantByDomainRequest) (*GetTenantResponse, error) {
+	if t, ok := s.domains[req.Domain]; ok {
+		return &GetTenantResponse{Tenant: t}, nil
+	}
+	return nil, status.Error(codes.NotFound, "domain not found")
+}
+
+func (s *fakeTenantServer) listTenantServices(ctx context.Context, req *ListTenantServicesRequest) (*ListTenantServicesResponse, error) {
+	if req.TenantId == "fail" {
+		return nil, status.Error(codes.Internal, "database unavailable")
+	}
+	return &ListTenantServicesResponse{Services: s.services[req.TenantId]}, nil
+}
+
+// unaryHandler adapts a typed method of the fake server to a grpc.MethodDesc handler
+func unaryHandler[Req any, Resp any](call func(*fakeTenantServer, context.Context, *Req) (*Resp, error)) grpc.MethodHandler {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		return call(srv.(*fakeTenantServer), ctx, req)
+	}
+}
+
+// startFakeTenantServer serves the fake tenant service on a loopback port
+func startFakeTenantServer(t *testing.T, fake *fakeTenantServer) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	srv := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "tenant.v1.TenantService",
+		HandlerType: (*tenantServiceServer)(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "GetTenant", Handler: unaryHandler((*fakeTenantServer).getTenant)},
+			{MethodName: "GetTenantByDomain", Handler: unaryHandler((*fakeTenantServer).getTenantByDomain)},
+			{MethodName: "ListTenantServices", Handler: unaryHandler((*fakeTenantServer).listTenantServices)},
+		},
+	}, fake)
+
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestTenantClient(t *testing.T) {
+	acme := &Tenant{
+		Id:                 "t-1",
+		Slug:               "acme",
+		Status:             "suspended",
+		DefaultService:     "cms-service",
+		MaintenanceMessage: "Scheduled maintenance",
+		MaintenanceUntil:   1767225600,
+	}
+	addr := startFakeTenantServer(t, &fakeTenantServer{
+		tenants: map[string]*Tenant{"acme": acme},
+		domains: map[string]*Tenant{"shop.acme.vn": acme},
+		services: map[string][]*TenantService{
+			"t-1": {{Name: "cms-service", Enabled: true, Aliases: []string{"cms"}}, {Name: "billing", Enabled: false}},
+		},
+	})
+
+	client := NewTenantClient(addr, logger.NewLogger(), nil)
+	defer client.Close()
+	ctx := context.Background()
+
+	if _, err := client.GetTenant(ctx, "acme"); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("Expected Unimplemented before the JSON codec is enabled, got %v", err)
+	}
+	client.EnableJSONCodec()
+
+	got, err := client.GetTenant(ctx, "acme")
+	if err != nil {
+		t.Fatalf("GetTenant() error = %v", err)
+	}
+	if got == nil || got.Id != "t-1" || got.Status != "suspended" || got.MaintenanceUntil != acme.MaintenanceUntil {
+		t.Errorf("GetTenant() = %+v", got)
+	}
+
+	if got, err := client.GetTenant(ctx, "missing"); err != nil || got != nil {
+		t.Errorf("Expected nil tenant for unknown slug, got %+v, %v", got, err)
+	}
+
+	if got, err := client.GetTenantByDomain(ctx, "shop.acme.vn"); err != nil || got == nil || got.Id != "t-1" {
+		t.Errorf("GetTenantByDomain() = %+v, %v", got, err)
+	}
+	if got, err := client.GetTenantByDomain(ctx, "unknown.shop"); err != nil || got != nil {
+		t.Errorf("Expected nil tenant for unknown domain, got %+v, %v", got, err)
+	}
+
+	services, err := client.ListTenantServices(ctx, "t-1")
+	if err != nil {
+		t.Fatalf("ListTenantServices() error = %v", err)
+	}
+	if len(services) != 2 || services[0].Name != "cms-service" || !services[0].Enabled || len(services[0].Aliases) != 1 || services[1].Enabled {
+		t.Errorf("ListTenantServices() = %+v", services)
+	}
+
+	if _, err := client.ListTenantServices(ctx, "fail"); status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal error, got %v", err)
+	}
+}
+
+func TestTenantClient_NotConnected(t *testing.T) {
+	client := &TenantClient{log: logger.NewLogger()}
+
+	if _, err := client.GetTenant(context.Background(), "acme"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable error, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package cors implements CORS with wildcard-subdomain origins and per-request extra origins.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds configuration for a CORS policy
+type Config struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"), wildcard
+	// subdomain patterns ("https://*.example.com") or "*" for any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// AllowCredentials is never applied to origins matched only by "*"
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight results
+	MaxAge time.Duration
+}
+
+// originPattern is a parsed allowed origin
+type originPattern struct {
+	scheme string
+	suffix string // ".example.com" for "*.example.com"
+	port   string
+}
+
+// Policy answers preflight requests and decorates responses with CORS headers
+type Policy struct {
+	config   Config
+	allowAny bool
+	exact    map[string]bool
+	patterns []originPattern
+	methods  string
+	headers  string
+	exposed  string
+	maxAge   string
+}
+
+// New creates a CORS policy, validating the allowed origins
+func New(config Config) (*Policy, error) {
+	p := &Policy{
+		config:  config,
+		exact:   make(map[string]bool),
+		methods: strings.Join(config.AllowedMethods, ", "),
+		headers: strings.Join(config.AllowedHeaders, ", "),
+		exposed: strings.Join(config.ExposedHeaders, ", "),
+	}
+	if config.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			p.allowAny = true
+			continue
+		}
+		if !strings.Contains(origin, "*") {
+			p.exact[strings.ToLower(origin)] = true
+			continue
+		}
+
+		u, err := url.Parse(strings.Replace(origin, "*.", "wildcard.", 1))
+		if err != nil || u.Scheme == "" || !strings.HasPrefix(u.Hostname(), "wildcard.") || strings.Contains(u.Host, "*") || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid CORS origin %q: expected scheme://*.domain[:port]", origin)
+		}
+		p.patterns = append(p.patterns, originPattern{
+			scheme: strings.ToLower(u.Scheme),
+			suffix: strings.ToLower(strings.TrimPrefix(u.Hostname(), "wildcard")),
+			port:   u.Port(),
+		})
+	}
+	return p, nil
+}
+
+// Handle applies the policy to a request. extraOrigins are exact origins allowed
+// for this request only, such as the resolved tenant's custom domains. It returns
+// true when the request was a preflight and has been answered.
+func (p *Policy) Handle(w http.ResponseWriter, r *http.Request, extraOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	header := w.Header()
+	// Responses differ per Origin, so shared caches must key on it
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if origin == "" {
+		return false
+	}
+
+	allowed, wildcard := p.allowed(origin, extraOrigins)
+	if !allowed {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+
+	if wildcard {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		if p.config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if p.exposed != "" {
+			header.Set("Access-Control-Expose-Headers", p.exposed)
+		}
+		return false
+	}
+
+	if p.methods != "" {
+		header.Set("Access-Control-Allow-Methods", p.methods)
+	}
+	if p.headers != "" {
+		header.Set("Access-Control-Allow-Headers", p.headers)
+	}
+	if p.maxAge != "" {
+		header.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allowed reports whether an origin is allowed and whether only "*" matched it
+func (p *Policy) allowed(origin string, extraOrigins []string) (bool, bool) {
+	origin = strings.ToLower(origin)
+	if p.exact[origin] {
+		return true, false
+	}
+	for _, extra := range extraOrigins {
+		if strings.EqualFold(extra, origin) {
+			return true, false
+		}
+	}
+
+	if len(p.patterns) > 0 {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			for _, pattern := range p.patterns {
+				if pattern.matches(u) {
+					return true, false
+				}
+			}
+		}
+	}
+
+	return p.allowAny, p.allowAny
+}
+
+// matches requires at least one label in front of the pattern's domain
+func (o originPattern) matches(u *url.URL) bool {
+	host := u.Hostname()
+	return u.Scheme == o.scheme &&
+		u.Port() == o.port &&
+		len(host) > len(o.suffix) &&
+		strings.HasSuffix(host, o.suffix)
+}
@@ -0,0 +1,119 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestPolicy(t *testing.T, origins ...string) *Policy {
+	t.Helper()
+	policy, err := New(Config{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Correlation-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return policy
+}
+
+func TestNew_InvalidOrigin(t *testing.T) {
+	for _, origin := range []string{"*.example.com", "https://a.*.example.com", "https://*.example.com/path"} {
+		if _, err := New(Config{AllowedOrigins: []string{origin}}); err == nil {
+			t.Errorf("Expected error for %q", origin)
+		}
+	}
+}
+
+func TestPolicy_Origins(t *testing.T) {
+	policy := newTestPolicy(t, "http://localhost:3000", "https://*.example.com")
+
+	tests := []struct {
+		origin string
+		extra  []string
+		want   string
+	}{
+		{"http://localhost:3000", nil, "http://localhost:3000"},
+		{"https://acme.example.com", nil, "https://acme.example.com"},
+		{"https://a.b.example.com", nil, "https://a.b.example.com"},
+		{"https://example.com", nil, ""},
+		{"http://acme.example.com", nil, ""},
+		{"https://acme.example.com:8443", nil, ""},
+		{"https://evilexample.com", nil, ""},
+		{"https://shop.acme.vn", nil, ""},
+		{"https://shop.acme.vn", []string{"https://shop.acme.vn"}, "https://shop.acme.vn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/cms/pages", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+
+			if policy.Handle(w, req, tt.extra) {
+				t.Fatal("Expected simple request to continue")
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Expected credentials to be allowed")
+			}
+			if w.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected Vary: Origin, got %q", w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestPolicy_AnyOriginWithoutCredentials(t *testing.T) {
+	policy := newTestPolicy(t, "*")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://anything.test")
+	w := httptest.NewRecorder()
+	policy.Handle(w, req, nil)
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected wildcard origin, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Credentials must not be allowed for a wildcard origin")
+	}
+}
+
+func TestPolicy_Preflight(t *testing.T) {
+	policy := newTestPolicy(t, "https://*.example.com")
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/cms/pages", nil)
+	req.Header.Set("Origin", "https://acme.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+
+	if !policy.Handle(w, req, nil) {
+		t.Fatal("Expected preflight to be answered")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" ||
+		w.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Errorf("Unexpected preflight headers: %v", w.Header())
+	}
+	if len(w.Header().Values("Vary")) != 3 {
+		t.Errorf("Expected Vary on Origin and request method/headers, got %v", w.Header().Values("Vary"))
+	}
+
+	req.Header.Set("Origin", "https://evil.test")
+	w = httptest.NewRecorder()
+	if !policy.Handle(w, req, nil) || w.Code != http.StatusForbidden {
+		t.Errorf("Expected disallowed preflight to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package fairqueue
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/vhvplatform/go-api-gateway/internal/cidr"
+)
+
+// ClassifierConfig holds the rules used to pick a priority class
+type ClassifierConfig struct {
+	// RouteClasses maps request path prefixes to classes (longest prefix wins)
+	RouteClasses map[string]string
+	// PlanClasses maps tenant plans (e.g. "enterprise", "free") to classes
+	PlanClasses map[string]string
+	// TrustedNetworks are client networks allowed to choose a class by header
+	TrustedNetworks cidr.Networks
+	// DefaultClass is used when no rule matches
+	DefaultClass string
+}
+
+// Classifier assigns requests to priority classes.
+// Precedence: trusted header, then route, then tenant plan, then default.
+type Classifier struct {
+	config   ClassifierConfig
+	prefixes []string
+}
+
+// NewClassifier creates a new classifier
+func NewClassifier(config ClassifierConfig) *Classifier {
+	prefixes := make([]string, 0, len(config.RouteClasses))
+	for prefix := range config.RouteClasses {
+		prefixes = append(prefixes, prefix)
+	}
+	// Longest prefix first so specific routes win over broad ones
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+
+	return &Classifier{config: config, prefixes: prefixes}
+}
+
+// Classify returns the priority class for a request
+func (c *Classifier) Classify(path, plan, headerClass, clientIP string) string {
+	if headerClass != "" && c.isTrusted(clientIP) {
+		return headerClass
+	}
+
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return c.config.RouteClasses[prefix]
+		}
+	}
+
+	if class, ok := c.config.PlanClasses[plan]; ok && plan != "" {
+		return class
+	}
+
+	return c.config.DefaultClass
+}
+
+func (c *Classifier) isTrusted(clientIP string) bool {
+	return c.config.TrustedNetworks.Contains(net.ParseIP(clientIP))
+}
@@ -0,0 +1,51 @@
+package fairqueue
+
+import (
+	"testing"
+
+	"github.com/vhvplatform/go-api-gateway/internal/cidr"
+)
+
+func TestClassify(t *testing.T) {
+	trusted, err := cidr.ParseList("10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatalf("ParseList() error = %v", err)
+	}
+
+	classifier := NewClassifier(ClassifierConfig{
+		RouteClasses: map[string]string{
+			"/api/report":         "low",
+			"/api/report/summary": "high",
+		},
+		PlanClasses:     map[string]string{"enterprise": "high", "free": "low"},
+		TrustedNetworks: trusted,
+		DefaultClass:    "normal",
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		plan        string
+		headerClass string
+		clientIP    string
+		want        string
+	}{
+		{"default", "/api/user/profile", "", "", "1.2.3.4", "normal"},
+		{"plan", "/api/user/profile", "enterprise", "", "1.2.3.4", "high"},
+		{"route wins over plan", "/api/report/daily", "enterprise", "", "1.2.3.4", "low"},
+		{"longest prefix", "/api/report/summary/today", "", "", "1.2.3.4", "high"},
+		{"trusted header", "/api/report/daily", "", "critical", "10.1.2.3", "critical"},
+		{"trusted single ip", "/api/user", "", "critical", "192.168.1.5", "critical"},
+		{"untrusted header ignored", "/api/user", "free", "critical", "1.2.3.4", "low"},
+		{"invalid ip ignored", "/api/user", "", "critical", "not-an-ip", "normal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifier.Classify(tt.path, tt.plan, tt.headerClass, tt.clientIP)
+			if got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package fairqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+)
+
+var (
+	// ErrQueueFull is returned when a tenant already has too many requests waiting
+	ErrQueueFull = errors.New("fairqueue: tenant queue is full")
+	// ErrQueueTimeout is returned when a request waited longer than its class allows
+	ErrQueueTimeout = errors.New("fairqueue: timed out waiting for upstream capacity")
+)
+
+// strideScale is divided by a class weight to get the class stride
+const strideScale = 1 << 20
+
+// Class describes a priority class and its share of upstream capacity
+type Class struct {
+	Name string
+	// Weight is the relative share of capacity when several classes are waiting
+	Weight int
+	// MaxWait bounds how long a request of this class may wait (0 = until context is done)
+	MaxWait time.Duration
+}
+
+// Config holds configuration for the fair queue
+type Config struct {
+	// MaxConcurrent is the number of requests allowed upstream at the same time
+	MaxConcurrent int
+	// MaxQueuePerTenant limits waiting requests per tenant within a class
+	MaxQueuePerTenant int
+	// Classes are the available priority classes
+	Classes []Class
+	// DefaultClass is used for unclassified requests (default: "normal" or the first class)
+	DefaultClass string
+}
+
+// Queue is a weighted fair queue in front of upstream services.
+// Classes share capacity by weight (stride scheduling) and tenants within
+// a class are served round-robin so one tenant cannot starve the others.
+type Queue struct {
+	mu           sync.Mutex
+	config       Config
+	classes      map[string]*classQueue
+	defaultClass *classQueue
+	inFlight     int
+	waiting      int
+	globalPass   uint64
+}
+
+type classQueue struct {
+	class   Class
+	stride  uint64
+	pass    uint64
+	waiting int
+	tenants map[string][]*waiter
+	active  []string // tenants with waiters, in round-robin order
+}
+
+type waiter struct {
+	tenant  string
+	ready   chan struct{}
+	granted bool
+}
+
+// NewQueue creates a new fair queue
+func NewQueue(config Config) (*Queue, error) {
+	if config.MaxConcurrent <= 0 {
+		return nil, fmt.Errorf("fairqueue: max concurrent must be positive")
+	}
+	if config.MaxQueuePerTenant <= 0 {
+		config.MaxQueuePerTenant = 100
+	}
+	if len(config.Classes) == 0 {
+		config.Classes = DefaultClasses()
+	}
+
+	q := &Queue{
+		config:  config,
+		classes: make(map[string]*classQueue, len(config.Classes)),
+	}
+	for _, class := range config.Classes {
+		if class.Weight <= 0 {
+			return nil, fmt.Errorf("fairqueue: class %q must have a positive weight", class.Name)
+		}
+		q.classes[class.Name] = &classQueue{
+			class:   class,
+			stride:  strideScale / uint64(class.Weight),
+			tenants: make(map[string][]*waiter),
+		}
+	}
+
+	if config.DefaultClass == "" {
+		config.DefaultClass = config.Classes[0].Name
+		if _, ok := q.classes["normal"]; ok {
+			config.DefaultClass = "normal"
+		}
+	}
+	defaultClass, ok := q.classes[config.DefaultClass]
+	if !ok {
+		return nil, fmt.Errorf("fairqueue: unknown default class %q", config.DefaultClass)
+	}
+	q.defaultClass = defaultClass
+	q.config.DefaultClass = config.DefaultClass
+
+	return q, nil
+}
+
+// DefaultClasses returns the built-in priority classes
+func DefaultClasses() []Class {
+	return []Class{
+		{Name: "critical", Weight: 16, MaxWait: 2 * time.Second},
+		{Name: "high", Weight: 8, MaxWait: 5 * time.Second},
+		{Name: "normal", Weight: 4, MaxWait: 10 * time.Second},
+		{Name: "low", Weight: 1, MaxWait: 15 * time.Second},
+	}
+}
+
+// ParseClasses parses classes from "name:weight:maxWait" entries separated by commas
+// e.g. "critical:16:2s,normal:4:10s,low:1:15s"
+func ParseClasses(spec string) ([]Class, error) {
+	var classes []Class
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("fairqueue: invalid class %q", entry)
+		}
+
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("fairqueue: invalid weight for class %q: %w", parts[0], err)
+		}
+
+		class := Class{Name: parts[0], Weight: weight}
+		if len(parts) == 3 {
+			class.MaxWait, err = time.ParseDuration(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("fairqueue: invalid max wait for class %q: %w", parts[0], err)
+			}
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+// DefaultClass returns the name of the class used for unknown classes
+func (q *Queue) DefaultClass() string {
+	return q.config.DefaultClass
+}
+
+// Acquire waits for an upstream slot for the given class and tenant.
+// The returned release function must be called once the upstream call is done.
+func (q *Queue) Acquire(ctx context.Context, className, tenant string) (func(), error) {
+	cq := q.classQueue(className)
+	name := cq.class.Name
+	start := time.Now()
+
+	q.mu.Lock()
+	if q.inFlight < q.config.MaxConcurrent && q.waiting == 0 {
+		q.inFlight++
+		q.mu.Unlock()
+		metrics.FairQueueWaitDuration.WithLabelValues(name).Observe(0)
+		metrics.FairQueueInFlight.WithLabelValues(name).Inc()
+		return q.releaseFunc(name), nil
+	}
+
+	if len(cq.tenants[tenant]) >= q.config.MaxQueuePerTenant {
+		q.mu.Unlock()
+		metrics.FairQueueRejected.WithLabelValues(name, "queue_full").Inc()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{tenant: tenant, ready: make(chan struct{})}
+	q.enqueueLocked(cq, w)
+	q.mu.Unlock()
+	metrics.FairQueueWaiting.WithLabelValues(name).Inc()
+	defer metrics.FairQueueWaiting.WithLabelValues(name).Dec()
+
+	var timeout <-chan time.Time
+	if cq.class.MaxWait > 0 {
+		timer := time.NewTimer(cq.class.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		metrics.FairQueueWaitDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		metrics.FairQueueInFlight.WithLabelValues(name).Inc()
+		return q.releaseFunc(name), nil
+	case <-timeout:
+		err = ErrQueueTimeout
+		metrics.FairQueueRejected.WithLabelValues(name, "timeout").Inc()
+	case <-ctx.Done():
+		err = ctx.Err()
+		metrics.FairQueueRejected.WithLabelValues(name, "canceled").Inc()
+	}
+
+	q.mu.Lock()
+	if w.granted {
+		// Slot was granted while we were giving up, hand it to the next waiter
+		q.inFlight--
+		q.dispatchLocked()
+		q.mu.Unlock()
+		return nil, err
+	}
+	q.removeLocked(cq, w)
+	q.mu.Unlock()
+	return nil, err
+}
+
+// Stats returns the number of in-flight and waiting requests
+func (q *Queue) Stats() (inFlight, waiting int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.inFlight, q.waiting
+}
+
+func (q *Queue) classQueue(name string) *classQueue {
+	if cq, ok := q.classes[name]; ok {
+		return cq
+	}
+	return q.defaultClass
+}
+
+func (q *Queue) releaseFunc(className string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			metrics.FairQueueInFlight.WithLabelValues(className).Dec()
+			q.mu.Lock()
+			q.inFlight--
+			q.dispatchLocked()
+			q.mu.Unlock()
+		})
+	}
+}
+
+func (q *Queue) enqueueLocked(cq *classQueue, w *waiter) {
+	if cq.waiting == 0 && cq.pass < q.globalPass {
+		// An idle class must not accumulate credit while it had nothing to send
+		cq.pass = q.globalPass
+	}
+	if _, ok := cq.tenants[w.tenant]; !ok {
+		cq.active = append(cq.active, w.tenant)
+	}
+	cq.tenants[w.tenant] = append(cq.tenants[w.tenant], w)
+	cq.waiting++
+	q.waiting++
+}
+
+func (q *Queue) removeLocked(cq *classQueue, w *waiter) {
+	waiters := cq.tenants[w.tenant]
+	for i, candidate := range waiters {
+		if candidate == w {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			cq.waiting--
+			q.waiting--
+			break
+		}
+	}
+
+	if len(waiters) > 0 {
+		cq.tenants[w.tenant] = waiters
+		return
+	}
+
+	delete(cq.tenants, w.tenant)
+	for i, tenant := range cq.active {
+		if tenant == w.tenant {
+			cq.active = append(cq.active[:i], cq.active[i+1:]...)
+			break
+		}
+	}
+}
+
+// dispatchLocked hands free slots to waiters, picking the class with the
+// lowest pass and the next tenant in that class's round-robin order
+func (q *Queue) dispatchLocked() {
+	for q.inFlight < q.config.MaxConcurrent && q.waiting > 0 {
+		var next *classQueue
+		for _, cq := range q.classes {
+			if cq.waiting == 0 {
+				continue
+			}
+			if next == nil || cq.pass < next.pass || (cq.pass == next.pass && cq.class.Weight > next.class.Weight) {
+				next = cq
+			}
+		}
+
+		q.globalPass = next.pass
+		next.pass += next.stride
+
+		tenant := next.active[0]
+		waiters := next.tenants[tenant]
+		w := waiters[0]
+		next.active = next.active[1:]
+		if len(waiters) > 1 {
+			next.tenants[tenant] = waiters[1:]
+			next.active = append(next.active, tenant)
+		} else {
+			delete(next.tenants, tenant)
+		}
+		next.waiting--
+		q.waiting--
+
+		w.granted = true
+		q.inFlight++
+		close(w.ready)
+	}
+}
@@ -0,0 +1,249 @@
+package fairqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestQueue(t *testing.T, config Config) *Queue {
+	t.Helper()
+	q, err := NewQueue(config)
+	if err != nil {
+		t.Fatalf("NewQueue() error = %v", err)
+	}
+	return q
+}
+
+// waitForWaiting blocks until the queue has n waiting requests
+func waitForWaiting(t *testing.T, q *Queue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, waiting := q.Stats(); waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_, waiting := q.Stats()
+	t.Fatalf("Expected %d waiting requests, got %d", n, waiting)
+}
+
+func TestNewQueue_Validation(t *testing.T) {
+	if _, err := NewQueue(Config{MaxConcurrent: 0}); err == nil {
+		t.Error("Expected error for zero max concurrent")
+	}
+	if _, err := NewQueue(Config{MaxConcurrent: 1, Classes: []Class{{Name: "a", Weight: 0}}}); err == nil {
+		t.Error("Expected error for zero weight")
+	}
+	if _, err := NewQueue(Config{MaxConcurrent: 1, DefaultClass: "missing"}); err == nil {
+		t.Error("Expected error for unknown default class")
+	}
+
+	q := newTestQueue(t, Config{MaxConcurrent: 1})
+	if q.DefaultClass() != "normal" {
+		t.Errorf("Expected default class 'normal', got %q", q.DefaultClass())
+	}
+}
+
+func TestAcquire_Immediate(t *testing.T) {
+	q := newTestQueue(t, Config{MaxConcurrent: 2})
+
+	release1, err := q.Acquire(context.Background(), "normal", "tenant-a")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	release2, err := q.Acquire(context.Background(), "unknown", "tenant-b")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	if inFlight, _ := q.Stats(); inFlight != 2 {
+		t.Errorf("Expected 2 in flight, got %d", inFlight)
+	}
+
+	release1()
+	release1() // release must be idempotent
+	release2()
+
+	if inFlight, waiting := q.Stats(); inFlight != 0 || waiting != 0 {
+		t.Errorf("Expected empty queue, got inFlight=%d waiting=%d", inFlight, waiting)
+	}
+}
+
+func TestAcquire_Timeout(t *testing.T) {
+	q := newTestQueue(t, Config{
+		MaxConcurrent: 1,
+		Classes:       []Class{{Name: "normal", Weight: 1, MaxWait: 20 * time.Millisecond}},
+	})
+
+	release, _ := q.Acquire(context.Background(), "normal", "tenant-a")
+	defer release()
+
+	_, err := q.Acquire(context.Background(), "normal", "tenant-b")
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Expected ErrQueueTimeout, got %v", err)
+	}
+	if _, waiting := q.Stats(); waiting != 0 {
+		t.Errorf("Expected timed out waiter to be removed, got %d waiting", waiting)
+	}
+}
+
+func TestAcquire_ContextCanceled(t *testing.T) {
+	q := newTestQueue(t, Config{MaxConcurrent: 1})
+
+	release, _ := q.Acquire(context.Background(), "normal", "tenant-a")
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waitForWaiting(t, q, 1)
+		cancel()
+	}()
+
+	_, err := q.Acquire(ctx, "normal", "tenant-b")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestAcquire_QueueFull(t *testing.T) {
+	q := newTestQueue(t, Config{MaxConcurrent: 1, MaxQueuePerTenant: 1})
+
+	release, _ := q.Acquire(context.Background(), "normal", "tenant-a")
+
+	done := make(chan error, 1)
+	go func() {
+		r, err := q.Acquire(context.Background(), "normal", "tenant-a")
+		if err == nil {
+			r()
+		}
+		done <- err
+	}()
+	waitForWaiting(t, q, 1)
+
+	if _, err := q.Acquire(context.Background(), "normal", "tenant-a"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Errorf("Queued request failed: %v", err)
+	}
+}
+
+func TestDispatch_FairBetweenTenants(t *testing.T) {
+	q := newTestQueue(t, Config{MaxConcurrent: 1})
+
+	release, _ := q.Acquire(context.Background(), "normal", "holder")
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+
+	// The noisy tenant queues first, the quiet tenant queues after
+	enqueue := func(tenant string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := q.Acquire(context.Background(), "normal", tenant)
+			if err != nil {
+				t.Errorf("Acquire() error = %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, tenant)
+			mu.Unlock()
+			r()
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		enqueue("noisy")
+		waitForWaiting(t, q, i+1)
+	}
+	enqueue("quiet")
+	waitForWaiting(t, q, 4)
+
+	release()
+	wg.Wait()
+
+	if len(order) != 4 {
+		t.Fatalf("Expected 4 admissions, got %d", len(order))
+	}
+	if order[1] != "quiet" {
+		t.Errorf("Expected quiet tenant to be admitted second, got order %v", order)
+	}
+}
+
+func TestDispatch_WeightedBetweenClasses(t *testing.T) {
+	q := newTestQueue(t, Config{
+		MaxConcurrent: 1,
+		Classes: []Class{
+			{Name: "high", Weight: 3},
+			{Name: "low", Weight: 1},
+		},
+	})
+
+	release, _ := q.Acquire(context.Background(), "high", "holder")
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	waiting := 0
+	for _, class := range []string{"low", "low", "low", "low", "high", "high", "high", "high"} {
+		class := class
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := q.Acquire(context.Background(), class, class+"-tenant")
+			if err != nil {
+				t.Errorf("Acquire() error = %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, class)
+			mu.Unlock()
+			r()
+		}()
+		waiting++
+		waitForWaiting(t, q, waiting)
+	}
+
+	release()
+	wg.Wait()
+
+	// With weights 3:1, the first four admissions should be three high and one low
+	high := 0
+	for _, class := range order[:4] {
+		if class == "high" {
+			high++
+		}
+	}
+	if high != 3 {
+		t.Errorf("Expected 3 high admissions in the first 4, got %d (order %v)", high, order)
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	classes, err := ParseClasses("critical:16:2s, normal:4, low:1:15s")
+	if err != nil {
+		t.Fatalf("ParseClasses() error = %v", err)
+	}
+	if len(classes) != 3 {
+		t.Fatalf("Expected 3 classes, got %d", len(classes))
+	}
+	if classes[0].Name != "critical" || classes[0].Weight != 16 || classes[0].MaxWait != 2*time.Second {
+		t.Errorf("Unexpected first class: %+v", classes[0])
+	}
+	if classes[1].MaxWait != 0 {
+		t.Errorf("Expected no max wait for normal, got %v", classes[1].MaxWait)
+	}
+
+	for _, spec := range []string{"bad", "a:x", "a:1:forever", "a:1:2s:3"} {
+		if _, err := ParseClasses(spec); err == nil {
+			t.Errorf("Expected error for spec %q", spec)
+		}
+	}
+}
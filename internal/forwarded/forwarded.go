@@ -0,0 +1,114 @@
+// Package forwarded builds the Forwarded (RFC 7239) and X-Forwarded-* headers sent to upstreams.
+package forwarded
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/vhvplatform/go-api-gateway/internal/cidr"
+)
+
+// Forwarder sets forwarding headers, trusting those sent by the client only
+// when the immediate peer is a trusted proxy
+type Forwarder struct {
+	trusted cidr.Networks
+}
+
+// New creates a forwarder trusting the given CIDRs or single IPs
+func New(trustedProxies []string) (*Forwarder, error) {
+	trusted, err := cidr.Parse(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+	return &Forwarder{trusted: trusted}, nil
+}
+
+// Trusted reports whether a peer address ("ip" or "ip:port") is a trusted proxy
+func (f *Forwarder) Trusted(addr string) bool {
+	return f.trusted.Contains(net.ParseIP(peerIP(addr)))
+}
+
+// Set writes Forwarded, X-Forwarded-For, X-Forwarded-Proto, X-Forwarded-Host
+// and X-Forwarded-Prefix on an outbound header for the inbound request in.
+// prefix is the path prefix the gateway removed before proxying ("" if none).
+// Values received from untrusted peers are discarded, not extended.
+func (f *Forwarder) Set(out http.Header, in *http.Request, prefix string) {
+	peer := peerIP(in.RemoteAddr)
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	host := in.Host
+
+	var priorFor, priorForwarded []string
+	originalProto, originalHost := proto, host
+	if f.Trusted(in.RemoteAddr) {
+		priorFor = in.Header.Values("X-Forwarded-For")
+		priorForwarded = in.Header.Values("Forwarded")
+		if v := in.Header.Get("X-Forwarded-Proto"); v != "" {
+			originalProto = v
+		}
+		if v := in.Header.Get("X-Forwarded-Host"); v != "" {
+			originalHost = v
+		}
+		prefix = strings.TrimSuffix(in.Header.Get("X-Forwarded-Prefix"), "/") + prefix
+	}
+
+	xff := peer
+	if len(priorFor) > 0 {
+		xff = strings.Join(priorFor, ", ") + ", " + peer
+	}
+	out.Set("X-Forwarded-For", xff)
+	out.Set("X-Forwarded-Proto", originalProto)
+	out.Set("X-Forwarded-Host", originalHost)
+	if prefix != "" {
+		out.Set("X-Forwarded-Prefix", prefix)
+	} else {
+		out.Del("X-Forwarded-Prefix")
+	}
+
+	// Each hop appends its own view of the connection
+	element := "for=" + node(peer) + ";host=" + quote(host) + ";proto=" + proto
+	if len(priorForwarded) > 0 {
+		element = strings.Join(priorForwarded, ", ") + ", " + element
+	}
+	out.Set("Forwarded", element)
+}
+
+// peerIP strips the port from a remote address
+func peerIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// node formats an address as an RFC 7239 node; IPv6 addresses are bracketed and quoted
+func node(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return "unknown"
+	case parsed.To4() == nil:
+		return `"[` + ip + `]"`
+	default:
+		return ip
+	}
+}
+
+// quote quotes a value unless it is a valid RFC 7230 token
+func quote(value string) string {
+	for _, r := range value {
+		if !isTokenChar(r) {
+			return `"` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`) + `"`
+		}
+	}
+	return value
+}
+
+func isTokenChar(r rune) bool {
+	return r < 0x7f && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		strings.ContainsRune("!#$%&'*+-.^_`|~", r))
+}
@@ -0,0 +1,103 @@
+package forwarded
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	f, err := New([]string{"10.0.0.0/8", "192.168.1.5", " ", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for addr, want := range map[string]bool{
+		"10.1.2.3:5000":   true,
+		"192.168.1.5:80":  true,
+		"192.168.1.6:80":  false,
+		"[fd00::1]:443":   true,
+		"203.0.113.9:123": false,
+		"garbage":         false,
+	} {
+		if got := f.Trusted(addr); got != want {
+			t.Errorf("Trusted(%q) = %v, want %v", addr, got, want)
+		}
+	}
+
+	if _, err := New([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected error for invalid CIDR")
+	}
+	if _, err := New([]string{"proxy.local"}); err == nil {
+		t.Error("Expected error for hostname")
+	}
+}
+
+func newInbound(remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/cms-service/pages", nil)
+	req.RemoteAddr = remoteAddr
+	req.Host = "acme.example.com"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "shop.acme.vn")
+	req.Header.Set("X-Forwarded-Prefix", "/edge")
+	req.Header.Set("Forwarded", "for=198.51.100.7;proto=https")
+	return req
+}
+
+func TestSet_UntrustedPeer(t *testing.T) {
+	f, _ := New([]string{"10.0.0.0/8"})
+	out := http.Header{}
+	f.Set(out, newInbound("203.0.113.9:4000"), "/api/cms-service")
+
+	want := map[string]string{
+		"X-Forwarded-For":    "203.0.113.9",
+		"X-Forwarded-Proto":  "http",
+		"X-Forwarded-Host":   "acme.example.com",
+		"X-Forwarded-Prefix": "/api/cms-service",
+		"Forwarded":          "for=203.0.113.9;host=acme.example.com;proto=http",
+	}
+	for name, value := range want {
+		if got := out.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestSet_TrustedPeer(t *testing.T) {
+	f, _ := New([]string{"10.0.0.0/8"})
+	in := newInbound("10.0.0.2:4000")
+	in.TLS = &tls.ConnectionState{}
+	out := http.Header{}
+	f.Set(out, in, "")
+
+	want := map[string]string{
+		"X-Forwarded-For":    "198.51.100.7, 10.0.0.2",
+		"X-Forwarded-Proto":  "https",
+		"X-Forwarded-Host":   "shop.acme.vn",
+		"X-Forwarded-Prefix": "/edge",
+		"Forwarded":          "for=198.51.100.7;proto=https, for=10.0.0.2;host=acme.example.com;proto=https",
+	}
+	for name, value := range want {
+		if got := out.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestSet_IPv6AndPortInHost(t *testing.T) {
+	f, _ := New(nil)
+	in := httptest.NewRequest(http.MethodGet, "/", nil)
+	in.RemoteAddr = "[2001:db8::1]:5000"
+	in.Host = "localhost:8080"
+	out := http.Header{}
+	f.Set(out, in, "")
+
+	if got := out.Get("Forwarded"); got != `for="[2001:db8::1]";host="localhost:8080";proto=http` {
+		t.Errorf("Forwarded = %q", got)
+	}
+	if out.Get("X-Forwarded-Prefix") != "" {
+		t.Error("Expected no X-Forwarded-Prefix without a stripped prefix")
+	}
+}
@@ -0,0 +1,574 @@
+// Package graphql is a small GraphQL engine for the gateway's schema: it
+// parses and validates queries, enforces depth and complexity limits, checks
+// field permissions, and resolves fields concurrently so resolvers can batch
+// their upstream calls with a Loader. It supports object types, the builtin
+// scalars, fragments, variables and @include/@skip; interfaces, unions,
+// input objects, mutations, subscriptions and introspection are not
+// supported.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Error is a GraphQL error
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Response is a GraphQL response. Data is omitted when the request failed
+// before execution and null when execution failed at the root.
+type Response struct {
+	Data   interface{}
+	Errors []*Error
+
+	executed bool
+}
+
+// ErrorResponse is a response for a request that could not be executed
+func ErrorResponse(errs ...*Error) *Response {
+	return &Response{Errors: errs}
+}
+
+// Executed reports whether the query was executed, rather than rejected
+func (r *Response) Executed() bool {
+	return r.executed
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *Response) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Data   *json.RawMessage `json:"data,omitempty"`
+		Errors []*Error         `json:"errors,omitempty"`
+	}{Errors: r.Errors}
+	if r.executed {
+		data, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		raw := json.RawMessage(data)
+		out.Data = &raw
+	}
+	return json.Marshal(out)
+}
+
+// Config controls execution
+type Config struct {
+	// MaxDepth limits how deeply fields may be nested (0 = unlimited)
+	MaxDepth int
+	// MaxComplexity limits the query's total field cost (0 = unlimited)
+	MaxComplexity int
+	// DefaultListSize is the expected number of items of list fields whose
+	// size no first, last, limit or list argument bounds (default 10)
+	DefaultListSize int
+	// Authorize is called for fields with a Permission; an error leaves the
+	// field null with a FORBIDDEN error
+	Authorize func(ctx context.Context, permission string) error
+}
+
+// Execute runs a query against the schema
+func (s *Schema) Execute(ctx context.Context, config Config, req Request) *Response {
+	if config.DefaultListSize <= 0 {
+		config.DefaultListSize = 10
+	}
+	doc, err := parse(req.Query)
+	if err != nil {
+		return ErrorResponse(asError(err))
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return ErrorResponse(asError(err))
+	}
+	if op.kind != "query" {
+		return ErrorResponse(&Error{Message: fmt.Sprintf("%s operations are not supported", op.kind), Locations: []Location{op.loc}})
+	}
+	vars, errs := coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return ErrorResponse(errs...)
+	}
+
+	v := &validator{schema: s, config: config, doc: doc, vars: vars, defined: map[string]bool{}}
+	for _, def := range op.variables {
+		v.defined[def.name] = true
+	}
+	if errs := v.validate(op); len(errs) > 0 {
+		return ErrorResponse(errs...)
+	}
+
+	e := &executor{schema: s, config: config, doc: doc, vars: vars}
+	data, st := e.selectionSet(ctx, s.query, nil, op.selections, nil)
+	resp := &Response{Errors: e.errors, executed: true}
+	if st != failed {
+		resp.Data = data
+	}
+	return resp
+}
+
+func asError(err error) *Error {
+	if gqlErr, ok := err.(*Error); ok {
+		return gqlErr
+	}
+	return &Error{Message: err.Error()}
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+// coerceVariables applies the operation's variable definitions to the
+// request's variables
+func coerceVariables(op *operation, input map[string]interface{}) (map[string]interface{}, []*Error) {
+	vars := map[string]interface{}{}
+	var errs []*Error
+	for _, def := range op.variables {
+		if !scalars[def.typ.named()] {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" cannot be of non-input type %q.", def.name, def.typ), Locations: []Location{def.loc}})
+			continue
+		}
+		raw, provided := input[def.name]
+		if !provided && def.defaultVal != nil {
+			value, err := literal(def.defaultVal, nil)
+			if err == nil {
+				raw, provided = value, true
+			}
+		}
+		if !provided {
+			if def.typ.nonNull {
+				errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", def.name, def.typ), Locations: []Location{def.loc}})
+			}
+			continue
+		}
+		value, err := coerceInput(def.typ, raw)
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" got invalid value: %s.", def.name, err), Locations: []Location{def.loc}})
+			continue
+		}
+		vars[def.name] = value
+	}
+	return vars, errs
+}
+
+// literal converts a parsed value to Go, reading variables from vars
+func literal(v value, vars map[string]interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case intValue:
+		n, err := strconv.Atoi(string(x))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Int %s", x)
+		}
+		return n, nil
+	case floatValue:
+		return strconv.ParseFloat(string(x), 64)
+	case enumValue:
+		return nil, fmt.Errorf("enum value %s is not supported", x)
+	case variable:
+		return vars[string(x)], nil
+	case []value:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			value, err := literal(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	case []*objectField:
+		out := make(map[string]interface{}, len(x))
+		for _, f := range x {
+			value, err := literal(f.value, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[f.name] = value
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// coerceArgs returns a field's arguments, with defaults applied
+func coerceArgs(def *Field, args []*argument, vars map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(def.Args))
+	for name, arg := range def.Args {
+		if arg.Default != nil {
+			out[name] = arg.Default
+		}
+	}
+	for _, arg := range args {
+		if v, ok := arg.value.(variable); ok {
+			if _, set := vars[string(v)]; !set {
+				// unset variables leave the argument to its default
+				continue
+			}
+		}
+		value, err := coerceArgValue(def.Args[arg.name], arg.value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", arg.name, err)
+		}
+		out[arg.name] = value
+	}
+	for name, arg := range def.Args {
+		if arg.typ.nonNull && out[name] == nil {
+			return nil, fmt.Errorf("argument %q of type %q is required", name, arg.typ)
+		}
+	}
+	return out, nil
+}
+
+// coerceArgValue converts an argument's literal to the argument's type
+func coerceArgValue(arg *Argument, v value, vars map[string]interface{}) (interface{}, error) {
+	raw, err := literal(v, vars)
+	if err != nil {
+		return nil, err
+	}
+	return coerceInput(arg.typ, raw)
+}
+
+// completion states of a value
+const (
+	// completed values are valid, including null
+	completed = iota
+	// nulled values are null because of an error that was already reported
+	nulled
+	// failed values are invalid nulls that make the enclosing nullable value null
+	failed
+)
+
+// executor runs one operation
+type executor struct {
+	schema *Schema
+	config Config
+	doc    *document
+	vars   map[string]interface{}
+
+	mu     sync.Mutex
+	errors []*Error
+}
+
+func (e *executor) addError(err *Error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errors = append(e.errors, err)
+}
+
+// fieldError reports a field's error and leaves it null
+func (e *executor) fieldError(f *field, path []interface{}, err error) int {
+	gqlErr := asError(err)
+	out := &Error{Message: gqlErr.Message, Locations: []Location{f.loc}, Path: path, Extensions: gqlErr.Extensions}
+	e.addError(out)
+	return nulled
+}
+
+// orderedMap is an object result, keeping fields in the order they were selected
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON implements json.Marshaler
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		b.Write(k)
+		b.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// collectFields groups a selection set's fields by response key, expanding
+// fragments and applying @include and @skip
+func (e *executor) collectFields(obj *Object, sels []selection, keys *[]string, groups map[string][]*field, visited map[string]bool) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			if !e.included(s.directives) {
+				continue
+			}
+			key := s.responseKey()
+			if _, ok := groups[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			groups[key] = append(groups[key], s)
+		case *inlineFragment:
+			if !e.included(s.directives) || (s.typeCondition != "" && s.typeCondition != obj.Name) {
+				continue
+			}
+			e.collectFields(obj, s.selections, keys, groups, visited)
+		case *fragmentSpread:
+			if !e.included(s.directives) || visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			frag := e.doc.fragments[s.name]
+			if frag.typeCondition != obj.Name {
+				continue
+			}
+			e.collectFields(obj, frag.selections, keys, groups, visited)
+		}
+	}
+}
+
+// included evaluates @include(if:) and @skip(if:)
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		for _, arg := range d.arguments {
+			if arg.name != "if" {
+				continue
+			}
+			value, _ := literal(arg.value, e.vars)
+			cond, _ := value.(bool)
+			if d.name == "skip" && cond || d.name == "include" && !cond {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// selectionSet resolves the fields of an object. Fields with resolvers run
+// concurrently, so their upstream calls can be batched.
+func (e *executor) selectionSet(ctx context.Context, obj *Object, source interface{}, sels []selection, path []interface{}) (*orderedMap, int) {
+	var keys []string
+	groups := map[string][]*field{}
+	e.collectFields(obj, sels, &keys, groups, map[string]bool{})
+
+	result := &orderedMap{keys: keys, values: make(map[string]interface{}, len(keys))}
+	states := make([]int, len(keys))
+	values := make([]interface{}, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		fields := groups[key]
+		fieldPath := append(append([]interface{}{}, path...), key)
+		def := obj.Fields[fields[0].name]
+		if def != nil && def.Resolve != nil {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				values[i], states[i] = e.field(ctx, obj, source, fields, fieldPath)
+			}(i)
+			continue
+		}
+		values[i], states[i] = e.field(ctx, obj, source, fields, fieldPath)
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		if states[i] == failed {
+			return nil, failed
+		}
+		result.values[key] = values[i]
+	}
+	return result, completed
+}
+
+// field resolves and completes one field
+func (e *executor) field(ctx context.Context, obj *Object, source interface{}, fields []*field, path []interface{}) (value interface{}, state int) {
+	f := fields[0]
+	if f.name == "__typename" {
+		return obj.Name, completed
+	}
+	def := obj.Fields[f.name]
+
+	defer func() {
+		if r := recover(); r != nil {
+			value, state = nil, e.nonNull(def.typ, e.fieldError(f, path, fmt.Errorf("internal error resolving field")))
+		}
+	}()
+
+	if def.Permission != "" && e.config.Authorize != nil {
+		if err := e.config.Authorize(ctx, def.Permission); err != nil {
+			gqlErr := asError(err)
+			if gqlErr.Extensions == nil {
+				gqlErr = &Error{Message: gqlErr.Message, Extensions: map[string]interface{}{"code": "FORBIDDEN"}}
+			}
+			return nil, e.nonNull(def.typ, e.fieldError(f, path, gqlErr))
+		}
+	}
+
+	args, err := coerceArgs(def, f.arguments, e.vars)
+	if err != nil {
+		return nil, e.nonNull(def.typ, e.fieldError(f, path, err))
+	}
+	var resolved interface{}
+	if def.Resolve != nil {
+		resolved, err = def.Resolve(ResolveParams{Context: ctx, Source: source, Args: args})
+	} else {
+		resolved = defaultResolve(source, f.name)
+	}
+	if err != nil {
+		return nil, e.nonNull(def.typ, e.fieldError(f, path, err))
+	}
+	return e.complete(ctx, def.typ, fields, resolved, path)
+}
+
+// nonNull turns a reported field error into a failure for non-null fields
+func (e *executor) nonNull(t *typeRef, state int) int {
+	if t.nonNull && state == nulled {
+		return failed
+	}
+	return state
+}
+
+// complete converts a resolved value to the field's type
+func (e *executor) complete(ctx context.Context, t *typeRef, fields []*field, value interface{}, path []interface{}) (interface{}, int) {
+	if t.nonNull {
+		inner := *t
+		inner.nonNull = false
+		v, state := e.complete(ctx, &inner, fields, value, path)
+		if state != completed {
+			return nil, failed
+		}
+		if v == nil {
+			e.fieldError(fields[0], path, fmt.Errorf("Cannot return null for non-nullable field."))
+			return nil, failed
+		}
+		return v, completed
+	}
+
+	if isNil(value) {
+		return nil, completed
+	}
+
+	if t.elem != nil {
+		return e.completeList(ctx, t.elem, fields, value, path)
+	}
+
+	if scalars[t.name] {
+		v, err := serializeScalar(t.name, value)
+		if err != nil {
+			return nil, e.fieldError(fields[0], path, err)
+		}
+		return v, completed
+	}
+
+	obj := e.schema.objects[t.name]
+	var sels []selection
+	for _, f := range fields {
+		sels = append(sels, f.selections...)
+	}
+	m, state := e.selectionSet(ctx, obj, value, sels, path)
+	if state == failed {
+		return nil, nulled
+	}
+	return m, completed
+}
+
+// completeList completes list items concurrently when they are objects, so
+// their resolvers' upstream calls can be batched
+func (e *executor) completeList(ctx context.Context, elem *typeRef, fields []*field, value interface{}, path []interface{}) (interface{}, int) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, e.fieldError(fields[0], path, fmt.Errorf("expected a list, got %T", value))
+	}
+
+	n := rv.Len()
+	items := make([]interface{}, n)
+	states := make([]int, n)
+	object := !scalars[elem.named()]
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		itemPath := append(append([]interface{}{}, path...), i)
+		if object {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				items[i], states[i] = e.complete(ctx, elem, fields, rv.Index(i).Interface(), itemPath)
+			}(i)
+			continue
+		}
+		items[i], states[i] = e.complete(ctx, elem, fields, rv.Index(i).Interface(), itemPath)
+	}
+	wg.Wait()
+
+	for _, state := range states {
+		if state == failed {
+			return nil, nulled
+		}
+	}
+	return items, completed
+}
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// defaultResolve reads a field from a map source, by its name or its
+// snake_case form
+func defaultResolve(source interface{}, name string) interface{} {
+	m, ok := source.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if v, ok := m[name]; ok {
+		return v
+	}
+	return m[snakeCase(name)]
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,354 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSchema has users whose tenants are batched through a loader. It
+// returns the batches the loader requested.
+func testSchema(t *testing.T) (*Schema, func() [][]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var batches [][]string
+
+	users := map[string]map[string]interface{}{
+		"1": {"id": "1", "name": "Ada", "tenant_id": "t1", "email": "ada@example.com"},
+		"2": {"id": "2", "name": "Bob", "tenant_id": "t2"},
+		"3": {"id": "3", "name": "Cy", "tenant_id": "t1"},
+		"4": {"name": "No ID", "tenant_id": "t1"},
+	}
+	type loaderKey struct{}
+	tenants := func(ctx context.Context) *Loader {
+		return ctx.Value(loaderKey{}).(*Loader)
+	}
+
+	user := &Object{Name: "User", Fields: map[string]*Field{
+		"id":       {Type: "ID!"},
+		"name":     {Type: "String"},
+		"tenantId": {Type: "ID"},
+		"email":    {Type: "String", Permission: "users:read:email"},
+		"tenant": {Type: "Tenant", Resolve: func(p ResolveParams) (interface{}, error) {
+			id := p.Source.(map[string]interface{})["tenant_id"].(string)
+			return tenants(p.Context).Load(p.Context, id)
+		}},
+	}}
+	tenant := &Object{Name: "Tenant", Fields: map[string]*Field{
+		"id":   {Type: "ID!"},
+		"name": {Type: "String"},
+	}}
+	query := &Object{Name: "Query", Fields: map[string]*Field{
+		"me": {Type: "User", Resolve: func(p ResolveParams) (interface{}, error) {
+			return users["1"], nil
+		}},
+		"user": {Type: "User", Args: map[string]*Argument{"id": {Type: "ID!"}}, Resolve: func(p ResolveParams) (interface{}, error) {
+			if u, ok := users[p.Args["id"].(string)]; ok {
+				return u, nil
+			}
+			return nil, nil
+		}},
+		"users": {Type: "[User]", Args: map[string]*Argument{"ids": {Type: "[ID!]!"}}, Resolve: func(p ResolveParams) (interface{}, error) {
+			var out []interface{}
+			for _, id := range p.Args["ids"].([]interface{}) {
+				if u, ok := users[id.(string)]; ok {
+					out = append(out, u)
+				} else {
+					out = append(out, nil)
+				}
+			}
+			return out, nil
+		}},
+		"search": {Type: "[User!]!", Args: map[string]*Argument{"limit": {Type: "Int", Default: 20}}, Resolve: func(p ResolveParams) (interface{}, error) {
+			return []interface{}{users["1"]}, nil
+		}},
+		"greeting": {Type: "String!", Args: map[string]*Argument{"name": {Type: "String", Default: "world"}}, Resolve: func(p ResolveParams) (interface{}, error) {
+			return "hello " + p.Args["name"].(string), nil
+		}},
+		"fail": {Type: "String!", Resolve: func(p ResolveParams) (interface{}, error) {
+			return nil, errors.New("upstream unavailable")
+		}},
+	}}
+
+	schema, err := NewSchema(query, user, tenant)
+	if err != nil {
+		t.Fatalf("NewSchema: %v", err)
+	}
+	// each request gets its own tenant loader
+	setup := func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, loaderKey{}, NewLoader(ctx, func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+			mu.Lock()
+			sorted := append([]string{}, keys...)
+			sort.Strings(sorted)
+			batches = append(batches, sorted)
+			mu.Unlock()
+			out := map[string]interface{}{}
+			for _, key := range keys {
+				out[key] = map[string]interface{}{"id": key, "name": "Tenant " + key}
+			}
+			return out, nil
+		}))
+	}
+	testContexts.Store(schema, setup)
+
+	return schema, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return batches
+	}
+}
+
+// testContexts holds each test schema's context setup
+var testContexts sync.Map
+
+func execute(t *testing.T, schema *Schema, config Config, query string, vars map[string]interface{}) (string, *Response) {
+	t.Helper()
+	ctx := context.Background()
+	if setup, ok := testContexts.Load(schema); ok {
+		ctx = setup.(func(context.Context) context.Context)(ctx)
+	}
+	resp := schema.Execute(ctx, config, Request{Query: query, Variables: vars})
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data), resp
+}
+
+func TestExecute(t *testing.T) {
+	schema, _ := testSchema(t)
+	got, _ := execute(t, schema, Config{}, `{
+		me { __typename name id tenantId }
+		first: user(id: 2) { name }
+		greeting
+		hi: greeting(name: "ada")
+	}`, nil)
+	want := `{"data":{"me":{"__typename":"User","name":"Ada","id":"1","tenantId":"t1"},"first":{"name":"Bob"},"greeting":"hello world","hi":"hello ada"}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteFragmentsAndDirectives(t *testing.T) {
+	schema, _ := testSchema(t)
+	got, _ := execute(t, schema, Config{}, `query Q($withName: Boolean!, $id: ID!) {
+		user(id: $id) {
+			...fields
+			... on User @include(if: $withName) { name }
+			tenantId @skip(if: true)
+		}
+	}
+	fragment fields on User { id }`, map[string]interface{}{"withName": false, "id": "3"})
+	want := `{"data":{"user":{"id":"3"}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteBatchesLoads(t *testing.T) {
+	schema, batches := testSchema(t)
+	got, _ := execute(t, schema, Config{}, `{
+		users(ids: ["1", "2", "3"]) { tenant { name } }
+		me { tenant { id } }
+	}`, nil)
+	want := `{"data":{"users":[{"tenant":{"name":"Tenant t1"}},{"tenant":{"name":"Tenant t2"}},{"tenant":{"name":"Tenant t1"}}],"me":{"tenant":{"id":"t1"}}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if b := batches(); len(b) != 1 || strings.Join(b[0], ",") != "t1,t2" {
+		t.Errorf("batches = %v, want one batch of t1,t2", b)
+	}
+}
+
+func TestExecutePermissions(t *testing.T) {
+	schema, _ := testSchema(t)
+	var checked []string
+	var mu sync.Mutex
+	config := Config{Authorize: func(ctx context.Context, permission string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		checked = append(checked, permission)
+		return fmt.Errorf("missing permission %s", permission)
+	}}
+	got, resp := execute(t, schema, config, `{ me { name email } }`, nil)
+	if want := `{"data":{"me":{"name":"Ada","email":null}}`; !strings.HasPrefix(got, want) {
+		t.Errorf("got  %s\nwant prefix %s", got, want)
+	}
+	if len(resp.Errors) != 1 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	err := resp.Errors[0]
+	if err.Extensions["code"] != "FORBIDDEN" || fmt.Sprint(err.Path) != "[me email]" {
+		t.Errorf("unexpected error %+v", err)
+	}
+	if len(checked) != 1 || checked[0] != "users:read:email" {
+		t.Errorf("checked = %v", checked)
+	}
+}
+
+func TestExecuteNullPropagation(t *testing.T) {
+	schema, _ := testSchema(t)
+
+	// a null non-null field nulls its nullable parent
+	got, resp := execute(t, schema, Config{}, `{ user(id: "4") { id name } me { name } }`, nil)
+	if want := `{"data":{"user":null,"me":{"name":"Ada"}}`; !strings.HasPrefix(got, want) {
+		t.Errorf("got  %s\nwant prefix %s", got, want)
+	}
+	if len(resp.Errors) != 1 || fmt.Sprint(resp.Errors[0].Path) != "[user id]" {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+
+	// a failed non-null root field nulls the data
+	got, resp = execute(t, schema, Config{}, `{ me { name } fail }`, nil)
+	if want := `{"data":null,"errors":[{"message":"upstream unavailable"`; !strings.HasPrefix(got, want) {
+		t.Errorf("got  %s\nwant prefix %s", got, want)
+	}
+	if len(resp.Errors) != 1 {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+
+	// a null item of a nullable list stays null
+	got, _ = execute(t, schema, Config{}, `{ users(ids: ["1", "9"]) { name } }`, nil)
+	if want := `{"data":{"users":[{"name":"Ada"},null]}}`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteValidation(t *testing.T) {
+	schema, _ := testSchema(t)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ nope }`, `Cannot query field "nope" on type "Query".`},
+		{`{ user { id } }`, `Field "user" argument "id" of type "ID!" is required`},
+		{`{ user(id: 1, extra: 2) { id } }`, `Unknown argument "extra" on field "Query.user".`},
+		{`{ me }`, `Field "me" of type "User" must have a selection of subfields.`},
+		{`{ greeting { id } }`, `Field "greeting" must not have a selection`},
+		{`{ user(id: $id) { id } }`, `Variable "$id" is not defined.`},
+		{`query($id: ID) { me { id } }`, `Variable "$id" is never used.`},
+		{`{ user(id: true) { id } }`, `Argument "id" has invalid value`},
+		{`{ me { ...a } } fragment a on User { ...b } fragment b on User { ...a }`, `within itself`},
+		{`{ me { ...missing } }`, `Unknown fragment "missing".`},
+		{`{ me { ... on Tenant { id } } }`, `can never be of type "Tenant"`},
+		{`{ me { id } } fragment unused on User { id }`, `Fragment "unused" is never used.`},
+		{`{ me @deprecated { id } }`, `Unknown directive "@deprecated".`},
+		{`mutation { me { id } }`, `mutation operations are not supported`},
+		{`query A { me { id } } query B { me { id } }`, `Must provide operation name`},
+	}
+	for _, tt := range tests {
+		got, resp := execute(t, schema, Config{}, tt.query, nil)
+		if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.want) {
+			t.Errorf("%s: got %s, want error %q", tt.query, got, tt.want)
+		}
+		if strings.Contains(got, `"data"`) {
+			t.Errorf("%s: invalid query has data: %s", tt.query, got)
+		}
+	}
+}
+
+func TestExecuteVariables(t *testing.T) {
+	schema, _ := testSchema(t)
+	query := `query($ids: [ID!]!) { users(ids: $ids) { name } }`
+
+	got, _ := execute(t, schema, Config{}, query, map[string]interface{}{"ids": []interface{}{"2", float64(3)}})
+	if want := `{"data":{"users":[{"name":"Bob"},{"name":"Cy"}]}}`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	_, resp := execute(t, schema, Config{}, query, nil)
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, `"$ids" of required type "[ID!]!" was not provided`) {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+	_, resp = execute(t, schema, Config{}, query, map[string]interface{}{"ids": []interface{}{true}})
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "got invalid value") {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	schema, _ := testSchema(t)
+
+	_, resp := execute(t, schema, Config{MaxDepth: 2}, `{ me { tenant { name } } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "QUERY_TOO_DEEP" {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+	_, resp = execute(t, schema, Config{MaxDepth: 3}, `{ me { tenant { name } } }`, nil)
+	if len(resp.Errors) != 0 {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+
+	// users: 1 + 3 ids * (name 1 + tenant (1 + name 1)) = 10
+	query := `{ users(ids: ["1", "2", "3"]) { name tenant { name } } }`
+	_, resp = execute(t, schema, Config{MaxComplexity: 9}, query, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["complexity"] != 10 {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+	_, resp = execute(t, schema, Config{MaxComplexity: 10}, query, nil)
+	if len(resp.Errors) != 0 {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+
+	// search: 1 + limit (default 20) * 1
+	_, resp = execute(t, schema, Config{MaxComplexity: 20}, `{ search { name } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["complexity"] != 21 {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+	_, resp = execute(t, schema, Config{MaxComplexity: 20}, `{ search(limit: 5) { name } }`, nil)
+	if len(resp.Errors) != 0 {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+
+	// fragments spread many times are bounded
+	var b strings.Builder
+	b.WriteString("{ me { ...f0 } }\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "fragment f%d on User { a: tenant { id } ...f%d b: tenant { id } ...f%d }\n", i, i+1, i+1)
+	}
+	b.WriteString("fragment f20 on User { id }")
+	_, resp = execute(t, schema, Config{}, b.String(), nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "QUERY_TOO_COMPLEX" {
+		t.Errorf("errors = %+v", resp.Errors)
+	}
+}
+
+func TestSchemaSDL(t *testing.T) {
+	schema, _ := testSchema(t)
+	sdl := schema.SDL()
+	for _, want := range []string{
+		"type Query {",
+		"  users(ids: [ID!]!): [User]",
+		`  search(limit: Int = 20): [User!]!`,
+		"type Tenant {",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL missing %q:\n%s", want, sdl)
+		}
+	}
+}
+
+func TestNewSchemaErrors(t *testing.T) {
+	user := &Object{Name: "User", Fields: map[string]*Field{"id": {Type: "ID!"}}}
+	tests := []struct {
+		query   *Object
+		objects []*Object
+		want    string
+	}{
+		{&Object{Name: "Query", Fields: map[string]*Field{"u": {Type: "Missing"}}}, nil, "unknown type"},
+		{&Object{Name: "Query", Fields: map[string]*Field{"u": {Type: "[User"}}}, []*Object{user}, "invalid type"},
+		{&Object{Name: "Query", Fields: map[string]*Field{"u": {Type: "User", Args: map[string]*Argument{"a": {Type: "User"}}}}}, []*Object{user}, "argument"},
+		{&Object{Name: "Query"}, nil, "no fields"},
+		{&Object{Name: "Query", Fields: map[string]*Field{"u": {Type: "User"}}}, []*Object{user, user}, "duplicate type"},
+	}
+	for _, tt := range tests {
+		_, err := NewSchema(tt.query, tt.objects...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewSchema error = %v, want %q", err, tt.want)
+		}
+	}
+}
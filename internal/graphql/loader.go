@@ -0,0 +1,142 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchFunc loads the values of keys. Keys missing from the result resolve
+// to nil; an error fails every key of the batch.
+type BatchFunc func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// Loader batches and caches loads of one request. Loads issued while sibling
+// fields resolve are collected for a short wait and fetched with one call
+// to the batch function, and each key is loaded at most once.
+type Loader struct {
+	ctx      context.Context
+	batch    BatchFunc
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[string]*load
+	pending []string
+	timer   *time.Timer
+}
+
+// load is the result of loading one key
+type load struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+const (
+	// DefaultLoaderWait is how long a loader collects keys before a batch
+	DefaultLoaderWait = 2 * time.Millisecond
+	// DefaultMaxBatch is the largest batch a loader requests
+	DefaultMaxBatch = 100
+)
+
+// NewLoader creates a loader whose batches run with ctx, normally the
+// request's context
+func NewLoader(ctx context.Context, batch BatchFunc) *Loader {
+	return &Loader{
+		ctx:      ctx,
+		batch:    batch,
+		wait:     DefaultLoaderWait,
+		maxBatch: DefaultMaxBatch,
+		cache:    make(map[string]*load),
+	}
+}
+
+// Load returns the value of key, waiting for its batch
+func (l *Loader) Load(ctx context.Context, key string) (interface{}, error) {
+	l.mu.Lock()
+	ld, ok := l.cache[key]
+	if !ok {
+		ld = &load{done: make(chan struct{})}
+		l.cache[key] = ld
+		l.pending = append(l.pending, key)
+		if len(l.pending) >= l.maxBatch {
+			l.dispatchLocked()
+		} else if l.timer == nil {
+			l.timer = time.AfterFunc(l.wait, l.dispatch)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-ld.done:
+		return ld.value, ld.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany returns the values of keys, in order
+func (l *Loader) LoadMany(ctx context.Context, keys []string) ([]interface{}, error) {
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (l *Loader) dispatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dispatchLocked()
+}
+
+// dispatchLocked starts a batch for the pending keys; l.mu must be held
+func (l *Loader) dispatchLocked() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if len(l.pending) == 0 {
+		return
+	}
+	keys := l.pending
+	l.pending = nil
+	loads := make([]*load, len(keys))
+	for i, key := range keys {
+		loads[i] = l.cache[key]
+	}
+	go l.run(keys, loads)
+}
+
+func (l *Loader) run(keys []string, loads []*load) {
+	var values map[string]interface{}
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &Error{Message: "internal error loading data"}
+			}
+		}()
+		values, err = l.batch(l.ctx, keys)
+	}()
+	for i, ld := range loads {
+		if err != nil {
+			ld.err = err
+		} else {
+			ld.value = values[keys[i]]
+		}
+		close(ld.done)
+	}
+}
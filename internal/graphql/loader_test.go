@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoaderBatchesAndCaches(t *testing.T) {
+	var mu sync.Mutex
+	var batches []string
+	loader := NewLoader(context.Background(), func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		mu.Lock()
+		sorted := append([]string{}, keys...)
+		sort.Strings(sorted)
+		batches = append(batches, strings.Join(sorted, ","))
+		mu.Unlock()
+		out := map[string]interface{}{}
+		for _, key := range keys {
+			if key != "missing" {
+				out[key] = "value " + key
+			}
+		}
+		return out, nil
+	})
+
+	values, err := loader.LoadMany(context.Background(), []string{"b", "a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("LoadMany: %v", err)
+	}
+	if fmt.Sprint(values) != "[value b value a value b <nil>]" {
+		t.Errorf("values = %v", values)
+	}
+
+	// cached keys are not loaded again
+	if v, err := loader.Load(context.Background(), "a"); err != nil || v != "value a" {
+		t.Errorf("Load(a) = %v, %v", v, err)
+	}
+	if len(batches) != 1 || batches[0] != "a,b,missing" {
+		t.Errorf("batches = %v", batches)
+	}
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	loader := NewLoader(context.Background(), func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		mu.Lock()
+		sizes = append(sizes, len(keys))
+		mu.Unlock()
+		return nil, nil
+	})
+	loader.wait = time.Hour
+	loader.maxBatch = 2
+
+	keys := []string{"1", "2", "3", "4"}
+	if _, err := loader.LoadMany(context.Background(), keys); err != nil {
+		t.Fatalf("LoadMany: %v", err)
+	}
+	sort.Ints(sizes)
+	if fmt.Sprint(sizes) != "[2 2]" {
+		t.Errorf("batch sizes = %v", sizes)
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	loader := NewLoader(context.Background(), func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		return nil, errors.New("service unavailable")
+	})
+	if _, err := loader.Load(context.Background(), "a"); err == nil || err.Error() != "service unavailable" {
+		t.Errorf("Load error = %v", err)
+	}
+
+	panicking := NewLoader(context.Background(), func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		panic("boom")
+	})
+	if _, err := panicking.Load(context.Background(), "a"); err == nil {
+		t.Error("expected an error from a panicking batch")
+	}
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	slow := NewLoader(context.Background(), func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		<-blocked
+		return nil, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Load(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Load error = %v, want deadline exceeded", err)
+	}
+}
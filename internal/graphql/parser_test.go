@@ -0,0 +1,107 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# a comment
+		query Users($ids: [ID!]!, $limit: Int = 5) {
+			list: users(ids: $ids, limit: $limit) { ...userFields }
+			me @include(if: true) { id ... on User { email } }
+		}
+		fragment userFields on User { id, name }
+		{ me { id } }
+	`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(doc.operations) != 2 || len(doc.fragments) != 1 {
+		t.Fatalf("got %d operations and %d fragments", len(doc.operations), len(doc.fragments))
+	}
+
+	op := doc.operations[0]
+	if op.kind != "query" || op.name != "Users" || len(op.variables) != 2 {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	if op.variables[0].typ.String() != "[ID!]!" || op.variables[1].defaultVal != intValue("5") {
+		t.Errorf("unexpected variables %+v %+v", op.variables[0], op.variables[1])
+	}
+	list := op.selections[0].(*field)
+	if list.responseKey() != "list" || list.name != "users" || len(list.arguments) != 2 {
+		t.Errorf("unexpected field %+v", list)
+	}
+	if list.arguments[0].value != variable("ids") {
+		t.Errorf("argument value = %#v", list.arguments[0].value)
+	}
+	me := op.selections[1].(*field)
+	if len(me.directives) != 1 || me.directives[0].name != "include" {
+		t.Errorf("unexpected directives %+v", me.directives)
+	}
+	if inline, ok := me.selections[1].(*inlineFragment); !ok || inline.typeCondition != "User" {
+		t.Errorf("unexpected inline fragment %#v", me.selections[1])
+	}
+	if doc.operations[1].kind != "query" || doc.operations[1].name != "" {
+		t.Errorf("unexpected shorthand operation %+v", doc.operations[1])
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`{ f(a: -1.5e3, b: "say \"hi\" é", c: """
+		block
+		  indented
+	""", d: [1, null, RED], e: {x: false}) }`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	args := doc.operations[0].selections[0].(*field).arguments
+	if args[0].value != floatValue("-1.5e3") {
+		t.Errorf("float = %#v", args[0].value)
+	}
+	if args[1].value != `say "hi" é` {
+		t.Errorf("string = %#v", args[1].value)
+	}
+	if args[2].value != "block\n  indented" {
+		t.Errorf("block string = %#v", args[2].value)
+	}
+	list := args[3].value.([]value)
+	if list[0] != intValue("1") || list[1] != nil || list[2] != enumValue("RED") {
+		t.Errorf("list = %#v", list)
+	}
+	obj := args[4].value.([]*objectField)
+	if obj[0].name != "x" || obj[0].value != false {
+		t.Errorf("object = %#v", obj)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+		line  int
+	}{
+		{"", "no operation", 0},
+		{"{ me { id }", `unexpected "<EOF>"`, 1},
+		{"{\n  me(id: $) }", `unexpected ")"`, 2},
+		{`{ f(a: "unterminated) }`, "unterminated string", 1},
+		{"{ f(a: 01) }", "invalid number", 1},
+		{"{ f }\nfragment F on T { a }\nfragment F on T { b }", `only one fragment named "F"`, 3},
+		{"query Q($a: Int) { a($a: 1) }", `unexpected "$"`, 1},
+	}
+	for _, tt := range tests {
+		_, err := parse(tt.query)
+		if err == nil {
+			t.Errorf("parse(%q) succeeded", tt.query)
+			continue
+		}
+		gqlErr := err.(*Error)
+		if !strings.Contains(gqlErr.Message, tt.want) {
+			t.Errorf("parse(%q) = %q, want %q", tt.query, gqlErr.Message, tt.want)
+		}
+		if tt.line > 0 && (len(gqlErr.Locations) != 1 || gqlErr.Locations[0].Line != tt.line) {
+			t.Errorf("parse(%q) locations = %+v, want line %d", tt.query, gqlErr.Locations, tt.line)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// PersistedQueryStore stores queries by their SHA-256 hash
+type PersistedQueryStore interface {
+	Get(ctx context.Context, hash string) (string, bool)
+	Set(ctx context.Context, hash, query string)
+}
+
+// PersistedQueries implements automatic persisted queries (APQ): clients
+// send the SHA-256 hash of a query in extensions.persistedQuery.sha256Hash,
+// and the query itself only after the gateway answers PersistedQueryNotFound.
+// With only set, the gateway runs nothing but the queries in the store, and
+// clients cannot register new ones.
+type PersistedQueries struct {
+	store PersistedQueryStore
+	only  bool
+}
+
+// NewPersistedQueries creates persisted queries backed by store
+func NewPersistedQueries(store PersistedQueryStore, only bool) *PersistedQueries {
+	return &PersistedQueries{store: store, only: only}
+}
+
+// Resolve fills in the request's query from its persisted query hash, and
+// stores new queries sent with their hash
+func (p *PersistedQueries) Resolve(ctx context.Context, req *Request) *Error {
+	hash := persistedQueryHash(req)
+	if hash == "" {
+		if p.only {
+			return &Error{Message: "Only persisted queries are allowed", Extensions: map[string]interface{}{"code": "PERSISTED_QUERY_REQUIRED"}}
+		}
+		return nil
+	}
+
+	if query, ok := p.store.Get(ctx, hash); ok {
+		req.Query = query
+		return nil
+	}
+	if p.only {
+		return &Error{Message: "PersistedQueryNotFound", Extensions: map[string]interface{}{"code": "PERSISTED_QUERY_NOT_FOUND"}}
+	}
+	if req.Query == "" {
+		return &Error{Message: "PersistedQueryNotFound", Extensions: map[string]interface{}{"code": "PERSISTED_QUERY_NOT_FOUND"}}
+	}
+	if HashQuery(req.Query) != hash {
+		return &Error{Message: "provided sha does not match query", Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"}}
+	}
+	p.store.Set(ctx, hash, req.Query)
+	return nil
+}
+
+// persistedQueryHash returns the request's persisted query hash, if any
+func persistedQueryHash(req *Request) string {
+	pq, ok := req.Extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	hash, _ := pq["sha256Hash"].(string)
+	return strings.ToLower(hash)
+}
+
+// HashQuery returns the hex SHA-256 hash of a query
+func HashQuery(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore is a bounded in-memory PersistedQueryStore. Queries loaded
+// with Preload are never evicted; registered queries are evicted oldest
+// first once the store is full.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	max     int
+	queries map[string]string
+	order   []string
+}
+
+// NewMemoryStore creates a store holding up to max registered queries
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{max: max, queries: make(map[string]string)}
+}
+
+// Get implements PersistedQueryStore
+func (s *MemoryStore) Get(_ context.Context, hash string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	query, ok := s.queries[hash]
+	return query, ok
+}
+
+// Set implements PersistedQueryStore
+func (s *MemoryStore) Set(_ context.Context, hash, query string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queries[hash]; ok || s.max <= 0 {
+		return
+	}
+	if len(s.order) >= s.max {
+		delete(s.queries, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.queries[hash] = query
+	s.order = append(s.order, hash)
+}
+
+// Preload adds queries that are never evicted
+func (s *MemoryStore) Preload(queries map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, query := range queries {
+		s.queries[hash] = query
+	}
+}
+
+// LoadPersistedQueries reads a persisted query manifest: either a JSON
+// object of hash to query, or a JSON array of queries whose hashes are
+// computed. Hashes of the object form are verified.
+func LoadPersistedQueries(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted queries: %w", err)
+	}
+
+	queries := map[string]string{}
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, query := range list {
+			queries[HashQuery(query)] = query
+		}
+		return queries, nil
+	}
+
+	var byHash map[string]string
+	if err := json.Unmarshal(data, &byHash); err != nil {
+		return nil, fmt.Errorf("failed to parse persisted queries: %w", err)
+	}
+	for hash, query := range byHash {
+		if HashQuery(query) != strings.ToLower(hash) {
+			return nil, fmt.Errorf("persisted query %s does not match its hash", hash)
+		}
+		queries[strings.ToLower(hash)] = query
+	}
+	return queries, nil
+}
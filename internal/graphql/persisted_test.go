@@ -0,0 +1,104 @@
+package graphql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func persistedRequest(query, hash string) *Request {
+	return &Request{
+		Query:      query,
+		Extensions: map[string]interface{}{"persistedQuery": map[string]interface{}{"version": float64(1), "sha256Hash": hash}},
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	ctx := context.Background()
+	pq := NewPersistedQueries(NewMemoryStore(10), false)
+	query := "{ me { id } }"
+	hash := HashQuery(query)
+
+	// the hash alone is unknown until the query is registered
+	req := persistedRequest("", hash)
+	if err := pq.Resolve(ctx, req); err == nil || err.Extensions["code"] != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Fatalf("Resolve = %+v, want not found", err)
+	}
+	if err := pq.Resolve(ctx, persistedRequest(query, hash)); err != nil {
+		t.Fatalf("register: %+v", err)
+	}
+	req = persistedRequest("", hash)
+	if err := pq.Resolve(ctx, req); err != nil || req.Query != query {
+		t.Errorf("Resolve = %+v, query %q", err, req.Query)
+	}
+
+	if err := pq.Resolve(ctx, persistedRequest("{ other }", hash[:63]+"0")); err == nil || err.Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("mismatched hash = %+v", err)
+	}
+	if err := pq.Resolve(ctx, &Request{Query: query}); err != nil {
+		t.Errorf("plain query = %+v", err)
+	}
+}
+
+func TestPersistedQueriesOnly(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "queries.json")
+	if err := os.WriteFile(path, []byte(`["{ me { id } }"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	queries, err := LoadPersistedQueries(path)
+	if err != nil {
+		t.Fatalf("LoadPersistedQueries: %v", err)
+	}
+	store := NewMemoryStore(0)
+	store.Preload(queries)
+	pq := NewPersistedQueries(store, true)
+
+	req := persistedRequest("", HashQuery("{ me { id } }"))
+	if err := pq.Resolve(ctx, req); err != nil || req.Query != "{ me { id } }" {
+		t.Errorf("Resolve = %+v, query %q", err, req.Query)
+	}
+	if err := pq.Resolve(ctx, &Request{Query: "{ me { id } }"}); err == nil || err.Extensions["code"] != "PERSISTED_QUERY_REQUIRED" {
+		t.Errorf("plain query = %+v", err)
+	}
+	other := "{ me { name } }"
+	if err := pq.Resolve(ctx, persistedRequest(other, HashQuery(other))); err == nil || err.Extensions["code"] != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Errorf("unknown query = %+v", err)
+	}
+}
+
+func TestLoadPersistedQueriesByHash(t *testing.T) {
+	dir := t.TempDir()
+	query := "{ me { id } }"
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`{"`+HashQuery(query)+`": "{ me { id } }"}`), 0o600)
+	queries, err := LoadPersistedQueries(good)
+	if err != nil || queries[HashQuery(query)] != query {
+		t.Errorf("LoadPersistedQueries = %v, %v", queries, err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"abc": "{ me { id } }"}`), 0o600)
+	if _, err := LoadPersistedQueries(bad); err == nil {
+		t.Error("expected an error for a mismatched hash")
+	}
+}
+
+func TestMemoryStoreEvicts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+	store.Preload(map[string]string{"pinned": "q0"})
+	store.Set(ctx, "a", "q1")
+	store.Set(ctx, "b", "q2")
+	store.Set(ctx, "c", "q3")
+	if _, ok := store.Get(ctx, "a"); ok {
+		t.Error("oldest query was not evicted")
+	}
+	for _, hash := range []string{"pinned", "b", "c"} {
+		if _, ok := store.Get(ctx, hash); !ok {
+			t.Errorf("query %s missing", hash)
+		}
+	}
+}
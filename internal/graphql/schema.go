@@ -0,0 +1,342 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtin scalar types
+var scalars = map[string]bool{"ID": true, "String": true, "Int": true, "Float": true, "Boolean": true}
+
+// typeRef is a type reference such as "[User!]!"
+type typeRef struct {
+	name    string
+	elem    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// named returns the type's innermost named type
+func (t *typeRef) named() string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+func parseType(s string) (*typeRef, error) {
+	p := &parser{lexer: &lexer{src: s, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	t, err := p.typeRef()
+	if err != nil || p.tok.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid type %q", s)
+	}
+	return t, nil
+}
+
+// ResolveParams are passed to resolvers
+type ResolveParams struct {
+	Context context.Context
+	// Source is the parent object's value (nil for Query fields)
+	Source interface{}
+	// Args are the field's coerced arguments, with defaults applied
+	Args map[string]interface{}
+}
+
+// ResolveFunc returns a field's value: a scalar, a list ([]interface{} or
+// any slice), or a map[string]interface{} for object types
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+// Field is a field of an object type
+type Field struct {
+	// Type is the field's type in SDL notation, e.g. "String", "[User!]!"
+	Type        string
+	Description string
+	Args        map[string]*Argument
+	// Resolve computes the value; by default the field is read from a map
+	// source by its name or its snake_case form (tenantId, tenant_id)
+	Resolve ResolveFunc
+	// Permission is required to read the field, checked with Config.Authorize
+	Permission string
+	// Cost is the field's complexity (default 1). The cost of a list field's
+	// selections is multiplied by its expected size.
+	Cost int
+
+	typ *typeRef
+}
+
+// Argument is a field argument. Only scalars and lists of scalars are supported.
+type Argument struct {
+	Type        string
+	Default     interface{}
+	Description string
+
+	typ *typeRef
+}
+
+// Object is an object type
+type Object struct {
+	Name        string
+	Description string
+	Fields      map[string]*Field
+}
+
+// Schema is an executable schema
+type Schema struct {
+	query   *Object
+	objects map[string]*Object
+}
+
+// NewSchema creates a schema from its query type and the object types it uses
+func NewSchema(query *Object, objects ...*Object) (*Schema, error) {
+	s := &Schema{query: query, objects: map[string]*Object{}}
+	for _, obj := range append([]*Object{query}, objects...) {
+		if scalars[obj.Name] || s.objects[obj.Name] != nil {
+			return nil, fmt.Errorf("duplicate type %s", obj.Name)
+		}
+		s.objects[obj.Name] = obj
+	}
+
+	for _, obj := range s.objects {
+		if len(obj.Fields) == 0 {
+			return nil, fmt.Errorf("type %s has no fields", obj.Name)
+		}
+		for name, f := range obj.Fields {
+			typ, err := parseType(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", obj.Name, name, err)
+			}
+			if !scalars[typ.named()] && s.objects[typ.named()] == nil {
+				return nil, fmt.Errorf("%s.%s: unknown type %s", obj.Name, name, typ.named())
+			}
+			f.typ = typ
+			if f.Cost == 0 {
+				f.Cost = 1
+			}
+			for argName, arg := range f.Args {
+				if arg.typ, err = parseType(arg.Type); err != nil {
+					return nil, fmt.Errorf("%s.%s(%s): %w", obj.Name, name, argName, err)
+				}
+				if !scalars[arg.typ.named()] {
+					return nil, fmt.Errorf("%s.%s(%s): arguments must be scalars", obj.Name, name, argName)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// SDL prints the schema in the GraphQL schema definition language
+func (s *Schema) SDL() string {
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		if name != s.query.Name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{s.query.Name}, names...)
+
+	var b strings.Builder
+	if s.query.Name != "Query" {
+		fmt.Fprintf(&b, "schema {\n  query: %s\n}\n\n", s.query.Name)
+	}
+	for i, name := range names {
+		obj := s.objects[name]
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeDescription(&b, obj.Description, "")
+		fmt.Fprintf(&b, "type %s {\n", obj.Name)
+		fields := make([]string, 0, len(obj.Fields))
+		for name := range obj.Fields {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		for _, name := range fields {
+			f := obj.Fields[name]
+			writeDescription(&b, f.Description, "  ")
+			fmt.Fprintf(&b, "  %s%s: %s\n", name, argsSDL(f.Args), f.typ)
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, description, indent string) {
+	if description != "" {
+		fmt.Fprintf(b, "%s%s\n", indent, strconv.Quote(description))
+	}
+}
+
+func argsSDL(args map[string]*Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		arg := args[name]
+		parts[i] = name + ": " + arg.typ.String()
+		if arg.Default != nil {
+			def, _ := json.Marshal(arg.Default)
+			parts[i] += " = " + string(def)
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// coerceInput converts a variable value (decoded JSON) to an input type
+func coerceInput(t *typeRef, v interface{}) (interface{}, error) {
+	if v == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected a non-null %s", t)
+		}
+		return nil, nil
+	}
+	if t.elem != nil {
+		items, ok := v.([]interface{})
+		if !ok {
+			// a single value is a list of one
+			items = []interface{}{v}
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(t.elem, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = coerced
+		}
+		return out, nil
+	}
+	return coerceScalar(t.name, v)
+}
+
+// coerceScalar converts an input value to a scalar: ID and String to string,
+// Int to int, Float to float64 and Boolean to bool
+func coerceScalar(name string, v interface{}) (interface{}, error) {
+	switch name {
+	case "ID":
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case int:
+			return strconv.Itoa(x), nil
+		case float64:
+			if x == math.Trunc(x) {
+				return strconv.FormatInt(int64(x), 10), nil
+			}
+		case json.Number:
+			if _, err := x.Int64(); err == nil {
+				return x.String(), nil
+			}
+		}
+	case "String":
+		if x, ok := v.(string); ok {
+			return x, nil
+		}
+	case "Boolean":
+		if x, ok := v.(bool); ok {
+			return x, nil
+		}
+	case "Int":
+		switch x := v.(type) {
+		case int:
+			return x, nil
+		case float64:
+			if x == math.Trunc(x) && x >= math.MinInt32 && x <= math.MaxInt32 {
+				return int(x), nil
+			}
+		case json.Number:
+			if n, err := x.Int64(); err == nil && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	case "Float":
+		switch x := v.(type) {
+		case int:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		case json.Number:
+			if f, err := x.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("expected %s, got %v", name, v)
+}
+
+// serializeScalar converts a resolved value to a scalar's output form
+func serializeScalar(name string, v interface{}) (interface{}, error) {
+	switch name {
+	case "ID", "String":
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case json.Number:
+			return x.String(), nil
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64), nil
+		case int, int32, int64, bool:
+			return fmt.Sprint(x), nil
+		}
+	case "Boolean":
+		if x, ok := v.(bool); ok {
+			return x, nil
+		}
+	case "Int":
+		switch x := v.(type) {
+		case int:
+			return x, nil
+		case int32:
+			return int(x), nil
+		case int64:
+			return x, nil
+		case float64:
+			if x == math.Trunc(x) {
+				return int64(x), nil
+			}
+		case json.Number:
+			if n, err := x.Int64(); err == nil {
+				return n, nil
+			}
+		}
+	case "Float":
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case int:
+			return float64(x), nil
+		case int64:
+			return float64(x), nil
+		case json.Number:
+			if f, err := x.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot represent %v as %s", v, name)
+}
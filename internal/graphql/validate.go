@@ -0,0 +1,296 @@
+package graphql
+
+import (
+	"fmt"
+)
+
+// maxFieldVisits bounds the fields a query may select once its fragments
+// are expanded, so fragments spread many times cannot blow up validation
+const maxFieldVisits = 10000
+
+// validator checks an operation against the schema and computes its depth
+// and complexity
+type validator struct {
+	schema  *Schema
+	config  Config
+	doc     *document
+	vars    map[string]interface{}
+	defined map[string]bool
+
+	used      map[string]bool
+	fragments map[string]bool
+	depth     int
+	visits    int
+	errors    []*Error
+	seen      map[string]bool
+}
+
+func (v *validator) errorf(loc Location, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	key := fmt.Sprintf("%d:%d:%s", loc.Line, loc.Column, msg)
+	if v.seen[key] {
+		// fragments spread more than once are walked more than once
+		return
+	}
+	v.seen[key] = true
+	v.errors = append(v.errors, &Error{Message: msg, Locations: []Location{loc}})
+}
+
+func (v *validator) validate(op *operation) []*Error {
+	v.used = map[string]bool{}
+	v.fragments = map[string]bool{}
+	v.seen = map[string]bool{}
+
+	checked := map[string]bool{}
+	for _, frag := range v.doc.fragments {
+		if v.schema.objects[frag.typeCondition] == nil {
+			v.errorf(frag.loc, "Unknown type %q.", frag.typeCondition)
+		}
+		v.fragmentCycles(frag, map[string]bool{}, checked)
+	}
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	v.directives(op.directives)
+	complexity := v.selections(v.schema.query, op.selections, 1)
+	if v.visits > maxFieldVisits {
+		return []*Error{{
+			Message:    fmt.Sprintf("Query selects more than %d fields.", maxFieldVisits),
+			Extensions: map[string]interface{}{"code": "QUERY_TOO_COMPLEX"},
+		}}
+	}
+
+	for _, def := range op.variables {
+		if !v.used[def.name] {
+			v.errorf(def.loc, "Variable \"$%s\" is never used.", def.name)
+		}
+	}
+	for name, frag := range v.doc.fragments {
+		if !v.fragments[name] {
+			v.errorf(frag.loc, "Fragment %q is never used.", name)
+		}
+	}
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	if v.config.MaxDepth > 0 && v.depth > v.config.MaxDepth {
+		return []*Error{{
+			Message:    fmt.Sprintf("Query depth %d exceeds the maximum of %d.", v.depth, v.config.MaxDepth),
+			Extensions: map[string]interface{}{"code": "QUERY_TOO_DEEP", "depth": v.depth, "maxDepth": v.config.MaxDepth},
+		}}
+	}
+	if v.config.MaxComplexity > 0 && complexity > v.config.MaxComplexity {
+		return []*Error{{
+			Message:    fmt.Sprintf("Query complexity %d exceeds the maximum of %d.", complexity, v.config.MaxComplexity),
+			Extensions: map[string]interface{}{"code": "QUERY_TOO_COMPLEX", "complexity": complexity, "maxComplexity": v.config.MaxComplexity},
+		}}
+	}
+	return nil
+}
+
+// fragmentCycles reports fragments that spread themselves. Fragments in
+// checked are known to be free of cycles.
+func (v *validator) fragmentCycles(frag *fragment, path, checked map[string]bool) {
+	if checked[frag.name] {
+		return
+	}
+	if path[frag.name] {
+		v.errorf(frag.loc, "Cannot spread fragment %q within itself.", frag.name)
+		return
+	}
+	path[frag.name] = true
+	v.walkSpreads(frag.selections, func(spread *fragmentSpread) {
+		if next := v.doc.fragments[spread.name]; next != nil {
+			v.fragmentCycles(next, path, checked)
+		}
+	})
+	delete(path, frag.name)
+	checked[frag.name] = true
+}
+
+func (v *validator) walkSpreads(sels []selection, fn func(*fragmentSpread)) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			v.walkSpreads(s.selections, fn)
+		case *inlineFragment:
+			v.walkSpreads(s.selections, fn)
+		case *fragmentSpread:
+			fn(s)
+		}
+	}
+}
+
+// selections validates a selection set and returns its complexity
+func (v *validator) selections(obj *Object, sels []selection, depth int) int {
+	cost := 0
+	for _, sel := range sels {
+		if v.visits > maxFieldVisits {
+			return cost
+		}
+		switch s := sel.(type) {
+		case *field:
+			v.visits++
+			cost += v.field(obj, s, depth)
+		case *inlineFragment:
+			v.directives(s.directives)
+			if s.typeCondition != "" && s.typeCondition != obj.Name {
+				v.errorf(s.loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", obj.Name, s.typeCondition)
+				continue
+			}
+			cost += v.selections(obj, s.selections, depth)
+		case *fragmentSpread:
+			v.directives(s.directives)
+			frag := v.doc.fragments[s.name]
+			if frag == nil {
+				v.errorf(s.loc, "Unknown fragment %q.", s.name)
+				continue
+			}
+			v.fragments[s.name] = true
+			if frag.typeCondition != obj.Name {
+				v.errorf(s.loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", s.name, obj.Name, frag.typeCondition)
+				continue
+			}
+			cost += v.selections(obj, frag.selections, depth)
+		}
+	}
+	return cost
+}
+
+func (v *validator) field(obj *Object, f *field, depth int) int {
+	if depth > v.depth {
+		v.depth = depth
+	}
+	v.directives(f.directives)
+
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || len(f.selections) > 0 {
+			v.errorf(f.loc, "Field \"__typename\" takes no arguments or selections.")
+		}
+		return 0
+	}
+	def := obj.Fields[f.name]
+	if def == nil {
+		v.errorf(f.loc, "Cannot query field %q on type %q.", f.name, obj.Name)
+		return 0
+	}
+
+	provided := map[string]bool{}
+	for _, arg := range f.arguments {
+		provided[arg.name] = true
+		argDef := def.Args[arg.name]
+		if argDef == nil {
+			v.errorf(arg.loc, "Unknown argument %q on field \"%s.%s\".", arg.name, obj.Name, f.name)
+			continue
+		}
+		if !v.variables(arg.value, arg.loc) {
+			continue
+		}
+		if _, err := coerceArgValue(argDef, arg.value, v.vars); err != nil {
+			v.errorf(arg.loc, "Argument %q has invalid value: %s.", arg.name, err)
+		}
+	}
+	for name, argDef := range def.Args {
+		if argDef.typ.nonNull && argDef.Default == nil && !provided[name] {
+			v.errorf(f.loc, "Field %q argument %q of type %q is required, but it was not provided.", f.name, name, argDef.typ)
+		}
+	}
+
+	named := def.typ.named()
+	if scalars[named] {
+		if len(f.selections) > 0 {
+			v.errorf(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, def.typ)
+		}
+		return def.Cost
+	}
+	if len(f.selections) == 0 {
+		v.errorf(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, def.typ)
+		return def.Cost
+	}
+	child := v.selections(v.schema.objects[named], f.selections, depth+1)
+	return def.Cost + child*v.listSize(def, f)
+}
+
+// variables marks the value's variables used and reports undefined ones.
+// It returns false when the value uses an undefined variable.
+func (v *validator) variables(val value, loc Location) bool {
+	switch x := val.(type) {
+	case variable:
+		v.used[string(x)] = true
+		if !v.defined[string(x)] {
+			v.errorf(loc, "Variable \"$%s\" is not defined.", x)
+			return false
+		}
+	case []value:
+		ok := true
+		for _, item := range x {
+			ok = v.variables(item, loc) && ok
+		}
+		return ok
+	case []*objectField:
+		ok := true
+		for _, f := range x {
+			ok = v.variables(f.value, loc) && ok
+		}
+		return ok
+	}
+	return true
+}
+
+func (v *validator) directives(directives []*directive) {
+	for _, d := range directives {
+		if d.name != "include" && d.name != "skip" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		found := false
+		for _, arg := range d.arguments {
+			if arg.name != "if" {
+				v.errorf(arg.loc, "Unknown argument %q on directive \"@%s\".", arg.name, d.name)
+				continue
+			}
+			found = true
+			if !v.variables(arg.value, arg.loc) {
+				continue
+			}
+			if value, err := literal(arg.value, v.vars); err != nil || value == nil {
+				v.errorf(arg.loc, "Directive \"@%s\" argument \"if\" must be a Boolean.", d.name)
+			} else if _, ok := value.(bool); !ok {
+				v.errorf(arg.loc, "Directive \"@%s\" argument \"if\" must be a Boolean.", d.name)
+			}
+		}
+		if !found {
+			v.errorf(d.loc, "Directive \"@%s\" argument \"if\" of type \"Boolean!\" is required, but it was not provided.", d.name)
+		}
+	}
+}
+
+// listSize is the expected number of items of a list field: its first,
+// last, limit or size argument, else the length of a list argument, else
+// the configured default
+func (v *validator) listSize(def *Field, f *field) int {
+	if !isList(def.typ) {
+		return 1
+	}
+	args, err := coerceArgs(def, f.arguments, v.vars)
+	if err != nil {
+		return v.config.DefaultListSize
+	}
+	for _, name := range []string{"first", "last", "limit", "size"} {
+		if n, ok := args[name].(int); ok {
+			return max(n, 1)
+		}
+	}
+	for _, arg := range args {
+		if list, ok := arg.([]interface{}); ok {
+			return max(len(list), 1)
+		}
+	}
+	return v.config.DefaultListSize
+}
+
+func isList(t *typeRef) bool {
+	return t.elem != nil
+}
@@ -0,0 +1,179 @@
+package grpcproxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Verifier checks a bearer token and returns the metadata backends receive
+// for the caller in its place (e.g. x-internal-token), with the token's
+// tenant as x-tenant-id
+type Verifier func(ctx context.Context, token string) (metadata.MD, error)
+
+// tokenTenantKey is the context key of the verified token's tenant
+type tokenTenantKey struct{}
+
+// TokenTenant returns the tenant of the call's token verified by Auth
+func TokenTenant(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tokenTenantKey{}).(string)
+	return tenantID
+}
+
+// Limiters returns the rate limiter for a key
+type Limiters interface {
+	GetLimiter(key string) *rate.Limiter
+}
+
+// serverStream overrides the context of a server stream, to change the
+// incoming metadata seen by later interceptors and the proxy handler
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func withMetadata(ss grpc.ServerStream, md metadata.MD) grpc.ServerStream {
+	return withContext(ss, metadata.NewIncomingContext(ss.Context(), md))
+}
+
+func withContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
+
+// incomingMetadata returns a copy of the call's metadata that may be modified
+func incomingMetadata(ctx context.Context) metadata.MD {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return md.Copy()
+}
+
+// Auth requires a valid bearer token in the authorization metadata, as
+// AuthMiddleware does for HTTP requests. It runs before Tenant, which checks
+// the call's tenant against the token's.
+func Auth(verify Verifier) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md := incomingMetadata(ss.Context())
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return status.Error(codes.Unauthenticated, "authorization metadata required")
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || token == "" || strings.Contains(token, " ") {
+			return status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+		}
+
+		identity, err := verify(ss.Context(), token)
+		if err != nil {
+			return status.Error(codes.Unauthenticated, "invalid or expired token")
+		}
+		// only the gateway issues internal tokens
+		delete(md, "x-internal-token")
+		for key, values := range identity {
+			md[key] = values
+		}
+		ctx := metadata.NewIncomingContext(ss.Context(), md)
+		if tenantID := identity.Get("x-tenant-id"); len(tenantID) > 0 {
+			ctx = context.WithValue(ctx, tokenTenantKey{}, tenantID[0])
+		}
+		return handler(srv, withContext(ss, ctx))
+	}
+}
+
+// Tenant resolves the caller's tenant from the :authority (subdomain or
+// custom domain) or the tenant header, as TenantResolutionMiddleware does,
+// and passes the resolved ID as x-tenant-id. Calls naming no tenant belong
+// to the tenant of the token verified by Auth, and tokens of another tenant
+// than the one named are refused. gRPC calls are not known to be reads, so
+// read-only tenants are refused.
+func Tenant(resolver *tenant.Resolver) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md := incomingMetadata(ss.Context())
+		req, err := http.NewRequestWithContext(ss.Context(), http.MethodPost, info.FullMethod, nil)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid method name")
+		}
+		for key, values := range md {
+			if key == ":authority" && len(values) > 0 {
+				req.Host = values[0]
+				continue
+			}
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+
+		tokenTenant := TokenTenant(ss.Context())
+		profile, _, err := resolver.Resolve(req)
+		if err == nil && profile == nil && tokenTenant != "" {
+			profile, err = resolver.Lookup(ss.Context(), tokenTenant)
+		}
+		if errors.Is(err, tenant.ErrNotFound) {
+			return status.Error(codes.NotFound, "tenant not found")
+		}
+		if err != nil {
+			return status.Error(codes.Unavailable, "tenant service unavailable, please retry")
+		}
+		if profile == nil {
+			return handler(srv, ss)
+		}
+		if tokenTenant != "" && tokenTenant != profile.ID {
+			return status.Error(codes.PermissionDenied, "token does not belong to this tenant")
+		}
+
+		switch err := profile.Admit(req.Method); {
+		case errors.Is(err, tenant.ErrSuspended):
+			return status.Error(codes.PermissionDenied, "tenant is suspended")
+		case errors.Is(err, tenant.ErrReadOnly):
+			return status.Error(codes.PermissionDenied, "tenant is in read-only mode")
+		case err != nil:
+			// Deleted tenants look the same as tenants that never existed
+			return status.Error(codes.NotFound, "tenant not found")
+		}
+		// Backends see the resolved tenant, not whatever the client sent
+		md.Set("x-tenant-id", profile.ID)
+		return handler(srv, withMetadata(ss, md))
+	}
+}
+
+// RateLimit limits calls per client IP
+func RateLimit(limiters Limiters) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !limiters.GetLimiter(peerIP(ss.Context())).Allow() {
+			return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(srv, ss)
+	}
+}
+
+// Metrics records calls by service and status code. Services without a
+// backend are counted as "unknown", keeping client-chosen names out of labels.
+func Metrics(backends Backends) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		service := Service(info.FullMethod)
+		if _, ok := backends.Conn(service); !ok {
+			service = "unknown"
+		}
+
+		metrics.GRPCProxyActiveCalls.WithLabelValues(service).Inc()
+		defer metrics.GRPCProxyActiveCalls.WithLabelValues(service).Dec()
+
+		start := time.Now()
+		err := handler(srv, ss)
+		metrics.GRPCProxyCallDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+		metrics.GRPCProxyCalls.WithLabelValues(service, status.Code(err).String()).Inc()
+		return err
+	}
+}
@@ -0,0 +1,186 @@
+// Package grpcproxy serves native gRPC clients, proxying every call to the
+// backend registered for its service. Messages are relayed undecoded, so any
+// unary or streaming method can be proxied without its protos.
+package grpcproxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Backends finds the connection serving a gRPC service
+type Backends interface {
+	Conn(service string) (*grpc.ClientConn, bool)
+}
+
+// clientStreamDesc lets every kind of method through the same stream
+var clientStreamDesc = &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+
+// skipMetadata are incoming keys describing the client's HTTP/2 exchange,
+// which the gateway's own connection to the backend sets anew
+var skipMetadata = map[string]bool{
+	":authority":   true,
+	"content-type": true,
+	"te":           true,
+	"user-agent":   true,
+}
+
+// NewServer returns a gRPC server that proxies all calls to backends. opts
+// add credentials, interceptors and limits.
+func NewServer(backends Backends, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ForceServerCodec(client.RawCodec{}),
+		grpc.UnknownServiceHandler(Handler(backends)),
+	)
+	return grpc.NewServer(opts...)
+}
+
+// Service returns the service name of a full method name ("/pkg.Service/Method")
+func Service(fullMethod string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service
+}
+
+// Handler relays calls to the backend of their service, passing the client's
+// metadata, deadline and cancellation through and the backend's headers,
+// messages, trailers and status back
+func Handler(backends Backends) grpc.StreamHandler {
+	return func(_ interface{}, ss grpc.ServerStream) error {
+		method, ok := grpc.MethodFromServerStream(ss)
+		if !ok {
+			return status.Error(codes.Internal, "method name unavailable")
+		}
+		conn, ok := backends.Conn(Service(method))
+		if !ok {
+			return status.Errorf(codes.Unimplemented, "unknown service %s", Service(method))
+		}
+
+		ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ss.Context(), outgoingMetadata(ss.Context())))
+		defer cancel()
+		cs, err := conn.NewStream(ctx, clientStreamDesc, method, grpc.ForceCodec(client.RawCodec{}))
+		if err != nil {
+			return err
+		}
+
+		requests := make(chan error, 1)
+		go func() { requests <- forwardRequests(ss, cs) }()
+		responses := make(chan error, 1)
+		go func() { responses <- forwardResponses(cs, ss) }()
+
+		for {
+			select {
+			case err := <-requests:
+				if err != nil {
+					// the client went away or broke the stream; end the backend
+					// call, and stop using ss before returning
+					cancel()
+					<-responses
+					if _, ok := status.FromError(err); ok {
+						return err
+					}
+					return status.FromContextError(err).Err()
+				}
+				// the client finished sending; wait for the backend to finish
+				requests = nil
+			case err := <-responses:
+				ss.SetTrailer(cs.Trailer())
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
+
+// forwardRequests copies the client's messages to the backend until the
+// client half-closes, which is passed on
+func forwardRequests(ss grpc.ServerStream, cs grpc.ClientStream) error {
+	for {
+		var msg []byte
+		err := ss.RecvMsg(&msg)
+		if errors.Is(err, io.EOF) {
+			return cs.CloseSend()
+		}
+		if err != nil {
+			return err
+		}
+		if err := cs.SendMsg(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				// the backend ended the call; its status comes from RecvMsg
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// forwardResponses copies the backend's headers and messages to the client.
+// It returns io.EOF when the call succeeded and the call's error otherwise.
+func forwardResponses(cs grpc.ClientStream, ss grpc.ServerStream) error {
+	headerSent := false
+	for {
+		var msg []byte
+		err := cs.RecvMsg(&msg)
+		if !headerSent {
+			// headers arrive with the first message, or with the status of a
+			// call that has none
+			if header, herr := cs.Header(); herr == nil && len(header) > 0 {
+				if err := ss.SendHeader(header); err != nil {
+					return err
+				}
+			}
+			headerSent = true
+		}
+		if err != nil {
+			return err
+		}
+		if err := ss.SendMsg(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+// outgoingMetadata is the client's metadata minus transport keys, with the
+// caller's address and a correlation ID for the backend
+func outgoingMetadata(ctx context.Context) metadata.MD {
+	in, _ := metadata.FromIncomingContext(ctx)
+	md := metadata.MD{}
+	for key, values := range in {
+		if skipMetadata[key] || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		md[key] = values
+	}
+	if ip := peerIP(ctx); ip != "" {
+		md.Set("x-forwarded-for", ip)
+	}
+	if len(md.Get("x-correlation-id")) == 0 {
+		md.Set("x-correlation-id", uuid.NewString())
+	}
+	return md
+}
+
+// peerIP returns the client's IP address
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
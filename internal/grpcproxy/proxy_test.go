@@ -0,0 +1,316 @@
+package grpcproxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// backends maps services to connections
+type backends map[string]*grpc.ClientConn
+
+func (b backends) Conn(service string) (*grpc.ClientConn, bool) {
+	conn, ok := b[service]
+	return conn, ok
+}
+
+// recorder keeps the metadata of the backend's last call
+type recorder struct {
+	mu sync.Mutex
+	md metadata.MD
+}
+
+func (r *recorder) last() metadata.MD {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.md
+}
+
+func listen(t *testing.T, srv *grpc.Server) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// startGateway serves the health service behind a proxy built with opts and
+// returns a health client talking to the proxy
+func startGateway(t *testing.T, opts ...grpc.ServerOption) (healthpb.HealthClient, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	backend := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			rec.mu.Lock()
+			rec.md = md
+			rec.mu.Unlock()
+			grpc.SetHeader(ctx, metadata.Pairs("x-served-by", "health"))
+			grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "done"))
+			return handler(ctx, req)
+		}),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(backend, hs)
+	backendConn := dial(t, listen(t, backend))
+
+	gateway := NewServer(backends{"grpc.health.v1.Health": backendConn}, opts...)
+	return healthpb.NewHealthClient(dial(t, listen(t, gateway))), rec
+}
+
+func TestProxy_Unary(t *testing.T) {
+	health, rec := startGateway(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-source", "mobile")
+	var header, trailer metadata.MD
+	resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: "users"}, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", resp.Status)
+	}
+	if got := header.Get("x-served-by"); len(got) != 1 || got[0] != "health" {
+		t.Errorf("header x-served-by = %v", got)
+	}
+	if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "done" {
+		t.Errorf("trailer x-trailer = %v", got)
+	}
+
+	md := rec.last()
+	if got := md.Get("x-request-source"); len(got) != 1 || got[0] != "mobile" {
+		t.Errorf("backend x-request-source = %v", got)
+	}
+	if got := md.Get("x-forwarded-for"); len(got) != 1 || got[0] != "127.0.0.1" {
+		t.Errorf("backend x-forwarded-for = %v", got)
+	}
+	if len(md.Get("x-correlation-id")) != 1 {
+		t.Errorf("backend x-correlation-id missing")
+	}
+}
+
+func TestProxy_Status(t *testing.T) {
+	health, _ := startGateway(t)
+
+	_, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("code = %v, want NotFound", status.Code(err))
+	}
+}
+
+func TestProxy_UnknownService(t *testing.T) {
+	gateway := NewServer(backends{})
+	conn := dial(t, listen(t, gateway))
+
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("code = %v, want Unimplemented", status.Code(err))
+	}
+}
+
+func TestProxy_ServerStreaming(t *testing.T) {
+	health, _ := startGateway(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	stream, err := health.Watch(ctx, &healthpb.HealthCheckRequest{Service: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", resp.Status)
+	}
+	// the deadline reaches the backend through the proxy
+	if _, err := stream.Recv(); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("code = %v, want DeadlineExceeded", status.Code(err))
+	}
+}
+
+func TestAuth(t *testing.T) {
+	verify := func(ctx context.Context, token string) (metadata.MD, error) {
+		if token != "good" {
+			return nil, errors.New("invalid token")
+		}
+		return metadata.Pairs("x-internal-token", "internal-jwt", "x-tenant-id", "t-1"), nil
+	}
+	health, rec := startGateway(t, grpc.StreamInterceptor(Auth(verify)))
+	req := &healthpb.HealthCheckRequest{Service: "users"}
+
+	tests := []struct {
+		name  string
+		pairs []string
+		code  codes.Code
+	}{
+		{"missing", nil, codes.Unauthenticated},
+		{"malformed", []string{"authorization", "Basic abc"}, codes.Unauthenticated},
+		{"invalid", []string{"authorization", "Bearer bad"}, codes.Unauthenticated},
+		{"valid", []string{"authorization", "Bearer good", "x-internal-token", "forged"}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tt.pairs...)
+			if _, err := health.Check(ctx, req); status.Code(err) != tt.code {
+				t.Errorf("code = %v, want %v", status.Code(err), tt.code)
+			}
+		})
+	}
+
+	md := rec.last()
+	if got := md.Get("x-internal-token"); len(got) != 1 || got[0] != "internal-jwt" {
+		t.Errorf("backend x-internal-token = %v, want the gateway's", got)
+	}
+	if got := md.Get("x-tenant-id"); len(got) != 1 || got[0] != "t-1" {
+		t.Errorf("backend x-tenant-id = %v", got)
+	}
+}
+
+// tenantSource serves one tenant
+type tenantSource struct {
+	profiles map[string]*tenant.Profile
+}
+
+func (s *tenantSource) GetTenant(ctx context.Context, idOrSlug string) (*tenant.Profile, error) {
+	if p, ok := s.profiles[idOrSlug]; ok {
+		return p, nil
+	}
+	return nil, tenant.ErrNotFound
+}
+
+func (s *tenantSource) GetTenantByDomain(ctx context.Context, domain string) (*tenant.Profile, error) {
+	return nil, tenant.ErrNotFound
+}
+
+func TestTenant(t *testing.T) {
+	source := &tenantSource{profiles: map[string]*tenant.Profile{
+		"acme":   {ID: "t-1", Slug: "acme", Status: tenant.StatusActive},
+		"frozen": {ID: "t-2", Slug: "frozen", Status: tenant.StatusSuspended},
+	}}
+	resolver := tenant.NewResolver(source, tenant.ResolverConfig{Header: "X-Tenant-ID"})
+	health, rec := startGateway(t, grpc.StreamInterceptor(Tenant(resolver)))
+	req := &healthpb.HealthCheckRequest{Service: "users"}
+
+	tests := map[string]codes.Code{"acme": codes.OK, "frozen": codes.PermissionDenied, "nobody": codes.NotFound}
+	for slug, code := range tests {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", slug)
+		if _, err := health.Check(ctx, req); status.Code(err) != code {
+			t.Errorf("%s: code = %v, want %v", slug, status.Code(err), code)
+		}
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "acme")
+	if _, err := health.Check(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.last().Get("x-tenant-id"); len(got) != 1 || got[0] != "t-1" {
+		t.Errorf("backend x-tenant-id = %v, want the resolved ID", got)
+	}
+}
+
+func TestTenant_Token(t *testing.T) {
+	source := &tenantSource{profiles: map[string]*tenant.Profile{
+		"t-1":    {ID: "t-1", Slug: "acme", Status: tenant.StatusActive},
+		"acme":   {ID: "t-1", Slug: "acme", Status: tenant.StatusActive},
+		"other":  {ID: "t-3", Slug: "other", Status: tenant.StatusActive},
+		"t-2":    {ID: "t-2", Slug: "frozen", Status: tenant.StatusSuspended},
+		"frozen": {ID: "t-2", Slug: "frozen", Status: tenant.StatusSuspended},
+	}}
+	verify := func(ctx context.Context, token string) (metadata.MD, error) {
+		return metadata.Pairs("x-internal-token", "internal-jwt", "x-tenant-id", token), nil
+	}
+	resolver := tenant.NewResolver(source, tenant.ResolverConfig{BaseDomains: []string{"example.com"}, Header: "X-Tenant-ID"})
+	health, rec := startGateway(t, grpc.ChainStreamInterceptor(Auth(verify), Tenant(resolver)))
+	req := &healthpb.HealthCheckRequest{Service: "users"}
+
+	tests := []struct {
+		name      string
+		token     string
+		authority string
+		code      codes.Code
+	}{
+		{"token tenant", "t-1", "", codes.OK},
+		{"suspended token tenant", "t-2", "", codes.PermissionDenied},
+		{"unknown token tenant", "t-9", "", codes.NotFound},
+		{"own subdomain", "t-1", "acme.example.com", codes.OK},
+		{"foreign subdomain", "t-1", "other.example.com", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tt.token)
+			var opts []grpc.CallOption
+			if tt.authority != "" {
+				opts = append(opts, grpc.CallAuthority(tt.authority))
+			}
+			if _, err := health.Check(ctx, req, opts...); status.Code(err) != tt.code {
+				t.Errorf("code = %v, want %v", status.Code(err), tt.code)
+			}
+		})
+	}
+
+	// a tenant header is replaced by the token's tenant
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer t-1", "x-tenant-id", "frozen")
+	if _, err := health.Check(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.last().Get("x-tenant-id"); len(got) != 1 || got[0] != "t-1" {
+		t.Errorf("backend x-tenant-id = %v, want the token's tenant", got)
+	}
+}
+
+// singleLimiter shares one limiter between all keys
+type singleLimiter struct {
+	limiter *rate.Limiter
+}
+
+func (l singleLimiter) GetLimiter(string) *rate.Limiter {
+	return l.limiter
+}
+
+func TestRateLimit(t *testing.T) {
+	limiters := singleLimiter{rate.NewLimiter(rate.Every(time.Hour), 1)}
+	health, _ := startGateway(t, grpc.StreamInterceptor(RateLimit(limiters)))
+	req := &healthpb.HealthCheckRequest{Service: "users"}
+
+	if _, err := health.Check(context.Background(), req); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if _, err := health.Check(context.Background(), req); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("code = %v, want ResourceExhausted", status.Code(err))
+	}
+}
+
+func TestService(t *testing.T) {
+	if got := Service("/user.v1.UserService/GetUser"); got != "user.v1.UserService" {
+		t.Errorf("Service = %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// dataFrame and trailerFrame are the gRPC-Web frame type flags
+	dataFrame    byte = 0x00
+	trailerFrame byte = 0x80
+	// compressedFlag marks a message compressed with grpc-encoding
+	compressedFlag byte = 0x01
+
+	frameHeaderLen = 5
+)
+
+// errCompressed is returned for compressed messages, which the gateway does
+// not negotiate and so never expects
+var errCompressed = errors.New("compressed gRPC-Web messages are not supported")
+
+// readMessages splits a request body into its length-prefixed messages
+func readMessages(body []byte) ([][]byte, error) {
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < frameHeaderLen {
+			return nil, fmt.Errorf("truncated gRPC-Web frame header")
+		}
+		flags := body[0]
+		length := binary.BigEndian.Uint32(body[1:frameHeaderLen])
+		if uint64(len(body)-frameHeaderLen) < uint64(length) {
+			return nil, fmt.Errorf("truncated gRPC-Web frame: want %d bytes, have %d", length, len(body)-frameHeaderLen)
+		}
+		if flags&trailerFrame != 0 {
+			return nil, fmt.Errorf("unexpected trailer frame in request")
+		}
+		if flags&compressedFlag != 0 {
+			return nil, errCompressed
+		}
+		messages = append(messages, body[frameHeaderLen:frameHeaderLen+length])
+		body = body[frameHeaderLen+length:]
+	}
+	return messages, nil
+}
+
+// decodeText decodes a grpc-web-text body. Clients may send it as several
+// separately padded base64 chunks, so it is decoded a chunk at a time.
+func decodeText(body []byte) ([]byte, error) {
+	body = bytes.TrimSpace(body)
+	var out []byte
+	for len(body) > 0 {
+		end := bytes.IndexByte(body, '=')
+		if end < 0 {
+			end = len(body)
+		} else {
+			for end < len(body) && body[end] == '=' {
+				end++
+			}
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(chunk, body[:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid grpc-web-text body: %w", err)
+		}
+		out = append(out, chunk[:n]...)
+		body = body[end:]
+	}
+	return out, nil
+}
+
+// frameWriter writes response frames, base64 encoding each for text mode so
+// clients can decode them as they arrive
+type frameWriter struct {
+	w    io.Writer
+	text bool
+}
+
+func (f *frameWriter) writeFrame(flags byte, payload []byte) error {
+	frame := make([]byte, frameHeaderLen+len(payload))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:frameHeaderLen], uint32(len(payload)))
+	copy(frame[frameHeaderLen:], payload)
+	if f.text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	_, err := f.w.Write(frame)
+	if err == nil {
+		if flusher, ok := f.w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	return err
+}
+
+// writeTrailers sends trailers as the final frame: HTTP/1.1-style header
+// lines with lower-case names, since browsers cannot read HTTP trailers
+func (f *frameWriter) writeTrailers(trailers metadata.MD) error {
+	keys := make([]string, 0, len(trailers))
+	for key := range trailers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range encodeValues(key, trailers[key]) {
+			b.WriteString(strings.ToLower(key))
+			b.WriteString(": ")
+			b.WriteString(value)
+			b.WriteString("\r\n")
+		}
+	}
+	return f.writeFrame(trailerFrame, []byte(b.String()))
+}
+
+// encodeValues base64 encodes binary metadata, which travels as raw bytes in
+// gRPC but must be text in HTTP headers and the trailer frame
+func encodeValues(key string, values []string) []string {
+	if !strings.HasSuffix(key, "-bin") {
+		return values
+	}
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = base64.RawStdEncoding.EncodeToString([]byte(value))
+	}
+	return encoded
+}
@@ -0,0 +1,270 @@
+// Package grpcweb translates gRPC-Web requests from browsers into native gRPC
+// calls. Unary and server-streaming methods are supported in both the binary
+// (application/grpc-web) and base64 text (application/grpc-web-text) modes;
+// messages are passed through undecoded, so no protos are needed.
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeBinary = "application/grpc-web"
+	contentTypeText   = "application/grpc-web-text"
+)
+
+var (
+	// RequestHeaders are the headers browsers must be allowed to send by CORS
+	RequestHeaders = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout"}
+	// ResponseHeaders are the headers browsers must be allowed to read by CORS
+	ResponseHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// streamDesc covers both unary and server-streaming methods; gRPC-Web has no
+// client streaming
+var streamDesc = &grpc.StreamDesc{ServerStreams: true}
+
+// skipHeaders are request headers that describe the HTTP exchange rather than
+// the call, and are not passed to the backend as metadata
+var skipHeaders = map[string]bool{
+	"accept":            true,
+	"accept-encoding":   true,
+	"accept-language":   true,
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"cookie":            true,
+	"host":              true,
+	"keep-alive":        true,
+	"origin":            true,
+	"referer":           true,
+	"te":                true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"user-agent":        true,
+	"x-grpc-web":        true,
+	"x-user-agent":      true,
+}
+
+// IsRequest reports whether the content type is a gRPC-Web one
+func IsRequest(contentType string) bool {
+	return strings.HasPrefix(contentType, contentTypeBinary)
+}
+
+func isText(contentType string) bool {
+	return strings.HasPrefix(contentType, contentTypeText)
+}
+
+// HeaderMetadata returns the request headers that carry call metadata.
+// gRPC-Web clients send metadata as plain headers, with binary (-bin) values
+// base64 encoded; reserved grpc- headers are left out.
+func HeaderMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range header {
+		key := strings.ToLower(name)
+		if skipHeaders[key] || strings.HasPrefix(key, "grpc-") || strings.HasPrefix(key, "proxy-") {
+			continue
+		}
+		if strings.HasSuffix(key, "-bin") {
+			for _, value := range values {
+				decoded, err := decodeBinaryHeader(value)
+				if err != nil {
+					continue
+				}
+				md.Append(key, string(decoded))
+			}
+			continue
+		}
+		md.Append(key, values...)
+	}
+	return md
+}
+
+func decodeBinaryHeader(value string) ([]byte, error) {
+	if len(value)%4 == 0 {
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// Timeout parses a grpc-timeout header value such as "10S" or "250m"
+func Timeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// Proxy calls method on conn with the request's message and metadata md, and
+// writes the replies and final status back in the request's gRPC-Web mode.
+// It returns the call's status code.
+func Proxy(w http.ResponseWriter, r *http.Request, conn grpc.ClientConnInterface, method string, md metadata.MD) codes.Code {
+	out := newFrameWriter(w, r)
+	msg, err := readRequest(r.Body, out.text)
+	if err != nil {
+		return finish(w, out, nil, status.Error(codes.InvalidArgument, err.Error()), false)
+	}
+
+	ctx := r.Context()
+	if timeout, ok := Timeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ctx, md))
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, streamDesc, method, grpc.ForceCodec(client.RawCodec{}))
+	if err != nil {
+		return finish(w, out, nil, err, false)
+	}
+	if err := stream.SendMsg(&msg); err != nil && !errors.Is(err, io.EOF) {
+		return finish(w, out, stream.Trailer(), err, false)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return finish(w, out, stream.Trailer(), err, false)
+	}
+
+	// The first reply is awaited before committing the response, so a call
+	// that fails outright gets a trailers-only response
+	var reply []byte
+	err = stream.RecvMsg(&reply)
+	header, _ := stream.Header()
+	for key, values := range header {
+		if key == "content-type" || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		for _, value := range encodeValues(key, values) {
+			w.Header().Add(key, value)
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return finish(w, out, stream.Trailer(), err, false)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	for err == nil {
+		if err := out.writeFrame(dataFrame, reply); err != nil {
+			// the client went away; cancelling ctx ends the call
+			return codes.Canceled
+		}
+		err = stream.RecvMsg(&reply)
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return finish(w, out, stream.Trailer(), err, true)
+}
+
+// WriteError answers the request with err's status without calling a backend
+func WriteError(w http.ResponseWriter, r *http.Request, err error) codes.Code {
+	return finish(w, newFrameWriter(w, r), nil, err, false)
+}
+
+// newFrameWriter sets the response content type for the request's mode
+func newFrameWriter(w http.ResponseWriter, r *http.Request) *frameWriter {
+	text := isText(r.Header.Get("Content-Type"))
+	if text {
+		w.Header().Set("Content-Type", contentTypeText+"+proto")
+	} else {
+		w.Header().Set("Content-Type", contentTypeBinary+"+proto")
+	}
+	return &frameWriter{w: w, text: text}
+}
+
+// readRequest returns the single request message in the body
+func readRequest(body io.Reader, text bool) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if text {
+		if data, err = decodeText(data); err != nil {
+			return nil, err
+		}
+	}
+	messages, err := readMessages(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("expected one request message, got %d", len(messages))
+	}
+	return messages[0], nil
+}
+
+// finish writes the call status as the trailer frame. When no reply headers
+// were sent yet the status is also set as headers (a trailers-only response),
+// which is where clients look for it on an early failure.
+func finish(w http.ResponseWriter, out *frameWriter, trailer metadata.MD, err error, started bool) codes.Code {
+	st := status.Convert(err)
+	trailers := metadata.MD{}
+	for key, values := range trailer {
+		trailers[key] = values
+	}
+	trailers.Set("grpc-status", strconv.Itoa(int(st.Code())))
+	trailers.Set("grpc-message", encodeMessage(st.Message()))
+	if len(st.Details()) > 0 {
+		if details, err := proto.Marshal(st.Proto()); err == nil {
+			trailers.Set("grpc-status-details-bin", string(details))
+		}
+	}
+
+	if !started {
+		for _, key := range []string{"grpc-status", "grpc-message", "grpc-status-details-bin"} {
+			if values := trailers.Get(key); len(values) > 0 {
+				w.Header().Set(key, encodeValues(key, values)[0])
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	// a failed write means the client is gone; there is no one left to tell
+	_ = out.writeTrailers(trailers)
+	return st.Code()
+}
+
+// encodeMessage percent-encodes a status message as the gRPC spec requires
+// for grpc-message
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
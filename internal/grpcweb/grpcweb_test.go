@@ -0,0 +1,271 @@
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	checkMethod = "/grpc.health.v1.Health/Check"
+	watchMethod = "/grpc.health.v1.Health/Watch"
+)
+
+// startHealthServer serves the standard health service and records the
+// metadata of the last call
+func startHealthServer(t *testing.T) (*grpc.ClientConn, *metadata.MD) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen metadata.MD
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			seen, _ = metadata.FromIncomingContext(ctx)
+			grpc.SetHeader(ctx, metadata.Pairs("x-served-by", "health"))
+			return handler(ctx, req)
+		}),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, &seen
+}
+
+func frame(flags byte, payload []byte) []byte {
+	out := make([]byte, frameHeaderLen+len(payload))
+	out[0] = flags
+	binary.BigEndian.PutUint32(out[1:], uint32(len(payload)))
+	copy(out[frameHeaderLen:], payload)
+	return out
+}
+
+func checkRequest(t *testing.T, service string) []byte {
+	t.Helper()
+	msg, err := proto.Marshal(&healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame(dataFrame, msg)
+}
+
+type response struct {
+	messages [][]byte
+	trailers map[string]string
+	header   http.Header
+}
+
+// parseResponse splits a binary response body into messages and trailers
+func parseResponse(t *testing.T, rec *httptest.ResponseRecorder) response {
+	t.Helper()
+	body := rec.Body.Bytes()
+	resp := response{trailers: map[string]string{}, header: rec.Header()}
+	for len(body) > 0 {
+		flags := body[0]
+		length := binary.BigEndian.Uint32(body[1:frameHeaderLen])
+		payload := body[frameHeaderLen : frameHeaderLen+length]
+		body = body[frameHeaderLen+length:]
+		if flags&trailerFrame == 0 {
+			resp.messages = append(resp.messages, payload)
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(payload)), "\r\n") {
+			key, value, _ := strings.Cut(line, ": ")
+			resp.trailers[key] = value
+		}
+	}
+	return resp
+}
+
+func TestProxy_Unary(t *testing.T) {
+	conn, seen := startHealthServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, checkMethod, bytes.NewReader(checkRequest(t, "users")))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	rec := httptest.NewRecorder()
+	md := metadata.Pairs("x-tenant-id", "acme")
+
+	if code := Proxy(rec, req, conn, checkMethod, md); code != codes.OK {
+		t.Fatalf("code = %v, want OK", code)
+	}
+	if got := rec.Header().Values("Content-Type"); len(got) != 1 || got[0] != "application/grpc-web+proto" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Header().Get("X-Served-By"); got != "health" {
+		t.Errorf("response metadata header = %q, want health", got)
+	}
+	resp := parseResponse(t, rec)
+	if len(resp.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(resp.messages))
+	}
+	var reply healthpb.HealthCheckResponse
+	if err := proto.Unmarshal(resp.messages[0], &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", reply.Status)
+	}
+	if resp.trailers["grpc-status"] != "0" {
+		t.Errorf("grpc-status trailer = %q, want 0", resp.trailers["grpc-status"])
+	}
+	if got := seen.Get("x-tenant-id"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("backend metadata x-tenant-id = %v", got)
+	}
+}
+
+func TestProxy_TextMode(t *testing.T) {
+	conn, _ := startHealthServer(t)
+
+	// two separately padded chunks, as streaming clients may send
+	raw := checkRequest(t, "users")
+	body := base64.StdEncoding.EncodeToString(raw[:4]) + base64.StdEncoding.EncodeToString(raw[4:])
+	req := httptest.NewRequest(http.MethodPost, checkMethod, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/grpc-web-text")
+	rec := httptest.NewRecorder()
+
+	if code := Proxy(rec, req, conn, checkMethod, nil); code != codes.OK {
+		t.Fatalf("code = %v, want OK", code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/grpc-web-text+proto" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	decoded, err := decodeText(rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Body = bytes.NewBuffer(decoded)
+	resp := parseResponse(t, rec)
+	if len(resp.messages) != 1 || resp.trailers["grpc-status"] != "0" {
+		t.Errorf("got %d messages, trailers %v", len(resp.messages), resp.trailers)
+	}
+}
+
+func TestProxy_Error(t *testing.T) {
+	conn, _ := startHealthServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, checkMethod, bytes.NewReader(checkRequest(t, "missing")))
+	req.Header.Set("Content-Type", "application/grpc-web")
+	rec := httptest.NewRecorder()
+
+	if code := Proxy(rec, req, conn, checkMethod, nil); code != codes.NotFound {
+		t.Fatalf("code = %v, want NotFound", code)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("HTTP status = %d, want 200", rec.Code)
+	}
+	// trailers-only: the status is in the headers as well as the trailer frame
+	if got := rec.Header().Get("Grpc-Status"); got != "5" {
+		t.Errorf("grpc-status header = %q, want 5", got)
+	}
+	resp := parseResponse(t, rec)
+	if len(resp.messages) != 0 {
+		t.Errorf("got %d messages, want none", len(resp.messages))
+	}
+	if resp.trailers["grpc-status"] != "5" || !strings.Contains(resp.trailers["grpc-message"], "unknown service") {
+		t.Errorf("trailers = %v", resp.trailers)
+	}
+}
+
+func TestProxy_ServerStreaming(t *testing.T) {
+	conn, _ := startHealthServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, watchMethod, bytes.NewReader(checkRequest(t, "users")))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	// Watch never ends on its own
+	req.Header.Set("Grpc-Timeout", "200m")
+	rec := httptest.NewRecorder()
+
+	if code := Proxy(rec, req, conn, watchMethod, nil); code != codes.DeadlineExceeded {
+		t.Fatalf("code = %v, want DeadlineExceeded", code)
+	}
+	resp := parseResponse(t, rec)
+	if len(resp.messages) != 1 {
+		t.Errorf("got %d messages, want the initial status", len(resp.messages))
+	}
+	if resp.trailers["grpc-status"] != "4" {
+		t.Errorf("grpc-status trailer = %q, want 4", resp.trailers["grpc-status"])
+	}
+}
+
+func TestProxy_InvalidBody(t *testing.T) {
+	conn, _ := startHealthServer(t)
+
+	tests := map[string][]byte{
+		"truncated":  checkRequest(t, "users")[:7],
+		"no message": {},
+		"two":        append(checkRequest(t, "users"), checkRequest(t, "users")...),
+		"compressed": frame(compressedFlag, []byte("x")),
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, checkMethod, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/grpc-web")
+			rec := httptest.NewRecorder()
+			if code := Proxy(rec, req, conn, checkMethod, nil); code != codes.InvalidArgument {
+				t.Errorf("code = %v, want InvalidArgument", code)
+			}
+		})
+	}
+}
+
+func TestHeaderMetadata(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/grpc-web")
+	header.Set("X-Grpc-Web", "1")
+	header.Set("Grpc-Timeout", "1S")
+	header.Set("Cookie", "session=1")
+	header.Set("X-Request-Source", "web")
+	header.Set("Trace-Bin", base64.StdEncoding.EncodeToString([]byte{0, 1, 2}))
+
+	md := HeaderMetadata(header)
+	if len(md) != 2 {
+		t.Errorf("metadata = %v, want two keys", md)
+	}
+	if got := md.Get("x-request-source"); len(got) != 1 || got[0] != "web" {
+		t.Errorf("x-request-source = %v", got)
+	}
+	if got := md.Get("trace-bin"); len(got) != 1 || got[0] != "\x00\x01\x02" {
+		t.Errorf("trace-bin = %q, want decoded bytes", got)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := map[string]bool{"10S": true, "250m": true, "1H": true, "5": false, "10x": false, "-1S": false, "123456789S": false}
+	for value, ok := range tests {
+		if _, got := Timeout(value); got != ok {
+			t.Errorf("Timeout(%q) ok = %v, want %v", value, got, ok)
+		}
+	}
+	if d, _ := Timeout("250m"); d.Milliseconds() != 250 {
+		t.Errorf("Timeout(250m) = %v", d)
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	if got := encodeMessage("not found: 100%\n"); got != "not found: 100%25%0A" {
+		t.Errorf("encodeMessage = %q", got)
+	}
+}
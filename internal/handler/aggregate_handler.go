@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/aggregate"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// AggregateHandler serves a route whose response is composed from several calls
+type AggregateHandler struct {
+	aggregator *aggregate.Aggregator
+	route      *aggregate.Route
+}
+
+// NewAggregateHandler creates a handler for an aggregate route
+func NewAggregateHandler(aggregator *aggregate.Aggregator, route *aggregate.Route) *AggregateHandler {
+	return &AggregateHandler{aggregator: aggregator, route: route}
+}
+
+// Handle makes the route's calls on behalf of the client and merges the results
+func (h *AggregateHandler) Handle(c *gin.Context) {
+	if subrequest.IsSubRequest(c.Request) {
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("NESTED_SUBREQUEST", "Aggregates cannot be called from other aggregates or batches", nil, c.GetString("correlation_id")))
+		return
+	}
+
+	params := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = param.Value
+	}
+	result := h.aggregator.Run(c.Request, h.route, params)
+	metrics.AggregateRequests.WithLabelValues(h.route.Path, result.Outcome()).Inc()
+	c.JSON(result.Status(), result)
+}
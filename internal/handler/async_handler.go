@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/async"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+)
+
+// AsyncHandler serves the status and results of background requests
+type AsyncHandler struct {
+	manager *async.Manager
+	path    string
+}
+
+// NewAsyncHandler creates a handler for the jobs under path
+func NewAsyncHandler(manager *async.Manager, path string) *AsyncHandler {
+	return &AsyncHandler{manager: manager, path: path}
+}
+
+// Status returns a job's state; finished jobs link to their result
+func (h *AsyncHandler) Status(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	resp := gin.H{"job": job}
+	if job.Finished() {
+		resp["result_url"] = h.path + "/" + job.ID + "/result"
+	} else {
+		c.Header("Retry-After", "2")
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Result replays a finished job's response
+func (h *AsyncHandler) Result(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	correlationID := c.GetString("correlation_id")
+	if !job.Finished() {
+		c.Header("Location", h.path+"/"+job.ID)
+		c.Header("Retry-After", "2")
+		c.JSON(http.StatusConflict, apierrors.NewErrorResponse("JOB_NOT_FINISHED", "The request is still running", gin.H{"status": job.Status}, correlationID))
+		return
+	}
+	result, err := h.manager.Result(c.Request.Context(), job)
+	if err != nil {
+		c.JSON(http.StatusNotFound, apierrors.NewErrorResponse("JOB_NOT_FOUND", "Job not found or expired", nil, correlationID))
+		return
+	}
+
+	for name, values := range result.Header {
+		if name == "Content-Length" {
+			continue
+		}
+		c.Writer.Header()[name] = values
+	}
+	c.Status(result.Status)
+	c.Writer.Write(result.Body)
+}
+
+// job reads the caller's job named in the path, responding 404 for jobs
+// that are unknown, expired or not the caller's
+func (h *AsyncHandler) job(c *gin.Context) (*async.Job, bool) {
+	owner := async.Owner{UserID: c.GetString("user_id"), TenantID: c.GetString("tenant_id")}
+	job, err := h.manager.Get(c.Request.Context(), c.Param("id"), owner)
+	if err != nil {
+		c.JSON(http.StatusNotFound, apierrors.NewErrorResponse("JOB_NOT_FOUND", "Job not found or expired", nil, c.GetString("correlation_id")))
+		return nil, false
+	}
+	return job, true
+}
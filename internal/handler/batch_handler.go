@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/batch"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// BatchHandler serves several requests sent in one
+type BatchHandler struct {
+	runner *batch.Runner
+}
+
+// NewBatchHandler creates a batch handler
+func NewBatchHandler(runner *batch.Runner) *BatchHandler {
+	return &BatchHandler{runner: runner}
+}
+
+// Handle runs a JSON array of requests and responds with their responses, in order
+func (h *BatchHandler) Handle(c *gin.Context) {
+	correlationID := c.GetString("correlation_id")
+	if subrequest.IsSubRequest(c.Request) {
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("NESTED_SUBREQUEST", "Batches cannot be called from other batches or aggregates", nil, correlationID))
+		return
+	}
+
+	var items []batch.Item
+	if err := json.NewDecoder(c.Request.Body).Decode(&items); err != nil {
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("INVALID_BATCH", "Batch must be a JSON array of requests", err.Error(), correlationID))
+		return
+	}
+	if err := h.runner.Validate(items); err != nil {
+		if errors.Is(err, batch.ErrTooManyItems) {
+			c.JSON(http.StatusRequestEntityTooLarge, apierrors.NewErrorResponse("BATCH_TOO_LARGE", "Batch has too many requests", err.Error(), correlationID))
+			return
+		}
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("INVALID_BATCH", "Invalid batch request", err.Error(), correlationID))
+		return
+	}
+
+	c.JSON(http.StatusOK, h.runner.Run(c.Request, items))
+}
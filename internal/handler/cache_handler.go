@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/cache"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// defaultCacheKeysLimit caps how many keys are listed when no limit is given
+const defaultCacheKeysLimit = 100
+
+// InspectableCache is a cache whose keys can be listed and purged by prefix
+type InspectableCache interface {
+	Keys(ctx context.Context, prefix string, limit int) ([]string, error)
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+	Stats() cache.Stats
+}
+
+// CacheHandler exposes cache inspection and purging for operators
+type CacheHandler struct {
+	cache InspectableCache
+	log   *logger.Logger
+}
+
+// NewCacheHandler creates a new cache handler
+func NewCacheHandler(cache InspectableCache, log *logger.Logger) *CacheHandler {
+	return &CacheHandler{
+		cache: cache,
+		log:   log,
+	}
+}
+
+// Stats returns the local cache counters
+func (h *CacheHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.cache.Stats())
+}
+
+// Keys lists cached keys.
+// Query params: prefix, limit (default: 100)
+func (h *CacheHandler) Keys(c *gin.Context) {
+	limit := defaultCacheKeysLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit', expected a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	prefix := c.Query("prefix")
+	keys, err := h.cache.Keys(c.Request.Context(), prefix, limit)
+	if err != nil {
+		h.log.Error("Failed to list cache keys", zap.String("prefix", prefix), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list cache keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prefix": prefix,
+		"keys":   keys,
+		"count":  len(keys),
+	})
+}
+
+// Purge removes every key starting with the required 'prefix' query param
+func (h *CacheHandler) Purge(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'prefix' is required"})
+		return
+	}
+
+	deleted, err := h.cache.DeletePrefix(c.Request.Context(), prefix)
+	if err != nil {
+		h.log.Error("Failed to purge cache keys", zap.String("prefix", prefix), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge cache keys"})
+		return
+	}
+
+	h.log.Info("Purged cache keys", zap.String("prefix", prefix), zap.Int("deleted", deleted))
+	c.JSON(http.StatusOK, gin.H{
+		"prefix":  prefix,
+		"deleted": deleted,
+	})
+}
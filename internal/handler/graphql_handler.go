@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/graphql"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// PermissionChecker checks a user's permissions within a tenant and returns
+// the missing ones, like PermissionMiddleware.HasPermissions
+type PermissionChecker func(ctx context.Context, userID, tenantID string, permissions ...string) (bool, []string, error)
+
+// GraphQLHandler serves the gateway's GraphQL endpoint
+type GraphQLHandler struct {
+	schema      *graphql.Schema
+	sources     *GraphQLSources
+	config      graphql.Config
+	persisted   *graphql.PersistedQueries
+	permissions PermissionChecker
+	log         *logger.Logger
+}
+
+// NewGraphQLHandler creates a GraphQL handler. Persisted queries are
+// optional; config's Authorize is set from permissions.
+func NewGraphQLHandler(schema *graphql.Schema, sources *GraphQLSources, config graphql.Config, persisted *graphql.PersistedQueries, permissions PermissionChecker, log *logger.Logger) *GraphQLHandler {
+	h := &GraphQLHandler{
+		schema:      schema,
+		sources:     sources,
+		config:      config,
+		persisted:   persisted,
+		permissions: permissions,
+		log:         log,
+	}
+	h.config.Authorize = h.authorize
+	return h
+}
+
+// Handle executes a query sent as JSON in a POST body, or in the query,
+// operationName, variables and extensions parameters of a GET request
+func (h *GraphQLHandler) Handle(c *gin.Context) {
+	correlationID := c.GetString("correlation_id")
+	if subrequest.IsSubRequest(c.Request) {
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("NESTED_SUBREQUEST", "GraphQL cannot be called from aggregates or batches", nil, correlationID))
+		return
+	}
+
+	req, err := h.readRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("INVALID_REQUEST", "Invalid GraphQL request", err.Error(), correlationID))
+		return
+	}
+
+	start := time.Now()
+	resp := h.execute(c, req)
+	metrics.GraphQLRequestDuration.Observe(time.Since(start).Seconds())
+	metrics.GraphQLRequests.WithLabelValues(graphqlOutcome(resp)).Inc()
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *GraphQLHandler) execute(c *gin.Context, req *graphql.Request) *graphql.Response {
+	if h.persisted != nil {
+		if gqlErr := h.persisted.Resolve(c.Request.Context(), req); gqlErr != nil {
+			return graphql.ErrorResponse(gqlErr)
+		}
+	}
+	if req.Query == "" {
+		return graphql.ErrorResponse(&graphql.Error{Message: "Must provide query string."})
+	}
+
+	ctx := h.sources.newGraphQLRequest(c.Request, c.GetString("user_id"), c.GetString("tenant_id"), h.authorize)
+	resp := h.schema.Execute(ctx, h.config, *req)
+	for _, gqlErr := range resp.Errors {
+		if code := gqlErr.Extensions["code"]; gqlErr.Path != nil && code != "FORBIDDEN" && code != "UNAUTHENTICATED" {
+			h.log.Warn("GraphQL field failed",
+				zap.String("correlation_id", c.GetString("correlation_id")),
+				zap.Any("path", gqlErr.Path),
+				zap.String("error", gqlErr.Message))
+		}
+	}
+	return resp
+}
+
+// readRequest decodes the GraphQL request of a GET or POST
+func (h *GraphQLHandler) readRequest(c *gin.Context) (*graphql.Request, error) {
+	req := &graphql.Request{}
+	if c.Request.Method != http.MethodGet {
+		if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+			return nil, fmt.Errorf("body must be a JSON GraphQL request: %w", err)
+		}
+		return req, nil
+	}
+
+	query := c.Request.URL.Query()
+	req.Query = query.Get("query")
+	req.OperationName = query.Get("operationName")
+	if vars := query.Get("variables"); vars != "" {
+		if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+			return nil, fmt.Errorf("variables must be a JSON object: %w", err)
+		}
+	}
+	if ext := query.Get("extensions"); ext != "" {
+		if err := json.Unmarshal([]byte(ext), &req.Extensions); err != nil {
+			return nil, fmt.Errorf("extensions must be a JSON object: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// authorize checks a field's permission for the caller
+func (h *GraphQLHandler) authorize(ctx context.Context, permission string) error {
+	req := requestFrom(ctx)
+	ok, _, err := h.permissions(ctx, req.userID, req.tenantID, permission)
+	if err != nil {
+		h.log.Error("Permission check error",
+			zap.String("user_id", req.userID),
+			zap.String("tenant_id", req.tenantID),
+			zap.String("permission", permission),
+			zap.Error(err))
+		return &graphql.Error{Message: "permission check failed", Extensions: map[string]interface{}{"code": "INTERNAL_SERVER_ERROR"}}
+	}
+	if !ok {
+		return &graphql.Error{
+			Message:    "insufficient permissions",
+			Extensions: map[string]interface{}{"code": "FORBIDDEN", "required_permissions": []string{permission}},
+		}
+	}
+	return nil
+}
+
+// Schema serves the schema in SDL
+func (h *GraphQLHandler) Schema(c *gin.Context) {
+	c.String(http.StatusOK, h.schema.SDL())
+}
+
+// graphqlOutcome labels a response for metrics
+func graphqlOutcome(resp *graphql.Response) string {
+	switch {
+	case len(resp.Errors) == 0:
+		return "success"
+	case !resp.Executed():
+		return "rejected"
+	case resp.Data == nil:
+		return "error"
+	}
+	return "partial"
+}
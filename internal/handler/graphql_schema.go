@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	"github.com/vhvplatform/go-api-gateway/internal/graphql"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/subrequest"
+)
+
+// GraphQLSources are the services the gateway's GraphQL schema reads from
+type GraphQLSources struct {
+	// Dispatcher calls the gateway's REST routes on the client's behalf, so
+	// upstream calls get the client's authentication and rate limits
+	Dispatcher *subrequest.Dispatcher
+	// Tenants looks up tenants by ID over gRPC
+	Tenants interface {
+		GetTenant(ctx context.Context, idOrSlug string) (*client.Tenant, error)
+	}
+	// UsersPath is the gateway path of the user collection; users are read
+	// from UsersPath/{id} and the caller from UsersPath/me
+	UsersPath string
+	// NotificationsPath is the gateway path of the caller's notifications
+	NotificationsPath string
+}
+
+// graphqlRequestKey is the context key of a request's graphqlRequest
+type graphqlRequestKey struct{}
+
+// CrossTenantPermission lets a GraphQL caller read tenants other than their own
+const CrossTenantPermission = "system.tenants"
+
+// graphqlRequest is the state of one GraphQL request shared by its resolvers
+type graphqlRequest struct {
+	parent   *http.Request
+	userID   string
+	tenantID string
+	// authorize checks a permission for the caller
+	authorize func(ctx context.Context, permission string) error
+	users     *graphql.Loader
+	tenants   *graphql.Loader
+}
+
+func requestFrom(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlRequestKey{}).(*graphqlRequest)
+}
+
+// newGraphQLRequest creates a request's state and loaders. The services
+// have no batch endpoints, so each batch fetches its distinct keys
+// concurrently; batching still removes duplicate calls for the same user or
+// tenant across the query.
+func (s *GraphQLSources) newGraphQLRequest(r *http.Request, userID, tenantID string, authorize func(ctx context.Context, permission string) error) context.Context {
+	req := &graphqlRequest{parent: r, userID: userID, tenantID: tenantID, authorize: authorize}
+	ctx := context.WithValue(r.Context(), graphqlRequestKey{}, req)
+	req.users = graphql.NewLoader(ctx, s.loadEach("users", func(ctx context.Context, id string) (interface{}, error) {
+		return s.get(ctx, s.UsersPath+"/"+url.PathEscape(id))
+	}))
+	req.tenants = graphql.NewLoader(ctx, s.loadEach("tenants", func(ctx context.Context, id string) (interface{}, error) {
+		tenant, err := s.Tenants.GetTenant(ctx, id)
+		if err != nil || tenant == nil {
+			return nil, err
+		}
+		return tenantObject(tenant), nil
+	}))
+	return ctx
+}
+
+// loadEach makes a batch function that loads keys concurrently, one call each
+func (s *GraphQLSources) loadEach(source string, load func(ctx context.Context, key string) (interface{}, error)) graphql.BatchFunc {
+	return func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		metrics.GraphQLLoaderBatchSize.WithLabelValues(source).Observe(float64(len(keys)))
+		values := make([]interface{}, len(keys))
+		errs := make([]error, len(keys))
+		var wg sync.WaitGroup
+		for i, key := range keys {
+			wg.Add(1)
+			go func(i int, key string) {
+				defer wg.Done()
+				values[i], errs[i] = load(ctx, key)
+			}(i, key)
+		}
+		wg.Wait()
+
+		out := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			out[key] = values[i]
+		}
+		return out, nil
+	}
+}
+
+// get reads a JSON document from a gateway route. Not found is null.
+func (s *GraphQLSources) get(ctx context.Context, path string) (interface{}, error) {
+	resp, err := s.Dispatcher.Do(ctx, requestFrom(ctx).parent, subrequest.Request{Method: http.MethodGet, Path: path})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.Status == http.StatusNotFound:
+		return nil, nil
+	case resp.Status == http.StatusUnauthorized:
+		return nil, &graphql.Error{Message: "authentication required", Extensions: map[string]interface{}{"code": "UNAUTHENTICATED"}}
+	case resp.Status == http.StatusForbidden:
+		return nil, &graphql.Error{Message: "insufficient permissions", Extensions: map[string]interface{}{"code": "FORBIDDEN"}}
+	case resp.Status >= 300:
+		return nil, &graphql.Error{
+			Message:    fmt.Sprintf("upstream returned %d", resp.Status),
+			Extensions: map[string]interface{}{"code": "UPSTREAM_ERROR", "status": resp.Status},
+		}
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		return nil, &graphql.Error{Message: "upstream returned invalid JSON", Extensions: map[string]interface{}{"code": "UPSTREAM_ERROR"}}
+	}
+	return unwrapData(body), nil
+}
+
+// unwrapData returns the payload of {"data": ...} envelopes
+func unwrapData(body interface{}) interface{} {
+	if m, ok := body.(map[string]interface{}); ok {
+		switch data := m["data"].(type) {
+		case map[string]interface{}, []interface{}:
+			return data
+		}
+	}
+	return body
+}
+
+// tenantObject converts a tenant to a GraphQL source
+func tenantObject(t *client.Tenant) map[string]interface{} {
+	domains := make([]interface{}, len(t.CustomDomains))
+	for i, domain := range t.CustomDomains {
+		domains[i] = domain
+	}
+	return map[string]interface{}{
+		"id":             t.Id,
+		"slug":           t.Slug,
+		"name":           t.Name,
+		"status":         t.Status,
+		"plan":           t.Plan,
+		"region":         t.Region,
+		"custom_domains": domains,
+	}
+}
+
+// sourceString reads a string field of an object source by either name
+func sourceString(source interface{}, names ...string) string {
+	m, _ := source.(map[string]interface{})
+	for _, name := range names {
+		switch v := m[name].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// NewGraphQLSchema creates the gateway's GraphQL schema over sources.
+// Fields with a permission are checked against the caller's permissions
+// like routes guarded by RequirePermission.
+func NewGraphQLSchema(sources *GraphQLSources) (*graphql.Schema, error) {
+	loadUser := func(p graphql.ResolveParams, id string) (interface{}, error) {
+		if id == "" {
+			return nil, nil
+		}
+		return requestFrom(p.Context).users.Load(p.Context, id)
+	}
+	// Tenants are read with the gateway's identity, so callers only see their
+	// own tenant unless they hold CrossTenantPermission
+	loadTenant := func(p graphql.ResolveParams, id string) (interface{}, error) {
+		if id == "" {
+			return nil, nil
+		}
+		req := requestFrom(p.Context)
+		tenant, err := req.tenants.Load(p.Context, id)
+		if err != nil {
+			return nil, err
+		}
+		own := id == req.tenantID
+		if tenant != nil {
+			own = req.tenantID != "" && sourceString(tenant, "id") == req.tenantID
+		}
+		if !own {
+			if err := req.authorize(p.Context, CrossTenantPermission); err != nil {
+				return nil, err
+			}
+		}
+		return tenant, nil
+	}
+
+	tenant := &graphql.Object{Name: "Tenant", Description: "A tenant of the platform", Fields: map[string]*graphql.Field{
+		"id":            {Type: "ID!"},
+		"slug":          {Type: "String"},
+		"name":          {Type: "String"},
+		"status":        {Type: "String"},
+		"plan":          {Type: "String", Permission: "billing.read"},
+		"region":        {Type: "String"},
+		"customDomains": {Type: "[String!]"},
+	}}
+
+	user := &graphql.Object{Name: "User", Description: "A user, as returned by the user service", Fields: map[string]*graphql.Field{
+		"id":       {Type: "ID!"},
+		"email":    {Type: "String"},
+		"name":     {Type: "String"},
+		"status":   {Type: "String"},
+		"tenantId": {Type: "ID"},
+		"roles":    {Type: "[String!]", Permission: "role.read"},
+		"tenant": {Type: "Tenant", Description: "The user's tenant", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return loadTenant(p, sourceString(p.Source, "tenantId", "tenant_id"))
+		}},
+	}}
+
+	notification := &graphql.Object{Name: "Notification", Description: "A notification sent to the caller", Fields: map[string]*graphql.Field{
+		"id":        {Type: "ID!"},
+		"type":      {Type: "String"},
+		"channel":   {Type: "String"},
+		"subject":   {Type: "String"},
+		"message":   {Type: "String"},
+		"status":    {Type: "String"},
+		"read":      {Type: "Boolean"},
+		"createdAt": {Type: "String"},
+		"user": {Type: "User", Description: "The notification's recipient", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return loadUser(p, sourceString(p.Source, "userId", "user_id"))
+		}},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"me": {Type: "User", Description: "The authenticated user", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return sources.get(p.Context, sources.UsersPath+"/me")
+		}},
+		"user": {
+			Type:       "User",
+			Args:       map[string]*graphql.Argument{"id": {Type: "ID!"}},
+			Permission: "user.read",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return loadUser(p, p.Args["id"].(string))
+			},
+		},
+		"users": {
+			Type:        "[User]",
+			Description: "Users by ID, in order; unknown IDs are null",
+			Args:        map[string]*graphql.Argument{"ids": {Type: "[ID!]!"}},
+			Permission:  "user.read",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				ids := p.Args["ids"].([]interface{})
+				keys := make([]string, len(ids))
+				for i, id := range ids {
+					keys[i] = id.(string)
+				}
+				return requestFrom(p.Context).users.LoadMany(p.Context, keys)
+			},
+		},
+		"tenant": {
+			Type:        "Tenant",
+			Description: "A tenant by ID or slug, by default the caller's tenant; other tenants need " + CrossTenantPermission,
+			Args:        map[string]*graphql.Argument{"id": {Type: "ID"}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				req := requestFrom(p.Context)
+				id, _ := p.Args["id"].(string)
+				if id == "" {
+					id = req.tenantID
+				}
+				return loadTenant(p, id)
+			},
+		},
+		"notifications": {
+			Type:        "[Notification!]",
+			Description: "The caller's latest notifications",
+			Args:        map[string]*graphql.Argument{"limit": {Type: "Int", Default: 20}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				limit := p.Args["limit"].(int)
+				if limit < 1 || limit > 100 {
+					return nil, fmt.Errorf("limit must be between 1 and 100")
+				}
+				body, err := sources.get(p.Context, sources.NotificationsPath+"?limit="+strconv.Itoa(limit))
+				if err != nil {
+					return nil, err
+				}
+				// the list may come bare or under a collection key
+				if m, ok := body.(map[string]interface{}); ok {
+					for _, key := range []string{"notifications", "items"} {
+						if list, ok := m[key].([]interface{}); ok {
+							return list, nil
+						}
+					}
+					return nil, nil
+				}
+				return body, nil
+			},
+		},
+	}}
+
+	return graphql.NewSchema(query, user, tenant, notification)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	"github.com/vhvplatform/go-api-gateway/internal/graphql"
+)
+
+// tenantDirectory serves tenants by ID or slug
+type tenantDirectory map[string]*client.Tenant
+
+func (d tenantDirectory) GetTenant(_ context.Context, idOrSlug string) (*client.Tenant, error) {
+	return d[idOrSlug], nil
+}
+
+func TestGraphQLTenantAccess(t *testing.T) {
+	acme := &client.Tenant{Id: "t1", Slug: "acme", Name: "Acme"}
+	globex := &client.Tenant{Id: "t2", Slug: "globex", Name: "Globex"}
+	sources := &GraphQLSources{Tenants: tenantDirectory{"t1": acme, "acme": acme, "t2": globex, "globex": globex}}
+	schema, err := NewGraphQLSchema(sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		permission string
+		wantName   interface{}
+		wantCode   string
+	}{
+		{"own tenant", `{ tenant { name } }`, "", "Acme", ""},
+		{"own tenant by slug", `{ tenant(id: "acme") { name } }`, "", "Acme", ""},
+		{"other tenant", `{ tenant(id: "globex") { name } }`, "", nil, "FORBIDDEN"},
+		{"unknown tenant", `{ tenant(id: "initech") { name } }`, "", nil, "FORBIDDEN"},
+		{"other tenant with permission", `{ tenant(id: "t2") { name } }`, CrossTenantPermission, "Globex", ""},
+	}
+	for _, tt := range tests {
+		authorize := func(_ context.Context, permission string) error {
+			if permission != tt.permission {
+				return &graphql.Error{Message: "insufficient permissions", Extensions: map[string]interface{}{"code": "FORBIDDEN"}}
+			}
+			return nil
+		}
+		r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		ctx := sources.newGraphQLRequest(r, "u1", "t1", authorize)
+
+		data, err := json.Marshal(schema.Execute(ctx, graphql.Config{}, graphql.Request{Query: tt.query}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp struct {
+			Data struct {
+				Tenant map[string]interface{} `json:"tenant"`
+			} `json:"data"`
+			Errors []struct {
+				Extensions map[string]interface{} `json:"extensions"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := resp.Data.Tenant["name"]; got != tt.wantName {
+			t.Errorf("%s: name = %v, want %v (%s)", tt.name, got, tt.wantName, data)
+		}
+		var code interface{}
+		if len(resp.Errors) > 0 {
+			code = resp.Errors[0].Extensions["code"]
+		}
+		if tt.wantCode != "" && code != tt.wantCode || tt.wantCode == "" && code != nil {
+			t.Errorf("%s: error code = %v, want %q", tt.name, code, tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/grpcweb"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/stream"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCWebHandler serves gRPC-Web calls from browsers over the gateway's
+// native gRPC connections
+type GRPCWebHandler struct {
+	backends *client.Backends
+	streams  *stream.Routes
+	prefix   string
+	log      *logger.Logger
+}
+
+// NewGRPCWebHandler creates a handler for calls mounted under prefix. Calls
+// on streams routes may outlive the server's write timeout, for
+// server-streaming methods.
+func NewGRPCWebHandler(backends *client.Backends, streams *stream.Routes, prefix string, log *logger.Logger) *GRPCWebHandler {
+	return &GRPCWebHandler{
+		backends: backends,
+		streams:  streams,
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		log:      log,
+	}
+}
+
+// Handle proxies a call to /<package>.<Service>/<Method>
+func (h *GRPCWebHandler) Handle(c *gin.Context) {
+	if !grpcweb.IsRequest(c.ContentType()) {
+		c.JSON(http.StatusUnsupportedMediaType, apierrors.NewErrorResponse("UNSUPPORTED_MEDIA_TYPE", "Expected a gRPC-Web request", nil, c.GetString("correlation_id")))
+		return
+	}
+
+	method := strings.TrimPrefix(c.Request.URL.Path, h.prefix)
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok || service == "" || name == "" || strings.Contains(name, "/") {
+		code := grpcweb.WriteError(c.Writer, c.Request, status.Errorf(codes.Unimplemented, "malformed method name %q", method))
+		metrics.GRPCWebRequests.WithLabelValues("unknown", code.String()).Inc()
+		return
+	}
+
+	conn, ok := h.backends.Conn(service)
+	if !ok {
+		code := grpcweb.WriteError(c.Writer, c.Request, status.Errorf(codes.Unimplemented, "unknown service %s", service))
+		metrics.GRPCWebRequests.WithLabelValues("unknown", code.String()).Inc()
+		return
+	}
+
+	if h.streams.Match(c.Request) {
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	}
+	code := grpcweb.Proxy(c.Writer, c.Request, conn, method, grpcWebMetadata(c))
+	if code == codes.Unknown || code == codes.Internal {
+		h.log.Warn("gRPC-Web call failed", zap.String("method", method), zap.String("code", code.String()))
+	}
+	if code == codes.Unimplemented {
+		// keep arbitrary client-supplied method names out of the metric labels
+		method = "unknown"
+	}
+	metrics.GRPCWebRequests.WithLabelValues(method, code.String()).Inc()
+}
+
+// grpcWebMetadata passes the caller's headers on as metadata, as gRPC-Web
+// clients send it, with the gateway's identity and tracing keys taking
+// precedence over anything the client supplied under those names
+func grpcWebMetadata(c *gin.Context) metadata.MD {
+	md := grpcweb.HeaderMetadata(c.Request.Header)
+	for key := range gatewayMetadata {
+		delete(md, key)
+	}
+	for key, values := range grpcMetadata(c) {
+		md[key] = values
+	}
+	return md
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metering"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// maxExportRange bounds the time range of one export
+const maxExportRange = 31 * 24 * time.Hour
+
+// MeteringHandler exposes usage records for billing
+type MeteringHandler struct {
+	meter *metering.Meter
+	log   *logger.Logger
+}
+
+// NewMeteringHandler creates a new metering handler
+func NewMeteringHandler(meter *metering.Meter, log *logger.Logger) *MeteringHandler {
+	return &MeteringHandler{
+		meter: meter,
+		log:   log,
+	}
+}
+
+// Export returns usage records for a time range as JSON or CSV.
+// Query params: from, to (RFC3339, default: last 24h, at most 31 days apart),
+// tenant_id, format (json|csv)
+func (h *MeteringHandler) Export(c *gin.Context) {
+	to := time.Now().UTC()
+	from := to.Add(-24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' time, expected RFC3339"})
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' time, expected RFC3339"})
+			return
+		}
+		to = parsed
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+	if to.Sub(from) > maxExportRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Time range must not exceed 31 days"})
+		return
+	}
+
+	records, err := h.meter.Query(from, to, c.Query("tenant_id"))
+	if err != nil {
+		h.log.Error("Failed to query usage records", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query usage"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=usage.csv")
+		c.Status(http.StatusOK)
+		err = metering.WriteCSV(c.Writer, records)
+	case "json":
+		c.Header("Content-Type", "application/json")
+		c.Status(http.StatusOK)
+		err = metering.WriteJSON(c.Writer, records)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, use json or csv"})
+		return
+	}
+
+	if err != nil {
+		h.log.Error("Failed to write usage export", zap.Error(err))
+	}
+}
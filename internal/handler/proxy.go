@@ -1,154 +1,388 @@
-package handler
-
-import (
-	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"os"
-	"strings"
-
-	"github.com/gin-gonic/gin"
-	"github.com/vhvplatform/go-shared/logger"
-	"go.uber.org/zap"
-)
-
-// ProxyHandler handles reverse proxying to other services
-type ProxyHandler struct {
-	log *logger.Logger
-}
-
-func NewProxyHandler(log *logger.Logger) *ProxyHandler {
-	return &ProxyHandler{log: log}
-}
-
-// APIProxy forwards requests to Go microservices
-func (h *ProxyHandler) APIProxy(c *gin.Context) {
-	// Path format: /api/service-name/api-path
-	serviceName := c.Param("service")
-	targetHost := h.getServiceURL(serviceName)
-
-	if targetHost == "" {
-		h.log.Warn("Unknown API service", zap.String("service", serviceName))
-		h.handleFailover(c, "Service not found")
-		return
-	}
-
-	h.proxyRequest(c, targetHost)
-}
-
-// PageProxy forwards requests to React Frontends
-func (h *ProxyHandler) PageProxy(c *gin.Context) {
-	// Path format: /page/service-name/page-path
-	parts := strings.Split(strings.TrimPrefix(c.Request.URL.Path, "/"), "/")
-	if len(parts) < 2 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page path"})
-		return
-	}
-
-	serviceName := parts[1]
-	targetHost := h.getServiceURL(serviceName + "-ui") // Convention: service-name-ui
-
-	if targetHost == "" {
-		h.handleFailover(c, "Page service not found")
-		return
-	}
-
-	h.proxyRequest(c, targetHost)
-}
-
-// UploadProxy forwards requests to file-service
-func (h *ProxyHandler) UploadProxy(c *gin.Context) {
-	// Path format: /upload/file-key
-	targetHost := h.getServiceURL("file-service")
-
-	if targetHost == "" {
-		h.handleFailover(c, "Upload service not found")
-		return
-	}
-
-	h.proxyRequest(c, targetHost)
-}
-
-// SlugProxy handles pretty URLs (slugs)
-func (h *ProxyHandler) SlugProxy(c *gin.Context) {
-	// Fallback for any other path
-	// Redirect to default service of tenant if available
-	tenantDefault := c.GetString("tenant_default_service")
-	if tenantDefault == "" {
-		tenantDefault = "cms-service" // System fallback
-	}
-
-	targetHost := h.getServiceURL(tenantDefault)
-	if targetHost == "" {
-		h.handleFailover(c, "Slug handler not found")
-		return
-	}
-	h.proxyRequest(c, targetHost)
-}
-
-func (h *ProxyHandler) proxyRequest(c *gin.Context, target string) {
-	targetURL, err := url.Parse(target)
-	if err != nil {
-		h.log.Error("Failed to parse target URL", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
-	}
-
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
-	// Director to modify request
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		req.Host = targetURL.Host
-		req.URL.Scheme = targetURL.Scheme
-		req.URL.Host = targetURL.Host
-	}
-
-	// ErrorHandler for Failover
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		h.log.Error("Proxy error, triggering failover", zap.Error(err), zap.String("target", target))
-		h.handleFailover(c, err.Error())
-	}
-
-	// ServeHTTP
-	proxy.ServeHTTP(c.Writer, c.Request)
-}
-
-func (h *ProxyHandler) handleFailover(c *gin.Context, originalErr string) {
-	// 1. Try Tenant Default Service (from context or db)
-	tenantDefault := c.GetString("tenant_default_service")
-	if tenantDefault != "" {
-		h.log.Info("Failing over to tenant default", zap.String("service", tenantDefault))
-		c.Set("tenant_default_service", "") // Clear to avoid infinite loop
-		h.proxyRequest(c, h.getServiceURL(tenantDefault))
-		return
-	}
-
-	// 2. Try System Default Service (e.g. Dashboard or Login)
-	systemDefault := "dashboard-service"
-	target := h.getServiceURL(systemDefault)
-	if target != "" {
-		h.log.Info("Failing over to system default", zap.String("service", systemDefault))
-		h.proxyRequest(c, target)
-		return
-	}
-
-	// 3. Last resort: Auth login
-	h.log.Info("Redirecting to auth login")
-	c.Redirect(http.StatusFound, "/auth/login?error="+url.QueryEscape(originalErr))
-}
-
-func (h *ProxyHandler) getServiceURL(serviceName string) string {
-	// In a real scenario, this would use Service Discovery (consul, k8s dns, etc.)
-	// For now, mapping from env or simple convention
-	envVar := strings.ToUpper(strings.ReplaceAll(serviceName, "-", "_")) + "_URL"
-	url := os.Getenv(envVar)
-	if url != "" {
-		return url
-	}
-
-	// Fallback to convention: http://service-name:port
-	// This is a simplified example
-	return ""
-}
+package handler
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/canary"
+	"github.com/vhvplatform/go-api-gateway/internal/forwarded"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/registry"
+	"github.com/vhvplatform/go-api-gateway/internal/stream"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"github.com/vhvplatform/go-api-gateway/internal/transform"
+	"github.com/vhvplatform/go-api-gateway/internal/websocket"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// ProxyConfig holds the dependencies of the proxy handler
+type ProxyConfig struct {
+	// Registry maps service names to upstream URLs
+	Registry *registry.Registry
+	// Canary splits API traffic between service versions; nil disables splitting
+	Canary *canary.Splitter
+	// Forwarded sets Forwarded/X-Forwarded-* headers; nil trusts no proxies
+	Forwarded *forwarded.Forwarder
+	// WebSockets manages upgraded connections on /api/:service; nil proxies them unmanaged
+	WebSockets *websocket.Manager
+	// Streams matches routes whose responses stream; nil matches only Server-Sent Events requests
+	Streams *stream.Routes
+}
+
+// ProxyHandler handles reverse proxying to other services
+type ProxyHandler struct {
+	registry   *registry.Registry
+	canary     *canary.Splitter
+	forwarded  *forwarded.Forwarder
+	websockets *websocket.Manager
+	streams    *stream.Routes
+	log        *logger.Logger
+}
+
+func NewProxyHandler(config ProxyConfig, log *logger.Logger) *ProxyHandler {
+	fwd := config.Forwarded
+	if fwd == nil {
+		fwd, _ = forwarded.New(nil)
+	}
+	return &ProxyHandler{
+		registry:   config.Registry,
+		canary:     config.Canary,
+		forwarded:  fwd,
+		websockets: config.WebSockets,
+		streams:    config.Streams,
+		log:        log,
+	}
+}
+
+// APIProxy forwards requests to Go microservices
+func (h *ProxyHandler) APIProxy(c *gin.Context) {
+	// Path format: /api/service-name/api-path
+	profile := tenantProfile(c)
+	if profile != nil {
+		if tenantID := c.GetString("tenant_id"); tenantID != "" && tenantID != profile.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token does not belong to this tenant"})
+			return
+		}
+	}
+
+	serviceName, enabled := apiService(c)
+	if !enabled {
+		h.log.Debug("Service disabled for tenant",
+			zap.String("tenant_id", profile.ID),
+			zap.String("service", serviceName))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	c.Set("upstream_service", serviceName)
+	targetHost, version, err := h.versionedServiceURL(c, serviceName)
+	if err != nil {
+		h.regionUnavailable(c, err)
+		return
+	}
+
+	if targetHost == "" {
+		h.log.Warn("Unknown API service", zap.String("service", serviceName))
+		h.handleFailover(c, "Service not found")
+		return
+	}
+
+	// WebSocket upgrades hold a per-tenant connection slot until the connection ends
+	if h.websockets != nil && websocket.IsUpgrade(c.Request) {
+		session, ok := h.openWebSocket(c, serviceName)
+		if !ok {
+			return
+		}
+		defer session.Close()
+	}
+
+	if h.canary != nil {
+		start := time.Now()
+		defer func() {
+			metrics.CanaryRequests.WithLabelValues(serviceName, version, strconv.Itoa(c.Writer.Status())).Inc()
+			metrics.CanaryRequestDuration.WithLabelValues(serviceName, version).Observe(time.Since(start).Seconds())
+		}()
+	}
+
+	h.proxyRequest(c, targetHost)
+}
+
+// apiService returns the service an API request is for and whether its tenant
+// may use it. Tenant profiles may alias service names and disable services.
+func apiService(c *gin.Context) (string, bool) {
+	serviceName := c.Param("service")
+	if profile := tenantProfile(c); profile != nil {
+		return profile.ResolveService(serviceName)
+	}
+	return serviceName, true
+}
+
+// CanaryVersion returns the canary version APIProxy picks for a request, or
+// "" without canary splitting, so the response cache can keep versions apart
+func (h *ProxyHandler) CanaryVersion(c *gin.Context) string {
+	if h.canary == nil {
+		return ""
+	}
+	serviceName, _ := apiService(c)
+	version, _ := h.canary.Choose(c.Request, serviceName, c.GetString("tenant_id"), stickyKey(c))
+	return version
+}
+
+// PageProxy forwards requests to React Frontends
+func (h *ProxyHandler) PageProxy(c *gin.Context) {
+	// Path format: /page/service-name/page-path
+	parts := strings.Split(strings.TrimPrefix(c.Request.URL.Path, "/"), "/")
+	if len(parts) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page path"})
+		return
+	}
+
+	serviceName := parts[1]
+	c.Set("upstream_service", serviceName+"-ui")
+	targetHost, err := h.getServiceURL(c, serviceName+"-ui") // Convention: service-name-ui
+	if err != nil {
+		h.regionUnavailable(c, err)
+		return
+	}
+
+	if targetHost == "" {
+		h.handleFailover(c, "Page service not found")
+		return
+	}
+
+	h.proxyRequest(c, targetHost)
+}
+
+// UploadProxy forwards requests to file-service
+func (h *ProxyHandler) UploadProxy(c *gin.Context) {
+	// Path format: /upload/file-key
+	c.Set("upstream_service", "file-service")
+	targetHost, err := h.getServiceURL(c, "file-service")
+	if err != nil {
+		h.regionUnavailable(c, err)
+		return
+	}
+
+	if targetHost == "" {
+		h.handleFailover(c, "Upload service not found")
+		return
+	}
+
+	h.proxyRequest(c, targetHost)
+}
+
+// SlugProxy handles pretty URLs (slugs)
+func (h *ProxyHandler) SlugProxy(c *gin.Context) {
+	// Fallback for any other path
+	// Redirect to default service of tenant if available
+	tenantDefault := c.GetString("tenant_default_service")
+	if tenantDefault == "" {
+		tenantDefault = "cms-service" // System fallback
+	}
+	c.Set("upstream_service", tenantDefault)
+
+	targetHost, err := h.getServiceURL(c, tenantDefault)
+	if err != nil {
+		h.regionUnavailable(c, err)
+		return
+	}
+	if targetHost == "" {
+		h.handleFailover(c, "Slug handler not found")
+		return
+	}
+	h.proxyRequest(c, targetHost)
+}
+
+func (h *ProxyHandler) proxyRequest(c *gin.Context, target string) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		h.log.Error("Failed to parse target URL", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Path rewrites and header rules of the route, from the registry
+	service := c.GetString("upstream_service")
+	route := h.registry.Route(service)
+	var vars transform.Vars
+	if route != nil {
+		// {service} is the name in the client's path, which tenant aliases
+		// may map to a differently named {upstream_service}
+		requested := c.Param("service")
+		if requested == "" {
+			requested = service
+		}
+		vars = transform.Vars{
+			"service":          requested,
+			"upstream_service": service,
+			"tenant_id":        c.GetString("tenant_id"),
+			"user_id":          c.GetString("user_id"),
+			"correlation_id":   c.GetString("correlation_id"),
+			"client_ip":        c.ClientIP(),
+		}
+	}
+
+	// Rewrite (unlike Director) starts from a request stripped of hop-by-hop
+	// and client-supplied forwarding headers, so only ours reach the upstream
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			prefix := tenant.PathPrefix(pr.In.Context())
+			if route != nil {
+				prefix += route.StrippedPrefix(pr.In.URL.Path, vars)
+				pr.Out.URL.Path = route.Path(pr.Out.URL.Path, vars)
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetURL(targetURL)
+			h.forwarded.Set(pr.Out.Header, pr.In, prefix)
+			if route != nil {
+				if route.PreserveHost {
+					pr.Out.Host = pr.In.Host
+				}
+				route.Request(pr.Out.Header, vars)
+			}
+		},
+	}
+	// Streaming routes flush every write; SSE and unknown-length responses always do
+	streamingRoute := h.streams.Match(c.Request)
+	if streamingRoute {
+		proxy.FlushInterval = -1
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if route != nil {
+			route.Response(resp.Header, vars)
+		}
+		h.prepareStream(c, service, resp, streamingRoute)
+		return nil
+	}
+
+	// ErrorHandler for Failover
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// A client that went away cancelled the upstream request; that is not an upstream failure
+		if h.clientGone(c, service, err) {
+			return
+		}
+		h.log.Error("Proxy error, triggering failover", zap.Error(err), zap.String("target", target))
+		h.handleFailover(c, err.Error())
+	}
+
+	// The proxy aborts the handler when copying a response fails midway; a
+	// client disconnect during a stream is expected and ends the request quietly
+	defer func() {
+		if p := recover(); p != nil {
+			if p == http.ErrAbortHandler && h.clientGone(c, service, c.Request.Context().Err()) {
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	// ServeHTTP
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *ProxyHandler) handleFailover(c *gin.Context, originalErr string) {
+	// 1. Try Tenant Default Service (from context or db)
+	tenantDefault := c.GetString("tenant_default_service")
+	if tenantDefault != "" {
+		h.log.Info("Failing over to tenant default", zap.String("service", tenantDefault))
+		c.Set("tenant_default_service", "") // Clear to avoid infinite loop
+		c.Set("upstream_service", tenantDefault)
+		target, err := h.getServiceURL(c, tenantDefault)
+		if err != nil {
+			// Failover never leaves the tenant's region
+			h.regionUnavailable(c, err)
+			return
+		}
+		h.proxyRequest(c, target)
+		return
+	}
+
+	// 2. Try System Default Service (e.g. Dashboard or Login)
+	systemDefault := "dashboard-service"
+	target, err := h.getServiceURL(c, systemDefault)
+	if err != nil {
+		h.regionUnavailable(c, err)
+		return
+	}
+	if target != "" {
+		h.log.Info("Failing over to system default", zap.String("service", systemDefault))
+		c.Set("upstream_service", systemDefault)
+		h.proxyRequest(c, target)
+		return
+	}
+
+	// 3. Last resort: Auth login
+	h.log.Info("Redirecting to auth login")
+	c.Redirect(http.StatusFound, "/auth/login?error="+url.QueryEscape(originalErr))
+}
+
+func (h *ProxyHandler) getServiceURL(c *gin.Context, serviceName string) (string, error) {
+	// In a real scenario, this would use Service Discovery (consul, k8s dns, etc.)
+	// For now, the registry maps names (and the tenant's pool and region) to URLs
+	pool, region := "", ""
+	if profile := tenantProfile(c); profile != nil {
+		pool, region = profile.Pool, profile.Region
+	}
+	return h.registry.URL(serviceName, pool, region)
+}
+
+// versionedServiceURL picks the canary version of a service for the request and
+// returns its upstream URL. Versions are registered as "service:version"; a
+// version without an upstream (in the tenant's region) falls back to stable.
+func (h *ProxyHandler) versionedServiceURL(c *gin.Context, serviceName string) (string, string, error) {
+	if h.canary != nil {
+		version, reason := h.canary.Choose(c.Request, serviceName, c.GetString("tenant_id"), stickyKey(c))
+		if version != canary.Stable {
+			target, err := h.getServiceURL(c, serviceName+":"+version)
+			if err == nil && target != "" {
+				c.Set("upstream_version", version)
+				return target, version, nil
+			}
+			h.log.Warn("Canary version has no upstream, using stable",
+				zap.String("service", serviceName),
+				zap.String("version", version),
+				zap.String("reason", reason))
+		}
+	}
+
+	target, err := h.getServiceURL(c, serviceName)
+	c.Set("upstream_version", canary.Stable)
+	return target, canary.Stable, err
+}
+
+// stickyKey identifies the client so canary assignment stays stable across requests
+func stickyKey(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return userID
+	}
+	if tenantID := c.GetString("tenant_id"); tenantID != "" {
+		return tenantID
+	}
+	return c.ClientIP()
+}
+
+// regionUnavailable rejects a request that could only be served outside the tenant's region
+func (h *ProxyHandler) regionUnavailable(c *gin.Context, err error) {
+	region := ""
+	if profile := tenantProfile(c); profile != nil {
+		region = profile.Region
+	}
+	h.log.Warn("No upstream in tenant region", zap.String("region", region), zap.Error(err))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":  "Service unavailable in the tenant's data region",
+		"region": region,
+	})
+}
+
+// tenantProfile returns the profile set by the tenant resolution middleware, if any
+func tenantProfile(c *gin.Context) *tenant.Profile {
+	value, _ := c.Get("tenant_profile")
+	profile, _ := value.(*tenant.Profile)
+	return profile
+}
@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/middleware"
+	"github.com/vhvplatform/go-api-gateway/internal/registry"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"github.com/vhvplatform/go-api-gateway/internal/transform"
+	"github.com/vhvplatform/go-shared/logger"
+)
+
+// upstream is a backend recording the requests it receives
+type upstream struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests = append(u.requests, r)
+		u.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// count returns how many requests the backend received
+func (u *upstream) count() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.requests)
+}
+
+// last returns the last request the backend received, or nil
+func (u *upstream) last() *http.Request {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.requests) == 0 {
+		return nil
+	}
+	return u.requests[len(u.requests)-1]
+}
+
+// recorder is a ResponseRecorder the reverse proxy can watch for disconnects
+type recorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (recorder) CloseNotify() <-chan bool {
+	return nil
+}
+
+// serveAPI sends a request to APIProxy as the tenant resolution and auth
+// middleware would leave it: with the tenant's profile and the token's tenant
+func serveAPI(h *ProxyHandler, profile *tenant.Profile, tokenTenant, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/api/:service/*path", func(c *gin.Context) {
+		if profile != nil {
+			c.Set("tenant_profile", profile)
+			if profile.DefaultService != "" {
+				c.Set("tenant_default_service", profile.DefaultService)
+			}
+		}
+		if tokenTenant != "" {
+			c.Set("tenant_id", tokenTenant)
+		}
+	}, h.APIProxy)
+
+	w := recorder{httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.ResponseRecorder
+}
+
+func newTestProxy(t *testing.T, upstreams []registry.Upstream, routes map[string]*transform.Route) *ProxyHandler {
+	t.Helper()
+	for _, route := range routes {
+		if err := route.Compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewProxyHandler(ProxyConfig{Registry: registry.New(upstreams, routes)}, logger.NewLogger())
+}
+
+func TestAPIProxy_TransformVarsWithAlias(t *testing.T) {
+	shop := newUpstream(t)
+	h := newTestProxy(t,
+		[]registry.Upstream{{Service: "ecommerce-service", URL: shop.URL}},
+		map[string]*transform.Route{"ecommerce-service": {
+			Rewrite: transform.Rewrite{StripPrefix: "/api/{service}"},
+			RequestHeaders: transform.HeaderRules{
+				Add: map[string]string{"X-Requested-Service": "{service}", "X-Upstream-Service": "{upstream_service}"},
+			},
+		}},
+	)
+	profile := &tenant.Profile{ID: "t1", Aliases: map[string]string{"shop": "ecommerce-service"}}
+
+	tests := []struct {
+		path        string
+		wantService string
+	}{
+		{"/api/shop/products", "shop"},
+		{"/api/ecommerce-service/products", "ecommerce-service"},
+	}
+	for _, tt := range tests {
+		w := serveAPI(h, profile, "t1", tt.path)
+		got := shop.last()
+		if w.Code != http.StatusOK || got == nil {
+			t.Fatalf("%s: status %d, upstream request %v", tt.path, w.Code, got)
+		}
+		if got.URL.Path != "/products" {
+			t.Errorf("%s: upstream path = %q, want /products", tt.path, got.URL.Path)
+		}
+		if v := got.Header.Get("X-Requested-Service"); v != tt.wantService {
+			t.Errorf("%s: {service} = %q, want %q", tt.path, v, tt.wantService)
+		}
+		if v := got.Header.Get("X-Upstream-Service"); v != "ecommerce-service" {
+			t.Errorf("%s: {upstream_service} = %q, want ecommerce-service", tt.path, v)
+		}
+	}
+}
+
+func TestAPIProxy_TenantServices(t *testing.T) {
+	shop := newUpstream(t)
+	cms := newUpstream(t)
+	h := newTestProxy(t, []registry.Upstream{
+		{Service: "ecommerce-service", URL: shop.URL},
+		{Service: "cms-service", URL: cms.URL},
+	}, nil)
+	profile := &tenant.Profile{
+		ID:       "t1",
+		Services: map[string]bool{"ecommerce-service": true, "cms-service": false},
+		Aliases:  map[string]string{"shop": "ecommerce-service"},
+	}
+
+	tests := []struct {
+		name        string
+		tokenTenant string
+		path        string
+		wantStatus  int
+		wantBackend *upstream
+	}{
+		{"enabled service", "t1", "/api/ecommerce-service/products", http.StatusOK, shop},
+		{"alias", "t1", "/api/shop/products", http.StatusOK, shop},
+		{"disabled service", "t1", "/api/cms-service/pages", http.StatusNotFound, nil},
+		{"unconfigured service", "t1", "/api/billing-service/invoices", http.StatusNotFound, nil},
+		{"token of another tenant", "t2", "/api/shop/products", http.StatusForbidden, nil},
+		{"anonymous", "", "/api/shop/products", http.StatusOK, shop},
+	}
+	for _, tt := range tests {
+		before := map[*upstream]int{shop: shop.count(), cms: cms.count()}
+		w := serveAPI(h, profile, tt.tokenTenant, tt.path)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		for backend, n := range before {
+			reached := backend.count() > n
+			if reached != (backend == tt.wantBackend) {
+				t.Errorf("%s: reached %s = %v", tt.name, backend.URL, reached)
+			}
+		}
+		if tt.wantBackend != nil && tt.wantBackend.last().URL.Path != tt.path {
+			t.Errorf("%s: upstream path = %q, want %q", tt.name, tt.wantBackend.last().URL.Path, tt.path)
+		}
+	}
+}
+
+func TestAPIProxy_TenantRegion(t *testing.T) {
+	shared := newUpstream(t)
+	eu := newUpstream(t)
+	enterpriseUS := newUpstream(t)
+	fallback := newUpstream(t)
+	// a closed upstream, so requests to it fail over
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	// unregistered services and failover targets fall back to <SERVICE>_URL
+	for _, name := range []string{"BILLING_SERVICE_URL", "DASHBOARD_SERVICE_URL", "HOME_SERVICE_URL"} {
+		t.Setenv(name, fallback.URL)
+	}
+	h := newTestProxy(t, []registry.Upstream{
+		{Service: "cms-service", URL: shared.URL},
+		{Service: "cms-service", Region: "eu", URL: eu.URL},
+		{Service: "cms-service", Pool: "enterprise", Region: "us", URL: enterpriseUS.URL},
+		{Service: "orders-service", Region: "eu", URL: down.URL},
+		{Service: "dashboard-service", URL: shared.URL},
+		{Service: "home-service", Region: "us", URL: enterpriseUS.URL},
+	}, nil)
+
+	tests := []struct {
+		name        string
+		profile     *tenant.Profile
+		path        string
+		wantStatus  int
+		wantBackend *upstream
+	}{
+		{"region upstream", &tenant.Profile{ID: "t1", Region: "eu"}, "/api/cms-service/pages", http.StatusOK, eu},
+		{"pool only in another region", &tenant.Profile{ID: "t1", Region: "eu", Pool: "enterprise"}, "/api/cms-service/pages", http.StatusOK, eu},
+		{"unknown region", &tenant.Profile{ID: "t1", Region: "ap"}, "/api/cms-service/pages", http.StatusServiceUnavailable, nil},
+		{"unregistered service", &tenant.Profile{ID: "t1", Region: "eu"}, "/api/billing-service/invoices", http.StatusServiceUnavailable, nil},
+		{"failover to system default", &tenant.Profile{ID: "t1", Region: "eu"}, "/api/orders-service/orders", http.StatusServiceUnavailable, nil},
+		{"failover to tenant default", &tenant.Profile{ID: "t1", Region: "eu", DefaultService: "home-service"}, "/api/orders-service/orders", http.StatusServiceUnavailable, nil},
+		{"unbound tenant", &tenant.Profile{ID: "t1"}, "/api/billing-service/invoices", http.StatusOK, fallback},
+	}
+	backends := []*upstream{shared, eu, enterpriseUS, fallback}
+	for _, tt := range tests {
+		before := make([]int, len(backends))
+		for i, backend := range backends {
+			before[i] = backend.count()
+		}
+
+		w := serveAPI(h, tt.profile, "", tt.path)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusServiceUnavailable && !strings.Contains(w.Body.String(), `"region":"`+tt.profile.Region+`"`) {
+			t.Errorf("%s: body = %s, want the tenant's region", tt.name, w.Body)
+		}
+		for i, backend := range backends {
+			if reached := backend.count() > before[i]; reached != (backend == tt.wantBackend) {
+				t.Errorf("%s: reached backend %d = %v", tt.name, i, reached)
+			}
+		}
+	}
+}
+
+// profileSource serves tenant profiles by ID; "broken" fails like an unreachable tenant service
+type profileSource map[string]*tenant.Profile
+
+func (s profileSource) GetTenant(_ context.Context, id string) (*tenant.Profile, error) {
+	if id == "broken" {
+		return nil, errors.New("tenant service unavailable")
+	}
+	if profile, ok := s[id]; ok {
+		return profile, nil
+	}
+	return nil, tenant.ErrNotFound
+}
+
+func (s profileSource) GetTenantByDomain(_ context.Context, _ string) (*tenant.Profile, error) {
+	return nil, tenant.ErrNotFound
+}
+
+// serveAuthenticated sends a request that names no tenant to APIProxy, as
+// the auth middleware leaves it, with the token's tenant loaded from profiles
+func serveAuthenticated(h *ProxyHandler, profiles profileSource, tokenTenant, path string) *httptest.ResponseRecorder {
+	resolver := tenant.NewResolver(profiles, tenant.ResolverConfig{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/api/:service/*path", func(c *gin.Context) {
+		c.Set("tenant_id", tokenTenant)
+	}, middleware.TokenTenantMiddleware(resolver, logger.NewLogger()), h.APIProxy)
+
+	w := recorder{httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.ResponseRecorder
+}
+
+func TestAPIProxy_TokenTenantRegion(t *testing.T) {
+	shared := newUpstream(t)
+	eu := newUpstream(t)
+	h := newTestProxy(t, []registry.Upstream{
+		{Service: "cms-service", URL: shared.URL},
+		{Service: "cms-service", Region: "eu", URL: eu.URL},
+	}, nil)
+	profiles := profileSource{
+		"t1": {ID: "t1", Region: "eu"},
+		"t2": {ID: "t2"},
+	}
+
+	tests := []struct {
+		name        string
+		tokenTenant string
+		wantStatus  int
+		wantBackend *upstream
+	}{
+		{"region-bound tenant", "t1", http.StatusOK, eu},
+		{"unbound tenant", "t2", http.StatusOK, shared},
+		{"unknown tenant", "t3", http.StatusNotFound, nil},
+		{"tenant service down", "broken", http.StatusServiceUnavailable, nil},
+	}
+	for _, tt := range tests {
+		before := map[*upstream]int{shared: shared.count(), eu: eu.count()}
+		w := serveAuthenticated(h, profiles, tt.tokenTenant, "/api/cms-service/pages")
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		for backend, n := range before {
+			if reached := backend.count() > n; reached != (backend == tt.wantBackend) {
+				t.Errorf("%s: reached %s = %v", tt.name, backend.URL, reached)
+			}
+		}
+	}
+}
+
+func TestAPIProxy_TokenTenantServices(t *testing.T) {
+	shop := newUpstream(t)
+	dedicated := newUpstream(t)
+	cms := newUpstream(t)
+	h := newTestProxy(t, []registry.Upstream{
+		{Service: "ecommerce-service", URL: shop.URL},
+		{Service: "ecommerce-service", Pool: "enterprise", URL: dedicated.URL},
+		{Service: "cms-service", URL: cms.URL},
+	}, nil)
+	profiles := profileSource{
+		"t1": {
+			ID:       "t1",
+			Services: map[string]bool{"ecommerce-service": true, "cms-service": false},
+			Aliases:  map[string]string{"shop": "ecommerce-service"},
+		},
+		"t2": {ID: "t2", Pool: "enterprise"},
+	}
+
+	// requests naming no tenant get the token tenant's services, aliases and pool
+	tests := []struct {
+		name        string
+		tokenTenant string
+		path        string
+		wantStatus  int
+		wantBackend *upstream
+	}{
+		{"alias", "t1", "/api/shop/products", http.StatusOK, shop},
+		{"disabled service", "t1", "/api/cms-service/pages", http.StatusNotFound, nil},
+		{"dedicated pool", "t2", "/api/ecommerce-service/products", http.StatusOK, dedicated},
+	}
+	for _, tt := range tests {
+		before := map[*upstream]int{shop: shop.count(), dedicated: dedicated.count(), cms: cms.count()}
+		w := serveAuthenticated(h, profiles, tt.tokenTenant, tt.path)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		for backend, n := range before {
+			if reached := backend.count() > n; reached != (backend == tt.wantBackend) {
+				t.Errorf("%s: reached %s = %v", tt.name, backend.URL, reached)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/respcache"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// ResponseCacheHandler purges cached upstream responses
+type ResponseCacheHandler struct {
+	cache *respcache.Cache
+	log   *logger.Logger
+}
+
+// NewResponseCacheHandler creates a new response cache handler
+func NewResponseCacheHandler(cache *respcache.Cache, log *logger.Logger) *ResponseCacheHandler {
+	return &ResponseCacheHandler{
+		cache: cache,
+		log:   log,
+	}
+}
+
+// PurgeTagsRequest lists the Surrogate-Key tags to purge
+type PurgeTagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1"`
+}
+
+// PurgeTags invalidates every cached response that carried one of the tags
+func (h *ResponseCacheHandler) PurgeTags(c *gin.Context) {
+	var req PurgeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: 'tags' is required"})
+		return
+	}
+
+	if err := h.cache.PurgeTags(c.Request.Context(), req.Tags); err != nil {
+		h.log.Error("Failed to purge response cache tags", zap.Strings("tags", req.Tags), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge tags"})
+		return
+	}
+
+	h.log.Info("Purged response cache tags", zap.Strings("tags", req.Tags))
+	c.JSON(http.StatusOK, gin.H{"purged_tags": req.Tags})
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/stream"
+	"go.uber.org/zap"
+)
+
+// prepareStream lifts the server's write timeout for Server-Sent Events, and
+// for any unknown-length response on a streaming route, so the stream lasts
+// until either side closes it
+func (h *ProxyHandler) prepareStream(c *gin.Context, service string, resp *http.Response, streamingRoute bool) {
+	kind := stream.Kind(resp)
+	if kind == "" || (kind == "chunked" && !streamingRoute) {
+		return
+	}
+
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.log.Warn("Streaming response is subject to the server write timeout", zap.String("service", service))
+	}
+	// Ask buffering proxies in front of the gateway (e.g. nginx) to pass events through
+	resp.Header.Set("X-Accel-Buffering", "no")
+	metrics.StreamingResponses.WithLabelValues(service, kind).Inc()
+}
+
+// clientGone reports whether err comes from the client disconnecting, which
+// cancels the upstream request
+func (h *ProxyHandler) clientGone(c *gin.Context, service string, err error) bool {
+	if !errors.Is(err, context.Canceled) || !errors.Is(c.Request.Context().Err(), context.Canceled) {
+		return false
+	}
+	h.log.Debug("Client disconnected, upstream request cancelled", zap.String("service", service))
+	metrics.ClientDisconnects.WithLabelValues(service).Inc()
+	c.Abort()
+	return true
+}
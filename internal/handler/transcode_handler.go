@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/transcode"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMetadataPrefix marks request headers passed to backends as gRPC metadata
+const grpcMetadataPrefix = "Grpc-Metadata-"
+
+// gatewayMetadata are the metadata keys set by the gateway, which clients cannot supply
+var gatewayMetadata = map[string]bool{
+	"authorization":    true,
+	"x-internal-token": true,
+	"x-tenant-id":      true,
+	"x-correlation-id": true,
+	"x-forwarded-for":  true,
+}
+
+// TranscodeHandler serves JSON/HTTP routes mapped to gRPC methods
+type TranscodeHandler struct {
+	transcoder *transcode.Transcoder
+	backends   *client.Backends
+	prefix     string
+	log        *logger.Logger
+}
+
+// NewTranscodeHandler creates a handler for routes mounted under prefix
+func NewTranscodeHandler(transcoder *transcode.Transcoder, backends *client.Backends, prefix string, log *logger.Logger) *TranscodeHandler {
+	return &TranscodeHandler{
+		transcoder: transcoder,
+		backends:   backends,
+		prefix:     strings.TrimSuffix(prefix, "/"),
+		log:        log,
+	}
+}
+
+// Handle translates the request to a gRPC call and the reply back to JSON
+func (h *TranscodeHandler) Handle(c *gin.Context) {
+	correlationID := c.GetString("correlation_id")
+	path := strings.TrimPrefix(c.Request.URL.EscapedPath(), h.prefix)
+
+	binding, vars, ok := h.transcoder.Match(c.Request.Method, path)
+	if !ok {
+		c.JSON(http.StatusNotFound, apierrors.NewErrorResponse("NOT_FOUND", "No method is mapped to this route", nil, correlationID))
+		return
+	}
+
+	conn, ok := h.backends.Conn(binding.Service())
+	if !ok {
+		h.log.Warn("No backend for gRPC service", zap.String("service", binding.Service()))
+		c.JSON(http.StatusServiceUnavailable, apierrors.NewErrorResponse("UNAVAILABLE", "Service unavailable", nil, correlationID))
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("INVALID_ARGUMENT", "Failed to read request body", nil, correlationID))
+		return
+	}
+	req, err := binding.NewRequest(vars, c.Request.URL.Query(), body)
+	if err != nil {
+		if errors.Is(err, transcode.ErrInvalidRequest) {
+			c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("INVALID_ARGUMENT", err.Error(), nil, correlationID))
+			return
+		}
+		h.log.Error("Failed to build gRPC request", zap.String("method", binding.FullMethod()), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, apierrors.NewErrorResponse("INTERNAL", "Internal server error", nil, correlationID))
+		return
+	}
+
+	ctx := metadata.NewOutgoingContext(c.Request.Context(), grpcMetadata(c))
+	resp := binding.NewResponse()
+	err = conn.Invoke(ctx, binding.FullMethod(), req, resp)
+	code := status.Code(err)
+	metrics.TranscodeRequests.WithLabelValues(binding.FullMethod(), code.String()).Inc()
+	if err != nil {
+		st := status.Convert(err)
+		if code == codes.Unknown || code == codes.Internal {
+			h.log.Error("gRPC call failed", zap.String("method", binding.FullMethod()), zap.Error(err))
+		}
+		c.JSON(transcode.HTTPStatus(code), apierrors.NewErrorResponse(transcode.ErrorCode(code), st.Message(), nil, correlationID))
+		return
+	}
+
+	data, err := binding.MarshalResponse(resp)
+	if err != nil {
+		h.log.Error("Failed to encode gRPC response", zap.String("method", binding.FullMethod()), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, apierrors.NewErrorResponse("INTERNAL", "Internal server error", nil, correlationID))
+		return
+	}
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// grpcMetadata carries the caller's identity and tracing headers to the
+// backend, as the HTTP proxy does with request headers. Headers named
+// Grpc-Metadata-<key> are passed as <key> unless the gateway sets that key.
+func grpcMetadata(c *gin.Context) metadata.MD {
+	md := metadata.MD{}
+	for _, name := range []string{"Authorization", "X-Internal-Token", "X-Tenant-ID"} {
+		if value := c.GetHeader(name); value != "" {
+			md.Set(name, value)
+		}
+	}
+	if correlationID := c.GetString("correlation_id"); correlationID != "" {
+		md.Set("x-correlation-id", correlationID)
+	}
+	md.Set("x-forwarded-for", c.ClientIP())
+
+	for name, values := range c.Request.Header {
+		key, ok := strings.CutPrefix(name, grpcMetadataPrefix)
+		key = strings.ToLower(key)
+		if ok && key != "" && !gatewayMetadata[key] && !strings.HasPrefix(key, "grpc-") {
+			md.Append(key, values...)
+		}
+	}
+	return md
+}
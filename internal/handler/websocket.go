@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/websocket"
+	"go.uber.org/zap"
+)
+
+// openWebSocket reserves a connection slot for the request's tenant and
+// arranges for the hijacked client connection to be managed by the session
+func (h *ProxyHandler) openWebSocket(c *gin.Context, service string) (*websocket.Session, bool) {
+	// Connections are limited per tenant, or per client IP without one
+	key := c.GetString("tenant_id")
+	if key == "" {
+		key = c.ClientIP()
+	}
+
+	session, err := h.websockets.Open(key, service)
+	switch {
+	case errors.Is(err, websocket.ErrTooManyConnections):
+		metrics.WebSocketRejected.WithLabelValues("tenant_limit").Inc()
+		h.log.Warn("WebSocket connection limit reached", zap.String("tenant", key), zap.String("service", service))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many WebSocket connections"})
+		return nil, false
+	case errors.Is(err, websocket.ErrDraining):
+		metrics.WebSocketRejected.WithLabelValues("draining").Inc()
+		c.Header("Connection", "close")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+
+	c.Writer = &websocketWriter{ResponseWriter: c.Writer, session: session}
+	return session, true
+}
+
+// websocketWriter hands the hijacked connection to the session
+type websocketWriter struct {
+	gin.ResponseWriter
+	session *websocket.Session
+}
+
+// Hijack implements http.Hijacker
+func (w *websocketWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.session.Attach(conn), rw, nil
+}
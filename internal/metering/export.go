@@ -0,0 +1,63 @@
+package metering
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteJSON writes records as a JSON array
+func WriteJSON(w io.Writer, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	return json.NewEncoder(w).Encode(records)
+}
+
+// WriteCSV writes records as CSV with one column per latency bucket
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{
+		"window_start", "window_end", "tenant_id", "service", "route",
+		"requests", "client_errors", "server_errors", "bytes_in", "bytes_out", "latency_sum_ms",
+	}
+	for _, bound := range LatencyBounds {
+		header = append(header, "latency_le_"+strconv.FormatInt(bound, 10)+"ms")
+	}
+	header = append(header, "latency_gt_"+strconv.FormatInt(LatencyBounds[len(LatencyBounds)-1], 10)+"ms")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		row := []string{
+			rec.WindowStart.UTC().Format(time.RFC3339),
+			rec.WindowEnd.UTC().Format(time.RFC3339),
+			rec.TenantID,
+			rec.Service,
+			rec.Route,
+			strconv.FormatInt(rec.Requests, 10),
+			strconv.FormatInt(rec.ClientErrors, 10),
+			strconv.FormatInt(rec.ServerErrors, 10),
+			strconv.FormatInt(rec.BytesIn, 10),
+			strconv.FormatInt(rec.BytesOut, 10),
+			strconv.FormatInt(rec.LatencySumMs, 10),
+		}
+		for i := 0; i <= len(LatencyBounds); i++ {
+			var count int64
+			if i < len(rec.LatencyBuckets) {
+				count = rec.LatencyBuckets[i]
+			}
+			row = append(row, strconv.FormatInt(count, 10))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
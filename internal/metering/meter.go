@@ -0,0 +1,310 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBounds are the upper bounds (in milliseconds) of the latency buckets.
+// A final implicit bucket counts everything slower than the last bound.
+var LatencyBounds = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// Sample is a single metered request
+type Sample struct {
+	Time     time.Time
+	TenantID string
+	Service  string
+	Route    string
+	Status   int
+	BytesIn  int64
+	BytesOut int64
+	Latency  time.Duration
+}
+
+// Record is the aggregated usage of one tenant/service/route in a time window
+type Record struct {
+	WindowStart    time.Time `json:"window_start"`
+	WindowEnd      time.Time `json:"window_end"`
+	TenantID       string    `json:"tenant_id"`
+	Service        string    `json:"service"`
+	Route          string    `json:"route"`
+	Requests       int64     `json:"requests"`
+	ClientErrors   int64     `json:"client_errors"`
+	ServerErrors   int64     `json:"server_errors"`
+	BytesIn        int64     `json:"bytes_in"`
+	BytesOut       int64     `json:"bytes_out"`
+	LatencySumMs   int64     `json:"latency_sum_ms"`
+	LatencyBuckets []int64   `json:"latency_buckets"`
+}
+
+type recordKey struct {
+	windowStart int64
+	tenantID    string
+	service     string
+	route       string
+}
+
+// Store persists flushed usage records
+type Store interface {
+	// Append writes records. On failure it returns an *AppendError when some
+	// records were written, and the others were not written at all.
+	Append(records []Record) error
+	Query(from, to time.Time) ([]Record, error)
+}
+
+// AppendError is returned by Store.Append when only some records were written
+type AppendError struct {
+	// Unwritten are the records that were not written
+	Unwritten []Record
+	Err       error
+}
+
+func (e *AppendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AppendError) Unwrap() error {
+	return e.Err
+}
+
+// Meter aggregates usage samples in memory and flushes them to a Store
+type Meter struct {
+	mu      sync.Mutex
+	window  time.Duration
+	store   Store
+	pending map[recordKey]*Record
+}
+
+// NewMeter creates a new meter aggregating samples into windows of the given size
+func NewMeter(store Store, window time.Duration) *Meter {
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &Meter{
+		window:  window,
+		store:   store,
+		pending: make(map[recordKey]*Record),
+	}
+}
+
+// Record adds a sample to the in-memory aggregates
+func (m *Meter) Record(s Sample) {
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+	windowStart := s.Time.UTC().Truncate(m.window)
+	key := recordKey{
+		windowStart: windowStart.UnixNano(),
+		tenantID:    s.TenantID,
+		service:     s.Service,
+		route:       s.Route,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.pending[key]
+	if !ok {
+		rec = &Record{
+			WindowStart:    windowStart,
+			WindowEnd:      windowStart.Add(m.window),
+			TenantID:       s.TenantID,
+			Service:        s.Service,
+			Route:          s.Route,
+			LatencyBuckets: make([]int64, len(LatencyBounds)+1),
+		}
+		m.pending[key] = rec
+	}
+
+	rec.Requests++
+	switch {
+	case s.Status >= 500:
+		rec.ServerErrors++
+	case s.Status >= 400:
+		rec.ClientErrors++
+	}
+	if s.BytesIn > 0 {
+		rec.BytesIn += s.BytesIn
+	}
+	if s.BytesOut > 0 {
+		rec.BytesOut += s.BytesOut
+	}
+
+	latencyMs := s.Latency.Milliseconds()
+	rec.LatencySumMs += latencyMs
+	rec.LatencyBuckets[bucketFor(latencyMs)]++
+}
+
+// Flush writes aggregates of closed windows to the store.
+// When all is true, windows that are still open are flushed as well (used on shutdown).
+func (m *Meter) Flush(all bool) error {
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	var records []Record
+	for key, rec := range m.pending {
+		if all || !rec.WindowEnd.After(now) {
+			records = append(records, *rec)
+			delete(m.pending, key)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	sortRecords(records)
+	if err := m.store.Append(records); err != nil {
+		// Put the unwritten records back so the next flush retries them;
+		// records already written would be billed twice
+		var appendErr *AppendError
+		if errors.As(err, &appendErr) {
+			records = appendErr.Unwritten
+		}
+		m.mu.Lock()
+		for _, rec := range records {
+			m.mergeLocked(rec)
+		}
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes closed windows periodically until ctx is done, then flushes everything
+func (m *Meter) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Flush(false); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-ctx.Done():
+			if err := m.Flush(true); err != nil && onError != nil {
+				onError(err)
+			}
+			return
+		}
+	}
+}
+
+// Query returns usage records whose window starts in [from, to), including
+// aggregates that have not been flushed yet
+func (m *Meter) Query(from, to time.Time, tenantID string) ([]Record, error) {
+	stored, err := m.store.Query(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[recordKey]*Record)
+	add := func(rec Record) {
+		if tenantID != "" && rec.TenantID != tenantID {
+			return
+		}
+		if rec.WindowStart.Before(from) || !rec.WindowStart.Before(to) {
+			return
+		}
+		key := keyOf(rec)
+		if existing, ok := merged[key]; ok {
+			mergeRecord(existing, rec)
+			return
+		}
+		rec.LatencyBuckets = append([]int64(nil), rec.LatencyBuckets...)
+		merged[key] = &rec
+	}
+
+	for _, rec := range stored {
+		add(rec)
+	}
+	m.mu.Lock()
+	for _, rec := range m.pending {
+		add(*rec)
+	}
+	m.mu.Unlock()
+
+	records := make([]Record, 0, len(merged))
+	for _, rec := range merged {
+		records = append(records, *rec)
+	}
+	sortRecords(records)
+	return records, nil
+}
+
+func (m *Meter) mergeLocked(rec Record) {
+	key := keyOf(rec)
+	if existing, ok := m.pending[key]; ok {
+		mergeRecord(existing, rec)
+		return
+	}
+	m.pending[key] = &rec
+}
+
+func keyOf(rec Record) recordKey {
+	return recordKey{
+		windowStart: rec.WindowStart.UnixNano(),
+		tenantID:    rec.TenantID,
+		service:     rec.Service,
+		route:       rec.Route,
+	}
+}
+
+func mergeRecord(dst *Record, src Record) {
+	dst.Requests += src.Requests
+	dst.ClientErrors += src.ClientErrors
+	dst.ServerErrors += src.ServerErrors
+	dst.BytesIn += src.BytesIn
+	dst.BytesOut += src.BytesOut
+	dst.LatencySumMs += src.LatencySumMs
+	for i := range dst.LatencyBuckets {
+		if i < len(src.LatencyBuckets) {
+			dst.LatencyBuckets[i] += src.LatencyBuckets[i]
+		}
+	}
+}
+
+func bucketFor(latencyMs int64) int {
+	for i, bound := range LatencyBounds {
+		if latencyMs <= bound {
+			return i
+		}
+	}
+	return len(LatencyBounds)
+}
+
+func sortRecords(records []Record) {
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.WindowStart.Equal(b.WindowStart) {
+			return a.WindowStart.Before(b.WindowStart)
+		}
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Route < b.Route
+	})
+}
+
+var idSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{24}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// NormalizeRoute replaces ID-like path segments with ":id" to keep route cardinality bounded
+func NormalizeRoute(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
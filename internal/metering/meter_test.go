@@ -0,0 +1,225 @@
+package metering
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory Store used by tests
+type memoryStore struct {
+	records []Record
+	err     error
+}
+
+func (s *memoryStore) Append(records []Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memoryStore) Query(from, to time.Time) ([]Record, error) {
+	var out []Record
+	for _, rec := range s.records {
+		if !rec.WindowStart.Before(from) && rec.WindowStart.Before(to) {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func TestRecord_Aggregates(t *testing.T) {
+	meter := NewMeter(&memoryStore{}, time.Minute)
+	base := time.Date(2026, 1, 8, 10, 0, 0, 0, time.UTC)
+
+	meter.Record(Sample{Time: base, TenantID: "t1", Service: "user", Route: "/profile", Status: 200, BytesIn: 10, BytesOut: 100, Latency: 5 * time.Millisecond})
+	meter.Record(Sample{Time: base.Add(10 * time.Second), TenantID: "t1", Service: "user", Route: "/profile", Status: 404, BytesIn: 20, BytesOut: 50, Latency: 300 * time.Millisecond})
+	meter.Record(Sample{Time: base.Add(20 * time.Second), TenantID: "t1", Service: "user", Route: "/profile", Status: 503, Latency: 10 * time.Second})
+	meter.Record(Sample{Time: base, TenantID: "t2", Service: "user", Route: "/profile", Status: 200})
+
+	records, err := meter.Query(base, base.Add(time.Hour), "t1")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+
+	rec := records[0]
+	if rec.Requests != 3 || rec.ClientErrors != 1 || rec.ServerErrors != 1 {
+		t.Errorf("Unexpected counts: %+v", rec)
+	}
+	if rec.BytesIn != 30 || rec.BytesOut != 150 {
+		t.Errorf("Unexpected bytes: in=%d out=%d", rec.BytesIn, rec.BytesOut)
+	}
+	if rec.LatencyBuckets[0] != 1 || rec.LatencyBuckets[4] != 1 || rec.LatencyBuckets[len(LatencyBounds)] != 1 {
+		t.Errorf("Unexpected latency buckets: %v", rec.LatencyBuckets)
+	}
+	if !rec.WindowEnd.Equal(base.Add(time.Minute)) {
+		t.Errorf("Expected window end %v, got %v", base.Add(time.Minute), rec.WindowEnd)
+	}
+}
+
+func TestFlush_ClosedWindowsOnly(t *testing.T) {
+	store := &memoryStore{}
+	meter := NewMeter(store, time.Minute)
+
+	meter.Record(Sample{Time: time.Now().Add(-time.Hour), TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+	meter.Record(Sample{Time: time.Now(), TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+
+	if err := meter.Flush(false); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("Expected 1 flushed record, got %d", len(store.records))
+	}
+
+	if err := meter.Flush(true); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(store.records) != 2 {
+		t.Errorf("Expected 2 flushed records after full flush, got %d", len(store.records))
+	}
+}
+
+func TestFlush_RetriesOnStoreError(t *testing.T) {
+	store := &memoryStore{err: errors.New("disk full")}
+	meter := NewMeter(store, time.Minute)
+	ts := time.Now().Add(-time.Hour)
+
+	meter.Record(Sample{Time: ts, TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+	if err := meter.Flush(true); err == nil {
+		t.Fatal("Expected flush error")
+	}
+
+	store.err = nil
+	meter.Record(Sample{Time: ts, TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+	if err := meter.Flush(true); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(store.records) != 1 || store.records[0].Requests != 2 {
+		t.Errorf("Expected one record with 2 requests, got %+v", store.records)
+	}
+}
+
+func TestFlush_RetriesOnlyUnwrittenRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	meter := NewMeter(store, time.Minute)
+	day1 := time.Date(2026, 1, 8, 23, 59, 0, 0, time.UTC)
+	day2 := day1.Add(time.Minute)
+
+	// the second day's file cannot be replaced
+	blocked := filepath.Join(dir, "usage-2026-01-09.jsonl")
+	if err := os.Mkdir(blocked, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	meter.Record(Sample{Time: day1, TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+	meter.Record(Sample{Time: day2, TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+	var appendErr *AppendError
+	if err := meter.Flush(true); !errors.As(err, &appendErr) || len(appendErr.Unwritten) != 1 {
+		t.Fatalf("Flush() error = %v, want an AppendError for the second day", err)
+	}
+
+	os.Remove(blocked)
+	if err := meter.Flush(true); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	records, err := store.Query(day1, day2.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Requests != 1 || records[1].Requests != 1 {
+		t.Errorf("Expected each window once with 1 request, got %+v", records)
+	}
+}
+
+func TestQuery_MergesStoredAndPending(t *testing.T) {
+	store := &memoryStore{}
+	meter := NewMeter(store, time.Minute)
+	ts := time.Now().Add(-time.Hour).Truncate(time.Minute)
+
+	meter.Record(Sample{Time: ts, TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+	_ = meter.Flush(true)
+	meter.Record(Sample{Time: ts, TenantID: "t1", Service: "user", Route: "/a", Status: 200})
+
+	records, err := meter.Query(ts, ts.Add(time.Minute), "")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Requests != 2 {
+		t.Errorf("Expected merged record with 2 requests, got %+v", records)
+	}
+
+	records, _ = meter.Query(ts.Add(time.Minute), ts.Add(time.Hour), "")
+	if len(records) != 0 {
+		t.Errorf("Expected no records outside range, got %d", len(records))
+	}
+}
+
+func TestNormalizeRoute(t *testing.T) {
+	tests := map[string]string{
+		"/profile":                              "/profile",
+		"/users/123":                            "/users/:id",
+		"/users/65a1b2c3d4e5f60718293a4b/roles": "/users/:id/roles",
+		"/files/3f2b8c1e-9a4d-4c2e-8f1a-0b1c2d3e4f5a": "/files/:id",
+		"/pages/about-us": "/pages/about-us",
+	}
+	for in, want := range tests {
+		if got := NormalizeRoute(in); got != want {
+			t.Errorf("NormalizeRoute(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	start := time.Date(2026, 1, 8, 10, 0, 0, 0, time.UTC)
+	records := []Record{{
+		WindowStart:    start,
+		WindowEnd:      start.Add(time.Minute),
+		TenantID:       "t1",
+		Service:        "user",
+		Route:          "/profile",
+		Requests:       3,
+		BytesIn:        30,
+		BytesOut:       150,
+		LatencyBuckets: make([]int64, len(LatencyBounds)+1),
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected header and 1 row, got %d rows", len(rows))
+	}
+	if len(rows[0]) != len(rows[1]) {
+		t.Errorf("Header has %d columns, row has %d", len(rows[0]), len(rows[1]))
+	}
+	if rows[1][2] != "t1" || rows[1][5] != "3" {
+		t.Errorf("Unexpected CSV row: %v", rows[1])
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded []Record
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded == nil {
+		t.Errorf("Expected empty JSON array, got %q", buf.String())
+	}
+}
@@ -0,0 +1,157 @@
+package metering
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore persists usage records as JSON lines, one file per UTC day
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates a file store rooted at dir
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create metering directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Append writes records to their day files and syncs them to disk. Each day
+// file is replaced as a whole, so a failed write leaves it unchanged; when a
+// later day fails, the records of the days written are left out of the
+// returned *AppendError.
+func (s *FileStore) Append(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDay := make(map[string][]Record)
+	var names []string
+	for _, rec := range records {
+		name := s.fileName(rec.WindowStart)
+		if _, ok := byDay[name]; !ok {
+			names = append(names, name)
+		}
+		byDay[name] = append(byDay[name], rec)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		if err := appendRecords(name, byDay[name]); err != nil {
+			if i == 0 {
+				return err
+			}
+			var unwritten []Record
+			for _, name := range names[i:] {
+				unwritten = append(unwritten, byDay[name]...)
+			}
+			return &AppendError{Unwritten: unwritten, Err: err}
+		}
+	}
+	return nil
+}
+
+// Query returns stored records whose window starts in [from, to)
+func (s *FileStore) Query(from, to time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		dayRecords, err := readRecords(s.fileName(day))
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range dayRecords {
+			if !rec.WindowStart.Before(from) && rec.WindowStart.Before(to) {
+				records = append(records, rec)
+			}
+		}
+	}
+	return records, nil
+}
+
+func (s *FileStore) fileName(t time.Time) string {
+	return filepath.Join(s.dir, "usage-"+t.UTC().Format("2006-01-02")+".jsonl")
+}
+
+// appendRecords writes the day file with records added to a temporary file
+// and renames it over the old one, so readers and crashes see either all of
+// the records or none
+func appendRecords(name string, records []Record) error {
+	existing, err := os.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read metering file: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create metering file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.Write(existing)
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		// End a torn line left by a crash, so the records below stay readable
+		w.WriteByte('\n')
+	}
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("failed to encode usage record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write metering file: %w", err)
+	}
+	if err := f.Chmod(0o644); err != nil {
+		return fmt.Errorf("failed to write metering file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to write metering file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write metering file: %w", err)
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("failed to replace metering file: %w", err)
+	}
+	return nil
+}
+
+func readRecords(name string) ([]Record, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metering file: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Skip a torn line left by a crash in the middle of a write
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read metering file: %w", err)
+	}
+	return records, nil
+}
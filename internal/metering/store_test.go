@@ -0,0 +1,97 @@
+package metering
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore_AppendAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	day1 := time.Date(2026, 1, 8, 23, 59, 0, 0, time.UTC)
+	day2 := day1.Add(time.Minute)
+	records := []Record{
+		{WindowStart: day1, WindowEnd: day2, TenantID: "t1", Service: "user", Route: "/a", Requests: 1},
+		{WindowStart: day2, WindowEnd: day2.Add(time.Minute), TenantID: "t1", Service: "user", Route: "/a", Requests: 2},
+	}
+	if err := store.Append(records); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "usage-2026-01-08.jsonl")); err != nil {
+		t.Errorf("Expected day file for 2026-01-08: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "usage-2026-01-09.jsonl")); err != nil {
+		t.Errorf("Expected day file for 2026-01-09: %v", err)
+	}
+
+	got, err := store.Query(day1, day2.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(got))
+	}
+
+	got, _ = store.Query(day2, day2.Add(time.Hour))
+	if len(got) != 1 || got[0].Requests != 2 {
+		t.Errorf("Expected only the second record, got %+v", got)
+	}
+}
+
+func TestFileStore_SkipsTornLines(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+
+	start := time.Date(2026, 1, 8, 10, 0, 0, 0, time.UTC)
+	if err := store.Append([]Record{{WindowStart: start, TenantID: "t1", Requests: 1}}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	f, _ := os.OpenFile(filepath.Join(dir, "usage-2026-01-08.jsonl"), os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`{"window_start":"2026-01-08T10:0`)
+	f.Close()
+
+	got, err := store.Query(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("Expected 1 record, got %d", len(got))
+	}
+}
+
+func TestFileStore_AppendKeepsFailedDayUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+
+	start := time.Date(2026, 1, 8, 10, 0, 0, 0, time.UTC)
+	if err := store.Append([]Record{{WindowStart: start, TenantID: "t1", Requests: 1}}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// a record that cannot be encoded fails the day's write
+	bad := Record{WindowStart: start.Add(time.Minute), TenantID: "t1", Requests: 2}
+	bad.WindowEnd = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := store.Append([]Record{{WindowStart: start.Add(time.Minute), TenantID: "t1", Requests: 3}, bad})
+	if err == nil {
+		t.Fatal("Expected append error")
+	}
+
+	got, err := store.Query(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Requests != 1 {
+		t.Errorf("Expected only the first record, got %+v", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files, got %d entries", len(entries))
+	}
+}
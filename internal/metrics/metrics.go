@@ -1,44 +1,371 @@
-package metrics
-
-import (
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-)
-
-var (
-	// RequestsTotal counts total number of requests
-	RequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "api_gateway_requests_total",
-			Help: "Total number of requests",
-		},
-		[]string{"method", "endpoint", "status"},
-	)
-
-	// RequestDuration measures request duration
-	RequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "api_gateway_request_duration_seconds",
-			Help:    "Request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "endpoint"},
-	)
-
-	// ActiveRequests tracks currently active requests
-	ActiveRequests = promauto.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "api_gateway_active_requests",
-			Help: "Number of active requests",
-		},
-	)
-
-	// CircuitBreakerState tracks circuit breaker states
-	CircuitBreakerState = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "api_gateway_circuit_breaker_state",
-			Help: "Circuit breaker state (0=closed, 1=open, 2=half-open)",
-		},
-		[]string{"service"},
-	)
-)
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// RequestsTotal counts total number of requests
+	RequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_requests_total",
+			Help: "Total number of requests",
+		},
+		[]string{"method", "endpoint", "status"},
+	)
+
+	// RequestDuration measures request duration
+	RequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_request_duration_seconds",
+			Help:    "Request duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "endpoint"},
+	)
+
+	// ActiveRequests tracks currently active requests
+	ActiveRequests = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_active_requests",
+			Help: "Number of active requests",
+		},
+	)
+
+	// CircuitBreakerState tracks circuit breaker states
+	CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_circuit_breaker_state",
+			Help: "Circuit breaker state (0=closed, 1=open, 2=half-open)",
+		},
+		[]string{"service"},
+	)
+)
+
+var (
+	// FairQueueWaiting tracks requests waiting for upstream capacity per priority class
+	FairQueueWaiting = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_fair_queue_waiting",
+			Help: "Number of requests waiting in the fair queue",
+		},
+		[]string{"class"},
+	)
+
+	// FairQueueInFlight tracks requests holding an upstream slot per priority class
+	FairQueueInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_fair_queue_in_flight",
+			Help: "Number of requests currently holding an upstream slot",
+		},
+		[]string{"class"},
+	)
+
+	// FairQueueWaitDuration measures how long requests wait before being admitted
+	FairQueueWaitDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_fair_queue_wait_seconds",
+			Help:    "Time spent waiting in the fair queue in seconds",
+			Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"class"},
+	)
+
+	// FairQueueRejected counts requests rejected by the fair queue
+	FairQueueRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_fair_queue_rejected_total",
+			Help: "Total number of requests rejected by the fair queue",
+		},
+		[]string{"class", "reason"},
+	)
+)
+
+var (
+	// ResponseCacheRequests counts proxied requests by response cache result
+	ResponseCacheRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_response_cache_requests_total",
+			Help: "Total number of proxied requests by response cache result (hit, miss, revalidated, bypass)",
+		},
+		[]string{"result"},
+	)
+)
+
+var (
+	// CanaryRequests counts proxied requests per service version, for comparing error rates
+	CanaryRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_canary_requests_total",
+			Help: "Total number of proxied requests by service version and status code",
+		},
+		[]string{"service", "version", "status"},
+	)
+
+	// CanaryRequestDuration measures upstream latency per service version
+	CanaryRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_canary_request_duration_seconds",
+			Help:    "Proxied request duration by service version in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service", "version"},
+	)
+)
+
+var (
+	// MirrorRequests counts mirrored requests by outcome
+	MirrorRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_mirror_requests_total",
+			Help: "Total number of mirrored requests by outcome (sent, match, diff, error, dropped, skipped)",
+		},
+		[]string{"result"},
+	)
+)
+
+var (
+	// WebSocketConnections is the number of open proxied WebSocket connections
+	WebSocketConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_websocket_connections",
+			Help: "Number of open WebSocket connections by service",
+		},
+		[]string{"service"},
+	)
+
+	// WebSocketConnectionDuration measures how long WebSocket connections stay open
+	WebSocketConnectionDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_websocket_connection_duration_seconds",
+			Help:    "WebSocket connection lifetime by service in seconds",
+			Buckets: []float64{1, 10, 60, 300, 900, 3600, 14400},
+		},
+		[]string{"service"},
+	)
+
+	// WebSocketMessages counts WebSocket data messages
+	WebSocketMessages = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_websocket_messages_total",
+			Help: "Total number of WebSocket messages by service and direction (inbound, outbound)",
+		},
+		[]string{"service", "direction"},
+	)
+
+	// WebSocketMessageBytes counts WebSocket message payload bytes
+	WebSocketMessageBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_websocket_message_bytes_total",
+			Help: "Total WebSocket message payload bytes by service and direction",
+		},
+		[]string{"service", "direction"},
+	)
+
+	// WebSocketRejected counts refused WebSocket upgrades
+	WebSocketRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_websocket_rejected_total",
+			Help: "Total number of refused WebSocket upgrades by reason (tenant_limit, draining)",
+		},
+		[]string{"reason"},
+	)
+)
+
+var (
+	// StreamingResponses counts proxied responses streamed to clients
+	StreamingResponses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_streaming_responses_total",
+			Help: "Total number of streamed responses by service and kind (sse, chunked)",
+		},
+		[]string{"service", "kind"},
+	)
+
+	// ClientDisconnects counts proxied requests cancelled because the client went away
+	ClientDisconnects = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_client_disconnects_total",
+			Help: "Total number of proxied requests cancelled by client disconnects",
+		},
+		[]string{"service"},
+	)
+)
+
+var (
+	// TranscodeRequests counts JSON requests transcoded to gRPC by method and status code
+	TranscodeRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_transcode_requests_total",
+			Help: "Total number of transcoded gRPC calls by method and gRPC status code",
+		},
+		[]string{"method", "code"},
+	)
+)
+
+var (
+	// GRPCWebRequests counts gRPC-Web calls by method and status code
+	GRPCWebRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_grpc_web_requests_total",
+			Help: "Total number of gRPC-Web calls by method and gRPC status code",
+		},
+		[]string{"method", "code"},
+	)
+)
+
+var (
+	// GRPCProxyCalls counts native gRPC calls proxied by service and status code
+	GRPCProxyCalls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_grpc_proxy_calls_total",
+			Help: "Total number of proxied gRPC calls by service and gRPC status code",
+		},
+		[]string{"service", "code"},
+	)
+
+	// GRPCProxyCallDuration tracks proxied gRPC call durations by service
+	GRPCProxyCallDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_grpc_proxy_call_duration_seconds",
+			Help:    "Duration of proxied gRPC calls in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service"},
+	)
+
+	// GRPCProxyActiveCalls tracks proxied gRPC calls in progress by service
+	GRPCProxyActiveCalls = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_grpc_proxy_active_calls",
+			Help: "Number of proxied gRPC calls in progress",
+		},
+		[]string{"service"},
+	)
+)
+
+var (
+	// AggregateRequests counts aggregate responses by route and outcome (complete, partial, failed)
+	AggregateRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_aggregate_requests_total",
+			Help: "Total number of aggregate responses by route and outcome",
+		},
+		[]string{"aggregate", "outcome"},
+	)
+
+	// AggregateCalls counts the calls made for aggregates by route, call and HTTP status
+	AggregateCalls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_aggregate_calls_total",
+			Help: "Total number of aggregate calls by route, call and HTTP status",
+		},
+		[]string{"aggregate", "call", "status"},
+	)
+
+	// AggregateCallDuration tracks aggregate call durations by route and call
+	AggregateCallDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_aggregate_call_duration_seconds",
+			Help:    "Duration of aggregate calls in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"aggregate", "call"},
+	)
+)
+
+var (
+	// GraphQLRequests counts GraphQL requests by outcome (success, partial, error, rejected)
+	GraphQLRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_graphql_requests_total",
+			Help: "Total number of GraphQL requests by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	// GraphQLRequestDuration tracks GraphQL request durations
+	GraphQLRequestDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_graphql_request_duration_seconds",
+			Help:    "Duration of GraphQL requests in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	// GraphQLLoaderBatchSize tracks how many keys GraphQL resolvers load per batch, by source
+	GraphQLLoaderBatchSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_graphql_loader_batch_size",
+			Help:    "Number of keys loaded per GraphQL loader batch",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100},
+		},
+		[]string{"source"},
+	)
+)
+
+var (
+	// BatchSize tracks how many requests batches hold
+	BatchSize = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_batch_size",
+			Help:    "Number of requests per batch",
+			Buckets: []float64{1, 2, 5, 10, 20, 50},
+		},
+	)
+
+	// BatchItems counts batch items by HTTP status
+	BatchItems = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_batch_items_total",
+			Help: "Total number of batch items by HTTP status",
+		},
+		[]string{"status"},
+	)
+)
+
+var (
+	// AsyncJobs counts asynchronous jobs by outcome (completed, failed, rejected)
+	AsyncJobs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_async_jobs_total",
+			Help: "Total number of asynchronous jobs by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	// AsyncJobsRunning tracks asynchronous jobs in progress
+	AsyncJobsRunning = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_async_jobs_running",
+			Help: "Number of asynchronous jobs in progress",
+		},
+	)
+
+	// AsyncJobDuration tracks how long asynchronous jobs run
+	AsyncJobDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "api_gateway_async_job_duration_seconds",
+			Help:    "Duration of asynchronous jobs in seconds",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+		},
+	)
+
+	// AsyncStoreErrors counts failures to store asynchronous jobs and results
+	AsyncStoreErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "api_gateway_async_store_errors_total",
+			Help: "Total number of failures to store asynchronous jobs and results",
+		},
+	)
+
+	// AsyncCallbacks counts job callbacks by outcome (delivered, failed)
+	AsyncCallbacks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_gateway_async_callbacks_total",
+			Help: "Total number of asynchronous job callbacks by outcome",
+		},
+		[]string{"outcome"},
+	)
+)
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/async"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// AsyncMiddleware runs authenticated requests that prefer respond-async in
+// the background and answers 202 Accepted with the job's status URL.
+// Other requests, and anonymous ones whose jobs nobody could poll, run as usual.
+func AsyncMiddleware(manager *async.Manager, statusPath string, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" || !manager.Accepts(c.Request) {
+			c.Next()
+			return
+		}
+
+		correlationID := c.GetString("correlation_id")
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("INVALID_REQUEST", "Failed to read request body", nil, correlationID))
+			c.Abort()
+			return
+		}
+
+		job, err := manager.Submit(c.Request, body, async.Owner{UserID: userID, TenantID: c.GetString("tenant_id")})
+		switch {
+		case errors.Is(err, async.ErrCallbackNotAllowed):
+			c.JSON(http.StatusBadRequest, apierrors.NewErrorResponse("CALLBACK_NOT_ALLOWED", "Callback URL is not allowed", err.Error(), correlationID))
+			c.Abort()
+			return
+		case errors.Is(err, async.ErrTooManyJobs):
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, apierrors.NewErrorResponse("TOO_MANY_JOBS", "Too many background requests, please retry", nil, correlationID))
+			c.Abort()
+			return
+		case err != nil:
+			log.Error("Failed to start background request", zap.String("correlation_id", correlationID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, apierrors.NewErrorResponse("INTERNAL_ERROR", "Failed to start background request", nil, correlationID))
+			c.Abort()
+			return
+		}
+
+		statusURL := statusPath + "/" + job.ID
+		c.Header("Location", statusURL)
+		c.Header("Preference-Applied", "respond-async")
+		c.JSON(http.StatusAccepted, gin.H{"job": job, "status_url": statusURL})
+		c.Abort()
+	}
+}
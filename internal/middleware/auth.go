@@ -1,98 +1,164 @@
-package middleware
-
-import (
-	"net/http"
-	"strings"
-	"time"
-
-	"github.com/gin-gonic/gin"
-	"github.com/vhvplatform/go-api-gateway/internal/client"
-	"github.com/vhvplatform/go-shared/cache"
-	"github.com/vhvplatform/go-shared/jwt"
-)
-
-// AuthMiddleware validates Opaque tokens via AuthService and injects Internal JWT
-func AuthMiddleware(authClient *client.AuthClient, tieredCache cache.Cache, jwtSecret string) gin.HandlerFunc {
-	jwtManager := jwt.NewManager(jwtSecret, 3600, 86400)
-
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
-		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
-		}
-
-		opaqueToken := parts[1]
-		cacheKey := "token:" + opaqueToken
-
-		// 1. Check Cache (Level 1 & Level 2 handled by TieredCache)
-		var resp client.VerifyTokenResponse
-		err := tieredCache.Get(c.Request.Context(), cacheKey, &resp)
-		if err == nil && resp.Valid {
-			// Cache hit
-			injectHeaders(c, &resp, jwtManager)
-			c.Next()
-			return
-		}
-
-		// 2. Cache miss, Verify Opaque Token via gRPC
-		apiResp, err := authClient.VerifyToken(c.Request.Context(), opaqueToken)
-		if err != nil || !apiResp.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
-		}
-
-		// 3. Cache the result (TieredCache handles L1/L2 updates)
-		_ = tieredCache.Set(c.Request.Context(), cacheKey, apiResp, 30*time.Minute)
-
-		// 4. Inject Headers and proceed
-		injectHeaders(c, apiResp, jwtManager)
-		c.Next()
-	}
-}
-
-func injectHeaders(c *gin.Context, resp *client.VerifyTokenResponse, jwtManager *jwt.Manager) {
-	// Generate Internal JWT
-	// Signature: GenerateToken(userID, tenantID, email string, roles, permissions []string)
-	internalToken, _ := jwtManager.GenerateToken(resp.UserId, resp.TenantId, resp.Email, resp.Roles, resp.Permissions)
-
-	// Inject Headers for backend services
-	c.Request.Header.Set("X-Tenant-ID", resp.TenantId)
-	c.Request.Header.Set("X-Internal-Token", internalToken)
-
-	// Set context vars for gateway internal use
-	c.Set("user_id", resp.UserId)
-	c.Set("tenant_id", resp.TenantId)
-	c.Set("roles", resp.Roles)
-	c.Set("permissions", resp.Permissions)
-}
-
-// TenantMiddleware ensures tenant context is available
-func TenantMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tenantID := c.GetString("tenant_id")
-		if tenantID == "" {
-			// Try to get from header
-			tenantID = c.GetHeader("X-Tenant-ID")
-			if tenantID == "" {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "Tenant ID required",
-				})
-				c.Abort()
-				return
-			}
-			c.Set("tenant_id", tenantID)
-		}
-
-		c.Next()
-	}
-}
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	internalcache "github.com/vhvplatform/go-api-gateway/internal/cache"
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	"github.com/vhvplatform/go-api-gateway/internal/websocket"
+	"github.com/vhvplatform/go-shared/cache"
+	"github.com/vhvplatform/go-shared/jwt"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// Authenticator verifies opaque tokens with AuthService, caching the results,
+// and issues the internal JWTs that backends receive in their place
+type Authenticator struct {
+	authClient *client.AuthClient
+	jwtManager *jwt.Manager
+	loader     *internalcache.Loader
+}
+
+// NewAuthenticator creates an Authenticator caching verified tokens in tieredCache
+func NewAuthenticator(authClient *client.AuthClient, tieredCache cache.Cache, jwtSecret string) *Authenticator {
+	return &Authenticator{
+		authClient: authClient,
+		jwtManager: jwt.NewManager(jwtSecret, 3600, 86400),
+		loader:     internalcache.NewLoader(tieredCache, internalcache.LoaderConfig{StaleTTL: time.Minute}),
+	}
+}
+
+// Verify checks an opaque token. Concurrent misses for the same token share
+// one VerifyToken call and expiring entries are refreshed in the background
+// while still being served.
+func (a *Authenticator) Verify(ctx context.Context, opaqueToken string) (*client.VerifyTokenResponse, error) {
+	// Check cache (L1 & L2 handled by TieredCache)
+	var resp client.VerifyTokenResponse
+	err := a.loader.GetOrLoad(ctx, tokenKey(opaqueToken), &resp, 30*time.Minute, func(ctx context.Context) (interface{}, error) {
+		apiResp, err := a.authClient.VerifyToken(ctx, opaqueToken)
+		if err != nil {
+			return nil, err
+		}
+		if !apiResp.Valid {
+			// Invalid tokens are not cached, and a token found revoked by a
+			// background refresh stops being served
+			return nil, internalcache.Evict(errInvalidToken)
+		}
+		return apiResp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Valid {
+		return nil, errInvalidToken
+	}
+	return &resp, nil
+}
+
+// tokenKey is the cache key of a verified token. Tokens are hashed, so key
+// listings and the shared cache never hold usable credentials.
+func tokenKey(opaqueToken string) string {
+	sum := sha256.Sum256([]byte(opaqueToken))
+	return "token:" + hex.EncodeToString(sum[:])
+}
+
+// InternalToken issues the internal JWT for a verified token
+func (a *Authenticator) InternalToken(resp *client.VerifyTokenResponse) string {
+	// Signature: GenerateToken(userID, tenantID, email string, roles, permissions []string)
+	internalToken, _ := a.jwtManager.GenerateToken(resp.UserId, resp.TenantId, resp.Email, resp.Roles, resp.Permissions)
+	return internalToken
+}
+
+// AuthMiddleware validates Opaque tokens via AuthService and injects Internal JWT
+func AuthMiddleware(authClient *client.AuthClient, tieredCache cache.Cache, jwtSecret string) gin.HandlerFunc {
+	return NewAuthenticator(authClient, tieredCache, jwtSecret).Middleware()
+}
+
+// Middleware authenticates requests with the Authenticator
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && websocket.IsUpgrade(c.Request) {
+			// Browsers cannot set headers on WebSocket handshakes; the token is
+			// removed from the query so it is not forwarded upstream
+			authHeader = websocketToken(c)
+		}
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			c.Abort()
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.Abort()
+			return
+		}
+
+		resp, err := a.Verify(c.Request.Context(), parts[1])
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		// Inject Headers and proceed
+		injectHeaders(c, resp, a.InternalToken(resp))
+		c.Next()
+	}
+}
+
+// websocketToken takes the access_token query parameter of a WebSocket handshake
+func websocketToken(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	token := query.Get("access_token")
+	if token == "" {
+		return ""
+	}
+	query.Del("access_token")
+	c.Request.URL.RawQuery = query.Encode()
+	return "Bearer " + token
+}
+
+func injectHeaders(c *gin.Context, resp *client.VerifyTokenResponse, internalToken string) {
+	// Inject Headers for backend services
+	c.Request.Header.Set("X-Tenant-ID", resp.TenantId)
+	c.Request.Header.Set("X-Internal-Token", internalToken)
+
+	// Set context vars for gateway internal use
+	c.Set("user_id", resp.UserId)
+	c.Set("tenant_id", resp.TenantId)
+	c.Set("roles", resp.Roles)
+	c.Set("permissions", resp.Permissions)
+	c.Set("tenant_plan", resp.Metadata["plan"])
+}
+
+// TenantMiddleware ensures tenant context is available
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := c.GetString("tenant_id")
+		if tenantID == "" {
+			// Try to get from header
+			tenantID = c.GetHeader("X-Tenant-ID")
+			if tenantID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Tenant ID required",
+				})
+				c.Abort()
+				return
+			}
+			c.Set("tenant_id", tenantID)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenKeyHidesToken(t *testing.T) {
+	key := tokenKey("opaque-secret")
+	if strings.Contains(key, "opaque-secret") || !strings.HasPrefix(key, "token:") {
+		t.Errorf("tokenKey() = %q", key)
+	}
+	if key != tokenKey("opaque-secret") || key == tokenKey("other") {
+		t.Errorf("tokenKey() is not a stable per-token key")
+	}
+}
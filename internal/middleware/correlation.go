@@ -1,21 +1,21 @@
-package middleware
-
-import (
-	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-)
-
-// CorrelationIDMiddleware adds a correlation ID to each request
-func CorrelationIDMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		correlationID := c.GetHeader("X-Correlation-ID")
-		if correlationID == "" {
-			correlationID = uuid.New().String()
-		}
-
-		c.Set("correlation_id", correlationID)
-		c.Header("X-Correlation-ID", correlationID)
-
-		c.Next()
-	}
-}
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CorrelationIDMiddleware adds a correlation ID to each request
+func CorrelationIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationID := c.GetHeader("X-Correlation-ID")
+		if correlationID == "" {
+			correlationID = uuid.New().String()
+		}
+
+		c.Set("correlation_id", correlationID)
+		c.Header("X-Correlation-ID", correlationID)
+
+		c.Next()
+	}
+}
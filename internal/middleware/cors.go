@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/cors"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+)
+
+// CORSMiddleware applies the gateway CORS policy. Origins on the resolved
+// tenant's custom domains are allowed in addition to the configured ones, so it
+// must run after TenantResolutionMiddleware and before TenantAdmissionMiddleware.
+func CORSMiddleware(policy *cors.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Handle(c.Writer, c.Request, tenantOrigins(c)) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// tenantOrigins returns the HTTPS origins of the resolved tenant's custom domains
+func tenantOrigins(c *gin.Context) []string {
+	value, ok := c.Get("tenant_profile")
+	if !ok {
+		return nil
+	}
+	profile, ok := value.(*tenant.Profile)
+	if !ok || len(profile.CustomDomains) == 0 {
+		return nil
+	}
+
+	origins := make([]string, 0, len(profile.CustomDomains))
+	for _, domain := range profile.CustomDomains {
+		origins = append(origins, "https://"+domain)
+	}
+	return origins
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/fairqueue"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// PriorityHeader lets trusted clients choose the priority class of a request
+const PriorityHeader = "X-Priority-Class"
+
+// FairQueueMiddleware classifies requests into priority classes and admits
+// them to the upstream through a weighted fair queue shared by all tenants
+func FairQueueMiddleware(queue *fairqueue.Queue, classifier *fairqueue.Classifier, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// requests without an authenticated or resolved tenant share by client
+		// address; a client-supplied X-Tenant-ID could fill another tenant's queue
+		tenantID := c.GetString("tenant_id")
+		if tenantID == "" {
+			tenantID = "ip:" + c.ClientIP()
+		}
+
+		class := classifier.Classify(
+			c.Request.URL.Path,
+			c.GetString("tenant_plan"),
+			c.GetHeader(PriorityHeader),
+			c.ClientIP(),
+		)
+		c.Set("priority_class", class)
+
+		release, err := queue.Acquire(c.Request.Context(), class, tenantID)
+		if err != nil {
+			log.Warn("Request rejected by fair queue",
+				zap.String("tenant_id", tenantID),
+				zap.String("class", class),
+				zap.Error(err))
+
+			code := "QUEUE_TIMEOUT"
+			if errors.Is(err, fairqueue.ErrQueueFull) {
+				code = "QUEUE_FULL"
+			}
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, apierrors.NewErrorResponse(
+				code,
+				"Upstream capacity exhausted, please retry",
+				gin.H{"priority_class": class},
+				c.GetString("correlation_id"),
+			))
+			c.Abort()
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
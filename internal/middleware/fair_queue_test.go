@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/fairqueue"
+	"github.com/vhvplatform/go-shared/logger"
+)
+
+func TestFairQueue_TenantHeaderDoesNotPickQueue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	queue, err := fairqueue.NewQueue(fairqueue.Config{
+		MaxConcurrent:     1,
+		MaxQueuePerTenant: 1,
+		Classes:           []fairqueue.Class{{Name: "normal", Weight: 1, MaxWait: 20 * time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatalf("NewQueue() error = %v", err)
+	}
+
+	// Fill the only slot and t-victim's whole queue
+	release, err := queue.Acquire(context.Background(), "normal", "holder")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = queue.Acquire(ctx, "normal", "t-victim")
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for _, waiting := queue.Stats(); waiting == 0; _, waiting = queue.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if tenantID := c.GetHeader("X-Resolved-Tenant"); tenantID != "" {
+			c.Set("tenant_id", tenantID)
+		}
+	})
+	r.Use(FairQueueMiddleware(queue, fairqueue.NewClassifier(fairqueue.ClassifierConfig{DefaultClass: "normal"}), logger.NewLogger()))
+	r.GET("/api/v1/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode string
+	}{
+		// t-victim's queue is full, so only requests queued under it are rejected at once
+		{"resolved tenant", "X-Resolved-Tenant", "t-victim", "QUEUE_FULL"},
+		{"spoofed header", "X-Tenant-ID", "t-victim", "QUEUE_TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/items", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected status 503, got %d", w.Code)
+			}
+			var body apierrors.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode body %q: %v", w.Body.String(), err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s", tt.wantCode, body.Code)
+			}
+		})
+	}
+}
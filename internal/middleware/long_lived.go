@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/async"
+	"github.com/vhvplatform/go-api-gateway/internal/stream"
+	"github.com/vhvplatform/go-api-gateway/internal/websocket"
+)
+
+// SkipLongLived runs next for every request except WebSocket upgrades on
+// WebSocket routes, streaming requests and background requests, which
+// outlive request timeouts and must not be compressed or buffered. Upgrades
+// are only exempt on configured routes, since any client can ask for one.
+func SkipLongLived(streams *stream.Routes, websockets *websocket.Routes, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if websockets.Match(c.Request) || streams.Match(c.Request) || async.IsJob(c.Request) {
+			c.Next()
+			return
+		}
+		next(c)
+	}
+}
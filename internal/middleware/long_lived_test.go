@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/websocket"
+)
+
+func TestSkipLongLived_WebSocketRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(SkipLongLived(nil, websocket.NewRoutes([]string{"/api/chat/"}), func(c *gin.Context) {
+		c.Header("X-Guarded", "true")
+		c.Next()
+	}))
+	r.GET("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		path        string
+		wantGuarded bool
+	}{
+		{"/api/chat/ws", false},
+		{"/api/user/ws", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if guarded := w.Header().Get("X-Guarded") == "true"; guarded != tt.wantGuarded {
+			t.Errorf("%s: guarded = %v, want %v", tt.path, guarded, tt.wantGuarded)
+		}
+	}
+}
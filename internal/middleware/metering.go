@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metering"
+)
+
+// anonymousTenant is the tenant key of requests with no resolved tenant
+const anonymousTenant = "anonymous"
+
+// countingReader counts bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// MeteringMiddleware records per-tenant usage (requests, bytes, latency) for billing
+func MeteringMiddleware(meter *metering.Meter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		var body *countingReader
+		if c.Request.Body != nil {
+			body = &countingReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		// only tenants established by authentication or tenant resolution
+		// are billed; a client-supplied X-Tenant-ID could bill anyone
+		tenantID := c.GetString("tenant_id")
+		if tenantID == "" {
+			tenantID = anonymousTenant
+		}
+
+		service := c.GetString("upstream_service")
+		if service == "" {
+			service = c.Param("service")
+		}
+
+		route := c.Param("path")
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		sample := metering.Sample{
+			Time:     start,
+			TenantID: tenantID,
+			Service:  service,
+			Route:    metering.NormalizeRoute("/" + strings.TrimPrefix(route, "/")),
+			Status:   c.Writer.Status(),
+			BytesOut: int64(c.Writer.Size()),
+			Latency:  time.Since(start),
+		}
+		if body != nil {
+			sample.BytesIn = body.n
+		}
+		meter.Record(sample)
+	}
+}
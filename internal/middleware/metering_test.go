@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metering"
+)
+
+func TestMetering_TenantHeaderIsNotBilled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store, err := metering.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	meter := metering.NewMeter(store, time.Minute)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if tenantID := c.GetHeader("X-Resolved-Tenant"); tenantID != "" {
+			c.Set("tenant_id", tenantID)
+		}
+	})
+	r.Use(MeteringMiddleware(meter))
+	r.GET("/api/v1/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, header := range []string{"X-Tenant-ID", "X-Resolved-Tenant"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/items", nil)
+		req.Header.Set(header, "t-victim")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	for tenantID, want := range map[string]int64{"t-victim": 1, anonymousTenant: 1} {
+		records, err := meter.Query(from, to, tenantID)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		var requests int64
+		for _, rec := range records {
+			requests += rec.Requests
+		}
+		if requests != want {
+			t.Errorf("Expected %d requests billed to %s, got %d", want, tenantID, requests)
+		}
+	}
+}
@@ -1,37 +1,37 @@
-package middleware
-
-import (
-	"strconv"
-	"time"
-
-	"github.com/gin-gonic/gin"
-	"github.com/vhvplatform/go-api-gateway/internal/metrics"
-)
-
-// MetricsMiddleware collects Prometheus metrics for requests
-func MetricsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		start := time.Now()
-
-		// Increment active requests
-		metrics.ActiveRequests.Inc()
-		defer metrics.ActiveRequests.Dec()
-
-		c.Next()
-
-		// Record metrics after request completes
-		duration := time.Since(start).Seconds()
-		status := strconv.Itoa(c.Writer.Status())
-
-		metrics.RequestsTotal.WithLabelValues(
-			c.Request.Method,
-			c.FullPath(),
-			status,
-		).Inc()
-
-		metrics.RequestDuration.WithLabelValues(
-			c.Request.Method,
-			c.FullPath(),
-		).Observe(duration)
-	}
-}
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+)
+
+// MetricsMiddleware collects Prometheus metrics for requests
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		// Increment active requests
+		metrics.ActiveRequests.Inc()
+		defer metrics.ActiveRequests.Dec()
+
+		c.Next()
+
+		// Record metrics after request completes
+		duration := time.Since(start).Seconds()
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.RequestsTotal.WithLabelValues(
+			c.Request.Method,
+			c.FullPath(),
+			status,
+		).Inc()
+
+		metrics.RequestDuration.WithLabelValues(
+			c.Request.Method,
+			c.FullPath(),
+		).Observe(duration)
+	}
+}
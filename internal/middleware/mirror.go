@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/mirror"
+)
+
+// mirrorRecorder keeps a copy of the primary response for comparison with the shadow
+type mirrorRecorder struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func (w *mirrorRecorder) Write(data []byte) (int, error) {
+	if !w.truncated {
+		if int64(w.body.Len()+len(data)) > w.limit {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *mirrorRecorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *mirrorRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MirrorMiddleware sends a sampled copy of proxied requests to shadow upstreams
+// once the primary response has been written, so clients never wait on them
+func MirrorMiddleware(m *mirror.Mirror) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := m.Sample(c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		body, ok := bufferBody(c.Request, m.MaxBodySize())
+		if !ok {
+			metrics.MirrorRequests.WithLabelValues("skipped").Inc()
+			c.Next()
+			return
+		}
+
+		var recorder *mirrorRecorder
+		if m.Compare() {
+			recorder = &mirrorRecorder{ResponseWriter: c.Writer, limit: m.MaxBodySize()}
+			c.Writer = recorder
+		}
+
+		c.Next()
+
+		// Headers are copied after the handlers ran, so they include the identity
+		// headers the gateway added (the mirror drops credentials); rewrites made
+		// only on the proxied request, such as X-Forwarded-*, are not included
+		req := &mirror.Request{
+			Method:     c.Request.Method,
+			RequestURI: c.Request.URL.RequestURI(),
+			Header:     c.Request.Header.Clone(),
+			Body:       body,
+		}
+		var primary *mirror.Response
+		if recorder != nil {
+			c.Writer = recorder.ResponseWriter
+			primary = &mirror.Response{
+				Status:    recorder.Status(),
+				Header:    recorder.Header().Clone(),
+				Body:      recorder.body.Bytes(),
+				Truncated: recorder.truncated,
+			}
+		}
+		m.Send(rule, req, primary)
+	}
+}
+
+// bufferBody reads a request body of at most limit bytes and puts it back for
+// the upstream. It reports false, leaving the body intact, when it is larger.
+func bufferBody(r *http.Request, limit int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > limit {
+		return nil, false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+
+	if err != nil || int64(len(data)) > limit {
+		return nil, false
+	}
+	return data, true
+}
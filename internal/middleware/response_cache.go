@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+	"github.com/vhvplatform/go-api-gateway/internal/respcache"
+)
+
+// CacheStatusHeader tells clients whether a response came from the gateway cache
+const CacheStatusHeader = "X-Cache"
+
+// cacheRecorder passes the upstream response through to the client while
+// keeping a copy of it for the response cache
+type cacheRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+	status   int
+	tags     []string
+
+	// revalidating swallows a 304 from the upstream so the cached body can be served instead
+	revalidating bool
+	notModified  bool
+}
+
+func (w *cacheRecorder) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	if code == http.StatusNotModified && w.revalidating {
+		w.notModified = true
+		return
+	}
+
+	header := w.Header()
+	if keys := header.Get(respcache.SurrogateKeyHeader); keys != "" {
+		w.tags = strings.Fields(keys)
+	}
+	header.Del(respcache.SurrogateKeyHeader)
+	header.Set(CacheStatusHeader, "MISS")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(data), nil
+	}
+
+	if !w.overflow {
+		if int64(w.body.Len()+len(data)) > w.limit {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheRecorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift
+// the write timeout of a stream
+func (w *cacheRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *cacheRecorder) Flush() {
+	if w.notModified {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// ResponseCacheMiddleware serves proxied GET requests from the shared response
+// cache, revalidates stale entries with the upstream and stores cacheable responses.
+// Successful unsafe requests invalidate the cached responses for their URL.
+// version returns the upstream version a request goes to, such as its canary
+// version, so versions are cached apart; nil means a single version.
+func ResponseCacheMiddleware(rc *respcache.Cache, version func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Only a resolved or authenticated tenant keys the cache; the client's
+		// tenant header would let it write into another tenant's entries
+		tenantID := c.GetString("tenant_id")
+		_, authenticated := c.Get("user_id")
+
+		rule, cacheable := rc.Cacheable(c.Request)
+		if !cacheable {
+			metrics.ResponseCacheRequests.WithLabelValues("bypass").Inc()
+			c.Next()
+			if isUnsafeMethod(c.Request.Method) && c.Writer.Status() < http.StatusBadRequest {
+				rc.Invalidate(c.Request.Context(), c.Request, tenantID)
+			}
+			return
+		}
+
+		ctx := c.Request.Context()
+		upstreamVersion := ""
+		if version != nil {
+			upstreamVersion = version(c)
+		}
+		key := rc.Key(c.Request, tenantID, upstreamVersion, authenticated)
+		clientHeader := c.Request.Header.Clone()
+		forceRevalidate := respcache.ParseCacheControl(clientHeader.Get("Cache-Control")).Has("no-cache")
+
+		entry, found := rc.Lookup(ctx, key, c.Request)
+		if found && entry.Fresh(time.Now()) && !forceRevalidate {
+			metrics.ResponseCacheRequests.WithLabelValues("hit").Inc()
+			serveCached(c, entry, clientHeader, "HIT")
+			c.Abort()
+			return
+		}
+
+		// Headers already set by the gateway (correlation ID, CORS...) are not cached
+		gatewayHeader := c.Writer.Header().Clone()
+		recorder := &cacheRecorder{ResponseWriter: c.Writer, limit: rc.MaxBodySize()}
+		if found {
+			// Ask the upstream whether our stale copy is still valid
+			c.Request.Header.Del("If-None-Match")
+			c.Request.Header.Del("If-Modified-Since")
+			recorder.revalidating = entry.SetValidators(c.Request.Header)
+		}
+
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		if recorder.notModified {
+			metrics.ResponseCacheRequests.WithLabelValues("revalidated").Inc()
+			entry = rc.Revalidated(ctx, key, c.Request, rule, authenticated, entry, upstreamHeader(c.Writer.Header(), gatewayHeader))
+			serveCached(c, entry, clientHeader, "REVALIDATED")
+			return
+		}
+
+		metrics.ResponseCacheRequests.WithLabelValues("miss").Inc()
+		if !recorder.overflow && !c.IsAborted() {
+			header := upstreamHeader(c.Writer.Header(), gatewayHeader)
+			rc.Store(ctx, key, c.Request, rule, authenticated, recorder.status, header, recorder.body.Bytes(), recorder.tags)
+		}
+	}
+}
+
+// serveCached writes a cached response, answering 304 if the client's copy is current
+func serveCached(c *gin.Context, entry *respcache.Entry, clientHeader http.Header, status string) {
+	header := c.Writer.Header()
+	for name, values := range entry.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(entry.Age(time.Now()).Seconds())))
+	header.Set(CacheStatusHeader, status)
+
+	if entry.NotModified(clientHeader) {
+		header.Del("Content-Length")
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Status(entry.Status)
+	_, _ = c.Writer.Write(entry.Body)
+}
+
+// upstreamHeader returns the response headers that were added or changed after gatewayHeader was taken
+func upstreamHeader(header, gatewayHeader http.Header) http.Header {
+	result := make(http.Header, len(header))
+	for name, values := range header {
+		if name == CacheStatusHeader || slices.Equal(values, gatewayHeader[name]) {
+			continue
+		}
+		result[name] = values
+	}
+	return result
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
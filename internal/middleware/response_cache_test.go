@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/cache"
+	"github.com/vhvplatform/go-api-gateway/internal/respcache"
+)
+
+// mapStore is a respcache.Store that keeps every write, unlike the local cache
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (s *mapStore) Get(_ context.Context, key string, dest interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.values[key]
+	if !ok {
+		return cache.ErrCacheMiss
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (s *mapStore) Set(_ context.Context, key string, value interface{}, _ time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = data
+	return nil
+}
+
+func (s *mapStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+// cachedEngine serves upstream behind the response cache and counts the
+// requests that reach it
+func cachedEngine(upstream gin.HandlerFunc) (*gin.Engine, *respcache.Cache, *int) {
+	rc := respcache.New(&mapStore{values: map[string][]byte{}}, respcache.Config{})
+	calls := 0
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ResponseCacheMiddleware(rc, nil))
+	r.Any("/api/*path", func(c *gin.Context) {
+		calls++
+		upstream(c)
+	})
+	return r, rc, &calls
+}
+
+func get(r http.Handler, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCache_HitAndConditionalRequest(t *testing.T) {
+	r, _, calls := cachedEngine(func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		c.Header("ETag", `"v1"`)
+		c.String(http.StatusOK, "pages")
+	})
+
+	first := get(r, "/api/cms/pages", nil)
+	if first.Code != http.StatusOK || first.Header().Get(CacheStatusHeader) != "MISS" {
+		t.Fatalf("first: %d %s", first.Code, first.Header().Get(CacheStatusHeader))
+	}
+
+	second := get(r, "/api/cms/pages", nil)
+	if second.Header().Get(CacheStatusHeader) != "HIT" || second.Body.String() != "pages" || *calls != 1 {
+		t.Errorf("second: %s %q after %d upstream calls", second.Header().Get(CacheStatusHeader), second.Body, *calls)
+	}
+
+	// a client holding the current version gets 304 from the cache
+	conditional := get(r, "/api/cms/pages", map[string]string{"If-None-Match": `"v1"`})
+	if conditional.Code != http.StatusNotModified || conditional.Body.Len() != 0 || *calls != 1 {
+		t.Errorf("conditional: %d %q after %d upstream calls", conditional.Code, conditional.Body, *calls)
+	}
+}
+
+func TestResponseCache_Revalidation(t *testing.T) {
+	var ifNoneMatch string
+	r, _, calls := cachedEngine(func(c *gin.Context) {
+		ifNoneMatch = c.GetHeader("If-None-Match")
+		c.Header("Cache-Control", "max-age=0")
+		c.Header("ETag", `"v1"`)
+		if ifNoneMatch == `"v1"` {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.String(http.StatusOK, "pages")
+	})
+
+	get(r, "/api/cms/pages", nil)
+	w := get(r, "/api/cms/pages", nil)
+	if ifNoneMatch != `"v1"` {
+		t.Errorf("upstream If-None-Match = %q, want the cached ETag", ifNoneMatch)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "pages" || w.Header().Get(CacheStatusHeader) != "REVALIDATED" || *calls != 2 {
+		t.Errorf("revalidated: %d %q %s after %d upstream calls", w.Code, w.Body, w.Header().Get(CacheStatusHeader), *calls)
+	}
+}
+
+func TestResponseCache_VaryVariants(t *testing.T) {
+	r, _, calls := cachedEngine(func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		c.Header("Vary", "Accept-Language")
+		c.String(http.StatusOK, "hello "+c.GetHeader("Accept-Language"))
+	})
+
+	for _, lang := range []string{"en", "vi", "en", "vi"} {
+		w := get(r, "/api/cms/greeting", map[string]string{"Accept-Language": lang})
+		if w.Body.String() != "hello "+lang {
+			t.Errorf("%s: body = %q", lang, w.Body)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("upstream calls = %d, want one per variant", *calls)
+	}
+}
+
+func TestResponseCache_TagPurge(t *testing.T) {
+	r, rc, calls := cachedEngine(func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		c.Header(respcache.SurrogateKeyHeader, "user-1 users")
+		c.String(http.StatusOK, "user")
+	})
+
+	first := get(r, "/api/users/1", nil)
+	if first.Header().Get(respcache.SurrogateKeyHeader) != "" {
+		t.Errorf("surrogate keys reached the client")
+	}
+	if w := get(r, "/api/users/1", nil); w.Header().Get(CacheStatusHeader) != "HIT" {
+		t.Fatalf("second: %s, want HIT", w.Header().Get(CacheStatusHeader))
+	}
+
+	if err := rc.PurgeTags(context.Background(), []string{"user-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, "/api/users/1", nil); w.Header().Get(CacheStatusHeader) != "MISS" || *calls != 2 {
+		t.Errorf("after purge: %s after %d upstream calls, want MISS", w.Header().Get(CacheStatusHeader), *calls)
+	}
+}
+
+func TestResponseCache_TenantHeaderDoesNotPickKey(t *testing.T) {
+	r, _, calls := cachedEngine(func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, "pages")
+	})
+
+	spoofed := get(r, "/api/cms/pages", map[string]string{"X-Tenant-ID": "t-1"})
+	if spoofed.Code != http.StatusOK {
+		t.Fatalf("spoofed: %d", spoofed.Code)
+	}
+
+	// the header named no tenant, so the response was stored for no tenant
+	plain := get(r, "/api/cms/pages", nil)
+	if plain.Header().Get(CacheStatusHeader) != "HIT" || *calls != 1 {
+		t.Errorf("plain: %s after %d upstream calls", plain.Header().Get(CacheStatusHeader), *calls)
+	}
+}
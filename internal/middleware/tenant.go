@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/client"
+	apierrors "github.com/vhvplatform/go-api-gateway/internal/errors"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// tenantRejectionKey holds the response to a request TenantResolutionMiddleware
+// refused, until TenantAdmissionMiddleware sends it
+const tenantRejectionKey = "tenant_rejection"
+
+// TenantResolutionMiddleware identifies the tenant of every request from its
+// host, path prefix or header and exposes the tenant's routing profile to the
+// proxy handlers. Requests that name no tenant pass through unchanged.
+// Requests for unknown or inadmissible tenants are refused by
+// TenantAdmissionMiddleware, so CORS can run in between and browsers can read
+// the error; the refused tenant's profile is exposed for CORS only.
+func TenantResolutionMiddleware(resolver *tenant.Resolver, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profile, method, err := resolver.Resolve(c.Request)
+		if errors.Is(err, tenant.ErrNotFound) {
+			c.Set(tenantRejectionKey, func(c *gin.Context) {
+				tenantNotFound(c, method)
+			})
+			c.Next()
+			return
+		}
+		if err != nil {
+			log.Error("Failed to resolve tenant", zap.String("resolution", string(method)), zap.Error(err))
+			c.Set(tenantRejectionKey, tenantUnavailable)
+			c.Next()
+			return
+		}
+
+		if profile != nil {
+			if err := profile.Admit(c.Request.Method); err != nil {
+				c.Set("tenant_profile", profile)
+				c.Set(tenantRejectionKey, func(c *gin.Context) {
+					rejectTenant(c, profile, err)
+				})
+				c.Next()
+				return
+			}
+
+			setTenantProfile(c, profile, method)
+		}
+
+		c.Next()
+	}
+}
+
+// TokenTenantMiddleware loads the profile of the authenticated token's tenant
+// for requests that named no tenant, so the tenant's lifecycle status and
+// routing profile apply whether or not the client names it. It must follow
+// the auth middleware. Requests are refused when the profile cannot be
+// loaded, rather than being served without the tenant's status and region,
+// and when they name a tenant other than the token's.
+func TokenTenantMiddleware(resolver *tenant.Resolver, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := c.GetString("tenant_id")
+		if value, resolved := c.Get("tenant_profile"); resolved {
+			// A client naming a tenant must hold one of its tokens
+			if profile := value.(*tenant.Profile); tenantID != "" && tenantID != profile.ID {
+				c.JSON(http.StatusForbidden, apierrors.NewErrorResponse(
+					"TENANT_FORBIDDEN",
+					"Token does not belong to this tenant",
+					nil,
+					c.GetString("correlation_id"),
+				))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if tenantID == "" {
+			c.Next()
+			return
+		}
+
+		profile, err := resolver.Lookup(c.Request.Context(), tenantID)
+		if errors.Is(err, tenant.ErrNotFound) {
+			tenantNotFound(c, tenant.MethodToken)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			log.Error("Failed to load token tenant", zap.String("tenant_id", tenantID), zap.Error(err))
+			tenantUnavailable(c)
+			c.Abort()
+			return
+		}
+
+		if err := profile.Admit(c.Request.Method); err != nil {
+			rejectTenant(c, profile, err)
+			c.Abort()
+			return
+		}
+
+		setTenantProfile(c, profile, tenant.MethodToken)
+		c.Next()
+	}
+}
+
+// setTenantProfile exposes a resolved tenant's profile to the handlers
+func setTenantProfile(c *gin.Context, profile *tenant.Profile, method tenant.Method) {
+	c.Set("tenant_profile", profile)
+	c.Set("tenant_id", profile.ID)
+	c.Set("tenant_resolution", string(method))
+	if profile.DefaultService != "" {
+		c.Set("tenant_default_service", profile.DefaultService)
+	}
+	if profile.Plan != "" {
+		c.Set("tenant_plan", profile.Plan)
+	}
+	// Upstreams see the resolved tenant, not whatever the client sent
+	c.Request.Header.Set("X-Tenant-ID", profile.ID)
+}
+
+func tenantNotFound(c *gin.Context, method tenant.Method) {
+	c.JSON(http.StatusNotFound, apierrors.NewErrorResponse(
+		"TENANT_NOT_FOUND",
+		"Tenant not found",
+		gin.H{"resolution": method},
+		c.GetString("correlation_id"),
+	))
+}
+
+func tenantUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, apierrors.NewErrorResponse(
+		"TENANT_UNAVAILABLE",
+		"Tenant service unavailable, please retry",
+		nil,
+		c.GetString("correlation_id"),
+	))
+}
+
+// TenantAdmissionMiddleware refuses the requests TenantResolutionMiddleware
+// could not admit. It must follow TenantResolutionMiddleware, after CORSMiddleware.
+func TenantAdmissionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := c.Get(tenantRejectionKey); ok {
+			value.(func(*gin.Context))(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rejectTenant answers a request the tenant's lifecycle status does not admit
+func rejectTenant(c *gin.Context, profile *tenant.Profile, err error) {
+	correlationID := c.GetString("correlation_id")
+	switch {
+	case errors.Is(err, tenant.ErrSuspended):
+		details := gin.H{"maintenance": true}
+		if m := profile.Maintenance; m != nil {
+			if m.Message != "" {
+				details["message"] = m.Message
+			}
+			if !m.Until.IsZero() {
+				details["until"] = m.Until
+				if wait := time.Until(m.Until); wait > 0 {
+					c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				}
+			}
+		}
+		c.JSON(http.StatusForbidden, apierrors.NewErrorResponse(
+			"TENANT_SUSPENDED",
+			"Tenant is suspended",
+			details,
+			correlationID,
+		))
+	case errors.Is(err, tenant.ErrReadOnly):
+		c.Header("Allow", "GET, HEAD, OPTIONS")
+		c.JSON(http.StatusForbidden, apierrors.NewErrorResponse(
+			"TENANT_READ_ONLY",
+			"Tenant is in read-only mode",
+			nil,
+			correlationID,
+		))
+	default:
+		// Deleted tenants look the same as tenants that never existed
+		c.JSON(http.StatusNotFound, apierrors.NewErrorResponse(
+			"TENANT_NOT_FOUND",
+			"Tenant not found",
+			nil,
+			correlationID,
+		))
+	}
+}
+
+// tenantSource adapts the tenant service client to tenant.Source
+type tenantSource struct {
+	client *client.TenantClient
+}
+
+// NewTenantSource returns a tenant.Source backed by the tenant service
+func NewTenantSource(tenantClient *client.TenantClient) tenant.Source {
+	return &tenantSource{client: tenantClient}
+}
+
+func (s *tenantSource) GetTenant(ctx context.Context, idOrSlug string) (*tenant.Profile, error) {
+	t, err := s.client.GetTenant(ctx, idOrSlug)
+	return s.profile(ctx, t, err)
+}
+
+func (s *tenantSource) GetTenantByDomain(ctx context.Context, domain string) (*tenant.Profile, error) {
+	t, err := s.client.GetTenantByDomain(ctx, domain)
+	return s.profile(ctx, t, err)
+}
+
+// profile builds a routing profile from a tenant and its configured services
+func (s *tenantSource) profile(ctx context.Context, t *client.Tenant, err error) (*tenant.Profile, error) {
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, tenant.ErrNotFound
+	}
+
+	services, err := s.client.ListTenantServices(ctx, t.Id)
+	if err != nil {
+		return nil, err
+	}
+	var enabled map[string]bool
+	var aliases map[string]string
+	for _, service := range services {
+		if enabled == nil {
+			enabled = make(map[string]bool)
+		}
+		enabled[service.Name] = service.Enabled
+		for _, alias := range service.Aliases {
+			if aliases == nil {
+				aliases = make(map[string]string)
+			}
+			aliases[alias] = service.Name
+		}
+	}
+
+	var maintenance *tenant.Maintenance
+	if t.MaintenanceMessage != "" || t.MaintenanceUntil > 0 {
+		maintenance = &tenant.Maintenance{Message: t.MaintenanceMessage}
+		if t.MaintenanceUntil > 0 {
+			maintenance.Until = time.Unix(t.MaintenanceUntil, 0).UTC()
+		}
+	}
+
+	return &tenant.Profile{
+		ID:             t.Id,
+		Slug:           t.Slug,
+		Name:           t.Name,
+		Status:         t.Status,
+		Plan:           t.Plan,
+		DefaultService: t.DefaultService,
+		Services:       enabled,
+		Aliases:        aliases,
+		Pool:           t.Pool,
+		Region:         t.Region,
+		CustomDomains:  t.CustomDomains,
+		Theme:          t.Theme,
+		Maintenance:    maintenance,
+	}, nil
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-api-gateway/internal/cors"
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+	"github.com/vhvplatform/go-shared/logger"
+)
+
+// tenantSourceStub serves profiles by ID; "broken" fails like an unreachable tenant service
+type tenantSourceStub map[string]*tenant.Profile
+
+func (s tenantSourceStub) GetTenant(_ context.Context, id string) (*tenant.Profile, error) {
+	if id == "broken" {
+		return nil, errors.New("tenant service unavailable")
+	}
+	if profile, ok := s[id]; ok {
+		return profile, nil
+	}
+	return nil, tenant.ErrNotFound
+}
+
+func (s tenantSourceStub) GetTenantByDomain(_ context.Context, _ string) (*tenant.Profile, error) {
+	return nil, tenant.ErrNotFound
+}
+
+// tenantEngine chains tenant resolution, CORS, admission and the token
+// tenant as the gateway does
+func tenantEngine(t *testing.T, profiles tenantSourceStub) *gin.Engine {
+	t.Helper()
+	policy, err := cors.New(cors.Config{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := tenant.NewResolver(profiles, tenant.ResolverConfig{Header: "X-Tenant-ID"})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(
+		TenantResolutionMiddleware(resolver, logger.NewLogger()),
+		CORSMiddleware(policy),
+		TenantAdmissionMiddleware(),
+	)
+	// a stand-in for the auth middleware: the bearer token is the tenant ID
+	r.Any("/api/*path", func(c *gin.Context) {
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			c.Set("tenant_id", token)
+		}
+	}, TokenTenantMiddleware(resolver, logger.NewLogger()), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("tenant_id"))
+	})
+	return r
+}
+
+func TestTenantRejectionsCarryCORSHeaders(t *testing.T) {
+	r := tenantEngine(t, tenantSourceStub{
+		"acme": {ID: "acme", Status: tenant.StatusSuspended, CustomDomains: []string{"acme.shop"}},
+	})
+
+	tests := []struct {
+		tenant     string
+		origin     string
+		wantStatus int
+	}{
+		{"missing", "https://app.example.com", http.StatusNotFound},
+		{"broken", "https://app.example.com", http.StatusServiceUnavailable},
+		{"acme", "https://app.example.com", http.StatusForbidden},
+		// the suspended tenant's own custom domain is still allowed
+		{"acme", "https://acme.shop", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("X-Tenant-ID", tt.tenant)
+		req.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s from %s: status = %d, want %d", tt.tenant, tt.origin, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+			t.Errorf("%s from %s: Access-Control-Allow-Origin = %q", tt.tenant, tt.origin, got)
+		}
+	}
+}
+
+func TestTenantLifecycleStatus(t *testing.T) {
+	until := time.Now().Add(time.Hour).UTC()
+	r := tenantEngine(t, tenantSourceStub{
+		"active":    {ID: "active", Status: tenant.StatusActive},
+		"suspended": {ID: "suspended", Status: tenant.StatusSuspended, Maintenance: &tenant.Maintenance{Message: "Upgrading", Until: until}},
+		"paused":    {ID: "paused", Status: tenant.StatusSuspended},
+		"archived":  {ID: "archived", Status: tenant.StatusReadOnly},
+		"deleted":   {ID: "deleted", Status: tenant.StatusDeleted},
+	})
+
+	tests := []struct {
+		tenant      string
+		method      string
+		wantStatus  int
+		wantCode    string
+		wantHeaders map[string]string
+		wantDetails map[string]interface{}
+	}{
+		{"active", http.MethodPost, http.StatusOK, "", nil, nil},
+		{"suspended", http.MethodGet, http.StatusForbidden, "TENANT_SUSPENDED",
+			map[string]string{"Retry-After": "3600"},
+			map[string]interface{}{"maintenance": true, "message": "Upgrading", "until": until.Format(time.RFC3339Nano)}},
+		{"paused", http.MethodGet, http.StatusForbidden, "TENANT_SUSPENDED",
+			map[string]string{"Retry-After": ""},
+			map[string]interface{}{"maintenance": true}},
+		{"archived", http.MethodGet, http.StatusOK, "", nil, nil},
+		{"archived", http.MethodHead, http.StatusOK, "", nil, nil},
+		{"archived", http.MethodPost, http.StatusForbidden, "TENANT_READ_ONLY",
+			map[string]string{"Allow": "GET, HEAD, OPTIONS"}, nil},
+		{"archived", http.MethodDelete, http.StatusForbidden, "TENANT_READ_ONLY", nil, nil},
+		{"deleted", http.MethodGet, http.StatusNotFound, "TENANT_NOT_FOUND", nil, nil},
+		{"missing", http.MethodGet, http.StatusNotFound, "TENANT_NOT_FOUND", nil, map[string]interface{}{"resolution": "header"}},
+	}
+	for _, tt := range tests {
+		name := tt.tenant + " " + tt.method
+		req := httptest.NewRequest(tt.method, "/api/users", nil)
+		req.Header.Set("X-Tenant-ID", tt.tenant)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, tt.wantStatus)
+			continue
+		}
+		for header, want := range tt.wantHeaders {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("%s: %s = %q, want %q", name, header, got, want)
+			}
+		}
+		if tt.wantCode == "" {
+			if tt.method != http.MethodHead && w.Body.String() != tt.tenant {
+				t.Errorf("%s: handler saw tenant %q", name, w.Body)
+			}
+			continue
+		}
+
+		var body struct {
+			Code    string                 `json:"code"`
+			Details map[string]interface{} `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if body.Code != tt.wantCode {
+			t.Errorf("%s: code = %q, want %q", name, body.Code, tt.wantCode)
+		}
+		if tt.wantDetails != nil && !reflect.DeepEqual(body.Details, tt.wantDetails) {
+			t.Errorf("%s: details = %v, want %v", name, body.Details, tt.wantDetails)
+		}
+	}
+}
+
+func TestTokenTenantAdmission(t *testing.T) {
+	r := tenantEngine(t, tenantSourceStub{
+		"active":   {ID: "active", Status: tenant.StatusActive},
+		"paused":   {ID: "paused", Status: tenant.StatusSuspended},
+		"archived": {ID: "archived", Status: tenant.StatusReadOnly},
+		"deleted":  {ID: "deleted", Status: tenant.StatusDeleted},
+		"other":    {ID: "other", Status: tenant.StatusActive},
+	})
+
+	tests := []struct {
+		token      string
+		header     string
+		method     string
+		wantStatus int
+		wantCode   string
+	}{
+		{"active", "", http.MethodPost, http.StatusOK, ""},
+		{"paused", "", http.MethodGet, http.StatusForbidden, "TENANT_SUSPENDED"},
+		{"archived", "", http.MethodGet, http.StatusOK, ""},
+		{"archived", "", http.MethodPost, http.StatusForbidden, "TENANT_READ_ONLY"},
+		{"deleted", "", http.MethodGet, http.StatusNotFound, "TENANT_NOT_FOUND"},
+		{"missing", "", http.MethodGet, http.StatusNotFound, "TENANT_NOT_FOUND"},
+		{"broken", "", http.MethodGet, http.StatusServiceUnavailable, "TENANT_UNAVAILABLE"},
+		{"active", "active", http.MethodGet, http.StatusOK, ""},
+		{"paused", "other", http.MethodGet, http.StatusForbidden, "TENANT_FORBIDDEN"},
+	}
+	for _, tt := range tests {
+		name := tt.token + " " + tt.method
+		req := httptest.NewRequest(tt.method, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		if tt.header != "" {
+			req.Header.Set("X-Tenant-ID", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantCode == "" {
+			continue
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != tt.wantCode {
+			t.Errorf("%s: code = %q, want %q (%v)", name, body.Code, tt.wantCode, err)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package mirror replays sampled copies of proxied requests against shadow upstreams.
+package mirror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/metrics"
+)
+
+// ShadowHeader marks mirrored requests so shadow upstreams can skip side effects
+const ShadowHeader = "X-Shadow-Request"
+
+// Rule mirrors requests whose path starts with Prefix to Target
+type Rule struct {
+	Prefix string
+	// Target is the shadow upstream base URL
+	Target string
+	// SampleRate is the fraction of requests mirrored, from 0 to 1
+	SampleRate float64
+}
+
+// Config holds configuration for the mirror
+type Config struct {
+	// Rules select which routes are mirrored; the longest matching prefix wins
+	Rules []Rule
+	// MaxBodySize is the largest request body mirrored and response body compared (default: 1MB)
+	MaxBodySize int64
+	// Timeout bounds each shadow request (default: 10 seconds)
+	Timeout time.Duration
+	// MaxConcurrent caps in-flight shadow requests; requests beyond it are dropped (default: 64)
+	MaxConcurrent int
+	// Compare enables comparing primary and shadow responses
+	Compare bool
+}
+
+// Request is a copy of a client request taken before it was proxied
+type Request struct {
+	Method     string
+	RequestURI string
+	Header     http.Header
+	Body       []byte
+}
+
+// Response is the primary upstream response as seen by the client
+type Response struct {
+	Status    int
+	Header    http.Header
+	Body      []byte
+	Truncated bool
+}
+
+// Result describes the outcome of a mirrored request
+type Result struct {
+	Rule          Rule
+	Request       *Request
+	Err           error
+	PrimaryStatus int
+	ShadowStatus  int
+	// Compared is false when comparison is disabled or a body was too large
+	Compared bool
+	// Diff describes how the responses differ ("" when they match)
+	Diff string
+}
+
+// Mirror sends copies of requests to shadow upstreams without blocking clients
+type Mirror struct {
+	config Config
+	client *http.Client
+	slots  chan struct{}
+	report func(Result)
+}
+
+// New creates a mirror; report is called with the result of every shadow request
+func New(config Config, report func(Result)) *Mirror {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 64
+	}
+
+	// Longest prefix first
+	rules := append([]Rule(nil), config.Rules...)
+	sort.Slice(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	config.Rules = rules
+
+	return &Mirror{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// Shadow redirects are compared, not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		slots:  make(chan struct{}, config.MaxConcurrent),
+		report: report,
+	}
+}
+
+// MaxBodySize returns the largest body that is mirrored
+func (m *Mirror) MaxBodySize() int64 {
+	return m.config.MaxBodySize
+}
+
+// Compare reports whether primary responses should be captured for comparison
+func (m *Mirror) Compare() bool {
+	return m.config.Compare
+}
+
+// Sample returns the rule for a path if the request is picked for mirroring
+func (m *Mirror) Sample(path string) (Rule, bool) {
+	for _, rule := range m.config.Rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule, rule.SampleRate >= 1 || rand.Float64() < rule.SampleRate
+		}
+	}
+	return Rule{}, false
+}
+
+// Send mirrors a request in the background. primary may be nil when
+// comparison is disabled. It reports false when the request was dropped
+// because too many shadow requests are in flight.
+func (m *Mirror) Send(rule Rule, req *Request, primary *Response) bool {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		metrics.MirrorRequests.WithLabelValues("dropped").Inc()
+		return false
+	}
+
+	go func() {
+		defer func() { <-m.slots }()
+		result := m.send(rule, req, primary)
+		metrics.MirrorRequests.WithLabelValues(result.outcome()).Inc()
+		if m.report != nil {
+			m.report(result)
+		}
+	}()
+	return true
+}
+
+func (m *Mirror) send(rule Rule, req *Request, primary *Response) Result {
+	result := Result{Rule: rule, Request: req}
+	if primary != nil {
+		result.PrimaryStatus = primary.Status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	shadowReq, err := newShadowRequest(ctx, rule.Target, req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	resp, err := m.client.Do(shadowReq)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	result.ShadowStatus = resp.StatusCode
+
+	if !m.config.Compare || primary == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return result
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, m.config.MaxBodySize+1))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	result.Diff, result.Compared = compare(primary, resp.StatusCode, body, int64(len(body)) > m.config.MaxBodySize)
+	return result
+}
+
+// outcome is the metrics label of a result
+func (r Result) outcome() string {
+	switch {
+	case r.Err != nil:
+		return "error"
+	case !r.Compared:
+		return "sent"
+	case r.Diff != "":
+		return "diff"
+	default:
+		return "match"
+	}
+}
+
+// credentialHeaders are never sent to shadow upstreams, which are often less
+// trusted than the primary: they could act as the client or the gateway
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Internal-Token"}
+
+// newShadowRequest rebuilds the client request against the shadow upstream
+func newShadowRequest(ctx context.Context, target string, req *Request) (*http.Request, error) {
+	base, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror target %q: %w", target, err)
+	}
+	ref, err := url.Parse(req.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + ref.Path
+	u.RawQuery = ref.RawQuery
+
+	shadowReq, err := http.NewRequestWithContext(ctx, req.Method, u.String(), bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	shadowReq.Header = req.Header.Clone()
+	for _, name := range []string{"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "Accept-Encoding"} {
+		// Accept-Encoding is dropped so the transport decompresses shadow responses
+		shadowReq.Header.Del(name)
+	}
+	for _, name := range credentialHeaders {
+		shadowReq.Header.Del(name)
+	}
+	shadowReq.Header.Set(ShadowHeader, "true")
+	return shadowReq, nil
+}
+
+// compare describes the difference between the primary and shadow responses
+func compare(primary *Response, status int, body []byte, truncated bool) (string, bool) {
+	if primary.Status != status {
+		return "status " + strconv.Itoa(primary.Status) + " != " + strconv.Itoa(status), true
+	}
+	if primary.Truncated || truncated {
+		// Bodies too large to compare; the status matched
+		return "", false
+	}
+
+	primaryBody := primary.Body
+	if strings.EqualFold(primary.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(bytes.NewReader(primaryBody))
+		if err != nil {
+			return "", false
+		}
+		decoded, err := io.ReadAll(zr)
+		if err != nil {
+			return "", false
+		}
+		primaryBody = decoded
+	}
+
+	if !bytes.Equal(primaryBody, body) {
+		return fmt.Sprintf("body differs (%d bytes != %d bytes)", len(primaryBody), len(body)), true
+	}
+	return "", true
+}
+
+// ParseRules builds rules from "prefix=url" or "prefix=url|rate" pairs
+func ParseRules(pairs map[string]string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(pairs))
+	for prefix, value := range pairs {
+		target, rawRate, hasRate := strings.Cut(value, "|")
+		rate := 1.0
+		if hasRate {
+			parsed, err := strconv.ParseFloat(rawRate, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				return nil, fmt.Errorf("invalid mirror rule %q: sample rate must be between 0 and 1", prefix+"="+value)
+			}
+			rate = parsed
+		}
+		if u, err := url.Parse(target); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid mirror rule %q: expected an absolute URL", prefix+"="+value)
+		}
+		rules = append(rules, Rule{Prefix: prefix, Target: target, SampleRate: rate})
+	}
+	return rules, nil
+}
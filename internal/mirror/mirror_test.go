@@ -0,0 +1,156 @@
+package mirror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// shadowServer records mirrored requests and answers with a fixed response
+func shadowServer(t *testing.T, status int, body string, block chan struct{}) (*httptest.Server, chan *http.Request, chan []byte) {
+	t.Helper()
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if block != nil {
+			<-block
+		}
+		data, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- data
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests, bodies
+}
+
+func waitResult(t *testing.T, results chan Result) Result {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for mirror result")
+		return Result{}
+	}
+}
+
+func TestMirror_SendsCopy(t *testing.T) {
+	srv, requests, bodies := shadowServer(t, http.StatusCreated, `{"id":1}`, nil)
+	results := make(chan Result, 1)
+	m := New(Config{Compare: true}, func(r Result) { results <- r })
+
+	req := &Request{
+		Method:     http.MethodPost,
+		RequestURI: "/api/cms-service/pages?draft=1",
+		Header: http.Header{
+			"X-Tenant-Id":      {"t-1"},
+			"Connection":       {"keep-alive"},
+			"Authorization":    {"Bearer opaque"},
+			"Cookie":           {"session=abc"},
+			"X-Internal-Token": {"internal-jwt"},
+		},
+		Body: []byte(`{"title":"Home"}`),
+	}
+	primary := &Response{Status: http.StatusCreated, Header: http.Header{}, Body: []byte(`{"id":1}`)}
+	if !m.Send(Rule{Target: srv.URL + "/shadow"}, req, primary) {
+		t.Fatal("Expected request to be mirrored")
+	}
+
+	result := waitResult(t, results)
+	if result.Err != nil || !result.Compared || result.Diff != "" {
+		t.Errorf("Expected matching responses, got %+v", result)
+	}
+
+	got := <-requests
+	if got.Method != http.MethodPost || got.URL.Path != "/shadow/api/cms-service/pages" || got.URL.RawQuery != "draft=1" {
+		t.Errorf("Unexpected shadow request %s %s", got.Method, got.URL)
+	}
+	if got.Header.Get(ShadowHeader) != "true" || got.Header.Get("X-Tenant-Id") != "t-1" {
+		t.Errorf("Unexpected shadow headers %v", got.Header)
+	}
+	for _, name := range []string{"Authorization", "Cookie", "X-Internal-Token"} {
+		if got.Header.Get(name) != "" {
+			t.Errorf("Shadow request carries %s", name)
+		}
+	}
+	if body := <-bodies; string(body) != `{"title":"Home"}` {
+		t.Errorf("Unexpected shadow body %q", body)
+	}
+}
+
+func TestMirror_ReportsDiffs(t *testing.T) {
+	srv, _, _ := shadowServer(t, http.StatusOK, "new", nil)
+	results := make(chan Result, 3)
+	m := New(Config{Compare: true}, func(r Result) { results <- r })
+	req := &Request{Method: http.MethodGet, RequestURI: "/", Header: http.Header{}}
+	rule := Rule{Target: srv.URL}
+
+	m.Send(rule, req, &Response{Status: http.StatusNotFound, Header: http.Header{}})
+	if r := waitResult(t, results); r.Diff != "status 404 != 200" {
+		t.Errorf("Expected status diff, got %q", r.Diff)
+	}
+
+	m.Send(rule, req, &Response{Status: http.StatusOK, Header: http.Header{}, Body: []byte("old")})
+	if r := waitResult(t, results); r.Diff == "" || !r.Compared {
+		t.Errorf("Expected body diff, got %+v", r)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("new"))
+	zw.Close()
+	m.Send(rule, req, &Response{Status: http.StatusOK, Header: http.Header{"Content-Encoding": {"gzip"}}, Body: gz.Bytes()})
+	if r := waitResult(t, results); r.Diff != "" || !r.Compared {
+		t.Errorf("Expected gzip primary body to match, got %+v", r)
+	}
+}
+
+func TestMirror_DropsWhenSaturated(t *testing.T) {
+	block := make(chan struct{})
+	srv, _, _ := shadowServer(t, http.StatusOK, "", block)
+	results := make(chan Result, 2)
+	m := New(Config{MaxConcurrent: 1}, func(r Result) { results <- r })
+	req := &Request{Method: http.MethodGet, RequestURI: "/", Header: http.Header{}}
+
+	if !m.Send(Rule{Target: srv.URL}, req, nil) {
+		t.Fatal("Expected first request to be mirrored")
+	}
+	if m.Send(Rule{Target: srv.URL}, req, nil) {
+		t.Error("Expected second request to be dropped")
+	}
+	close(block)
+	waitResult(t, results)
+}
+
+func TestMirror_Sample(t *testing.T) {
+	rules, err := ParseRules(map[string]string{
+		"/api/cms-service":        "http://shadow:8080",
+		"/api/cms-service/drafts": "http://shadow:8080|0",
+	})
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	m := New(Config{Rules: rules}, nil)
+
+	if _, ok := m.Sample("/api/cms-service/pages"); !ok {
+		t.Error("Expected full sample rate to mirror")
+	}
+	if _, ok := m.Sample("/api/cms-service/drafts/1"); ok {
+		t.Error("Expected zero sample rate on the longer prefix to win")
+	}
+	if _, ok := m.Sample("/api/billing"); ok {
+		t.Error("Expected unmatched route not to be mirrored")
+	}
+
+	for _, value := range []string{"shadow:8080", "http://shadow:8080|2"} {
+		if _, err := ParseRules(map[string]string{"/api": value}); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
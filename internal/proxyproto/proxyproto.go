@@ -0,0 +1,205 @@
+// Package proxyproto accepts HAProxy PROXY protocol (v1 and v2) headers on a
+// listener so connections report the client's address instead of the load balancer's.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/cidr"
+)
+
+// v2Signature starts every PROXY protocol v2 header
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v1MaxLength is the longest valid v1 header, including CRLF
+const v1MaxLength = 107
+
+// ErrInvalidHeader is returned when a trusted peer sends a malformed header
+var ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+// Config holds listener settings
+type Config struct {
+	// TrustedNetworks are the load balancers allowed to send PROXY headers;
+	// connections from other addresses are served as-is
+	TrustedNetworks cidr.Networks
+	// HeaderTimeout bounds how long to wait for the header (default 5s)
+	HeaderTimeout time.Duration
+}
+
+// Listener wraps a listener, reading PROXY headers from trusted peers
+type Listener struct {
+	net.Listener
+	config Config
+}
+
+// NewListener wraps inner with PROXY protocol support
+func NewListener(inner net.Listener, config Config) *Listener {
+	if config.HeaderTimeout <= 0 {
+		config.HeaderTimeout = 5 * time.Second
+	}
+	return &Listener{Listener: inner, config: config}
+}
+
+// Accept returns the next connection. The header is read lazily, on the
+// connection's first Read or RemoteAddr, so a slow peer cannot block Accept.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.config.HeaderTimeout}, nil
+}
+
+func (l *Listener) trusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && l.config.TrustedNetworks.Contains(tcpAddr.IP)
+}
+
+// Conn is a connection from a trusted peer that may start with a PROXY header
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	source net.Addr
+	err    error
+}
+
+// Read reads from the connection after the PROXY header
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY header, or the peer's
+// address when the header is absent or describes a local connection
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.source != nil {
+		return c.source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		c.err = err
+		return
+	}
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	first, err := c.reader.Peek(1)
+	if err != nil {
+		// Let the server see the error (or EOF) on its first Read
+		if err != io.EOF {
+			c.err = err
+		}
+		return
+	}
+
+	switch first[0] {
+	case 'P':
+		if prefix, err := c.reader.Peek(6); err == nil && string(prefix) == "PROXY " {
+			c.source, c.err = readV1(c.reader)
+		}
+	case v2Signature[0]:
+		if prefix, err := c.reader.Peek(len(v2Signature)); err == nil && bytes.Equal(prefix, v2Signature) {
+			c.source, c.err = readV2(c.reader)
+		}
+	}
+}
+
+// readV1 parses "PROXY TCP4 <src> <dst> <srcport> <dstport>\r\n"
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, v1MaxLength)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) == v1MaxLength {
+			return nil, fmt.Errorf("%w: v1 header too long", ErrInvalidHeader)
+		}
+	}
+
+	text, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, fmt.Errorf("%w: v1 header not terminated by CRLF", ErrInvalidHeader)
+	}
+	fields := strings.Split(text, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, text)
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, text)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 parses the binary v2 header, skipping any TLVs
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, header[12]>>4)
+	}
+	command := header[12] & 0x0f
+	if command > 1 {
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidHeader, command)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+
+	// LOCAL connections (health checks from the balancer itself) keep the peer address
+	if command == 0 {
+		return nil, nil
+	}
+
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("%w: short IPv4 address block", ErrInvalidHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("%w: short IPv6 address block", ErrInvalidHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// UNSPEC, UDP and unix sockets carry no usable client address
+		return nil, nil
+	}
+}
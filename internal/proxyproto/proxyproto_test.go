@@ -0,0 +1,147 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// accept sends data over a new TCP connection and returns the accepted side
+func accept(t *testing.T, trusted string, data []byte) net.Conn {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { inner.Close() })
+
+	_, network, _ := net.ParseCIDR(trusted)
+	ln := NewListener(inner, Config{TrustedNetworks: []*net.IPNet{network}, HeaderTimeout: time.Second})
+
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readBody(t *testing.T, conn net.Conn, n int) string {
+	t.Helper()
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return string(buf)
+}
+
+func v2Header(command, family byte, addresses []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestListener_V1(t *testing.T) {
+	conn := accept(t, "127.0.0.0/8", []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\nGET / HTTP/1.1\r\n"))
+
+	if got := conn.RemoteAddr().String(); got != "203.0.113.7:51234" {
+		t.Errorf("RemoteAddr() = %q, want %q", got, "203.0.113.7:51234")
+	}
+	if got := readBody(t, conn, 16); got != "GET / HTTP/1.1\r\n" {
+		t.Errorf("Read() = %q", got)
+	}
+}
+
+func TestListener_V1Unknown(t *testing.T) {
+	conn := accept(t, "127.0.0.0/8", []byte("PROXY UNKNOWN\r\nGET / HTTP/1.1\r\n"))
+
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Errorf("RemoteAddr() IP = %q, want peer address", got)
+	}
+	if got := readBody(t, conn, 16); got != "GET / HTTP/1.1\r\n" {
+		t.Errorf("Read() = %q", got)
+	}
+}
+
+func TestListener_V2(t *testing.T) {
+	ipv4 := []byte{198, 51, 100, 9, 10, 0, 0, 1, 0xc8, 0x1c, 0x01, 0xbb}
+	ipv6 := append(append(net.ParseIP("2001:db8::5").To16(), net.ParseIP("2001:db8::1").To16()...), 0x1f, 0x90, 0x01, 0xbb)
+	tlv := []byte{0x04, 0x00, 0x01, 0xff}
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"ipv4", v2Header(1, 0x11, ipv4), "198.51.100.9:51228"},
+		{"ipv4 with TLV", v2Header(1, 0x11, append(append([]byte{}, ipv4...), tlv...)), "198.51.100.9:51228"},
+		{"ipv6", v2Header(1, 0x21, ipv6), "[2001:db8::5]:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := accept(t, "127.0.0.0/8", append(tt.header, "PING"...))
+			if got := conn.RemoteAddr().String(); got != tt.want {
+				t.Errorf("RemoteAddr() = %q, want %q", got, tt.want)
+			}
+			if got := readBody(t, conn, 4); got != "PING" {
+				t.Errorf("Read() = %q", got)
+			}
+		})
+	}
+}
+
+func TestListener_V2Local(t *testing.T) {
+	conn := accept(t, "127.0.0.0/8", append(v2Header(0, 0x00, nil), "PING"...))
+
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Errorf("RemoteAddr() IP = %q, want peer address", got)
+	}
+	if got := readBody(t, conn, 4); got != "PING" {
+		t.Errorf("Read() = %q", got)
+	}
+}
+
+func TestListener_NoHeader(t *testing.T) {
+	conn := accept(t, "127.0.0.0/8", []byte("POST /api HTTP/1.1\r\n"))
+
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Errorf("RemoteAddr() IP = %q, want peer address", got)
+	}
+	if got := readBody(t, conn, 20); got != "POST /api HTTP/1.1\r\n" {
+		t.Errorf("Read() = %q", got)
+	}
+}
+
+func TestListener_UntrustedPeer(t *testing.T) {
+	data := "PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\n"
+	conn := accept(t, "10.0.0.0/8", []byte(data))
+
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Errorf("RemoteAddr() IP = %q, header from untrusted peer must be ignored", got)
+	}
+	if got := readBody(t, conn, len(data)); got != data {
+		t.Errorf("Read() = %q, want header passed through", got)
+	}
+}
+
+func TestListener_InvalidHeader(t *testing.T) {
+	conn := accept(t, "127.0.0.0/8", []byte("PROXY TCP4 not-an-ip 10.0.0.1 1 2\r\nGET / HTTP/1.1\r\n"))
+
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Read() error = %v, want ErrInvalidHeader", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package registry maps upstream service names to URLs, optionally per dedicated pool and region.
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/vhvplatform/go-api-gateway/internal/transform"
+)
+
+// Upstream is one registered upstream of a service
+type Upstream struct {
+	Service string
+	// Pool is the dedicated pool the upstream belongs to ("" = shared pool)
+	Pool string
+	// Region is the data-residency region the upstream serves ("" = unlabelled)
+	Region string
+	URL    string
+}
+
+// ErrNoUpstreamInRegion is returned when a service has no upstream in the region a tenant is bound to
+var ErrNoUpstreamInRegion = errors.New("no upstream in tenant region")
+
+// Registry resolves service names to upstream URLs and path/header
+// transformations. Services that are not registered fall back to the
+// <SERVICE_NAME>_URL environment variable.
+type Registry struct {
+	upstreams map[string][]Upstream
+	routes    map[string]*transform.Route
+	getenv    func(string) string
+}
+
+// New creates a registry from a list of upstreams and per-service routes.
+// Routes must already be compiled.
+func New(upstreams []Upstream, routes map[string]*transform.Route) *Registry {
+	r := &Registry{
+		upstreams: make(map[string][]Upstream),
+		routes:    routes,
+		getenv:    os.Getenv,
+	}
+	for _, u := range upstreams {
+		r.upstreams[u.Service] = append(r.upstreams[u.Service], u)
+	}
+	return r
+}
+
+// Route returns the transformations of a service, or nil to forward requests verbatim
+func (r *Registry) Route(service string) *transform.Route {
+	return r.routes[service]
+}
+
+// URL returns the upstream URL of a service, or "" if the service is unknown.
+// A tenant pinned to a pool uses the pool's upstream when the service has one
+// and the shared upstream otherwise. A tenant bound to a region only ever gets
+// upstreams labelled with that region; when there is none, URL returns
+// ErrNoUpstreamInRegion instead of crossing regions.
+func (r *Registry) URL(service, pool, region string) (string, error) {
+	shared := ""
+	for _, u := range r.upstreams[service] {
+		if u.Region != region {
+			continue
+		}
+		if pool != "" && u.Pool == pool {
+			return u.URL, nil
+		}
+		if u.Pool == "" && shared == "" {
+			shared = u.URL
+		}
+	}
+	if shared != "" {
+		return shared, nil
+	}
+
+	if region != "" {
+		return "", fmt.Errorf("%w: %s has no upstream in region %q", ErrNoUpstreamInRegion, service, region)
+	}
+	return r.getenv(EnvVar(service)), nil
+}
+
+// EnvVar returns the environment variable holding a service's shared upstream URL
+// ("cms-service:v2" reads CMS_SERVICE_V2_URL)
+func EnvVar(service string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ":", "_").Replace(service)) + "_URL"
+}
+
+// ParseUpstreams parses a comma separated list of "service[@pool][#region]=url"
+// entries, e.g. "cms-service#eu=http://cms-eu:8080". Canary versions of a service
+// are registered as "service:version", e.g. "cms-service:v2=http://cms-v2:8080".
+func ParseUpstreams(spec string) ([]Upstream, error) {
+	var upstreams []Upstream
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawURL, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid upstream %q: expected service[@pool][#region]=url", entry)
+		}
+		name, region, _ := strings.Cut(strings.TrimSpace(name), "#")
+		service, pool, _ := strings.Cut(name, "@")
+		if service == "" {
+			return nil, fmt.Errorf("invalid upstream %q: missing service name", entry)
+		}
+
+		rawURL = strings.TrimSpace(rawURL)
+		if u, err := url.Parse(rawURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid upstream %q: expected an absolute URL", entry)
+		}
+
+		upstreams = append(upstreams, Upstream{Service: service, Pool: pool, Region: region, URL: rawURL})
+	}
+	return upstreams, nil
+}
+
+// file is the JSON registry file format
+type file struct {
+	Services map[string]struct {
+		Upstreams []struct {
+			URL    string `json:"url"`
+			Pool   string `json:"pool"`
+			Region string `json:"region"`
+		} `json:"upstreams"`
+		transform.Route
+	} `json:"services"`
+}
+
+// LoadFile reads upstreams and route transformations from a JSON file:
+//
+//	{"services": {"cms-service": {
+//	    "upstreams": [{"url": "http://cms:8080"}, {"url": "http://cms-eu:8080", "region": "eu"}],
+//	    "rewrite": {"strip_prefix": "/api/{service}"},
+//	    "request_headers": {"add": {"X-Tenant-ID": "{tenant_id}"}}}}}
+func LoadFile(path string) ([]Upstream, map[string]*transform.Route, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("invalid registry file %s: %w", path, err)
+	}
+
+	var upstreams []Upstream
+	routes := make(map[string]*transform.Route)
+	for service, config := range f.Services {
+		for _, u := range config.Upstreams {
+			if parsed, err := url.Parse(u.URL); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return nil, nil, fmt.Errorf("invalid upstream %q of %s: expected an absolute URL", u.URL, service)
+			}
+			upstreams = append(upstreams, Upstream{Service: service, Pool: u.Pool, Region: u.Region, URL: u.URL})
+		}
+
+		route := config.Route
+		if err := route.Compile(); err != nil {
+			return nil, nil, fmt.Errorf("invalid route of %s: %w", service, err)
+		}
+		routes[service] = &route
+	}
+	return upstreams, routes, nil
+}
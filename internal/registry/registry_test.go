@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vhvplatform/go-api-gateway/internal/transform"
+)
+
+func TestParseUpstreams(t *testing.T) {
+	upstreams, err := ParseUpstreams("cms-service=http://cms:8080, cms-service@enterprise=http://cms-ent:8080, cms-service@enterprise#eu=http://cms-ent-eu:8080")
+	if err != nil {
+		t.Fatalf("ParseUpstreams() error = %v", err)
+	}
+	want := []Upstream{
+		{Service: "cms-service", URL: "http://cms:8080"},
+		{Service: "cms-service", Pool: "enterprise", URL: "http://cms-ent:8080"},
+		{Service: "cms-service", Pool: "enterprise", Region: "eu", URL: "http://cms-ent-eu:8080"},
+	}
+	if len(upstreams) != len(want) {
+		t.Fatalf("Expected %d upstreams, got %+v", len(want), upstreams)
+	}
+	for i := range want {
+		if upstreams[i] != want[i] {
+			t.Errorf("upstream %d = %+v, want %+v", i, upstreams[i], want[i])
+		}
+	}
+
+	for _, spec := range []string{"cms-service", "@pool=http://x:1", "cms-service=cms:8080"} {
+		if _, err := ParseUpstreams(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestRegistry_URL(t *testing.T) {
+	r := New([]Upstream{
+		{Service: "cms-service", URL: "http://cms:8080"},
+		{Service: "cms-service", Pool: "enterprise", URL: "http://cms-ent:8080"},
+		{Service: "report-service", Pool: "enterprise", URL: "http://report-ent:8080"},
+		{Service: "cms-service", Region: "eu", URL: "http://cms-eu:8080"},
+		{Service: "cms-service", Pool: "enterprise", Region: "eu", URL: "http://cms-ent-eu:8080"},
+	}, nil)
+	r.getenv = func(name string) string {
+		return map[string]string{
+			"BILLING_SERVICE_URL":    "http://billing:8080",
+			"BILLING_SERVICE_V2_URL": "http://billing-v2:8080",
+		}[name]
+	}
+
+	tests := []struct {
+		service string
+		pool    string
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{"cms-service", "", "", "http://cms:8080", false},
+		{"cms-service", "enterprise", "", "http://cms-ent:8080", false},
+		{"cms-service", "other", "", "http://cms:8080", false},
+		{"report-service", "enterprise", "", "http://report-ent:8080", false},
+		{"report-service", "", "", "", false},
+		{"billing-service", "enterprise", "", "http://billing:8080", false},
+		{"billing-service:v2", "", "", "http://billing-v2:8080", false},
+		{"unknown", "", "", "", false},
+		{"cms-service", "", "eu", "http://cms-eu:8080", false},
+		{"cms-service", "enterprise", "eu", "http://cms-ent-eu:8080", false},
+		{"cms-service", "", "us", "", true},
+		{"billing-service", "", "eu", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := r.URL(tt.service, tt.pool, tt.region)
+		if tt.wantErr != errors.Is(err, ErrNoUpstreamInRegion) {
+			t.Errorf("URL(%q, %q, %q) error = %v", tt.service, tt.pool, tt.region, err)
+		}
+		if got != tt.want {
+			t.Errorf("URL(%q, %q, %q) = %q, want %q", tt.service, tt.pool, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	data := `{"services": {
+		"cms-service": {
+			"upstreams": [{"url": "http://cms:8080"}, {"url": "http://cms-eu:8080", "region": "eu"}],
+			"rewrite": {"strip_prefix": "/api/{service}", "replace": [{"pattern": "^/v1/", "replacement": "/"}]},
+			"request_headers": {"add": {"X-Tenant-ID": "{tenant_id}"}}
+		}
+	}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	upstreams, routes, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	r := New(upstreams, routes)
+
+	if got, _ := r.URL("cms-service", "", "eu"); got != "http://cms-eu:8080" {
+		t.Errorf("URL() = %q", got)
+	}
+	route := r.Route("cms-service")
+	if route == nil {
+		t.Fatal("Expected route for cms-service")
+	}
+	if got := route.Path("/api/cms-service/v1/pages", transform.Vars{"service": "cms-service"}); got != "/pages" {
+		t.Errorf("Path() = %q", got)
+	}
+	if r.Route("billing") != nil {
+		t.Error("Expected no route for unregistered service")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(bad, []byte(`{"services": {"a": {"rewrite": {"replace": [{"pattern": "("}]}}}}`), 0o600)
+	if _, _, err := LoadFile(bad); err == nil {
+		t.Error("Expected error for invalid rewrite pattern")
+	}
+}
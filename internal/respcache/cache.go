@@ -0,0 +1,330 @@
+// Package respcache implements a shared HTTP response cache for proxied GET requests.
+package respcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SurrogateKeyHeader lists space-separated tags on an upstream response; it is
+// stripped before the response reaches the client
+const SurrogateKeyHeader = "Surrogate-Key"
+
+// Store is the key/value cache responses are kept in
+type Store interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Config holds configuration for the response cache
+type Config struct {
+	// Rules override caching per path prefix; the longest matching prefix wins
+	Rules []Rule
+	// MaxTTL caps the freshness lifetime of any response (default: 1 hour)
+	MaxTTL time.Duration
+	// StaleTTL is how long expired responses with an ETag or Last-Modified are
+	// kept for conditional revalidation (default: 10 minutes)
+	StaleTTL time.Duration
+	// MaxBodySize is the largest response body that is cached (default: 1MB)
+	MaxBodySize int64
+}
+
+// Entry is a stored response
+type Entry struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Tags       []string    `json:"tags,omitempty"`
+	StoredAt   time.Time   `json:"stored_at"`
+	FreshUntil time.Time   `json:"fresh_until"`
+}
+
+// variants is stored under the primary key and lists the request headers the
+// upstream varies on, so the variant key can be derived before the lookup
+type variants struct {
+	Vary []string `json:"vary,omitempty"`
+}
+
+// purgeMarker records when a tag was last purged
+type purgeMarker struct {
+	At time.Time `json:"at"`
+}
+
+// Cache stores upstream responses keyed by tenant, auth scope, host and URL
+type Cache struct {
+	store  Store
+	config Config
+}
+
+// New creates a new response cache on top of a store
+func New(store Store, config Config) *Cache {
+	if config.MaxTTL <= 0 {
+		config.MaxTTL = time.Hour
+	}
+	if config.StaleTTL <= 0 {
+		config.StaleTTL = 10 * time.Minute
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+
+	// Longest prefix first
+	rules := append([]Rule(nil), config.Rules...)
+	sort.Slice(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	config.Rules = rules
+
+	return &Cache{store: store, config: config}
+}
+
+// MaxBodySize returns the largest response body that is cached
+func (c *Cache) MaxBodySize() int64 {
+	return c.config.MaxBodySize
+}
+
+// Cacheable reports whether a request may be answered from the cache and returns its rule
+func (c *Cache) Cacheable(r *http.Request) (Rule, bool) {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return Rule{}, false
+	}
+	if ParseCacheControl(r.Header.Get("Cache-Control")).Has("no-store") {
+		return Rule{}, false
+	}
+
+	rule := c.rule(r.URL.Path)
+	return rule, !rule.Disabled
+}
+
+// Key returns the primary cache key for a request. Keys are scoped by tenant and
+// by whether the request was authenticated, so responses never cross tenants and
+// anonymous clients are never served responses produced for logged-in users.
+// They are also scoped by the upstream version serving the request ("" = the
+// only one), so clients of a canary and of stable never share responses.
+func (c *Cache) Key(r *http.Request, tenantID, version string, authenticated bool) string {
+	if tenantID == "" {
+		tenantID = "-"
+	}
+	scope := "anon"
+	if authenticated {
+		scope = "auth"
+	}
+	key := "resp:" + tenantID + ":" + scope + ":" + r.Host + r.URL.RequestURI()
+	if version != "" {
+		key += versionSeparator + version
+	}
+	return key
+}
+
+// versionSeparator starts the version part of a key; request URIs never contain it
+const versionSeparator = "#"
+
+// urlKey strips the version from a key. All versions of a URL share the entry
+// listing their Vary fields, so Invalidate drops them at once.
+func urlKey(key string) string {
+	if i := strings.LastIndex(key, versionSeparator); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// Lookup returns the stored response for a request, fresh or stale.
+// Responses carrying a purged tag are treated as missing.
+func (c *Cache) Lookup(ctx context.Context, key string, r *http.Request) (*Entry, bool) {
+	var v variants
+	if err := c.store.Get(ctx, urlKey(key), &v); err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := c.store.Get(ctx, variantKey(key, v.Vary, r.Header), &entry); err != nil {
+		return nil, false
+	}
+
+	for _, tag := range entry.Tags {
+		var marker purgeMarker
+		if err := c.store.Get(ctx, tagKey(tag), &marker); err == nil && !marker.At.Before(entry.StoredAt) {
+			return nil, false
+		}
+	}
+	return &entry, true
+}
+
+// Store saves an upstream response if its headers allow it and reports whether it was stored
+func (c *Cache) Store(ctx context.Context, key string, r *http.Request, rule Rule, authenticated bool, status int, header http.Header, body []byte, tags []string) bool {
+	if int64(len(body)) > c.config.MaxBodySize {
+		return false
+	}
+
+	now := time.Now()
+	fresh, ok := freshness(rule, authenticated, status, header, now)
+	if !ok {
+		return false
+	}
+
+	entry := &Entry{
+		Status:   status,
+		Header:   storedHeader(header),
+		Body:     body,
+		Tags:     tags,
+		StoredAt: now,
+	}
+	return c.save(ctx, key, r, entry, fresh, now)
+}
+
+// Revalidated refreshes a stale entry after the upstream answered 304 Not Modified.
+// Headers from the 304 replace the stored ones, as in RFC 9111 section 4.3.4.
+func (c *Cache) Revalidated(ctx context.Context, key string, r *http.Request, rule Rule, authenticated bool, entry *Entry, header http.Header) *Entry {
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for name, values := range storedHeader(header) {
+		if name == "Content-Length" {
+			continue
+		}
+		updated.Header[name] = values
+	}
+
+	now := time.Now()
+	updated.StoredAt = now
+	updated.FreshUntil = now
+	if fresh, ok := freshness(rule, authenticated, updated.Status, updated.Header, now); ok {
+		_ = c.save(ctx, key, r, &updated, fresh, now)
+	}
+	return &updated
+}
+
+// Invalidate drops the cached responses for a URL in both auth scopes and
+// every version, typically after a successful unsafe request to it
+func (c *Cache) Invalidate(ctx context.Context, r *http.Request, tenantID string) {
+	_ = c.store.Delete(ctx, c.Key(r, tenantID, "", false))
+	_ = c.store.Delete(ctx, c.Key(r, tenantID, "", true))
+}
+
+// PurgeTags invalidates every response that carried one of the tags
+func (c *Cache) PurgeTags(ctx context.Context, tags []string) error {
+	marker := purgeMarker{At: time.Now()}
+	// Markers must outlive every entry stored before them
+	ttl := c.config.MaxTTL + c.config.StaleTTL
+	for _, tag := range tags {
+		if err := c.store.Set(ctx, tagKey(tag), marker, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) save(ctx context.Context, key string, r *http.Request, entry *Entry, fresh time.Duration, now time.Time) bool {
+	fresh = min(fresh, c.config.MaxTTL)
+	entry.FreshUntil = now.Add(fresh)
+
+	ttl := fresh
+	if entry.hasValidators() {
+		ttl += c.config.StaleTTL
+	}
+	if ttl <= 0 {
+		return false
+	}
+
+	vary := varyFields(entry.Header)
+	if err := c.store.Set(ctx, variantKey(key, vary, r.Header), entry, ttl); err != nil {
+		return false
+	}
+	return c.store.Set(ctx, urlKey(key), variants{Vary: vary}, ttl) == nil
+}
+
+func (c *Cache) rule(path string) Rule {
+	for _, rule := range c.config.Rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule
+		}
+	}
+	return Rule{}
+}
+
+// Fresh reports whether the entry can be served without revalidation
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+// Age returns how long ago the entry was stored or revalidated
+func (e *Entry) Age(now time.Time) time.Duration {
+	return max(now.Sub(e.StoredAt), 0)
+}
+
+// SetValidators adds conditional headers so the upstream can answer 304
+func (e *Entry) SetValidators(header http.Header) bool {
+	set := false
+	if etag := e.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+		set = true
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+		set = true
+	}
+	return set
+}
+
+// NotModified evaluates a client's conditional headers against the entry
+func (e *Entry) NotModified(header http.Header) bool {
+	if inm := header.Get("If-None-Match"); inm != "" {
+		etag := e.Header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
+
+func (e *Entry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// weakETag strips the weak prefix; If-None-Match uses weak comparison
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// storedHeader copies the headers worth keeping for a cached response
+func storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, name := range []string{SurrogateKeyHeader, "Set-Cookie", "Age", "X-Cache", "Connection", "Transfer-Encoding"} {
+		stored.Del(name)
+	}
+	return stored
+}
+
+func variantKey(key string, vary []string, header http.Header) string {
+	if len(vary) == 0 {
+		return key + "#"
+	}
+	h := sha256.New()
+	for _, field := range vary {
+		h.Write([]byte(field + ":" + strings.Join(header.Values(field), ",") + "\n"))
+	}
+	return key + "#" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func tagKey(tag string) string {
+	return "resptag:" + tag
+}
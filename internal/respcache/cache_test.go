@@ -0,0 +1,298 @@
+package respcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store without expiry used by tests
+type memoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string, dest interface{}) error {
+	s.mu.Lock()
+	data, ok := s.data[key]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("miss")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.data[key] = data
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.data, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func store(t *testing.T, c *Cache, key string, r *http.Request, header http.Header, body string, tags ...string) {
+	t.Helper()
+	if !c.Store(context.Background(), key, r, Rule{}, false, http.StatusOK, header, []byte(body), tags) {
+		t.Fatal("Expected response to be stored")
+	}
+}
+
+func TestCache_Cacheable(t *testing.T) {
+	c := New(newMemoryStore(), Config{Rules: []Rule{
+		{Prefix: "/api/catalog", TTL: time.Minute},
+		{Prefix: "/api/catalog/private", Disabled: true},
+	}})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		want   bool
+	}{
+		{"get", http.MethodGet, "/api/catalog/items", nil, true},
+		{"post", http.MethodPost, "/api/catalog/items", nil, false},
+		{"range", http.MethodGet, "/api/catalog/items", http.Header{"Range": {"bytes=0-10"}}, false},
+		{"no-store", http.MethodGet, "/api/catalog/items", http.Header{"Cache-Control": {"no-store"}}, false},
+		{"longest rule wins", http.MethodGet, "/api/catalog/private/1", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			if _, got := c.Cacheable(r); got != tt.want {
+				t.Errorf("Cacheable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rule, _ := c.Cacheable(httptest.NewRequest(http.MethodGet, "/api/catalog/items", nil))
+	if rule.TTL != time.Minute {
+		t.Errorf("Expected matching rule, got %+v", rule)
+	}
+}
+
+func TestCache_StoreAndLookup(t *testing.T) {
+	c := New(newMemoryStore(), Config{})
+	ctx := context.Background()
+	r := httptest.NewRequest(http.MethodGet, "http://acme.example.com/page/cms/home?x=1", nil)
+	key := c.Key(r, "t1", "", false)
+
+	if _, ok := c.Lookup(ctx, key, r); ok {
+		t.Fatal("Expected miss before store")
+	}
+
+	store(t, c, key, r, http.Header{
+		"Cache-Control": {"max-age=60"},
+		"Content-Type":  {"text/html"},
+	}, "<h1>home</h1>")
+
+	entry, ok := c.Lookup(ctx, key, r)
+	if !ok {
+		t.Fatal("Expected hit after store")
+	}
+	if string(entry.Body) != "<h1>home</h1>" || entry.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if !entry.Fresh(time.Now()) {
+		t.Error("Expected entry to be fresh")
+	}
+
+	// Other tenants and authenticated requests never see the entry
+	if _, ok := c.Lookup(ctx, c.Key(r, "t2", "", false), r); ok {
+		t.Error("Expected entry to be scoped to its tenant")
+	}
+	if _, ok := c.Lookup(ctx, c.Key(r, "t1", "", true), r); ok {
+		t.Error("Expected entry to be scoped to anonymous requests")
+	}
+
+	c.Invalidate(ctx, r, "t1")
+	if _, ok := c.Lookup(ctx, key, r); ok {
+		t.Error("Expected miss after invalidation")
+	}
+}
+
+func TestCache_Versions(t *testing.T) {
+	c := New(newMemoryStore(), Config{})
+	ctx := context.Background()
+	r := httptest.NewRequest(http.MethodGet, "http://acme.example.com/api/cms/pages", nil)
+	header := http.Header{"Cache-Control": {"max-age=60"}}
+	store(t, c, c.Key(r, "t1", "stable", false), r, header, "stable")
+	store(t, c, c.Key(r, "t1", "v2", false), r, header, "v2")
+
+	for _, version := range []string{"stable", "v2"} {
+		if entry, ok := c.Lookup(ctx, c.Key(r, "t1", version, false), r); !ok || string(entry.Body) != version {
+			t.Errorf("Lookup(%s) = %v, %v; want the %s response", version, entry, ok, version)
+		}
+	}
+
+	// writes through any version invalidate all of them
+	c.Invalidate(ctx, r, "t1")
+	for _, version := range []string{"stable", "v2"} {
+		if _, ok := c.Lookup(ctx, c.Key(r, "t1", version, false), r); ok {
+			t.Errorf("Lookup(%s) hit after invalidation", version)
+		}
+	}
+}
+
+func TestCache_Vary(t *testing.T) {
+	c := New(newMemoryStore(), Config{})
+	ctx := context.Background()
+
+	en := httptest.NewRequest(http.MethodGet, "/api/catalog/items", nil)
+	en.Header.Set("Accept-Language", "en")
+	vi := httptest.NewRequest(http.MethodGet, "/api/catalog/items", nil)
+	vi.Header.Set("Accept-Language", "vi")
+	key := c.Key(en, "t1", "", false)
+
+	header := http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"accept-language"}}
+	store(t, c, key, en, header, "hello")
+
+	if _, ok := c.Lookup(ctx, key, vi); ok {
+		t.Error("Expected miss for a different Vary header value")
+	}
+	store(t, c, key, vi, header, "xin chao")
+
+	entry, ok := c.Lookup(ctx, key, en)
+	if !ok || string(entry.Body) != "hello" {
+		t.Errorf("Expected English variant, got %v", entry)
+	}
+	entry, ok = c.Lookup(ctx, key, vi)
+	if !ok || string(entry.Body) != "xin chao" {
+		t.Errorf("Expected Vietnamese variant, got %v", entry)
+	}
+}
+
+func TestCache_PurgeTags(t *testing.T) {
+	c := New(newMemoryStore(), Config{})
+	ctx := context.Background()
+
+	a := httptest.NewRequest(http.MethodGet, "/api/catalog/items/1", nil)
+	b := httptest.NewRequest(http.MethodGet, "/api/catalog/items/2", nil)
+	header := http.Header{"Cache-Control": {"max-age=60"}}
+	store(t, c, c.Key(a, "t1", "", false), a, header, "1", "product-1", "catalog")
+	store(t, c, c.Key(b, "t1", "", false), b, header, "2", "product-2", "catalog")
+
+	if err := c.PurgeTags(ctx, []string{"product-1"}); err != nil {
+		t.Fatalf("PurgeTags() error = %v", err)
+	}
+	if _, ok := c.Lookup(ctx, c.Key(a, "t1", "", false), a); ok {
+		t.Error("Expected tagged entry to be purged")
+	}
+	if _, ok := c.Lookup(ctx, c.Key(b, "t1", "", false), b); !ok {
+		t.Error("Expected untagged entry to survive")
+	}
+
+	// Responses stored after a purge are served again
+	time.Sleep(time.Millisecond)
+	store(t, c, c.Key(a, "t1", "", false), a, header, "1b", "product-1")
+	if entry, ok := c.Lookup(ctx, c.Key(a, "t1", "", false), a); !ok || string(entry.Body) != "1b" {
+		t.Errorf("Expected fresh entry after purge, got %v, %v", entry, ok)
+	}
+}
+
+func TestCache_StoreLimits(t *testing.T) {
+	c := New(newMemoryStore(), Config{MaxBodySize: 4, MaxTTL: time.Minute})
+	r := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+	key := c.Key(r, "", "", false)
+
+	if c.Store(context.Background(), key, r, Rule{}, false, http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, []byte("too large"), nil) {
+		t.Error("Expected oversized body not to be stored")
+	}
+
+	store(t, c, key, r, http.Header{"Cache-Control": {"max-age=86400"}}, "ok")
+	entry, _ := c.Lookup(context.Background(), key, r)
+	if entry.FreshUntil.After(time.Now().Add(time.Minute)) {
+		t.Errorf("Expected freshness to be capped at MaxTTL, got %v", entry.FreshUntil)
+	}
+
+	// no-cache without validators cannot be revalidated, so it is not stored
+	if c.Store(context.Background(), key, r, Rule{}, false, http.StatusOK, http.Header{"Cache-Control": {"no-cache"}}, []byte("ok"), nil) {
+		t.Error("Expected no-cache response without validators not to be stored")
+	}
+}
+
+func TestCache_Revalidated(t *testing.T) {
+	c := New(newMemoryStore(), Config{})
+	ctx := context.Background()
+	r := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+	key := c.Key(r, "t1", "", false)
+
+	store(t, c, key, r, http.Header{
+		"Cache-Control": {"no-cache"},
+		"Etag":          {`"v1"`},
+		"Content-Type":  {"application/json"},
+	}, `{"a":1}`)
+
+	entry, ok := c.Lookup(ctx, key, r)
+	if !ok || entry.Fresh(time.Now()) {
+		t.Fatalf("Expected stored stale entry, got %v, %v", entry, ok)
+	}
+
+	upstream := http.Header{}
+	if !entry.SetValidators(upstream) || upstream.Get("If-None-Match") != `"v1"` {
+		t.Errorf("Expected If-None-Match to be set, got %v", upstream)
+	}
+
+	updated := c.Revalidated(ctx, key, r, Rule{}, false, entry, http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}})
+	if !updated.Fresh(time.Now()) || string(updated.Body) != `{"a":1}` || updated.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected revalidated entry: %+v", updated)
+	}
+
+	stored, ok := c.Lookup(ctx, key, r)
+	if !ok || !stored.Fresh(time.Now()) {
+		t.Error("Expected revalidated entry to be stored as fresh")
+	}
+}
+
+func TestEntry_NotModified(t *testing.T) {
+	lastModified := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := &Entry{Header: http.Header{
+		"Etag":          {`W/"abc"`},
+		"Last-Modified": {lastModified.Format(http.TimeFormat)},
+	}}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"no conditions", http.Header{}, false},
+		{"matching etag", http.Header{"If-None-Match": {`"x", "abc"`}}, true},
+		{"star", http.Header{"If-None-Match": {"*"}}, true},
+		{"other etag", http.Header{"If-None-Match": {`"x"`}}, false},
+		{"etag takes precedence", http.Header{"If-None-Match": {`"x"`}, "If-Modified-Since": {lastModified.Format(http.TimeFormat)}}, false},
+		{"not modified since", http.Header{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}}, true},
+		{"modified since", http.Header{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entry.NotModified(tt.header); got != tt.want {
+				t.Errorf("NotModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
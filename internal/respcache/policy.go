@@ -0,0 +1,154 @@
+package respcache
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl holds parsed Cache-Control directives (lower-cased names)
+type CacheControl map[string]string
+
+// ParseCacheControl parses a Cache-Control header value
+func ParseCacheControl(header string) CacheControl {
+	cc := make(CacheControl)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return cc
+}
+
+// Has reports whether the directive is present
+func (cc CacheControl) Has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// Seconds returns a delta-seconds directive such as max-age
+func (cc CacheControl) Seconds(directive string) (time.Duration, bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// Rule overrides caching for requests whose path starts with Prefix
+type Rule struct {
+	Prefix string
+	// TTL replaces the freshness lifetime sent by the upstream. The cache key
+	// has no user part, so responses to authenticated requests are still only
+	// stored when the upstream marks them public or s-maxage.
+	TTL time.Duration
+	// Disabled turns caching off for the route
+	Disabled bool
+}
+
+// ParseRules builds rules from "prefix=ttl" or "prefix=off" pairs
+func ParseRules(pairs map[string]string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(pairs))
+	for prefix, value := range pairs {
+		if value == "off" {
+			rules = append(rules, Rule{Prefix: prefix, Disabled: true})
+			continue
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid cache rule %q: expected a positive duration or 'off'", prefix+"="+value)
+		}
+		rules = append(rules, Rule{Prefix: prefix, TTL: ttl})
+	}
+	return rules, nil
+}
+
+// cacheableStatus lists the status codes that may be stored
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// freshness returns how long a response may be served without revalidation.
+// ok is false when the response must not be stored at all.
+func freshness(rule Rule, authenticated bool, status int, header http.Header, now time.Time) (time.Duration, bool) {
+	if !cacheableStatus[status] || header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	for _, field := range varyFields(header) {
+		if field == "*" {
+			return 0, false
+		}
+	}
+
+	cc := ParseCacheControl(header.Get("Cache-Control"))
+	if cc.Has("no-store") || cc.Has("private") {
+		return 0, false
+	}
+
+	// Responses to authenticated requests are only shared when the upstream says so
+	if authenticated && !cc.Has("public") && !cc.Has("s-maxage") {
+		return 0, false
+	}
+
+	if rule.TTL > 0 {
+		return rule.TTL, true
+	}
+
+	if cc.Has("no-cache") {
+		// Stored, but revalidated on every use
+		return 0, true
+	}
+	if ttl, ok := cc.Seconds("s-maxage"); ok {
+		return ttl, true
+	}
+	if ttl, ok := cc.Seconds("max-age"); ok {
+		return ttl, true
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, true
+		}
+		date := now
+		if parsed, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = parsed
+		}
+		return max(expiresAt.Sub(date), 0), true
+	}
+
+	// No explicit freshness: no heuristic caching
+	return 0, false
+}
+
+// varyFields returns the canonical, sorted header names listed in Vary
+func varyFields(header http.Header) []string {
+	var fields []string
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if field != "*" {
+				field = http.CanonicalHeaderKey(field)
+			}
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,94 @@
+package respcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	cc := ParseCacheControl(`public, max-age=60, S-MAXAGE="120", no-transform`)
+
+	if !cc.Has("public") || !cc.Has("no-transform") {
+		t.Errorf("Expected flag directives, got %v", cc)
+	}
+	if ttl, ok := cc.Seconds("max-age"); !ok || ttl != time.Minute {
+		t.Errorf("max-age = %v, %v", ttl, ok)
+	}
+	if ttl, ok := cc.Seconds("s-maxage"); !ok || ttl != 2*time.Minute {
+		t.Errorf("s-maxage = %v, %v", ttl, ok)
+	}
+	if _, ok := cc.Seconds("public"); ok {
+		t.Error("Expected flag directive to have no seconds value")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(map[string]string{"/api/catalog": "5m", "/api/user": "off"})
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+	for _, rule := range rules {
+		switch rule.Prefix {
+		case "/api/catalog":
+			if rule.TTL != 5*time.Minute || rule.Disabled {
+				t.Errorf("Unexpected rule %+v", rule)
+			}
+		case "/api/user":
+			if !rule.Disabled {
+				t.Errorf("Expected rule to be disabled: %+v", rule)
+			}
+		}
+	}
+
+	if _, err := ParseRules(map[string]string{"/api": "soon"}); err == nil {
+		t.Error("Expected error for invalid TTL")
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		rule          Rule
+		authenticated bool
+		status        int
+		header        http.Header
+		wantTTL       time.Duration
+		wantOK        bool
+	}{
+		{"max-age", Rule{}, false, 200, http.Header{"Cache-Control": {"max-age=60"}}, time.Minute, true},
+		{"s-maxage wins", Rule{}, false, 200, http.Header{"Cache-Control": {"max-age=60, s-maxage=10"}}, 10 * time.Second, true},
+		{"no-store", Rule{}, false, 200, http.Header{"Cache-Control": {"no-store, max-age=60"}}, 0, false},
+		{"private", Rule{}, false, 200, http.Header{"Cache-Control": {"private, max-age=60"}}, 0, false},
+		{"no-cache stored for revalidation", Rule{}, false, 200, http.Header{"Cache-Control": {"no-cache"}}, 0, true},
+		{"no explicit freshness", Rule{}, false, 200, http.Header{}, 0, false},
+		{"uncacheable status", Rule{}, false, 500, http.Header{"Cache-Control": {"max-age=60"}}, 0, false},
+		{"set-cookie", Rule{}, false, 200, http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}, 0, false},
+		{"vary star", Rule{}, false, 200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}, 0, false},
+		{"authenticated not public", Rule{}, true, 200, http.Header{"Cache-Control": {"max-age=60"}}, 0, false},
+		{"authenticated public", Rule{}, true, 200, http.Header{"Cache-Control": {"public, max-age=60"}}, time.Minute, true},
+		{"authenticated s-maxage", Rule{}, true, 200, http.Header{"Cache-Control": {"s-maxage=30"}}, 30 * time.Second, true},
+		{"rule overrides", Rule{TTL: 5 * time.Minute}, false, 200, http.Header{}, 5 * time.Minute, true},
+		{"rule keeps authenticated private", Rule{TTL: 5 * time.Minute}, true, 200, http.Header{}, 0, false},
+		{"rule overrides authenticated public", Rule{TTL: 5 * time.Minute}, true, 200, http.Header{"Cache-Control": {"public, max-age=60"}}, 5 * time.Minute, true},
+		{"rule keeps no-store", Rule{TTL: 5 * time.Minute}, false, 200, http.Header{"Cache-Control": {"no-store"}}, 0, false},
+		{"expires", Rule{}, false, 200, http.Header{
+			"Date":    {now.Format(http.TimeFormat)},
+			"Expires": {now.Add(2 * time.Minute).Format(http.TimeFormat)},
+		}, 2 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, ok := freshness(tt.rule, tt.authenticated, tt.status, tt.header, now)
+			if ok != tt.wantOK || (ok && ttl != tt.wantTTL) {
+				t.Errorf("freshness() = %v, %v; want %v, %v", ttl, ok, tt.wantTTL, tt.wantOK)
+			}
+		})
+	}
+}
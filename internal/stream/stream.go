@@ -0,0 +1,56 @@
+// Package stream identifies streaming requests and responses (Server-Sent
+// Events, chunked streams) that are flushed as they arrive and outlive the
+// gateway's request and write timeouts.
+package stream
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// EventStream is the Server-Sent Events media type
+const EventStream = "text/event-stream"
+
+// Routes matches requests expected to stream their responses
+type Routes struct {
+	prefixes []string
+}
+
+// NewRoutes creates a matcher for the given path prefixes
+func NewRoutes(prefixes []string) *Routes {
+	return &Routes{prefixes: prefixes}
+}
+
+// Match reports whether req is on a streaming route. Request headers such as
+// Accept are not considered, since they are the client's choice. A nil
+// Routes matches nothing.
+func (r *Routes) Match(req *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEventStream reports whether a Content-Type is text/event-stream
+func IsEventStream(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == EventStream
+}
+
+// Kind classifies an upstream response: "sse", "chunked", or "" when it has a known length
+func Kind(resp *http.Response) string {
+	switch {
+	case IsEventStream(resp.Header.Get("Content-Type")):
+		return "sse"
+	case resp.ContentLength == -1:
+		return "chunked"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutes_Match(t *testing.T) {
+	routes := NewRoutes([]string{"/api/ai-service/", "/api/events/"})
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		want   bool
+	}{
+		{"event stream accept", "/api/cms-service/updates", "text/event-stream", false},
+		{"event stream on stream route", "/api/events/updates", "text/event-stream", true},
+		{"stream route", "/api/ai-service/completions", "application/json", true},
+		{"other route", "/api/cms-service/pages", "application/json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			if got := routes.Match(req); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var none *Routes
+	req := httptest.NewRequest(http.MethodGet, "/api/ai-service/completions", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if none.Match(req) {
+		t.Error("Nil routes should match nothing")
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		contentType   string
+		contentLength int64
+		want          string
+	}{
+		{"text/event-stream; charset=utf-8", -1, "sse"},
+		{"text/event-stream", 100, "sse"},
+		{"application/x-ndjson", -1, "chunked"},
+		{"application/json", 42, ""},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{"Content-Type": {tt.contentType}}, ContentLength: tt.contentLength}
+		if got := Kind(resp); got != tt.want {
+			t.Errorf("Kind(%q, %d) = %q, want %q", tt.contentType, tt.contentLength, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package subrequest runs requests on behalf of a client request through the
+// gateway's own handler, so they get the same routing, authentication,
+// permission checks and rate limits as if the client had sent them.
+package subrequest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+)
+
+type contextKey struct{}
+
+// inherited are the client request headers sub-requests carry: the caller's
+// credentials, tenant, locale and tracing
+var inherited = []string{
+	"Authorization",
+	"Accept-Language",
+	"User-Agent",
+	"X-Correlation-ID",
+	"X-Tenant-ID",
+	"X-Priority-Class",
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Prefix",
+	"X-Forwarded-Proto",
+	"X-Real-IP",
+}
+
+// clientHeaders identify the client and its priority. Sub-requests only
+// carry the client request's values: the sub-request has the client's
+// RemoteAddr, so the gateway would trust the addresses a caller put in them,
+// giving each sub-request its own rate limit or a trusted priority class.
+var clientHeaders = map[string]bool{
+	"Forwarded":          true,
+	"X-Forwarded-For":    true,
+	"X-Forwarded-Host":   true,
+	"X-Forwarded-Prefix": true,
+	"X-Forwarded-Proto":  true,
+	"X-Priority-Class":   true,
+	"X-Real-Ip":          true,
+}
+
+// Request is a request made on behalf of a client
+type Request struct {
+	Method string
+	// Path is the gateway path, with an optional query ("/api/users/me?fields=id")
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Response is a sub-request's recorded response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Dispatcher runs sub-requests through a handler
+type Dispatcher struct {
+	handler http.Handler
+}
+
+// New creates a Dispatcher serving sub-requests with handler, normally the
+// gateway's server handler, so tenant path prefixes are stripped as they are
+// for client requests
+func New(handler http.Handler) *Dispatcher {
+	return &Dispatcher{handler: handler}
+}
+
+// IsSubRequest reports whether r was made by a Dispatcher. Endpoints that
+// dispatch sub-requests refuse these, so they cannot recurse.
+func IsSubRequest(r *http.Request) bool {
+	return r.Context().Value(contextKey{}) != nil
+}
+
+// Do runs req for the client request parent. Paths resolve as a client's
+// would, and a client request under a tenant path prefix ("/t/acme/batch")
+// passes its tenant on to un-prefixed paths. It returns ctx's error if ctx
+// ends before the handler responds; the handler is left to finish on its own
+// once the request's context is cancelled.
+func (d *Dispatcher) Do(ctx context.Context, parent *http.Request, req Request) (*Response, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := req.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	ctx = context.WithValue(tenant.InheritPath(ctx, parent.Context()), contextKey{}, true)
+	sub, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range inherited {
+		if values := parent.Header.Values(name); len(values) > 0 {
+			sub.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	for name, values := range req.Header {
+		name = http.CanonicalHeaderKey(name)
+		if !clientHeaders[name] {
+			sub.Header[name] = values
+		}
+	}
+	if len(req.Body) > 0 && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	// rate limits and logs see the client
+	sub.Host = parent.Host
+	sub.RemoteAddr = parent.RemoteAddr
+	sub.TLS = parent.TLS
+
+	rec := &recorder{header: http.Header{}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.handler.ServeHTTP(rec, sub)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &Response{Status: status, Header: rec.header, Body: rec.body.Bytes()}, nil
+}
+
+// recorder is the http.ResponseWriter sub-requests are served with
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+// Flush lets streaming handlers run; the response is returned when complete
+func (r *recorder) Flush() {}
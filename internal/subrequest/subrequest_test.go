@@ -0,0 +1,171 @@
+package subrequest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/tenant"
+)
+
+func TestDispatcher_Do(t *testing.T) {
+	var got *http.Request
+	var body string
+	d := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Header().Set("X-Upstream", "users")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	parent := httptest.NewRequest(http.MethodPost, "http://gw.example.com/batch", nil)
+	parent.RemoteAddr = "203.0.113.7:4242"
+	parent.Header.Set("Authorization", "Bearer abc")
+	parent.Header.Set("X-Correlation-ID", "corr-1")
+	parent.Header.Set("Cookie", "session=1")
+
+	resp, err := d.Do(context.Background(), parent, Request{
+		Method: http.MethodPost,
+		Path:   "api/users?notify=true",
+		Header: http.Header{"x-request-source": {"batch"}},
+		Body:   []byte(`{"name":"a"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != http.StatusCreated || string(resp.Body) != `{"id":1}` || resp.Header.Get("X-Upstream") != "users" {
+		t.Errorf("response = %d %q %v", resp.Status, resp.Body, resp.Header)
+	}
+
+	if got.Method != http.MethodPost || got.URL.Path != "/api/users" || got.URL.Query().Get("notify") != "true" {
+		t.Errorf("request = %s %s", got.Method, got.URL)
+	}
+	if body != `{"name":"a"}` || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("body = %q, content type %q", body, got.Header.Get("Content-Type"))
+	}
+	if got.Header.Get("Authorization") != "Bearer abc" || got.Header.Get("X-Correlation-ID") != "corr-1" {
+		t.Errorf("inherited headers missing: %v", got.Header)
+	}
+	if got.Header.Get("Cookie") != "" {
+		t.Errorf("cookie inherited")
+	}
+	if got.Header.Get("X-Request-Source") != "batch" {
+		t.Errorf("sub-request header missing")
+	}
+	if got.RemoteAddr != parent.RemoteAddr || got.Host != "gw.example.com" {
+		t.Errorf("client = %s %s", got.RemoteAddr, got.Host)
+	}
+	if !IsSubRequest(got) || IsSubRequest(parent) {
+		t.Errorf("IsSubRequest wrong")
+	}
+}
+
+func TestDispatcher_ClientHeaders(t *testing.T) {
+	var got *http.Request
+	d := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r }))
+
+	parent := httptest.NewRequest(http.MethodGet, "/batch", nil)
+	parent.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	_, err := d.Do(context.Background(), parent, Request{
+		Path: "/api/users",
+		Header: http.Header{
+			"x-forwarded-for":  {"10.0.0.1"},
+			"X-Real-IP":        {"10.0.0.1"},
+			"Forwarded":        {"for=10.0.0.1"},
+			"X-Priority-Class": {"critical"},
+			"X-Item":           {"1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Header.Values("X-Forwarded-For"); len(v) != 1 || v[0] != "203.0.113.7" {
+		t.Errorf("X-Forwarded-For = %v, want the client's", v)
+	}
+	for _, name := range []string{"X-Real-IP", "Forwarded", "X-Priority-Class"} {
+		if v := got.Header.Get(name); v != "" {
+			t.Errorf("%s = %q, want unset", name, v)
+		}
+	}
+	if got.Header.Get("X-Item") != "1" {
+		t.Errorf("sub-request header missing")
+	}
+}
+
+func TestDispatcher_TenantPathPrefix(t *testing.T) {
+	type seen struct{ path, slug, prefix string }
+	var got seen
+	server := tenant.StripPathPrefix("/t/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = seen{r.URL.Path, tenant.PathSlug(r.Context()), tenant.PathPrefix(r.Context())}
+	}))
+	d := New(server)
+
+	tests := []struct {
+		name   string
+		parent string
+		path   string
+		want   seen
+	}{
+		{"prefixed item", "/batch", "/t/acme/api/users", seen{"/api/users", "acme", "/t/acme"}},
+		{"parent's tenant", "/t/acme/batch", "/api/users", seen{"/api/users", "acme", "/t/acme"}},
+		{"item's own tenant", "/t/acme/batch", "/t/other/api/users", seen{"/api/users", "other", "/t/other"}},
+		{"no tenant path", "/batch", "/api/users", seen{"/api/users", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the client request as the router sees it, prefix stripped
+			var parent *http.Request
+			tenant.StripPathPrefix("/t/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				parent = r
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tt.parent, nil))
+
+			got = seen{}
+			if _, err := d.Do(parent.Context(), parent, Request{Path: tt.path}); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("served %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcher_DefaultStatus(t *testing.T) {
+	d := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	resp, err := d.Do(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), Request{Path: "/x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.Status)
+	}
+}
+
+func TestDispatcher_Timeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	d := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+		w.Write([]byte(strings.Repeat("x", 10)))
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := d.Do(ctx, httptest.NewRequest(http.MethodGet, "/", nil), Request{Path: "/slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("handler context was not cancelled")
+	}
+}
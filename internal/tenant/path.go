@@ -0,0 +1,70 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type pathSlugKey struct{}
+
+type pathPrefixKey struct{}
+
+// StripPathPrefix serves requests for prefix+"<slug>/rest" as "/rest" and
+// records the slug for the Resolver. It wraps the router so the stripped path
+// is what gets routed, e.g. "/t/acme/page/cms/home" routes as "/page/cms/home".
+func StripPathPrefix(prefix string, next http.Handler) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		slug, path, _ := strings.Cut(rest, "/")
+		if slug == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), pathSlugKey{}, slug)
+		ctx = context.WithValue(ctx, pathPrefixKey{}, prefix+slug)
+		r2 := r.WithContext(ctx)
+		r2.URL = cloneURL(r.URL)
+		r2.URL.Path = "/" + path
+		r2.URL.RawPath = ""
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// PathSlug returns the tenant slug recorded by StripPathPrefix
+func PathSlug(ctx context.Context) string {
+	slug, _ := ctx.Value(pathSlugKey{}).(string)
+	return slug
+}
+
+// PathPrefix returns the path prefix removed by StripPathPrefix, e.g. "/t/acme"
+func PathPrefix(ctx context.Context) string {
+	prefix, _ := ctx.Value(pathPrefixKey{}).(string)
+	return prefix
+}
+
+// InheritPath returns ctx carrying the tenant path recorded on parent, so
+// requests made on behalf of a path-prefixed request resolve its tenant
+func InheritPath(ctx, parent context.Context) context.Context {
+	slug := PathSlug(parent)
+	if slug == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, pathSlugKey{}, slug)
+	return context.WithValue(ctx, pathPrefixKey{}, PathPrefix(parent))
+}
+
+func cloneURL(u *url.URL) *url.URL {
+	u2 := *u
+	return &u2
+}
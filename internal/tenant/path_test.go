@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripPathPrefix(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantPath string
+		wantURI  string
+		wantSlug string
+	}{
+		{"/t/acme/page/cms/home?lang=vi", "/page/cms/home", "/page/cms/home?lang=vi", "acme"},
+		{"/t/acme", "/", "/", "acme"},
+		{"/t/", "/t/", "/t/", ""},
+		{"/page/cms/home", "/page/cms/home", "/page/cms/home", ""},
+		{"/tenants/acme", "/tenants/acme", "/tenants/acme", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			var gotPath, gotURI, gotSlug, gotPrefix string
+			handler := StripPathPrefix("t", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotURI = r.RequestURI
+				gotSlug = PathSlug(r.Context())
+				gotPrefix = PathPrefix(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotPath != tt.wantPath || gotURI != tt.wantURI || gotSlug != tt.wantSlug {
+				t.Errorf("Got path %q, uri %q, slug %q; want %q, %q, %q",
+					gotPath, gotURI, gotSlug, tt.wantPath, tt.wantURI, tt.wantSlug)
+			}
+			if tt.wantSlug != "" && gotPrefix != "/t/"+tt.wantSlug {
+				t.Errorf("PathPrefix() = %q, want %q", gotPrefix, "/t/"+tt.wantSlug)
+			}
+		})
+	}
+}
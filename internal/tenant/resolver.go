@@ -0,0 +1,245 @@
+// Package tenant resolves which tenant a request belongs to and loads its routing profile.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/cache"
+)
+
+// ErrNotFound is returned by a Source when no tenant matches
+var ErrNotFound = errors.New("tenant not found")
+
+// Profile is the routing profile of a tenant
+type Profile struct {
+	ID             string `json:"id"`
+	Slug           string `json:"slug"`
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	Plan           string `json:"plan,omitempty"`
+	DefaultService string `json:"default_service,omitempty"`
+	// Services lists the tenant's configured services and whether each is
+	// enabled; tenants without any configured services may use every service
+	Services map[string]bool `json:"services,omitempty"`
+	// Aliases maps service names used by the tenant to registry service names
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Pool pins the tenant to a dedicated upstream pool ("" = shared pool)
+	Pool string `json:"pool,omitempty"`
+	// Region binds the tenant's traffic to upstreams in one region ("" = not bound)
+	Region string `json:"region,omitempty"`
+	// CustomDomains are the tenant's verified custom domains
+	CustomDomains []string          `json:"custom_domains,omitempty"`
+	Theme         map[string]string `json:"theme,omitempty"`
+	Maintenance   *Maintenance      `json:"maintenance,omitempty"`
+}
+
+// ServiceEnabled reports whether the tenant may use a service.
+// Tenants without configured services may use every service.
+func (p *Profile) ServiceEnabled(service string) bool {
+	if len(p.Services) == 0 {
+		return true
+	}
+	return p.Services[service]
+}
+
+// ResolveService applies the tenant's aliases to a requested service name and
+// reports whether the resulting service is enabled for the tenant
+func (p *Profile) ResolveService(name string) (string, bool) {
+	if service, ok := p.Aliases[name]; ok {
+		name = service
+	}
+	return name, p.ServiceEnabled(name)
+}
+
+// Source loads tenant profiles, typically from the tenant service
+type Source interface {
+	// GetTenant looks a tenant up by ID or slug
+	GetTenant(ctx context.Context, idOrSlug string) (*Profile, error)
+	// GetTenantByDomain looks a tenant up by a verified custom domain
+	GetTenantByDomain(ctx context.Context, domain string) (*Profile, error)
+}
+
+// Method describes how a tenant was identified
+type Method string
+
+const (
+	MethodPath         Method = "path"
+	MethodSubdomain    Method = "subdomain"
+	MethodCustomDomain Method = "custom_domain"
+	MethodHeader       Method = "header"
+	// MethodToken is the tenant of an authenticated token, for requests naming none
+	MethodToken Method = "token"
+)
+
+// ResolverConfig holds configuration for a Resolver
+type ResolverConfig struct {
+	// BaseDomains are the platform domains tenants get subdomains of (e.g. "example.com")
+	BaseDomains []string
+	// ReservedSubdomains are subdomains of BaseDomains that never name a tenant
+	ReservedSubdomains []string
+	// CustomDomains enables lookups of hosts outside BaseDomains as custom domains
+	CustomDomains bool
+	// Header names a request header carrying a tenant ID or slug ("" = disabled)
+	Header string
+	// Cache stores resolved profiles; nil disables caching
+	Cache cache.Store
+	// CacheTTL is how long profiles (and unknown hosts) are cached (default: 5 minutes)
+	CacheTTL time.Duration
+}
+
+// Resolver maps requests to tenants
+type Resolver struct {
+	config   ResolverConfig
+	source   Source
+	loader   *cache.Loader
+	reserved map[string]bool
+}
+
+// NewResolver creates a new tenant resolver
+func NewResolver(source Source, config ResolverConfig) *Resolver {
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = 5 * time.Minute
+	}
+	baseDomains := make([]string, 0, len(config.BaseDomains))
+	for _, domain := range config.BaseDomains {
+		baseDomains = append(baseDomains, strings.ToLower(strings.TrimPrefix(domain, ".")))
+	}
+	config.BaseDomains = baseDomains
+
+	r := &Resolver{
+		config:   config,
+		source:   source,
+		reserved: make(map[string]bool),
+	}
+	for _, sub := range config.ReservedSubdomains {
+		r.reserved[strings.ToLower(sub)] = true
+	}
+	if config.Cache != nil {
+		r.loader = cache.NewLoader(config.Cache, cache.LoaderConfig{StaleTTL: time.Minute})
+	}
+	return r
+}
+
+// Resolve identifies the tenant of a request from, in order: a path prefix
+// stripped by StripPathPrefix, the Host (subdomain or custom domain) and the
+// tenant header. It returns a nil profile when the request names no tenant,
+// and ErrNotFound when it names one that does not exist.
+func (r *Resolver) Resolve(req *http.Request) (*Profile, Method, error) {
+	ctx := req.Context()
+	if slug := PathSlug(ctx); slug != "" {
+		profile, err := r.byID(ctx, slug)
+		return profile, MethodPath, err
+	}
+
+	host := hostname(req.Host)
+	if sub, ok := r.subdomain(host); ok {
+		if sub != "" {
+			profile, err := r.byID(ctx, sub)
+			return profile, MethodSubdomain, err
+		}
+	} else if r.config.CustomDomains && host != "" && net.ParseIP(host) == nil && host != "localhost" {
+		profile, err := r.byDomain(ctx, host)
+		if err == nil {
+			return profile, MethodCustomDomain, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, MethodCustomDomain, err
+		}
+		// Unknown hosts fall through to the header
+	}
+
+	if r.config.Header != "" {
+		if value := strings.TrimSpace(req.Header.Get(r.config.Header)); value != "" {
+			profile, err := r.byID(ctx, value)
+			return profile, MethodHeader, err
+		}
+	}
+
+	return nil, "", nil
+}
+
+// Lookup loads a tenant's profile by ID or slug, such as the tenant of an
+// authenticated token. It returns ErrNotFound when no tenant matches.
+func (r *Resolver) Lookup(ctx context.Context, idOrSlug string) (*Profile, error) {
+	return r.byID(ctx, idOrSlug)
+}
+
+// subdomain returns the tenant label of a host under a base domain.
+// ok is true for every host under a base domain, with an empty label for
+// the base domain itself, reserved and nested subdomains.
+func (r *Resolver) subdomain(host string) (string, bool) {
+	for _, base := range r.config.BaseDomains {
+		if host == base {
+			return "", true
+		}
+		label, found := strings.CutSuffix(host, "."+base)
+		if !found {
+			continue
+		}
+		if strings.Contains(label, ".") || r.reserved[label] {
+			return "", true
+		}
+		return label, true
+	}
+	return "", false
+}
+
+// lookup is what gets cached, so unknown tenants are cached as well
+type lookup struct {
+	Profile *Profile `json:"profile"`
+}
+
+func (r *Resolver) byID(ctx context.Context, idOrSlug string) (*Profile, error) {
+	return r.load(ctx, "tenant:id:"+idOrSlug, func(ctx context.Context) (*Profile, error) {
+		return r.source.GetTenant(ctx, idOrSlug)
+	})
+}
+
+func (r *Resolver) byDomain(ctx context.Context, domain string) (*Profile, error) {
+	return r.load(ctx, "tenant:domain:"+domain, func(ctx context.Context) (*Profile, error) {
+		return r.source.GetTenantByDomain(ctx, domain)
+	})
+}
+
+func (r *Resolver) load(ctx context.Context, key string, get func(ctx context.Context) (*Profile, error)) (*Profile, error) {
+	fetch := func(ctx context.Context) (interface{}, error) {
+		profile, err := get(ctx)
+		if errors.Is(err, ErrNotFound) {
+			return lookup{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return lookup{Profile: profile}, nil
+	}
+
+	var result lookup
+	if r.loader == nil {
+		value, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = value.(lookup)
+	} else if err := r.loader.GetOrLoad(ctx, key, &result, r.config.CacheTTL, fetch); err != nil {
+		return nil, err
+	}
+
+	if result.Profile == nil {
+		return nil, ErrNotFound
+	}
+	return result.Profile, nil
+}
+
+// hostname strips the port from a Host header and lower-cases it
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
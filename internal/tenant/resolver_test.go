@@ -0,0 +1,251 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vhvplatform/go-api-gateway/internal/cache"
+)
+
+// fakeSource serves profiles from maps and counts lookups
+type fakeSource struct {
+	mu      sync.Mutex
+	byID    map[string]*Profile
+	domains map[string]*Profile
+	calls   int
+	err     error
+}
+
+func newFakeSource() *fakeSource {
+	acme := &Profile{ID: "t-1", Slug: "acme", Status: "active", DefaultService: "cms-service"}
+	return &fakeSource{
+		byID:    map[string]*Profile{"t-1": acme, "acme": acme},
+		domains: map[string]*Profile{"shop.acme.vn": acme},
+	}
+}
+
+func (s *fakeSource) GetTenant(ctx context.Context, idOrSlug string) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if p, ok := s.byID[idOrSlug]; ok {
+		return p, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *fakeSource) GetTenantByDomain(ctx context.Context, domain string) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if p, ok := s.domains[domain]; ok {
+		return p, nil
+	}
+	return nil, ErrNotFound
+}
+
+// memoryStore is a synchronous cache.Store
+type memoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string, dest interface{}) error {
+	s.mu.Lock()
+	data, ok := s.data[key]
+	s.mu.Unlock()
+	if !ok {
+		return cache.ErrCacheMiss
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.data[key] = data
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.data, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func newTestResolver(source Source, store cache.Store) *Resolver {
+	return NewResolver(source, ResolverConfig{
+		BaseDomains:        []string{"Example.com"},
+		ReservedSubdomains: []string{"www", "api"},
+		CustomDomains:      true,
+		Header:             "X-Tenant-ID",
+		Cache:              store,
+	})
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	resolver := newTestResolver(newFakeSource(), nil)
+
+	tests := []struct {
+		name       string
+		host       string
+		header     string
+		wantID     string
+		wantMethod Method
+		wantErr    error
+	}{
+		{"subdomain", "acme.example.com", "", "t-1", MethodSubdomain, nil},
+		{"subdomain with port", "ACME.example.com:8080", "", "t-1", MethodSubdomain, nil},
+		{"unknown subdomain", "nope.example.com", "", "", MethodSubdomain, ErrNotFound},
+		{"custom domain", "shop.acme.vn", "", "t-1", MethodCustomDomain, nil},
+		{"base domain uses header", "example.com", "acme", "t-1", MethodHeader, nil},
+		{"reserved subdomain uses header", "www.example.com", "t-1", "t-1", MethodHeader, nil},
+		{"nested subdomain", "a.b.example.com", "", "", "", nil},
+		{"unknown host uses header", "gateway.internal", "t-1", "t-1", MethodHeader, nil},
+		{"unknown header", "localhost", "nope", "", MethodHeader, ErrNotFound},
+		{"no tenant", "127.0.0.1:8080", "", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/page/cms/home", nil)
+			req.Host = tt.host
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+
+			profile, method, err := resolver.Resolve(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if method != tt.wantMethod {
+				t.Errorf("Resolve() method = %q, want %q", method, tt.wantMethod)
+			}
+			gotID := ""
+			if profile != nil {
+				gotID = profile.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("Resolve() tenant = %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestResolver_PathPrefixWins(t *testing.T) {
+	resolver := newTestResolver(newFakeSource(), nil)
+
+	var profile *Profile
+	var method Method
+	handler := StripPathPrefix("/t/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profile, method, _ = resolver.Resolve(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/t/acme/page/cms/home", nil)
+	req.Host = "other.example.com"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if profile == nil || profile.ID != "t-1" || method != MethodPath {
+		t.Errorf("Expected tenant from path, got %+v via %q", profile, method)
+	}
+}
+
+func TestResolver_SourceError(t *testing.T) {
+	source := newFakeSource()
+	source.err = errors.New("tenant service unavailable")
+	resolver := newTestResolver(source, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "shop.acme.vn"
+	if _, _, err := resolver.Resolve(req); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected source error, got %v", err)
+	}
+}
+
+func TestResolver_CachesProfilesAndMisses(t *testing.T) {
+	store := newMemoryStore()
+	source := newFakeSource()
+	resolver := NewResolver(source, ResolverConfig{
+		BaseDomains:   []string{"example.com"},
+		CustomDomains: true,
+		Cache:         store,
+		CacheTTL:      time.Minute,
+	})
+
+	resolve := func(host string) (*Profile, error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		profile, _, err := resolver.Resolve(req)
+		return profile, err
+	}
+
+	for i := 0; i < 3; i++ {
+		if p, err := resolve("acme.example.com"); err != nil || p.ID != "t-1" {
+			t.Fatalf("Resolve() = %+v, %v", p, err)
+		}
+		if _, err := resolve("unknown.shop"); err != nil {
+			t.Fatalf("Expected unknown custom domain to resolve to no tenant, got %v", err)
+		}
+	}
+
+	if source.calls != 2 {
+		t.Errorf("Expected 2 source calls with caching, got %d", source.calls)
+	}
+}
+
+func TestProfile_ResolveService(t *testing.T) {
+	open := &Profile{}
+	if service, ok := open.ResolveService("billing"); !ok || service != "billing" {
+		t.Error("Expected tenants without configured services to use every service")
+	}
+
+	limited := &Profile{
+		Services: map[string]bool{"cms-service": true, "ecommerce-service": true, "billing": false},
+		Aliases:  map[string]string{"shop": "ecommerce-service", "pay": "billing"},
+	}
+
+	tests := []struct {
+		name        string
+		wantService string
+		wantOK      bool
+	}{
+		{"cms-service", "cms-service", true},
+		{"shop", "ecommerce-service", true},
+		{"ecommerce-service", "ecommerce-service", true},
+		{"billing", "billing", false},
+		{"pay", "billing", false},
+		{"report-service", "report-service", false},
+	}
+	for _, tt := range tests {
+		service, ok := limited.ResolveService(tt.name)
+		if service != tt.wantService || ok != tt.wantOK {
+			t.Errorf("ResolveService(%q) = %q, %v; want %q, %v", tt.name, service, ok, tt.wantService, tt.wantOK)
+		}
+	}
+
+	allDisabled := &Profile{Services: map[string]bool{"billing": false}}
+	if _, ok := allDisabled.ResolveService("cms-service"); ok {
+		t.Error("Expected tenants with only disabled services to use none")
+	}
+}
@@ -0,0 +1,54 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Tenant lifecycle statuses
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+	StatusReadOnly  = "read_only"
+	StatusDeleted   = "deleted"
+)
+
+var (
+	// ErrSuspended is returned for every request to a suspended tenant
+	ErrSuspended = errors.New("tenant suspended")
+	// ErrReadOnly is returned for unsafe requests to a read-only tenant
+	ErrReadOnly = errors.New("tenant is read-only")
+	// ErrDeleted is returned for every request to a deleted tenant
+	ErrDeleted = errors.New("tenant deleted")
+)
+
+// Maintenance describes why a tenant is unavailable and until when
+type Maintenance struct {
+	Message string    `json:"message,omitempty"`
+	Until   time.Time `json:"until,omitempty"`
+}
+
+// Admit checks a request method against the tenant's lifecycle status.
+// Unknown statuses are treated as active so new states never lock tenants out.
+func (p *Profile) Admit(method string) error {
+	switch p.Status {
+	case StatusSuspended:
+		return ErrSuspended
+	case StatusDeleted:
+		return ErrDeleted
+	case StatusReadOnly:
+		if !safeMethod(method) {
+			return ErrReadOnly
+		}
+	}
+	return nil
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProfile_Admit(t *testing.T) {
+	tests := []struct {
+		status string
+		method string
+		want   error
+	}{
+		{StatusActive, http.MethodPost, nil},
+		{"", http.MethodDelete, nil},
+		{StatusSuspended, http.MethodGet, ErrSuspended},
+		{StatusDeleted, http.MethodGet, ErrDeleted},
+		{StatusReadOnly, http.MethodGet, nil},
+		{StatusReadOnly, http.MethodHead, nil},
+		{StatusReadOnly, http.MethodPatch, ErrReadOnly},
+		{StatusReadOnly, http.MethodPost, ErrReadOnly},
+	}
+
+	for _, tt := range tests {
+		p := &Profile{Status: tt.status}
+		if err := p.Admit(tt.method); err != tt.want {
+			t.Errorf("Admit(%s) with status %q = %v, want %v", tt.method, tt.status, err, tt.want)
+		}
+	}
+}
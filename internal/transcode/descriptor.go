@@ -0,0 +1,217 @@
+package transcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// httpRuleField is the field number of the google.api.http method option
+const httpRuleField = 72295728
+
+// LoadDescriptorSets reads binary FileDescriptorSets, e.g. produced by
+// "protoc --include_imports --descriptor_set_out". Well-known types may be omitted.
+func LoadDescriptorSets(paths []string) (*protoregistry.Files, error) {
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	var order []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+		}
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("invalid descriptor set %s: %w", path, err)
+		}
+		for _, file := range set.File {
+			if _, ok := protos[file.GetName()]; !ok {
+				protos[file.GetName()] = file
+				order = append(order, file.GetName())
+			}
+		}
+	}
+	return buildFiles(protos, order)
+}
+
+// buildFiles registers files after their dependencies, resolving imports that
+// are not in the set (such as well-known types) from the linked-in registry
+func buildFiles(protos map[string]*descriptorpb.FileDescriptorProto, order []string) (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	resolver := &fallbackResolver{files: files}
+	visiting := make(map[string]bool)
+
+	var register func(name string) error
+	register = func(name string) error {
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+		file, ok := protos[name]
+		if !ok {
+			// Left to the fallback resolver
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("import cycle at %s", name)
+		}
+		visiting[name] = true
+		for _, dep := range file.GetDependency() {
+			if err := register(dep); err != nil {
+				return err
+			}
+		}
+		desc, err := protodesc.NewFile(file, resolver)
+		if err != nil {
+			return fmt.Errorf("invalid descriptor %s: %w", name, err)
+		}
+		return files.RegisterFile(desc)
+	}
+
+	for _, name := range order {
+		if err := register(name); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+type fallbackResolver struct {
+	files *protoregistry.Files
+}
+
+func (r *fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// AnnotatedRules returns the google.api.http rules of every method in files.
+// Decoding them requires google/api/http.proto to be part of the descriptor sets.
+func AnnotatedRules(files *protoregistry.Files) ([]Rule, error) {
+	var rules []Rule
+	var rangeErr error
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				rule, ok, err := annotatedRule(files, methods.Get(j))
+				if err != nil {
+					rangeErr = err
+					return false
+				}
+				if ok {
+					rules = append(rules, rule)
+				}
+			}
+		}
+		return true
+	})
+	return rules, rangeErr
+}
+
+func annotatedRule(files *protoregistry.Files, method protoreflect.MethodDescriptor) (Rule, bool, error) {
+	options, err := proto.Marshal(method.Options())
+	if err != nil {
+		return Rule{}, false, err
+	}
+
+	var raw []byte
+	for len(options) > 0 {
+		num, typ, n := protowire.ConsumeTag(options)
+		if n < 0 {
+			return Rule{}, false, protowire.ParseError(n)
+		}
+		options = options[n:]
+		if num == httpRuleField && typ == protowire.BytesType {
+			value, m := protowire.ConsumeBytes(options)
+			if m < 0 {
+				return Rule{}, false, protowire.ParseError(m)
+			}
+			raw = value
+		}
+		m := protowire.ConsumeFieldValue(num, typ, options)
+		if m < 0 {
+			return Rule{}, false, protowire.ParseError(m)
+		}
+		options = options[m:]
+	}
+	if raw == nil {
+		return Rule{}, false, nil
+	}
+
+	desc, err := files.FindDescriptorByName("google.api.HttpRule")
+	if err != nil {
+		return Rule{}, false, fmt.Errorf("%s has a google.api.http option but google/api/http.proto is not in the descriptor sets (use --include_imports)", method.FullName())
+	}
+	msg := dynamicpb.NewMessage(desc.(protoreflect.MessageDescriptor))
+	if err := proto.Unmarshal(raw, msg); err != nil {
+		return Rule{}, false, fmt.Errorf("invalid google.api.http option on %s: %w", method.FullName(), err)
+	}
+
+	rule := ruleFromMessage(msg)
+	rule.Selector = string(method.FullName())
+	return rule, true, nil
+}
+
+// ruleFromMessage converts a dynamic google.api.HttpRule
+func ruleFromMessage(msg protoreflect.Message) Rule {
+	str := func(m protoreflect.Message, name protoreflect.Name) string {
+		if fd := m.Descriptor().Fields().ByName(name); fd != nil {
+			return m.Get(fd).String()
+		}
+		return ""
+	}
+
+	rule := Rule{
+		Get:          str(msg, "get"),
+		Put:          str(msg, "put"),
+		Post:         str(msg, "post"),
+		Delete:       str(msg, "delete"),
+		Patch:        str(msg, "patch"),
+		Body:         str(msg, "body"),
+		ResponseBody: str(msg, "response_body"),
+	}
+	fields := msg.Descriptor().Fields()
+	if fd := fields.ByName("custom"); fd != nil && msg.Has(fd) {
+		custom := msg.Get(fd).Message()
+		rule.Custom = &CustomPattern{Kind: str(custom, "kind"), Path: str(custom, "path")}
+	}
+	if fd := fields.ByName("additional_bindings"); fd != nil {
+		list := msg.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			rule.AdditionalBindings = append(rule.AdditionalBindings, ruleFromMessage(list.Get(i).Message()))
+		}
+	}
+	return rule
+}
+
+// LoadRules reads a mapping file: {"rules": [{"selector": "...", "get": "/v1/..."}]}
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcoding rules: %w", err)
+	}
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid transcoding rules %s: %w", path, err)
+	}
+	return file.Rules, nil
+}
@@ -0,0 +1,173 @@
+package transcode
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// segment kinds of a path template
+const (
+	literal = iota
+	wildcard
+	deepWildcard
+)
+
+type segment struct {
+	kind  int
+	value string
+}
+
+// variable binds the segments [start, end) to a field path; end is -1 for a trailing "**"
+type variable struct {
+	field string
+	start int
+	end   int
+}
+
+// template is a compiled google.api.http path template such as
+// "/v1/{name=projects/*/users/*}:cancel"
+type template struct {
+	raw       string
+	segments  []segment
+	variables []variable
+	verb      string
+}
+
+func parseTemplate(raw string) (*template, error) {
+	if !strings.HasPrefix(raw, "/") {
+		return nil, fmt.Errorf("path template %q must start with /", raw)
+	}
+	t := &template{raw: raw}
+
+	path := raw[1:]
+	// The verb follows the last segment, outside any variable
+	if i := strings.LastIndex(path, ":"); i >= 0 && !strings.Contains(path[i:], "}") {
+		path, t.verb = path[:i], path[i+1:]
+	}
+
+	for path != "" {
+		var part string
+		if strings.HasPrefix(path, "{") {
+			end := strings.Index(path, "}")
+			if end < 0 {
+				return nil, fmt.Errorf("path template %q has an unterminated variable", raw)
+			}
+			part, path = path[1:end], path[end+1:]
+			if err := t.addVariable(part); err != nil {
+				return nil, fmt.Errorf("path template %q: %w", raw, err)
+			}
+		} else {
+			part, path, _ = strings.Cut(path, "/")
+			t.segments = append(t.segments, parseSegment(part))
+			if path == "" && strings.HasSuffix(raw, "/") {
+				return nil, fmt.Errorf("path template %q has a trailing slash", raw)
+			}
+			continue
+		}
+		if path != "" {
+			if path[0] != '/' {
+				return nil, fmt.Errorf("path template %q: variable must end a segment", raw)
+			}
+			path = path[1:]
+		}
+	}
+
+	for i, s := range t.segments {
+		if s.kind == deepWildcard && i != len(t.segments)-1 {
+			return nil, fmt.Errorf("path template %q: ** must be the last segment", raw)
+		}
+	}
+	return t, nil
+}
+
+func parseSegment(part string) segment {
+	switch part {
+	case "*":
+		return segment{kind: wildcard}
+	case "**":
+		return segment{kind: deepWildcard}
+	default:
+		return segment{kind: literal, value: part}
+	}
+}
+
+func (t *template) addVariable(spec string) error {
+	field, pattern, ok := strings.Cut(spec, "=")
+	if field == "" {
+		return fmt.Errorf("variable without a field")
+	}
+	if !ok {
+		pattern = "*"
+	}
+
+	v := variable{field: field, start: len(t.segments)}
+	for _, part := range strings.Split(pattern, "/") {
+		if part == "" {
+			return fmt.Errorf("variable %q has an empty segment", field)
+		}
+		t.segments = append(t.segments, parseSegment(part))
+	}
+	v.end = len(t.segments)
+	if t.segments[v.end-1].kind == deepWildcard {
+		v.end = -1
+	}
+	t.variables = append(t.variables, v)
+	return nil
+}
+
+// match matches an escaped request path, returning the unescaped variable values
+func (t *template) match(escapedPath string) (map[string]string, bool) {
+	path := strings.TrimPrefix(escapedPath, "/")
+	if t.verb != "" {
+		var ok bool
+		if path, ok = strings.CutSuffix(path, ":"+t.verb); !ok {
+			return nil, false
+		}
+	}
+
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	for i, s := range t.segments {
+		switch {
+		case s.kind == deepWildcard:
+			// Matches the remaining segments
+		case i >= len(parts):
+			return nil, false
+		case s.kind == literal && unescape(parts[i]) != s.value:
+			return nil, false
+		}
+	}
+	last := len(t.segments)
+	if last > 0 && t.segments[last-1].kind == deepWildcard {
+		if len(parts) < last-1 {
+			return nil, false
+		}
+	} else if len(parts) != last {
+		return nil, false
+	}
+
+	values := make(map[string]string, len(t.variables))
+	for _, v := range t.variables {
+		end := v.end
+		if end < 0 {
+			end = len(parts)
+		}
+		matched := make([]string, 0, end-v.start)
+		for _, part := range parts[v.start:end] {
+			matched = append(matched, unescape(part))
+		}
+		values[v.field] = strings.Join(matched, "/")
+	}
+	return values, true
+}
+
+func unescape(part string) string {
+	if s, err := url.PathUnescape(part); err == nil {
+		return s
+	}
+	return part
+}
@@ -0,0 +1,330 @@
+// Package transcode maps JSON/HTTP requests to gRPC methods described by
+// protobuf descriptor sets, using google.api.http annotations or rules from a
+// mapping file.
+package transcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ErrInvalidRequest wraps errors caused by the client's path, query or body
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Rule maps a gRPC method to an HTTP method and path template, mirroring
+// google.api.HttpRule. Exactly one of the method fields is set.
+type Rule struct {
+	// Selector is the fully qualified method, e.g. "user.v1.UserService.GetUser"
+	Selector string `json:"selector"`
+	Get      string `json:"get,omitempty"`
+	Put      string `json:"put,omitempty"`
+	Post     string `json:"post,omitempty"`
+	Delete   string `json:"delete,omitempty"`
+	Patch    string `json:"patch,omitempty"`
+	// Custom maps other HTTP methods such as HEAD
+	Custom *CustomPattern `json:"custom,omitempty"`
+	// Body is the request field filled from the JSON body, "*" for the whole message
+	Body string `json:"body,omitempty"`
+	// ResponseBody is the response field returned instead of the whole message
+	ResponseBody       string `json:"response_body,omitempty"`
+	AdditionalBindings []Rule `json:"additional_bindings,omitempty"`
+}
+
+// CustomPattern is an HTTP method outside the standard ones
+type CustomPattern struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+func (r Rule) pattern() (method, path string, err error) {
+	patterns := []struct{ method, path string }{
+		{http.MethodGet, r.Get}, {http.MethodPut, r.Put}, {http.MethodPost, r.Post},
+		{http.MethodDelete, r.Delete}, {http.MethodPatch, r.Patch},
+	}
+	if r.Custom != nil {
+		patterns = append(patterns, struct{ method, path string }{strings.ToUpper(r.Custom.Kind), r.Custom.Path})
+	}
+
+	var found []string
+	for _, p := range patterns {
+		if p.path != "" {
+			method, path = p.method, p.path
+			found = append(found, p.method)
+		}
+	}
+	if len(found) != 1 {
+		return "", "", fmt.Errorf("rule for %s must set exactly one HTTP method, got %v", r.Selector, found)
+	}
+	return method, path, nil
+}
+
+// Binding is one HTTP route to a gRPC method
+type Binding struct {
+	Method       protoreflect.MethodDescriptor
+	httpMethod   string
+	template     *template
+	body         string
+	responseBody protoreflect.FieldDescriptor
+}
+
+// FullMethod returns the gRPC method name, e.g. "/user.v1.UserService/GetUser"
+func (b *Binding) FullMethod() string {
+	return "/" + string(b.Method.Parent().FullName()) + "/" + string(b.Method.Name())
+}
+
+// Service returns the fully qualified service name
+func (b *Binding) Service() string {
+	return string(b.Method.Parent().FullName())
+}
+
+// Transcoder matches HTTP requests to bindings
+type Transcoder struct {
+	bindings []*Binding
+}
+
+// New compiles rules against the methods in files. Rules are typically the
+// annotated rules of the files followed by rules from a mapping file.
+func New(files *protoregistry.Files, rules []Rule) (*Transcoder, error) {
+	t := &Transcoder{}
+	for _, rule := range rules {
+		if err := t.add(files, rule); err != nil {
+			return nil, err
+		}
+	}
+
+	// Literal segments take precedence over variables, e.g. /users/me over /users/{id}
+	sort.SliceStable(t.bindings, func(i, j int) bool {
+		return literals(t.bindings[i].template) > literals(t.bindings[j].template)
+	})
+	return t, nil
+}
+
+func (t *Transcoder) add(files *protoregistry.Files, rule Rule) error {
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(rule.Selector))
+	if err != nil {
+		return fmt.Errorf("unknown method %q: %w", rule.Selector, err)
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return fmt.Errorf("%q is not a method", rule.Selector)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return fmt.Errorf("streaming method %q cannot be transcoded", rule.Selector)
+	}
+
+	httpMethod, path, err := rule.pattern()
+	if err != nil {
+		return err
+	}
+	tmpl, err := parseTemplate(path)
+	if err != nil {
+		return err
+	}
+
+	b := &Binding{Method: method, httpMethod: httpMethod, template: tmpl, body: rule.Body}
+	if rule.Body != "" && rule.Body != "*" && method.Input().Fields().ByName(protoreflect.Name(rule.Body)) == nil {
+		return fmt.Errorf("%s: unknown body field %q", rule.Selector, rule.Body)
+	}
+	for _, v := range tmpl.variables {
+		if _, err := fieldPath(method.Input(), v.field); err != nil {
+			return fmt.Errorf("%s: %w", rule.Selector, err)
+		}
+	}
+	if rule.ResponseBody != "" {
+		if b.responseBody = method.Output().Fields().ByName(protoreflect.Name(rule.ResponseBody)); b.responseBody == nil {
+			return fmt.Errorf("%s: unknown response_body field %q", rule.Selector, rule.ResponseBody)
+		}
+	}
+	t.bindings = append(t.bindings, b)
+
+	for _, additional := range rule.AdditionalBindings {
+		additional.Selector = rule.Selector
+		if err := t.add(files, additional); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func literals(t *template) int {
+	n := 0
+	for _, s := range t.segments {
+		if s.kind == literal {
+			n++
+		}
+	}
+	return n
+}
+
+// Match finds the binding for an HTTP method and escaped path, with the path
+// variable values
+func (t *Transcoder) Match(method, escapedPath string) (*Binding, map[string]string, bool) {
+	for _, b := range t.bindings {
+		if b.httpMethod != method {
+			continue
+		}
+		if vars, ok := b.template.match(escapedPath); ok {
+			return b, vars, true
+		}
+	}
+	return nil, nil, false
+}
+
+// NewRequest builds the gRPC request from the JSON body, the path variables
+// and, for fields not covered by the body, the query parameters
+func (b *Binding) NewRequest(vars map[string]string, query url.Values, body []byte) (proto.Message, error) {
+	msg := dynamicpb.NewMessage(b.Method.Input())
+
+	if b.body != "" && len(strings.TrimSpace(string(body))) > 0 {
+		target := protoreflect.Message(msg)
+		if b.body != "*" {
+			fd := b.Method.Input().Fields().ByName(protoreflect.Name(b.body))
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return nil, setFromJSON(msg, fd, body)
+			}
+			target = msg.Mutable(fd).Message()
+		}
+		if err := protojson.Unmarshal(body, target.Interface()); err != nil {
+			return nil, fmt.Errorf("%w: body: %v", ErrInvalidRequest, err)
+		}
+	}
+
+	for field, value := range vars {
+		if err := setField(msg, field, []string{value}); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.body != "*" {
+		for key, values := range query {
+			if _, bound := vars[key]; bound || (b.body != "" && (key == b.body || strings.HasPrefix(key, b.body+"."))) {
+				continue
+			}
+			// Unknown parameters (e.g. cache busters) are ignored
+			if _, err := fieldPath(b.Method.Input(), key); err != nil {
+				continue
+			}
+			if err := setField(msg, key, values); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return msg, nil
+}
+
+// NewResponse returns an empty response message to decode the gRPC reply into
+func (b *Binding) NewResponse() proto.Message {
+	return dynamicpb.NewMessage(b.Method.Output())
+}
+
+// MarshalResponse encodes the reply as JSON, or only its response_body field
+func (b *Binding) MarshalResponse(resp proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(resp)
+	if err != nil || b.responseBody == nil {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if value, ok := fields[b.responseBody.JSONName()]; ok {
+		return value, nil
+	}
+	// Unpopulated fields are omitted by protojson
+	if b.responseBody.IsList() {
+		return []byte("[]"), nil
+	}
+	if b.responseBody.Message() != nil || b.responseBody.IsMap() {
+		return []byte("{}"), nil
+	}
+	return protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(resp)
+}
+
+// fieldPath resolves a dotted field path such as "user.address.city"
+func fieldPath(desc protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	var fields []protoreflect.FieldDescriptor
+	for i, name := range strings.Split(path, ".") {
+		if desc == nil {
+			return nil, fmt.Errorf("field %q: %q is not a message", path, strings.Join(strings.Split(path, ".")[:i], "."))
+		}
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = desc.Fields().ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("unknown field %q", path)
+		}
+		fields = append(fields, fd)
+		desc = fd.Message()
+		if fd.IsList() || fd.IsMap() {
+			desc = nil
+		}
+	}
+	return fields, nil
+}
+
+// setField sets a scalar (or repeated scalar) field from string values
+func setField(msg protoreflect.Message, path string, values []string) error {
+	fields, err := fieldPath(msg.Descriptor(), path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	for _, fd := range fields[:len(fields)-1] {
+		msg = msg.Mutable(fd).Message()
+	}
+
+	fd := fields[len(fields)-1]
+	if fd.Message() != nil && !fd.IsList() && !fd.IsMap() && isWrapper(fd.Message()) {
+		msg = msg.Mutable(fd).Message()
+		fd = fd.Message().Fields().Get(0)
+	}
+	if fd.IsMap() || fd.Message() != nil {
+		return fmt.Errorf("%w: field %q cannot be set from the path or query", ErrInvalidRequest, path)
+	}
+	if !fd.IsList() && len(values) > 1 {
+		values = values[len(values)-1:]
+	}
+
+	for _, raw := range values {
+		value, err := parseScalar(fd, raw)
+		if err != nil {
+			return fmt.Errorf("%w: field %q: %v", ErrInvalidRequest, path, err)
+		}
+		if fd.IsList() {
+			msg.Mutable(fd).List().Append(value)
+		} else {
+			msg.Set(fd, value)
+		}
+	}
+	return nil
+}
+
+// setFromJSON sets a non-message body field (scalar, list or map) from JSON
+func setFromJSON(msg *dynamicpb.Message, fd protoreflect.FieldDescriptor, body []byte) error {
+	wrapped, err := json.Marshal(map[string]json.RawMessage{fd.JSONName(): body})
+	if err != nil {
+		return fmt.Errorf("%w: body: %v", ErrInvalidRequest, err)
+	}
+	if err := protojson.Unmarshal(wrapped, msg); err != nil {
+		return fmt.Errorf("%w: body: %v", ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// isWrapper reports whether a message is a google.protobuf wrapper type (e.g. StringValue)
+func isWrapper(desc protoreflect.MessageDescriptor) bool {
+	return desc.ParentFile().Package() == "google.protobuf" && strings.HasSuffix(string(desc.Name()), "Value") &&
+		desc.Fields().Len() == 1 && desc.Fields().Get(0).Name() == "value"
+}
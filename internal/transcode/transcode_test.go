@@ -0,0 +1,421 @@
+package transcode
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(protoJSONName(name)),
+		Number:   proto.Int32(number),
+		Label:    label.Enum(),
+		Type:     typ.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func protoJSONName(name string) string {
+	out := []byte{}
+	upper := false
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' {
+			upper = true
+			continue
+		}
+		c := name[i]
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+const (
+	tString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	tBool    = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+	tInt32   = descriptorpb.FieldDescriptorProto_TYPE_INT32
+	tMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	tEnum    = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+)
+
+// httpProto is the part of google/api/http.proto used by the tests
+func httpProto() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("google/api/http.proto"),
+		Package: proto.String("google.api"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("HttpRule"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("selector", 1, tString, "", false),
+					field("get", 2, tString, "", false),
+					field("put", 3, tString, "", false),
+					field("post", 4, tString, "", false),
+					field("delete", 5, tString, "", false),
+					field("patch", 6, tString, "", false),
+					field("body", 7, tString, "", false),
+					field("custom", 8, tMessage, ".google.api.CustomHttpPattern", false),
+					field("additional_bindings", 11, tMessage, ".google.api.HttpRule", true),
+					field("response_body", 12, tString, "", false),
+				},
+			},
+			{
+				Name: proto.String("CustomHttpPattern"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("kind", 1, tString, "", false),
+					field("path", 2, tString, "", false),
+				},
+			},
+		},
+	}
+}
+
+// httpOption encodes a google.api.http method option
+func httpOption(rule []byte) *descriptorpb.MethodOptions {
+	options := &descriptorpb.MethodOptions{}
+	raw := protowire.AppendTag(nil, httpRuleField, protowire.BytesType)
+	options.ProtoReflect().SetUnknown(protowire.AppendBytes(raw, rule))
+	return options
+}
+
+func stringField(num protowire.Number, value string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), value)
+}
+
+func usersProto() *descriptorpb.FileDescriptorProto {
+	getUser := append(stringField(2, "/v1/users/{id}"),
+		protowire.AppendBytes(protowire.AppendTag(nil, 11, protowire.BytesType), stringField(2, "/v1/orgs/{filter.name}/users/{id}"))...)
+	listFiles := append(stringField(2, "/v1/files/{id=**}:list"), stringField(12, "users")...)
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/v1/users.proto"),
+		Package:    proto.String("test.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/api/http.proto", "google/protobuf/wrappers.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATUS_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("STATUS_ACTIVE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Filter"), Field: []*descriptorpb.FieldDescriptorProto{field("name", 1, tString, "", false)}},
+			{Name: proto.String("GetUserRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, tString, "", false),
+				field("verbose", 2, tBool, "", false),
+				field("tags", 3, tString, "", true),
+				field("filter", 4, tMessage, ".test.v1.Filter", false),
+				field("status", 5, tEnum, ".test.v1.Status", false),
+				field("limit", 6, tMessage, ".google.protobuf.Int32Value", false),
+			}},
+			{Name: proto.String("User"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, tString, "", false),
+				field("display_name", 2, tString, "", false),
+				field("age", 3, tInt32, "", false),
+			}},
+			{Name: proto.String("CreateUserRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("parent", 1, tString, "", false),
+				field("user", 2, tMessage, ".test.v1.User", false),
+			}},
+			{Name: proto.String("ListUsersResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("users", 1, tMessage, ".test.v1.User", true),
+				field("next_page_token", 2, tString, "", false),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("UserService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("GetUser"), InputType: proto.String(".test.v1.GetUserRequest"), OutputType: proto.String(".test.v1.User"), Options: httpOption(getUser)},
+				{Name: proto.String("CreateUser"), InputType: proto.String(".test.v1.CreateUserRequest"), OutputType: proto.String(".test.v1.User")},
+				{Name: proto.String("ListFiles"), InputType: proto.String(".test.v1.GetUserRequest"), OutputType: proto.String(".test.v1.ListUsersResponse"), Options: httpOption(listFiles)},
+			},
+		}},
+	}
+}
+
+// newTestTranscoder writes a descriptor set (dependencies listed after
+// dependents, and without wrappers.proto) and loads it with a mapping rule
+func newTestTranscoder(t *testing.T) *Transcoder {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{usersProto(), httpProto()}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "users.pb")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	files, err := LoadDescriptorSets([]string{path})
+	if err != nil {
+		t.Fatalf("LoadDescriptorSets() error = %v", err)
+	}
+	rules, err := AnnotatedRules(files)
+	if err != nil {
+		t.Fatalf("AnnotatedRules() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("AnnotatedRules() = %d rules, want 2", len(rules))
+	}
+
+	rules = append(rules,
+		Rule{Selector: "test.v1.UserService.CreateUser", Post: "/v1/{parent=orgs/*}/users", Body: "user"},
+		Rule{Selector: "test.v1.UserService.GetUser", Get: "/v1/users/me"},
+	)
+	transcoder, err := New(files, rules)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return transcoder
+}
+
+func requestJSON(t *testing.T, msg proto.Message) string {
+	t.Helper()
+	data, err := protojson.MarshalOptions{}.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	// protojson output whitespace is unstable; compare through a compact form
+	var v map[string]interface{}
+	_ = json.Unmarshal(data, &v)
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		want     map[string]string
+	}{
+		{"/v1/users/{id}", "/v1/users/42", map[string]string{"id": "42"}},
+		{"/v1/users/{id}", "/v1/users/a%2Fb", map[string]string{"id": "a/b"}},
+		{"/v1/users/{id}", "/v1/users/42/extra", nil},
+		{"/v1/{name=orgs/*/users/*}", "/v1/orgs/acme/users/7", map[string]string{"name": "orgs/acme/users/7"}},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c.txt", map[string]string{"path": "a/b/c.txt"}},
+		{"/v1/users/{id}:cancel", "/v1/users/42:cancel", map[string]string{"id": "42"}},
+		{"/v1/users/{id}:cancel", "/v1/users/42", nil},
+		{"/v1/*/users", "/v1/anything/users", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := parseTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("parseTemplate(%q) error = %v", tt.template, err)
+		}
+		got, ok := tmpl.match(tt.path)
+		if ok != (tt.want != nil) {
+			t.Errorf("%s match(%q) ok = %v, want %v", tt.template, tt.path, ok, tt.want != nil)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s match(%q)[%s] = %q, want %q", tt.template, tt.path, k, got[k], v)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"v1/users", "/v1/{id", "/v1/**/users", "/v1/users/"} {
+		if _, err := parseTemplate(invalid); err == nil {
+			t.Errorf("parseTemplate(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestTranscoder_GetWithQuery(t *testing.T) {
+	transcoder := newTestTranscoder(t)
+
+	binding, vars, ok := transcoder.Match(http.MethodGet, "/v1/users/42")
+	if !ok {
+		t.Fatal("Expected a binding for GET /v1/users/42")
+	}
+	if binding.FullMethod() != "/test.v1.UserService/GetUser" || binding.Service() != "test.v1.UserService" {
+		t.Errorf("FullMethod() = %q, Service() = %q", binding.FullMethod(), binding.Service())
+	}
+
+	query := url.Values{
+		"verbose": {"true"}, "tags": {"a", "b"}, "filter.name": {"x"},
+		"status": {"STATUS_ACTIVE"}, "limit": {"5"}, "_": {"1700000000"},
+	}
+	req, err := binding.NewRequest(vars, query, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	want := `{"filter":{"name":"x"},"id":"42","limit":5,"status":"STATUS_ACTIVE","tags":["a","b"],"verbose":true}`
+	if got := requestJSON(t, req); got != want {
+		t.Errorf("Request = %s, want %s", got, want)
+	}
+
+	if _, err := binding.NewRequest(vars, url.Values{"verbose": {"maybe"}}, nil); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("NewRequest() with invalid bool error = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestTranscoder_Bindings(t *testing.T) {
+	transcoder := newTestTranscoder(t)
+
+	// Literal path wins over the {id} variable
+	binding, vars, ok := transcoder.Match(http.MethodGet, "/v1/users/me")
+	if !ok || binding.Method.Name() != "GetUser" || len(vars) != 0 {
+		t.Errorf("GET /v1/users/me matched %v with %v", ok, vars)
+	}
+
+	// Additional binding with a nested path variable
+	binding, vars, ok = transcoder.Match(http.MethodGet, "/v1/orgs/acme/users/7")
+	if !ok {
+		t.Fatal("Expected the additional binding to match")
+	}
+	req, err := binding.NewRequest(vars, nil, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	if got := requestJSON(t, req); got != `{"filter":{"name":"acme"},"id":"7"}` {
+		t.Errorf("Request = %s", got)
+	}
+
+	if _, _, ok := transcoder.Match(http.MethodDelete, "/v1/users/42"); ok {
+		t.Error("DELETE should not match a GET binding")
+	}
+}
+
+func TestTranscoder_Body(t *testing.T) {
+	transcoder := newTestTranscoder(t)
+
+	binding, vars, ok := transcoder.Match(http.MethodPost, "/v1/orgs/acme/users")
+	if !ok {
+		t.Fatal("Expected a binding for POST /v1/orgs/acme/users")
+	}
+	req, err := binding.NewRequest(vars, url.Values{"user.age": {"9"}}, []byte(`{"displayName":"Ann","age":30}`))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	// Query parameters never override fields covered by the body
+	if got := requestJSON(t, req); got != `{"parent":"orgs/acme","user":{"age":30,"displayName":"Ann"}}` {
+		t.Errorf("Request = %s", got)
+	}
+
+	if _, err := binding.NewRequest(vars, nil, []byte(`{"unknown":1}`)); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("NewRequest() with unknown body field error = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestTranscoder_ResponseBody(t *testing.T) {
+	transcoder := newTestTranscoder(t)
+
+	binding, vars, ok := transcoder.Match(http.MethodGet, "/v1/files/a/b.txt:list")
+	if !ok || vars["id"] != "a/b.txt" {
+		t.Fatalf("GET /v1/files/a/b.txt:list matched %v with %v", ok, vars)
+	}
+
+	resp := binding.NewResponse()
+	if got, _ := binding.MarshalResponse(resp); string(got) != "[]" {
+		t.Errorf("Empty MarshalResponse() = %s, want []", got)
+	}
+
+	if err := protojson.Unmarshal([]byte(`{"users":[{"id":"1"}],"nextPageToken":"t"}`), resp.(*dynamicpb.Message)); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	got, err := binding.MarshalResponse(resp)
+	if err != nil {
+		t.Fatalf("MarshalResponse() error = %v", err)
+	}
+	var users []map[string]string
+	if err := json.Unmarshal(got, &users); err != nil || len(users) != 1 || users[0]["id"] != "1" {
+		t.Errorf("MarshalResponse() = %s", got)
+	}
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{httpProto(), usersProto()}}
+	data, _ := proto.Marshal(set)
+	path := filepath.Join(t.TempDir(), "users.pb")
+	_ = os.WriteFile(path, data, 0o600)
+	files, err := LoadDescriptorSets([]string{path})
+	if err != nil {
+		t.Fatalf("LoadDescriptorSets() error = %v", err)
+	}
+
+	for _, rule := range []Rule{
+		{Selector: "test.v1.UserService.Missing", Get: "/v1/missing"},
+		{Selector: "test.v1.UserService.GetUser"},
+		{Selector: "test.v1.UserService.GetUser", Get: "/v1/a", Post: "/v1/b"},
+		{Selector: "test.v1.UserService.GetUser", Get: "/v1/users/{nope}"},
+		{Selector: "test.v1.UserService.CreateUser", Post: "/v1/users", Body: "nope"},
+	} {
+		if _, err := New(files, []Rule{rule}); err == nil {
+			t.Errorf("New(%+v) expected error", rule)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	content := `{"rules": [{"selector": "user.v1.UserService.GetUser", "get": "/v1/users/{id}", "additional_bindings": [{"get": "/v1/me"}]}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].Get != "/v1/users/{id}" || len(rules[0].AdditionalBindings) != 1 {
+		t.Errorf("LoadRules() = %+v", rules)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	for code, want := range map[codes.Code]int{
+		codes.OK:               http.StatusOK,
+		codes.NotFound:         http.StatusNotFound,
+		codes.InvalidArgument:  http.StatusBadRequest,
+		codes.Unauthenticated:  http.StatusUnauthorized,
+		codes.PermissionDenied: http.StatusForbidden,
+		codes.Unavailable:      http.StatusServiceUnavailable,
+		codes.DataLoss:         http.StatusInternalServerError,
+	} {
+		if got := HTTPStatus(code); got != want {
+			t.Errorf("HTTPStatus(%s) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for code, want := range map[codes.Code]string{
+		codes.NotFound:           "NOT_FOUND",
+		codes.Internal:           "INTERNAL",
+		codes.FailedPrecondition: "FAILED_PRECONDITION",
+	} {
+		if got := ErrorCode(code); got != want {
+			t.Errorf("ErrorCode(%s) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package transform rewrites upstream request paths and request/response headers.
+package transform
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Vars are the values available to templates as {name}, e.g. {tenant_id}
+type Vars map[string]string
+
+// Expand replaces {name} placeholders with their values; unknown names expand to ""
+func (v Vars) Expand(template string) string {
+	if !strings.Contains(template, "{") {
+		return template
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(template[:start])
+		b.WriteString(v[template[start+1:start+end]])
+		template = template[start+end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// Replace is a regular expression replacement applied to the path
+type Replace struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// Rewrite transforms the path sent upstream: strip prefix, then regex
+// replacements in order, then add prefix. The zero value forwards paths verbatim.
+type Rewrite struct {
+	// StripPrefix is removed from the start of the path; it may use templates such as "/api/{service}"
+	StripPrefix string    `json:"strip_prefix,omitempty"`
+	Replace     []Replace `json:"replace,omitempty"`
+	AddPrefix   string    `json:"add_prefix,omitempty"`
+}
+
+// HeaderRules transform headers: rename, then remove, then add (replacing
+// existing values). Added values may use templates.
+type HeaderRules struct {
+	Rename map[string]string `json:"rename,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+}
+
+// Route holds the transformations of one route
+type Route struct {
+	Rewrite         Rewrite     `json:"rewrite"`
+	RequestHeaders  HeaderRules `json:"request_headers"`
+	ResponseHeaders HeaderRules `json:"response_headers"`
+	// PreserveHost sends the client's Host header upstream instead of the upstream's host
+	PreserveHost bool `json:"preserve_host,omitempty"`
+}
+
+// Compile validates the route's regular expressions; it must be called before use
+func (r *Route) Compile() error {
+	for i := range r.Rewrite.Replace {
+		re, err := regexp.Compile(r.Rewrite.Replace[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid rewrite pattern %q: %w", r.Rewrite.Replace[i].Pattern, err)
+		}
+		r.Rewrite.Replace[i].re = re
+	}
+	return nil
+}
+
+// Path applies the rewrite to a request path
+func (r *Route) Path(path string, vars Vars) string {
+	rw := r.Rewrite
+	if rw.StripPrefix != "" {
+		if prefix := vars.Expand(rw.StripPrefix); strings.HasPrefix(path, prefix) {
+			path = strings.TrimPrefix(path, prefix)
+		}
+	}
+	for _, replace := range rw.Replace {
+		if replace.re != nil {
+			path = replace.re.ReplaceAllString(path, replace.Replacement)
+		}
+	}
+	if rw.AddPrefix != "" {
+		path = strings.TrimSuffix(vars.Expand(rw.AddPrefix), "/") + "/" + strings.TrimPrefix(path, "/")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// StrippedPrefix returns the prefix the rewrite removes from path, or "" if none
+func (r *Route) StrippedPrefix(path string, vars Vars) string {
+	if r.Rewrite.StripPrefix == "" {
+		return ""
+	}
+	if prefix := vars.Expand(r.Rewrite.StripPrefix); strings.HasPrefix(path, prefix) {
+		return strings.TrimSuffix(prefix, "/")
+	}
+	return ""
+}
+
+// Request applies the request header rules
+func (r *Route) Request(header http.Header, vars Vars) {
+	r.RequestHeaders.apply(header, vars)
+}
+
+// Response applies the response header rules
+func (r *Route) Response(header http.Header, vars Vars) {
+	r.ResponseHeaders.apply(header, vars)
+}
+
+func (h HeaderRules) apply(header http.Header, vars Vars) {
+	for from, to := range h.Rename {
+		if values := header.Values(from); len(values) > 0 {
+			header.Del(from)
+			header[http.CanonicalHeaderKey(to)] = values
+		}
+	}
+	for _, name := range h.Remove {
+		header.Del(name)
+	}
+	for name, value := range h.Add {
+		header.Set(name, vars.Expand(value))
+	}
+}
@@ -0,0 +1,113 @@
+package transform
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestVars_Expand(t *testing.T) {
+	vars := Vars{"tenant_id": "t-1", "user_id": "u-1"}
+
+	tests := map[string]string{
+		"plain":                      "plain",
+		"{tenant_id}":                "t-1",
+		"/t/{tenant_id}/u/{user_id}": "/t/t-1/u/u-1",
+		"{unknown}-x":                "-x",
+		"{unclosed":                  "{unclosed",
+	}
+	for template, want := range tests {
+		if got := vars.Expand(template); got != want {
+			t.Errorf("Expand(%q) = %q, want %q", template, got, want)
+		}
+	}
+}
+
+func TestRoute_Path(t *testing.T) {
+	vars := Vars{"service": "cms-service", "tenant_id": "t-1"}
+
+	tests := []struct {
+		name    string
+		rewrite Rewrite
+		path    string
+		want    string
+	}{
+		{"verbatim", Rewrite{}, "/api/cms-service/pages", "/api/cms-service/pages"},
+		{"strip prefix", Rewrite{StripPrefix: "/api/{service}"}, "/api/cms-service/pages", "/pages"},
+		{"strip to root", Rewrite{StripPrefix: "/api/{service}"}, "/api/cms-service", "/"},
+		{"prefix not matched", Rewrite{StripPrefix: "/api/other"}, "/api/cms-service/pages", "/api/cms-service/pages"},
+		{"add prefix", Rewrite{StripPrefix: "/api/{service}", AddPrefix: "/v2/tenants/{tenant_id}/"}, "/api/cms-service/pages", "/v2/tenants/t-1/pages"},
+		{"regex", Rewrite{
+			StripPrefix: "/api/{service}",
+			Replace:     []Replace{{Pattern: `^/pages/(\d+)$`, Replacement: "/documents/$1"}},
+		}, "/api/cms-service/pages/42", "/documents/42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &Route{Rewrite: tt.rewrite}
+			if err := route.Compile(); err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if got := route.Path(tt.path, vars); got != tt.want {
+				t.Errorf("Path(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute_StrippedPrefix(t *testing.T) {
+	vars := Vars{"service": "cms-service"}
+	route := &Route{Rewrite: Rewrite{StripPrefix: "/api/{service}/"}}
+
+	if got := route.StrippedPrefix("/api/cms-service/pages", vars); got != "/api/cms-service" {
+		t.Errorf("StrippedPrefix() = %q, want %q", got, "/api/cms-service")
+	}
+	if got := route.StrippedPrefix("/other/pages", vars); got != "" {
+		t.Errorf("StrippedPrefix() = %q, want empty", got)
+	}
+	if got := (&Route{}).StrippedPrefix("/api/cms-service/pages", vars); got != "" {
+		t.Errorf("StrippedPrefix() without strip_prefix = %q, want empty", got)
+	}
+}
+
+func TestRoute_CompileInvalidPattern(t *testing.T) {
+	route := &Route{Rewrite: Rewrite{Replace: []Replace{{Pattern: "("}}}}
+	if err := route.Compile(); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestRoute_Headers(t *testing.T) {
+	route := &Route{
+		RequestHeaders: HeaderRules{
+			Rename: map[string]string{"X-Old-Name": "x-new-name"},
+			Remove: []string{"Cookie"},
+			Add:    map[string]string{"X-Tenant": "{tenant_id}", "X-Trace": "{correlation_id}"},
+		},
+		ResponseHeaders: HeaderRules{Remove: []string{"Server"}},
+	}
+	vars := Vars{"tenant_id": "t-1", "correlation_id": "c-1"}
+
+	header := http.Header{
+		"X-Old-Name": {"a", "b"},
+		"Cookie":     {"session=1"},
+		"X-Tenant":   {"spoofed"},
+	}
+	route.Request(header, vars)
+
+	if got := header.Values("X-New-Name"); len(got) != 2 || header.Get("X-Old-Name") != "" {
+		t.Errorf("Expected header to be renamed, got %v", header)
+	}
+	if header.Get("Cookie") != "" {
+		t.Error("Expected Cookie to be removed")
+	}
+	if header.Get("X-Tenant") != "t-1" || header.Get("X-Trace") != "c-1" {
+		t.Errorf("Expected templated headers, got %v", header)
+	}
+
+	response := http.Header{"Server": {"nginx"}}
+	route.Response(response, vars)
+	if response.Get("Server") != "" {
+		t.Error("Expected response header to be removed")
+	}
+}
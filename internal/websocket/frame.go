@@ -0,0 +1,103 @@
+package websocket
+
+import "encoding/binary"
+
+// opClose is the first control frame opcode (RFC 6455 section 5.2)
+const opClose = 0x8
+
+// frameParser follows frame boundaries in a byte stream without buffering
+// payloads, reporting the size of each complete data message
+type frameParser struct {
+	header    [14]byte
+	have      int
+	need      int
+	remaining uint64
+	fin       bool
+	opcode    byte
+	message   uint64
+	onMessage func(size int)
+}
+
+// feed consumes the next chunk of the stream
+func (p *frameParser) feed(b []byte) {
+	for len(b) > 0 {
+		if p.remaining > 0 {
+			n := uint64(len(b))
+			if n > p.remaining {
+				n = p.remaining
+			}
+			p.remaining -= n
+			if p.opcode < opClose {
+				p.message += n
+			}
+			b = b[n:]
+			if p.remaining == 0 {
+				p.frameDone()
+			}
+			continue
+		}
+
+		p.header[p.have] = b[0]
+		p.have++
+		b = b[1:]
+
+		if p.have == 2 {
+			p.need = 2
+			switch p.header[1] & 0x7f {
+			case 126:
+				p.need += 2
+			case 127:
+				p.need += 8
+			}
+			if p.header[1]&0x80 != 0 {
+				p.need += 4
+			}
+		}
+		if p.have >= 2 && p.have == p.need {
+			p.parseHeader()
+		}
+	}
+}
+
+func (p *frameParser) parseHeader() {
+	p.fin = p.header[0]&0x80 != 0
+	p.opcode = p.header[0] & 0x0f
+
+	switch length := p.header[1] & 0x7f; length {
+	case 126:
+		p.remaining = uint64(binary.BigEndian.Uint16(p.header[2:4]))
+	case 127:
+		p.remaining = binary.BigEndian.Uint64(p.header[2:10])
+	default:
+		p.remaining = uint64(length)
+	}
+
+	p.have = 0
+	if p.remaining == 0 {
+		p.frameDone()
+	}
+}
+
+func (p *frameParser) frameDone() {
+	// Control frames may be interleaved with the fragments of a data message
+	if p.opcode >= opClose || !p.fin {
+		return
+	}
+	if p.onMessage != nil {
+		p.onMessage(int(p.message))
+	}
+	p.message = 0
+}
+
+// boundary reports whether the stream is between frames
+func (p *frameParser) boundary() bool {
+	return p.have == 0 && p.remaining == 0
+}
+
+// closeFrame builds an unmasked (server to client) close frame
+func closeFrame(code uint16, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	return append([]byte{0x80 | opClose, byte(len(payload))}, payload...)
+}
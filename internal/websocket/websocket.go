@@ -0,0 +1,336 @@
+// Package websocket manages proxied WebSocket connections: per-tenant limits,
+// idle timeouts, message accounting and draining on shutdown.
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManyConnections is returned when a tenant is at its connection limit
+	ErrTooManyConnections = errors.New("too many websocket connections")
+	// ErrDraining is returned once shutdown has started
+	ErrDraining = errors.New("websocket connections are draining")
+)
+
+// goingAway is the close code sent to clients on shutdown
+const closeGoingAway = 1001
+
+// Direction of a message
+type Direction string
+
+const (
+	// Inbound messages flow from the client to the upstream
+	Inbound Direction = "inbound"
+	// Outbound messages flow from the upstream to the client
+	Outbound Direction = "outbound"
+)
+
+// IsUpgrade reports whether r asks to upgrade to the WebSocket protocol
+func IsUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Routes matches the path prefixes where WebSocket upgrades are expected
+type Routes struct {
+	prefixes []string
+}
+
+// NewRoutes creates a matcher for the given path prefixes
+func NewRoutes(prefixes []string) *Routes {
+	return &Routes{prefixes: prefixes}
+}
+
+// Match reports whether req is a WebSocket upgrade on one of the routes.
+// A nil Routes matches nothing.
+func (r *Routes) Match(req *http.Request) bool {
+	if r == nil || !IsUpgrade(req) {
+		return false
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config holds connection limits
+type Config struct {
+	// MaxPerTenant limits concurrent connections per tenant; 0 means unlimited
+	MaxPerTenant int
+	// IdleTimeout closes connections with no traffic in either direction; 0 disables it
+	IdleTimeout time.Duration
+}
+
+// Events receives connection events, e.g. to record metrics. Any field may be nil.
+type Events struct {
+	Opened  func(service string)
+	Closed  func(service string, duration time.Duration)
+	Message func(service string, direction Direction, size int)
+}
+
+// Manager tracks the gateway's WebSocket connections
+type Manager struct {
+	config Config
+	events Events
+
+	mu       sync.Mutex
+	tenants  map[string]int
+	sessions map[*Session]struct{}
+	draining bool
+	done     sync.WaitGroup
+}
+
+// NewManager creates a connection manager
+func NewManager(config Config, events Events) *Manager {
+	return &Manager{
+		config:   config,
+		events:   events,
+		tenants:  make(map[string]int),
+		sessions: make(map[*Session]struct{}),
+	}
+}
+
+// Open reserves a connection slot for tenant before the upgrade is proxied.
+// The session must be closed once the proxied connection ends.
+func (m *Manager) Open(tenant, service string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.draining {
+		return nil, ErrDraining
+	}
+	if m.config.MaxPerTenant > 0 && m.tenants[tenant] >= m.config.MaxPerTenant {
+		return nil, ErrTooManyConnections
+	}
+
+	m.tenants[tenant]++
+	s := &Session{manager: m, tenant: tenant, service: service}
+	m.sessions[s] = struct{}{}
+	m.done.Add(1)
+	return s, nil
+}
+
+// Connections returns the number of open sessions of a tenant
+func (m *Manager) Connections(tenant string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tenants[tenant]
+}
+
+// Shutdown refuses new connections, asks clients to close with a going-away
+// close frame, and waits for connections to end. Connections still open when
+// ctx is done are closed.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.draining = true
+	sessions := make([]*Session, 0, len(m.sessions))
+	for s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.goAway()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.done.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, s := range sessions {
+			s.forceClose()
+		}
+		return ctx.Err()
+	}
+}
+
+// Session is one proxied WebSocket connection
+type Session struct {
+	manager *Manager
+	tenant  string
+	service string
+
+	mu       sync.Mutex
+	conn     *conn
+	opened   time.Time
+	draining bool
+}
+
+// Attach wraps the hijacked client connection. It clears the server's read
+// and write deadlines, which would otherwise end long-lived connections.
+func (s *Session) Attach(c net.Conn) net.Conn {
+	_ = c.SetDeadline(time.Time{})
+
+	m := s.manager
+	wrapped := &conn{Conn: c, session: s}
+	wrapped.in.onMessage = func(size int) { s.message(Inbound, size) }
+	wrapped.out.onMessage = func(size int) { s.message(Outbound, size) }
+	if m.config.IdleTimeout > 0 {
+		wrapped.idle = time.AfterFunc(m.config.IdleTimeout, func() { c.Close() })
+	}
+
+	s.mu.Lock()
+	s.conn = wrapped
+	s.opened = time.Now()
+	draining := s.draining
+	s.mu.Unlock()
+
+	if m.events.Opened != nil {
+		m.events.Opened(s.service)
+	}
+	if draining {
+		wrapped.goAway()
+	}
+	return wrapped
+}
+
+// Close releases the session's slot and closes its connection
+func (s *Session) Close() {
+	m := s.manager
+	m.mu.Lock()
+	if _, ok := m.sessions[s]; !ok {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.sessions, s)
+	if m.tenants[s.tenant]--; m.tenants[s.tenant] <= 0 {
+		delete(m.tenants, s.tenant)
+	}
+	m.mu.Unlock()
+
+	s.mu.Lock()
+	c, opened := s.conn, s.opened
+	s.mu.Unlock()
+
+	if c != nil {
+		if c.idle != nil {
+			c.idle.Stop()
+		}
+		c.Close()
+		if m.events.Closed != nil {
+			m.events.Closed(s.service, time.Since(opened))
+		}
+	}
+	m.done.Done()
+}
+
+func (s *Session) message(direction Direction, size int) {
+	if s.manager.events.Message != nil {
+		s.manager.events.Message(s.service, direction, size)
+	}
+}
+
+// goAway sends a going-away close frame, or marks the session so Attach sends one
+func (s *Session) goAway() {
+	s.mu.Lock()
+	c := s.conn
+	s.draining = true
+	s.mu.Unlock()
+
+	if c != nil {
+		c.goAway()
+	}
+}
+
+func (s *Session) forceClose() {
+	s.mu.Lock()
+	c := s.conn
+	s.mu.Unlock()
+
+	if c != nil {
+		c.Close()
+	}
+}
+
+// conn is the client side of a proxied connection. Reads carry client frames,
+// writes carry upstream frames.
+type conn struct {
+	net.Conn
+	session *Session
+	idle    *time.Timer
+	in      frameParser
+
+	writeMu      sync.Mutex
+	out          frameParser
+	closePending bool
+	closeSent    bool
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+		c.in.feed(b[:n])
+	}
+	return n, err
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	// No data may follow our close frame; the upstream's remaining output is dropped
+	if c.closeSent {
+		return len(b), nil
+	}
+
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.touch()
+		c.out.feed(b[:n])
+	}
+	if err == nil && c.closePending && c.out.boundary() {
+		c.writeCloseLocked()
+	}
+	return n, err
+}
+
+// goAway sends a close frame as soon as no upstream frame is partially written
+func (c *conn) goAway() {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return
+	}
+	if c.out.boundary() {
+		c.writeCloseLocked()
+		return
+	}
+	c.closePending = true
+}
+
+func (c *conn) writeCloseLocked() {
+	c.closeSent = true
+	_, _ = c.Conn.Write(closeFrame(closeGoingAway, "server shutting down"))
+}
+
+func (c *conn) touch() {
+	if c.idle != nil {
+		c.idle.Reset(c.session.manager.config.IdleTimeout)
+	}
+}
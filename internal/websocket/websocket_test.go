@@ -0,0 +1,291 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// frame builds a frame; client frames are masked
+func frame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	out := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		out = append(out, maskBit|byte(n))
+	case n <= 0xffff:
+		out = append(out, maskBit|126, byte(n>>8), byte(n))
+	default:
+		out = append(out, maskBit|127, 0, 0, 0, 0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	if masked {
+		out = append(out, 1, 2, 3, 4)
+	}
+	return append(out, payload...)
+}
+
+func TestIsUpgrade(t *testing.T) {
+	tests := []struct {
+		connection, upgrade string
+		want                bool
+	}{
+		{"Upgrade", "websocket", true},
+		{"keep-alive, upgrade", "WebSocket", true},
+		{"keep-alive", "websocket", false},
+		{"Upgrade", "h2c", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/chat/ws", nil)
+		req.Header.Set("Connection", tt.connection)
+		req.Header.Set("Upgrade", tt.upgrade)
+		if got := IsUpgrade(req); got != tt.want {
+			t.Errorf("IsUpgrade(%q, %q) = %v, want %v", tt.connection, tt.upgrade, got, tt.want)
+		}
+	}
+}
+
+func TestRoutes_Match(t *testing.T) {
+	routes := NewRoutes([]string{"/api/chat/"})
+
+	tests := []struct {
+		name    string
+		path    string
+		upgrade bool
+		want    bool
+	}{
+		{"upgrade on route", "/api/chat/ws", true, true},
+		{"upgrade elsewhere", "/api/user/ws", true, false},
+		{"plain request on route", "/api/chat/ws", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			if got := routes.Match(req); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var none *Routes
+	req := httptest.NewRequest(http.MethodGet, "/api/chat/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if none.Match(req) {
+		t.Error("Nil routes should match nothing")
+	}
+}
+
+func TestFrameParser(t *testing.T) {
+	var sizes []int
+	p := frameParser{onMessage: func(size int) { sizes = append(sizes, size) }}
+
+	var stream []byte
+	stream = append(stream, frame(true, 0x1, []byte("hello"), true)...)
+	stream = append(stream, frame(false, 0x2, make([]byte, 300), false)...)
+	stream = append(stream, frame(true, 0x9, []byte("ping"), false)...) // control frame between fragments
+	stream = append(stream, frame(true, 0x0, make([]byte, 70000), false)...)
+	stream = append(stream, frame(true, 0x1, nil, false)...)
+
+	// Feed in awkward chunk sizes so headers split across reads
+	for len(stream) > 0 {
+		n := 3
+		if n > len(stream) {
+			n = len(stream)
+		}
+		p.feed(stream[:n])
+		stream = stream[n:]
+		if len(stream) == 0 && !p.boundary() {
+			t.Error("Expected a frame boundary at the end of the stream")
+		}
+	}
+
+	want := []int{5, 70300, 0}
+	if len(sizes) != len(want) {
+		t.Fatalf("Got messages %v, want %v", sizes, want)
+	}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Errorf("Message %d size = %d, want %d", i, sizes[i], want[i])
+		}
+	}
+}
+
+func TestManager_TenantLimit(t *testing.T) {
+	m := NewManager(Config{MaxPerTenant: 2}, Events{})
+
+	first, err := m.Open("tenant-a", "chat")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := m.Open("tenant-a", "chat"); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := m.Open("tenant-a", "chat"); !errors.Is(err, ErrTooManyConnections) {
+		t.Errorf("Open() error = %v, want ErrTooManyConnections", err)
+	}
+	if _, err := m.Open("tenant-b", "chat"); err != nil {
+		t.Errorf("Other tenant should not be limited, got %v", err)
+	}
+
+	first.Close()
+	first.Close() // idempotent
+	if got := m.Connections("tenant-a"); got != 1 {
+		t.Errorf("Connections() = %d, want 1", got)
+	}
+	if _, err := m.Open("tenant-a", "chat"); err != nil {
+		t.Errorf("Open() after Close error = %v", err)
+	}
+}
+
+func TestSession_Messages(t *testing.T) {
+	var mu sync.Mutex
+	counts := map[Direction]int{}
+	var opened, closed int
+	m := NewManager(Config{}, Events{
+		Opened: func(string) { opened++ },
+		Closed: func(string, time.Duration) { closed++ },
+		Message: func(service string, direction Direction, size int) {
+			mu.Lock()
+			counts[direction] += size
+			mu.Unlock()
+		},
+	})
+
+	server, client := net.Pipe()
+	s, _ := m.Open("tenant-a", "chat")
+	wrapped := s.Attach(server)
+
+	go client.Write(frame(true, 0x1, []byte("hi"), true))
+	buf := make([]byte, 64)
+	if _, err := io.ReadAtLeast(wrapped, buf, 8); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	go io.Copy(io.Discard, client)
+	if _, err := wrapped.Write(frame(true, 0x1, []byte("welcome"), false)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	s.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if counts[Inbound] != 2 || counts[Outbound] != 7 {
+		t.Errorf("Message bytes = %v, want inbound 2 and outbound 7", counts)
+	}
+	if opened != 1 || closed != 1 {
+		t.Errorf("Opened/Closed events = %d/%d, want 1/1", opened, closed)
+	}
+}
+
+func TestSession_IdleTimeout(t *testing.T) {
+	m := NewManager(Config{IdleTimeout: 50 * time.Millisecond}, Events{})
+	server, client := net.Pipe()
+	defer client.Close()
+
+	s, _ := m.Open("tenant-a", "chat")
+	defer s.Close()
+	wrapped := s.Attach(server)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := wrapped.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected read error after idle timeout")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Idle connection was not closed")
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	m := NewManager(Config{}, Events{})
+	server, client := net.Pipe()
+
+	s, _ := m.Open("tenant-a", "chat")
+	wrapped := s.Attach(server)
+
+	// An upstream frame is half written when shutdown starts
+	partial := frame(true, 0x1, []byte("partial"), false)
+	go wrapped.Write(partial[:4])
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		shutdownDone <- m.Shutdown(ctx)
+	}()
+
+	// Wait for shutdown to start refusing connections
+	for i := 0; ; i++ {
+		extra, err := m.Open("tenant-b", "chat")
+		if errors.Is(err, ErrDraining) {
+			break
+		}
+		extra.Close()
+		if i == 50 {
+			t.Fatal("Open() kept succeeding during shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The close frame follows the rest of the frame in progress
+	go wrapped.Write(partial[4:])
+	want := append(append([]byte{}, partial[4:]...), closeFrame(closeGoingAway, "server shutting down")...)
+	got = make([]byte, len(want))
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Got %x, want %x", got, want)
+	}
+
+	s.Close()
+	if err := <-shutdownDone; err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestManager_ShutdownDeadline(t *testing.T) {
+	m := NewManager(Config{}, Events{})
+	server, client := net.Pipe()
+	go io.Copy(io.Discard, client)
+
+	s, _ := m.Open("tenant-a", "chat")
+	wrapped := s.Attach(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want DeadlineExceeded", err)
+	}
+	if _, err := wrapped.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected connection to be closed after the shutdown deadline")
+	}
+	s.Close()
+}